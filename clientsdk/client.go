@@ -0,0 +1,284 @@
+// client.go - HTTP client for the TaxiScheduler API
+// Typed calls for the endpoints of the server's HTTP API, with retries and
+// exponential backoff for failures that are safe to repeat
+
+// Package clientsdk lets Go programs use a TaxiScheduler server over its HTTP API
+// without building requests by hand:
+//
+//	admin := clientsdk.New(clientsdk.Config{BaseURL: "http://localhost:8080", Token: adminToken})
+//	taxiID, err := admin.RegisterTaxi(ctx, clientsdk.Location{X: 3, Y: 4}, clientsdk.ChildSeat)
+//
+//	credentials, err := admin.RegisterClient(ctx, "Ana Lima")
+//	rider := admin.WithToken(credentials.Token)
+//	updates, err := rider.StreamRideUpdates(ctx)
+//	rideID, err := rider.RequestRide(ctx, clientsdk.RideOrder{Start: clientsdk.Location{X: 1, Y: 2}, End: clientsdk.Location{X: 40, Y: 5}})
+//	for update := range updates {
+//		fmt.Println(update.RideID, update.Type)
+//	}
+//
+// The package only depends on the standard library.
+package clientsdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Retry defaults, used for zero Config fields.
+const (
+	defaultMaxRetries = 3                      // Retries after the first attempt
+	defaultBackoff    = 200 * time.Millisecond // Wait before the first retry, doubled after every failure
+	maxBackoff        = 5 * time.Second        // Longest wait between two attempts
+	defaultTimeout    = 10 * time.Second       // Per attempt, for the default HTTP client
+)
+
+// apiPrefix is the version of the HTTP API the client speaks, so its types keep
+// matching the server's JSON as later versions are added.
+const apiPrefix = "/v1"
+
+// ErrUnauthorized matches (with errors.Is) an APIError for a missing, unknown or revoked token.
+var ErrUnauthorized = errors.New("unknown client or invalid token")
+
+// ErrForbidden matches an APIError for a token whose account may not make the call,
+// e.g. a rider token on an admin endpoint.
+var ErrForbidden = errors.New("not allowed for this account")
+
+// APIError is an error answer of the server.
+type APIError struct {
+	StatusCode int    // HTTP status, e.g. 400
+	Message    string // The server's explanation
+}
+
+// Error returns the status and the server's message.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("taxischeduler: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether target is the sentinel error for the answer's status.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// Config configures a Client. Zero fields get defaults.
+type Config struct {
+	BaseURL    string        // Where the server's HTTP API is served, e.g. "http://localhost:8080" (required)
+	Token      string        // API token sent as "Authorization: Bearer <token>" ("" for none)
+	HTTPClient *http.Client  // Client for every call (default: one with a 10s timeout and the TLS config)
+	TLS        *tls.Config   // For https servers: trusted CAs, and the client certificate drivers need if the server asks (default: system CAs)
+	MaxRetries int           // Retries after a failed attempt (default 3; negative for none)
+	Backoff    time.Duration // Wait before the first retry, doubled after each one up to 5s (default 200ms)
+}
+
+// Client calls a TaxiScheduler server as the account of its token.
+// A Client is safe for concurrent use.
+type Client struct {
+	config Config
+}
+
+// New creates a Client for the server at config.BaseURL.
+func New(config Config) *Client {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+		if config.TLS != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = config.TLS
+			config.HTTPClient.Transport = transport
+		}
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaultBackoff
+	}
+	return &Client{config: config}
+}
+
+// WithToken returns a Client with the same settings that calls as another account.
+func (c *Client) WithToken(token string) *Client {
+	config := c.config
+	config.Token = token
+	return &Client{config: config}
+}
+
+// RegisterClient signs up a rider and returns its ID and API token.
+// Needs no token.
+func (c *Client) RegisterClient(ctx context.Context, name string) (Credentials, error) {
+	var credentials Credentials
+	err := c.do(ctx, http.MethodPost, "/clients", map[string]string{"name": name}, &credentials)
+	return credentials, err
+}
+
+// RegisterTaxi adds a taxi with the given attributes at location to the fleet and
+// returns its ID. Needs an admin token.
+func (c *Client) RegisterTaxi(ctx context.Context, location Location, attributes TaxiAttributes) (int, error) {
+	var answer struct {
+		TaxiID int `json:"taxi_id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/taxis", taxiRegistration{Location: location, Attributes: attributes}, &answer)
+	return answer.TaxiID, err
+}
+
+// ApproveTaxi lets a taxi pending approval serve rides. Needs an admin token.
+func (c *Client) ApproveTaxi(ctx context.Context, taxiID int) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/admin/taxis/%d/approve", taxiID), nil, nil)
+}
+
+// RejectTaxi turns down a taxi pending approval, which removes it from the fleet.
+// The reason may be empty. Needs an admin token.
+func (c *Client) RejectTaxi(ctx context.Context, taxiID int, reason string) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/admin/taxis/%d/reject", taxiID), map[string]string{"reason": reason}, nil)
+}
+
+// RequestRide books a ride for the rider of the token and returns its ID.
+func (c *Client) RequestRide(ctx context.Context, order RideOrder) (int, error) {
+	var answer struct {
+		RideID int `json:"ride_id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/rides", order, &answer)
+	return answer.RideID, err
+}
+
+// QuoteRide prices the trip of an order for the rider of the token. The fare is held
+// for 5 minutes: book it by setting the quote's ID as the order's QuoteID.
+func (c *Client) QuoteRide(ctx context.Context, order RideOrder) (RideQuote, error) {
+	var quote RideQuote
+	err := c.do(ctx, http.MethodPost, "/quotes", order, &quote)
+	return quote, err
+}
+
+// RequestRides books every ride of a batch for the rider of the token, or none of
+// them if the server rejects any, and returns their IDs in order.
+func (c *Client) RequestRides(ctx context.Context, orders []RideOrder) ([]int, error) {
+	var answer struct {
+		RideIDs []int `json:"ride_ids"`
+	}
+	err := c.do(ctx, http.MethodPost, "/rides/batch", orders, &answer)
+	return answer.RideIDs, err
+}
+
+// WaitForRide long-polls a ride of the rider of the token (any ride for an admin token):
+// it returns once the ride's status is no longer since, e.g. "ASSIGNED", or after
+// timeout (at most a minute) with Changed false. To follow a ride without a WebSocket,
+// call it again with the status returned. An empty since returns the ride at once.
+// The timeout must be shorter than the HTTP client's (10s for the default one).
+func (c *Client) WaitForRide(ctx context.Context, rideID int, since string, timeout time.Duration) (RideWait, error) {
+	var wait RideWait
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/rides/%d/wait?since=%s&timeout=%s", rideID, url.QueryEscape(since), timeout), nil, &wait)
+	return wait, err
+}
+
+// FindTaxisNear returns the available taxis within radius (at most 50) of location,
+// nearest first, e.g. to show the cars around the rider. Needs a rider token.
+func (c *Client) FindTaxisNear(ctx context.Context, location Location, radius int) ([]NearbyTaxi, error) {
+	var taxis []NearbyTaxi
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/taxis/near?x=%d&y=%d&radius=%d", location.X, location.Y, radius), nil, &taxis)
+	return taxis, err
+}
+
+// Favorites returns the places the rider of the token saved, sorted by name.
+func (c *Client) Favorites(ctx context.Context) ([]FavoritePlace, error) {
+	var favorites []FavoritePlace
+	err := c.do(ctx, http.MethodGet, "/favorites", nil, &favorites)
+	return favorites, err
+}
+
+// SetFavorite saves location under name (e.g. "home") for the rider of the token, to use
+// as the From or To of RideOrders. It replaces a favorite of the same name, ignoring case.
+func (c *Client) SetFavorite(ctx context.Context, name string, location Location) error {
+	return c.do(ctx, http.MethodPut, "/favorites/"+url.PathEscape(name), location, nil)
+}
+
+// RemoveFavorite forgets one of the rider's favorite places.
+func (c *Client) RemoveFavorite(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/favorites/"+url.PathEscape(name), nil, nil)
+}
+
+// do sends one API call with body as JSON (nil for none) and decodes the answer into
+// out (nil to ignore it). Failed attempts are retried with exponential backoff when
+// repeating them cannot do anything twice: connection failures, 429 and 503 answers,
+// and for GET, PUT and DELETE also other network errors and 5xx answers.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("taxischeduler: encoding request: %w", err)
+		}
+	}
+
+	backoff := c.config.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.attempt(ctx, method, path, payload, out)
+		if err == nil || !retry || attempt == c.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// attempt makes one try of a call. Returns whether a failure is worth retrying.
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out any) (bool, error) {
+	request, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+apiPrefix+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("taxischeduler: %w", err)
+	}
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+	response, err := c.config.HTTPClient.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		// A failed dial never reached the server, so even a POST can be sent again
+		var opErr *net.OpError
+		notSent := errors.As(err, &opErr) && opErr.Op == "dial"
+		return idempotent || notSent, fmt.Errorf("taxischeduler: %s %s: %w", method, path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable ||
+			(idempotent && response.StatusCode >= 500)
+		return retry, &APIError{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	if out == nil {
+		return false, nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("taxischeduler: decoding %s %s answer: %w", method, path, err)
+	}
+	return false, nil
+}
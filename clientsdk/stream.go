@@ -0,0 +1,269 @@
+// stream.go - Live ride updates
+// Receives the rider's ride events from GET /notifications/ws, a WebSocket, and
+// reconnects with backoff when the connection drops
+
+package clientsdk
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket protocol constants (RFC 6455).
+const (
+	wsGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // Mixed into the handshake key
+	wsOpText      = 0x1
+	wsOpClose     = 0x8
+	wsOpPing      = 0x9
+	wsOpPong      = 0xA
+	wsMaxPayload  = 64 << 10 // Largest message accepted; ride events are far smaller
+	updatesBuffer = 64       // Updates held for a slow reader before the stream waits for it
+)
+
+// StreamRideUpdates receives the events of the token's rider's rides as they happen,
+// while the rider's notification preferences include the "websocket" channel.
+// The first connection is made before returning, so a bad token or address is
+// reported here. After that a dropped connection is made again with backoff;
+// events from while it was down are not replayed.
+// The channel is closed once ctx is done, or if the server later refuses the token.
+func (c *Client) StreamRideUpdates(ctx context.Context) (<-chan RideUpdate, error) {
+	conn, err := c.dialUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan RideUpdate, updatesBuffer)
+	go c.streamUpdates(ctx, conn, updates)
+	return updates, nil
+}
+
+// streamUpdates reads conn into updates, reconnecting whenever it drops.
+// Closes updates when ctx is done or the token is refused.
+func (c *Client) streamUpdates(ctx context.Context, conn *wsClient, updates chan<- RideUpdate) {
+	defer close(updates)
+	for {
+		conn.read(ctx, updates)
+		conn.Close()
+
+		backoff := c.config.Backoff
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			var err error
+			if conn, err = c.dialUpdates(ctx); err == nil {
+				break
+			}
+			if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) {
+				return // Retrying cannot help until the caller has a new token
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// wsClient is the client end of a WebSocket. Frames it sends are masked, as the
+// protocol requires of clients.
+type wsClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex // One frame at a time
+}
+
+// dialUpdates opens the WebSocket of GET /notifications/ws.
+// A refused handshake is returned as an *APIError.
+func (c *Client) dialUpdates(ctx context.Context) (*wsClient, error) {
+	base, err := url.Parse(c.config.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("taxischeduler: parsing base URL: %w", err)
+	}
+	port := base.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[base.Scheme]
+	}
+	address := net.JoinHostPort(base.Hostname(), port)
+
+	var conn net.Conn
+	switch base.Scheme {
+	case "http":
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	case "https":
+		tlsConfig := &tls.Config{}
+		if c.config.TLS != nil {
+			tlsConfig = c.config.TLS.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = base.Hostname()
+		}
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	default:
+		return nil, fmt.Errorf("taxischeduler: base URL must be http or https, got %q", base.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("taxischeduler: connecting for ride updates: %w", err)
+	}
+
+	ws, err := handshake(ctx, conn, base.Host, strings.TrimRight(base.Path, "/")+apiPrefix+"/notifications/ws", c.config.Token)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// handshake upgrades conn to a WebSocket for path (RFC 6455 section 4.1).
+func handshake(ctx context.Context, conn net.Conn, host, path, token string) (*wsClient, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(defaultTimeout))
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if token != "" {
+		request += "Authorization: Bearer " + token + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		return nil, fmt.Errorf("taxischeduler: WebSocket handshake: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, fmt.Errorf("taxischeduler: WebSocket handshake: %w", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		return nil, &APIError{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if response.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("taxischeduler: WebSocket handshake: server answered with the wrong accept key")
+	}
+	return &wsClient{conn: conn, reader: reader}, nil
+}
+
+// read delivers every update of the connection until it drops or ctx is done.
+func (ws *wsClient) read(ctx context.Context, updates chan<- RideUpdate) {
+	stop := context.AfterFunc(ctx, func() {
+		ws.writeFrame(wsOpClose, nil)
+		ws.conn.Close() // Unblocks readFrame
+	})
+	defer stop()
+
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpText:
+			var update RideUpdate
+			if json.Unmarshal(payload, &update) != nil {
+				continue // Not an event this version knows; skip it
+			}
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+		case wsOpPing:
+			ws.writeFrame(wsOpPong, payload)
+		case wsOpClose:
+			ws.writeFrame(wsOpClose, nil)
+			return
+		}
+	}
+}
+
+// readFrame reads one whole frame. The server sends every event as a single
+// unfragmented text frame, so continuation frames need no joining.
+func (ws *wsClient) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > wsMaxPayload {
+		return 0, nil, fmt.Errorf("frame of %d bytes is over the %d byte limit", length, wsMaxPayload)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	return opcode, payload, nil
+}
+
+// writeFrame sends one masked frame with a final bit set.
+func (ws *wsClient) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// Close closes the connection.
+func (ws *wsClient) Close() error {
+	return ws.conn.Close()
+}
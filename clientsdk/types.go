@@ -0,0 +1,172 @@
+// types.go - Request and answer types of the TaxiScheduler API
+// Mirrors the JSON the server reads and writes, so callers work with typed values
+
+package clientsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Location is a point on the service grid, (0, 0) to (99, 99).
+type Location struct {
+	X int
+	Y int
+}
+
+// TaxiAttributes is a set of taxi features, stored as bit flags.
+// Combine them with |, e.g. WheelchairAccessible | ChildSeat.
+type TaxiAttributes uint
+
+const (
+	WheelchairAccessible TaxiAttributes = 1 << iota // Has a ramp or lift for wheelchairs
+	ChildSeat                                       // Carries a child seat
+	Electric                                        // Electric vehicle
+	Luxury                                          // Luxury class vehicle
+)
+
+// Duration is a time.Duration that reads and writes as a string like "5s" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string such as "500ms" or "2m".
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string such as "5s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Credentials are the ID and API token of a registered account.
+type Credentials struct {
+	ClientID int    `json:"client_id"`
+	Token    string `json:"token"` // Pass to Client.WithToken to call as this account
+}
+
+// RideOrder is a ride to book. Give either a location or a place name (e.g. "Airport",
+// or one of the rider's favorites such as "home") for each end.
+type RideOrder struct {
+	Start        Location          `json:"start"`
+	End          Location          `json:"end"`
+	From         string            `json:"from,omitempty"`         // Named pickup, used instead of Start
+	To           string            `json:"to,omitempty"`           // Named destination, used instead of End
+	Waypoints    []Location        `json:"waypoints,omitempty"`    // Stops between start and end, in order
+	Requirements TaxiAttributes    `json:"requirements,omitempty"` // Features the taxi must have
+	Pool         string            `json:"pool,omitempty"`         // Dispatch pool to serve the ride from ("" = general fleet)
+	Priority     string            `json:"priority,omitempty"`     // PriorityLow, PriorityNormal (default) or PriorityHigh
+	ExpiresIn    Duration          `json:"expires_in,omitzero"`    // Give up if no taxi is assigned this soon (zero for no deadline)
+	Metadata     map[string]string `json:"metadata,omitempty"`     // Application data carried with the ride
+	QuoteID      int               `json:"quote_id,omitempty"`     // Quote to book the ride at, from QuoteRide (0 for none)
+}
+
+// Ride priorities of a RideOrder. Low-priority rides may be rejected with a 503, or
+// deferred, while the fleet is saturated; high-priority ones are dispatched first.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// Ride event types of a RideUpdate.
+const (
+	RideCreated           = "RIDE_CREATED"
+	TaxiAssigned          = "TAXI_ASSIGNED"
+	RideOffered           = "RIDE_OFFERED"
+	RideAccepted          = "RIDE_ACCEPTED"
+	RideDeclined          = "RIDE_DECLINED"
+	RideStarted           = "RIDE_STARTED"
+	RideFinished          = "RIDE_FINISHED"
+	RideReassigned        = "RIDE_REASSIGNED"
+	RideExpired           = "RIDE_EXPIRED"
+	RideRequeued          = "RIDE_REQUEUED"
+	RideFailed            = "RIDE_FAILED"
+	RideArchived          = "RIDE_ARCHIVED"
+	RideDeferred          = "RIDE_DEFERRED"
+	RideNoShow            = "RIDE_NO_SHOW"
+	RideWaitSLABreached   = "RIDE_WAIT_SLA_BREACHED"
+	RidePickupSLABreached = "RIDE_PICKUP_SLA_BREACHED"
+)
+
+// RideUpdate is something that happened to one of the rider's rides.
+type RideUpdate struct {
+	Type     string            `json:"type"`               // What happened, e.g. RideFinished
+	RideID   int               `json:"ride_id"`            // ID of the ride
+	TaxiID   int               `json:"taxi_id"`            // Taxi involved (0 if none)
+	Time     time.Time         `json:"time"`               // When it happened (the server's simulated time)
+	TraceID  string            `json:"trace_id,omitempty"` // Trace ID of the ride's request
+	ClientID int               `json:"client_id"`          // Rider who requested the ride
+	Metadata map[string]string `json:"metadata,omitempty"` // The ride's metadata
+}
+
+// Terminal reports whether the ride never changes again after this update.
+func (u RideUpdate) Terminal() bool {
+	return u.Type == RideFinished || u.Type == RideExpired || u.Type == RideFailed || u.Type == RideNoShow
+}
+
+// RideQuote is a fare held for one trip of the rider until ExpiresAt. A RideOrder for
+// the same trip with its ID as QuoteID is charged Fare; a quote books one ride.
+type RideQuote struct {
+	ID        int        `json:"quote_id"`
+	ClientID  int        `json:"client_id"`
+	Start     Location   `json:"start"`
+	End       Location   `json:"end"`
+	Waypoints []Location `json:"waypoints,omitempty"`
+	Distance  int        `json:"distance"`
+	Fare      int        `json:"fare"` // Minor units of Currency, e.g. cents
+	Currency  string     `json:"currency"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// Ride is the state of a ride.
+type Ride struct {
+	ID         int       `json:"id"`
+	ClientID   int       `json:"client_id"`
+	TaxiID     int       `json:"taxi_id,omitempty"` // 0 until a taxi is assigned
+	Start      Location  `json:"start"`
+	End        Location  `json:"end"`
+	Status     string    `json:"status"` // e.g. "ASSIGNED"
+	CreatedAt  time.Time `json:"created_at"`
+	AssignedAt time.Time `json:"assigned_at,omitzero"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// RideWait is the answer of WaitForRide.
+type RideWait struct {
+	Changed bool `json:"changed"` // False if the wait timed out with the ride still in the status waited on
+	Ride    Ride `json:"ride"`
+}
+
+// NearbyTaxi is an available taxi found around a location.
+type NearbyTaxi struct {
+	TaxiID     int            `json:"taxi_id"`
+	Location   Location       `json:"location"`
+	Distance   int            `json:"distance"` // From the searched location, as the crow flies
+	Attributes TaxiAttributes `json:"attributes"`
+}
+
+// FavoritePlace is a location the rider saved under a name, usable as the From or To
+// of their RideOrders.
+type FavoritePlace struct {
+	Name     string   `json:"name"`
+	Location Location `json:"location"`
+}
+
+// taxiRegistration is the body of POST /admin/taxis.
+type taxiRegistration struct {
+	Location   Location       `json:"location"`
+	Attributes TaxiAttributes `json:"attributes"`
+}
@@ -0,0 +1,307 @@
+// main.go - Command-line entry point
+// Parses the flags and runs the simulation, or the load test, end-to-end check,
+// matching benchmark or trace replay they ask for
+
+// Command taxischeduler runs the taxi scheduling simulation; the README describes its flags.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/server"
+	"github.com/Nart-Tehaucha/TaxiScheduler/sim"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+func main() {
+	// Note: As of Go 1.20, the global random generator is automatically seeded
+	loadTest := flag.Bool("loadtest", false, "run the load generator instead of the simulation")
+	loadTaxis := flag.Int("loadtest-taxis", 10000, "taxis in the fleet for -loadtest")
+	loadRides := flag.Int("loadtest-rides", 100000, "ride requests for -loadtest")
+	loadWorkers := flag.Int("loadtest-workers", runtime.GOMAXPROCS(0), "concurrent assigners for -loadtest")
+	endToEnd := flag.Bool("e2e", false, "run the end-to-end invariant check on a manual clock instead of the simulation")
+	endToEndTaxis := flag.Int("e2e-taxis", 10, "taxis in the fleet for -e2e")
+	endToEndRides := flag.Int("e2e-rides", 50, "ride requests for -e2e")
+	lookAhead := flag.Duration("lookahead", 0, "hold rides for busy taxis finishing within this long closer to the pickup, e.g. 5s (0 = off)")
+	batchMatching := flag.String("batch-matching", string(scheduler.OptimalMatching), "with -batch-window, match each batch to taxis: optimal (least total pickup distance) or greedy (closest taxi for each ride in turn)")
+	matchBench := flag.Bool("match-bench", false, "benchmark optimal against greedy batch matching on random batches instead of running the simulation")
+	matchBenchRides := flag.Int("match-bench-rides", 50, "rides per batch for -match-bench")
+	matchBenchTaxis := flag.Int("match-bench-taxis", 75, "available taxis per batch for -match-bench")
+	matchBenchBatches := flag.Int("match-bench-batches", 200, "batches for -match-bench")
+	batchWindow := flag.Duration("batch-window", 0, "collect ride requests this long and match each batch to taxis for the least total pickup distance, e.g. 500ms (0 = assign one at a time)")
+	taxiApproval := flag.Bool("taxi-approval", false, "new taxis get no rides until an admin approves them (POST /admin/taxis/{id}/approve, needs -http)")
+	cooldown := flag.Duration("cooldown", 0, "keep taxis out of dispatch this long after each drop-off, e.g. 30s (0 = off)")
+	routeCache := flag.Int("route-cache", 0, "cache this many distances in front of the router (0 = off)")
+	roadGrid := flag.Bool("road-grid", false, "route on a street grid operators can edit (PATCH /admin/roads) instead of straight-line distances")
+	drivers := flag.Int("drivers", 0, "replace the scenario's taxis with this many simulated drivers who must accept each ride")
+	configPath := flag.String("config", "", "apply runtime settings from this JSON file and reload it on change or SIGHUP")
+	journalPath := flag.String("journal", "", "persist rides to this JSON Lines journal and restore them on startup")
+	archivePath := flag.String("archive", "", "move finished and failed rides out of memory into this gzip-compressed JSON Lines file once older than -retention")
+	retention := flag.Duration("retention", 24*time.Hour, "how long (simulated time) finished rides stay in memory with -archive")
+	eventsPath := flag.String("events", "", "append ride events to this file (.csv for CSV, otherwise JSON Lines)")
+	httpAddr := flag.String("http", "", "serve the HTTP API on this address, e.g. :8080, 127.0.0.1:8080 or [::1]:8080")
+	rateLimit := flag.Float64("rate-limit", 0, "with -http, allow each caller this many requests per second on average, e.g. 20 (0 = unlimited)")
+	tlsCert := flag.String("tls-cert", "", "with -http, serve HTTPS with this PEM certificate chain (needs -tls-key)")
+	tlsKey := flag.String("tls-key", "", "PEM private key of -tls-cert")
+	driverCA := flag.String("driver-ca", "", "with -tls-cert, require driver endpoints to present a client certificate signed by a CA in this PEM file")
+	seed := flag.Int64("seed", 0, "random seed for taxi and ride locations and chaos faults, to reproduce a run (0 = the scenario's seed, else random)")
+	adaptiveDispatch := flag.Int("adaptive-dispatch", 0, "speed dispatch up while more than this many requests wait in a lane (0 = fixed pace)")
+	taxiSpeed := flag.Float64("taxi-speed", scheduler.DefaultTaxiSpeed, "distance units a taxi drives per simulated second")
+	noShow := flag.Float64("no-show", 0, "chance a passenger is not at the pickup when the taxi arrives, e.g. 0.05 (rides end NO_SHOW with a no-show fee)")
+	speedVariance := flag.Float64("speed-variance", 0, "let each ride take up to this fraction longer or shorter, e.g. 0.2")
+	speed := flag.Float64("speed", 1, "simulation speed factor, e.g. 100 runs the scenario 100x faster")
+	redisAddr := flag.String("redis", "", "keep the fleet in the Redis server at this address, shared with every instance using it, e.g. localhost:6379")
+	storeShards := flag.Int("store-shards", 1, "split the in-memory fleet across this many locks (also for -loadtest and -e2e)")
+	redisPrefix := flag.String("redis-prefix", "taxischeduler", "namespace of the fleet's keys for -redis")
+	geocoderURL := flag.String("geocoder-url", "", "look up place names the built-in landmarks do not know with this geocoding service")
+	fixturePath := flag.String("fixture", "", "load the taxis and rides of this JSON fixture before the scenario starts")
+	scenarioPath := flag.String("scenario", "", "load taxi and ride waves from this JSON file (default: 15 taxis, 100 rides)")
+	forecastTick := flag.Duration("forecast", 0, "with -reposition, move idle taxis toward the demand forecast for the next 5 ticks of this length, e.g. 1m (0 = recent demand)")
+	reposition := flag.Duration("reposition", 0, "move idle taxis toward demand this often, e.g. 30s (0 = off)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "drive taxis idle this long toward demand, one cell at a time, e.g. 1m (0 = off)")
+	recordPath := flag.String("record", "", "record every input and state change of the run to this trace file")
+	replayPath := flag.String("replay", "", "replay the inputs of a recorded trace instead of running the scenario")
+	compare := flag.String("compare", "", "with -replay, replay the trace against these strategies and compare them: default, nearest, idle, lookahead, batched, batch-greedy (comma-separated) or a .json file")
+	flag.Parse()
+
+	if *loadTest {
+		fmt.Println("[Main] Running load test...")
+		result := sim.RunLoadTest(sim.LoadTestConfig{Taxis: *loadTaxis, Rides: *loadRides, Workers: *loadWorkers, Shards: *storeShards})
+		fmt.Printf("[Main] %s\n", result)
+		return
+	}
+
+	if *matchBench {
+		fmt.Println("[Main] Benchmarking batch matching...")
+		result := sim.RunMatchingBenchmark(sim.MatchingBenchmarkConfig{Rides: *matchBenchRides, Taxis: *matchBenchTaxis, Batches: *matchBenchBatches})
+		fmt.Printf("[Main] %s\n", result)
+		return
+	}
+
+	if *endToEnd {
+		fmt.Println("[Main] Running end-to-end check...")
+		stdout := os.Stdout // RunEndToEnd silences os.Stdout for good
+		endToEndSeed := *seed
+		if endToEndSeed == 0 {
+			endToEndSeed = 1
+		}
+		result := server.RunEndToEnd(server.EndToEndConfig{Taxis: *endToEndTaxis, Rides: *endToEndRides, Seed: endToEndSeed, LookAhead: *lookAhead, RedisAddr: *redisAddr, StoreShards: *storeShards})
+		fmt.Fprintf(stdout, "[Main] %s\n", result)
+		if !result.Passed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *compare != "" {
+		if *replayPath == "" {
+			log.Fatalf("[Main] -compare needs a recorded trace to replay (-replay)\n")
+		}
+		strategies, err := server.ParseStrategies(*compare)
+		if err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		entries, err := server.ReadTrace(*replayPath)
+		if err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		compareSeed := *seed
+		if compareSeed == 0 {
+			compareSeed = 1
+		}
+		fmt.Printf("[Main] Comparing %d strategies on %s...\n", len(strategies), *replayPath)
+		stdout := os.Stdout // CompareStrategies silences os.Stdout for good
+		report := server.CompareStrategies(entries, strategies, compareSeed)
+		fmt.Fprintf(stdout, "[Main] Strategy comparison:\n%s\n", report)
+		return
+	}
+
+	fmt.Println("=== TaxiScheduler System Starting ===")
+	fmt.Println()
+
+	scenario := sim.DefaultScenario()
+	if *scenarioPath != "" {
+		loaded, err := sim.LoadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		scenario = loaded
+	}
+
+	// Settle on one seed for the whole run and print it, so any run can be repeated
+	if *seed != 0 {
+		scenario.Seed = *seed
+	}
+	if scenario.Seed == 0 {
+		scenario.Seed = rand.Int63n(1<<31) + 1
+	}
+	fmt.Printf("[Main] Random seed %d (rerun with -seed %d)\n", scenario.Seed, scenario.Seed)
+
+	// Create the server (API gateway)
+	config := server.ServerConfig{
+		Clock:          taxi.NewScaledClock(*speed),
+		RouteCacheSize: *routeCache,
+		StoreShards:    *storeShards,
+		Seed:           scenario.Seed,
+		TaxiSpeed:      *taxiSpeed,
+		SpeedVariance:  *speedVariance,
+	}
+	if *roadGrid {
+		config.Router = taxi.NewGridRouter(taxi.GridArea.Max.X+1, taxi.GridArea.Max.Y+1)
+	}
+	if *geocoderURL != "" {
+		config.Geocoder = server.GeocoderChain{server.NewStaticGeocoder(server.DefaultPlaces), server.NewHTTPGeocoder(*geocoderURL)}
+	}
+	if *redisAddr != "" {
+		taxis, err := taxi.NewRedisTaxiStore(*redisAddr, *redisPrefix, config.Clock)
+		if err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		config.Taxis = taxis
+	}
+	srv := server.NewServerWithConfig(config)
+	clock := srv.Clock()
+	if *httpAddr != "" {
+		if *rateLimit > 0 {
+			srv.SetRateLimit(server.RateLimit{PerSecond: *rateLimit})
+		}
+		if _, err := srv.StartHTTP(server.ListenConfig{Addr: *httpAddr, CertFile: *tlsCert, KeyFile: *tlsKey, ClientCAFile: *driverCA}); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		_, token := srv.RegisterAdmin("operator")
+		fmt.Printf("[Main] Admin token for the HTTP API: %s\n", token)
+	}
+	if *forecastTick > 0 {
+		forecast := scheduler.DefaultForecastConfig()
+		forecast.Tick = *forecastTick
+		if err := srv.EnableDemandForecast(forecast); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *reposition > 0 {
+		srv.EnableAutoRepositioning(*reposition)
+	}
+	if *idleTimeout > 0 {
+		srv.EnableIdleRepositioning(*idleTimeout)
+	}
+	if *lookAhead > 0 {
+		srv.EnableLookAhead(*lookAhead)
+	}
+	if *batchWindow > 0 {
+		srv.EnableBatching(*batchWindow)
+		if err := srv.SetBatchMatching(scheduler.BatchMatching(*batchMatching)); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *cooldown > 0 {
+		srv.SetTaxiCooldown(*cooldown)
+	}
+	if *taxiApproval {
+		srv.RequireTaxiApproval()
+	}
+	if *noShow > 0 {
+		srv.EnableChaos(scheduler.FaultConfig{NoShowProbability: *noShow})
+	}
+	if *adaptiveDispatch > 0 {
+		srv.EnableAdaptiveDispatch(scheduler.AdaptiveRate{Threshold: *adaptiveDispatch})
+	}
+	if *configPath != "" {
+		if err := srv.WatchConfig(*configPath); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *journalPath != "" {
+		if err := srv.EnableJournal(*journalPath); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *archivePath != "" {
+		if err := srv.EnableArchival(*archivePath, *retention); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *eventsPath != "" {
+		if err := srv.ExportEvents(*eventsPath); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *recordPath != "" {
+		if err := srv.EnableRecording(*recordPath); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	if *fixturePath != "" {
+		if _, err := srv.LoadFixture(*fixturePath); err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+	}
+	var replayer *server.TraceReplayer
+	var recorded []server.TraceEntry
+	if *replayPath != "" {
+		entries, err := server.ReadTrace(*replayPath)
+		if err != nil {
+			log.Fatalf("[Main] %v\n", err)
+		}
+		recorded = entries
+		replayer = server.NewTraceReplayer(srv, recorded)
+	}
+
+	// Simulated drivers answer every offer themselves, so the plain scenario taxis
+	// (which never answer) are left out
+	if *drivers > 0 {
+		scenario.Taxis = nil
+		srv.RequireConfirmation(10 * time.Second)
+		rng := rand.New(rand.NewSource(scenario.Seed))
+		for i := 0; i < *drivers; i++ {
+			location := taxi.Location{X: rng.Intn(100), Y: rng.Intn(100)}
+			behavior := sim.DefaultDriverBehavior()
+			behavior.Seed = rng.Int63()
+			go sim.NewDriverClient(srv, location, taxi.TaxiAttributes(rng.Intn(16)), behavior).Start()
+		}
+	}
+
+	if replayer != nil {
+		// The trace stands in for the scenario's clients (blocks until every input is sent)
+		replayer.Start()
+	} else {
+		// Create clients that use the server API
+		taxiClient := sim.NewTaxiClient(srv, scenario)
+		userClient := sim.NewUserClient(srv, scenario)
+
+		// Start taxi client in background (default: 15 taxis, 1 per 5 seconds = ~75 seconds)
+		go taxiClient.Start()
+
+		// Start user client (blocks until all requests are sent; the default
+		// scenario waits 10 seconds first so some taxis have registered)
+		userClient.Start()
+	}
+
+	// Shutdown the server
+	srv.Shutdown()
+
+	// Wait for remaining rides to complete
+	fmt.Println("[Main] All requests sent, waiting for rides to complete...")
+	clock.Sleep(30 * time.Second)
+
+	// Show the best earning taxis of the run
+	fmt.Println("[Main] Top earners:")
+	for _, entry := range srv.GetTopEarners(5) {
+		fmt.Printf("[Main]   %s\n", entry)
+	}
+
+	// Compare the replayed run against the recorded one
+	if replayer != nil {
+		fmt.Printf("[Main] Recorded run: %s\n", server.SummarizeTrace(recorded))
+		fmt.Printf("[Main] Replayed run: %s\n", replayer.Summary())
+	}
+
+	fmt.Println()
+	fmt.Println("=== TaxiScheduler System Finished ===")
+}
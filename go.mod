@@ -0,0 +1,3 @@
+module github.com/Nart-Tehaucha/TaxiScheduler
+
+go 1.24
@@ -0,0 +1,128 @@
+// relay.go - Lossless event delivery
+// Hands events to a reader in order without ever blocking the sender or dropping one
+
+// Package relay hands values to a reader in order without blocking the sender or
+// dropping any.
+package relay
+
+import "sync"
+
+// Relay delivers every value pushed to it on a buffered channel, in order.
+// Values the channel has no room for wait in a backlog of unlimited size, so Push never
+// blocks and nothing is lost: a reader that falls behind only costs memory.
+// The backlog is moved into the channel by a goroutine that only runs while it is not empty.
+// All methods are safe for concurrent use.
+type Relay[T any] struct {
+	C       chan T        // Channel handed to the reader; only the relay sends on it or closes it
+	mu      sync.Mutex    // Protects every field below
+	backlog []T           // Values waiting for room in C, oldest first
+	pumping bool          // The pump goroutine is running
+	closed  bool          // Close or Unsubscribe was called; C is closed once the backlog is delivered
+	dropped bool          // Unsubscribe was called
+	stop    chan struct{} // Closed by Unsubscribe, so the pump stops waiting for the reader
+}
+
+// New creates a relay whose channel holds buffer values before the backlog is used.
+func New[T any](buffer int) *Relay[T] {
+	return &Relay[T]{C: make(chan T, buffer), stop: make(chan struct{})}
+}
+
+// Push delivers value after every value pushed before it, without blocking.
+// Values pushed after Close are ignored.
+func (r *Relay[T]) Push(value T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	// Straight into the channel, unless older values are still waiting
+	if len(r.backlog) == 0 {
+		select {
+		case r.C <- value:
+			return
+		default:
+		}
+	}
+	r.backlog = append(r.backlog, value)
+	if !r.pumping {
+		r.pumping = true
+		go r.pump()
+	}
+}
+
+// pump moves the backlog into the channel, waiting for the reader as long as it takes,
+// and closes the channel once the backlog is empty after Close.
+func (r *Relay[T]) pump() {
+	for {
+		r.mu.Lock()
+		if len(r.backlog) == 0 {
+			r.backlog = nil // Drop the array grown by the last burst
+			r.pumping = false
+			if r.closed {
+				close(r.C)
+			}
+			r.mu.Unlock()
+			return
+		}
+		// The value stays in the backlog until sent, so Push keeps queueing behind it
+		value := r.backlog[0]
+		r.mu.Unlock()
+
+		select {
+		case r.C <- value:
+		case <-r.stop:
+		}
+
+		r.mu.Lock()
+		if len(r.backlog) > 0 { // Unsubscribe drops the backlog
+			var zero T
+			r.backlog[0] = zero // Let the garbage collector have it
+			r.backlog = r.backlog[1:]
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Close stops accepting values and closes the channel once every value pushed so far
+// has been handed over. The reader must keep reading until then.
+// Does nothing after Close or Unsubscribe.
+func (r *Relay[T]) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if !r.pumping {
+		close(r.C)
+	}
+}
+
+// Unsubscribe is Close for a reader that stops reading: values still in the backlog
+// are dropped and the channel is closed without waiting for the reader, who may still
+// read the values in its buffer. Safe to call more than once, and after Close.
+func (r *Relay[T]) Unsubscribe() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dropped {
+		return
+	}
+	wasClosed := r.closed
+	r.closed, r.dropped = true, true
+	r.backlog = nil
+	close(r.stop)
+	// A running pump closes C on its way out; so did Close if no pump was running
+	if !r.pumping && !wasClosed {
+		close(r.C)
+	}
+}
+
+// Len returns how many values are waiting for the reader, in the channel and the backlog.
+func (r *Relay[T]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.C) + len(r.backlog)
+}
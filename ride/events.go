@@ -0,0 +1,265 @@
+// events.go - Ride lifecycle events
+// Lets other components react to ride changes without polling the RideStore
+
+package ride
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/internal/relay"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RideEventType describes what happened to a ride.
+type RideEventType string
+
+const (
+	RideCreated    RideEventType = "RIDE_CREATED"    // The ride was requested
+	TaxiAssigned   RideEventType = "TAXI_ASSIGNED"   // A taxi was assigned to the ride
+	RideOffered    RideEventType = "RIDE_OFFERED"    // The assigned taxi must accept the ride (confirmation mode)
+	RideAccepted   RideEventType = "RIDE_ACCEPTED"   // The taxi accepted the offer
+	RideDeclined   RideEventType = "RIDE_DECLINED"   // The taxi declined or did not answer in time
+	RideStarted    RideEventType = "RIDE_STARTED"    // The ride is IN_PROGRESS
+	RideFinished   RideEventType = "RIDE_FINISHED"   // The ride is FINISHED
+	RideReassigned RideEventType = "RIDE_REASSIGNED" // The ride's taxi failed and the ride went back to the queue
+	RideExpired    RideEventType = "RIDE_EXPIRED"    // No taxi was assigned before the ride's deadline
+	RideRequeued   RideEventType = "RIDE_REQUEUED"   // An operator sent the ride back to the queue from the dead-letter queue
+	RideFailed     RideEventType = "RIDE_FAILED"     // The ride could not be resumed after a restart, or Shutdown kept it from the queue
+	RideArchived   RideEventType = "RIDE_ARCHIVED"   // The ride was written to the archive and dropped from memory
+	RideDeferred   RideEventType = "RIDE_DEFERRED"   // The low-priority ride is held back until the fleet is less saturated
+	RideNoShow     RideEventType = "RIDE_NO_SHOW"    // The passenger was not at the pickup when the taxi arrived
+
+	RideWaitSLABreached   RideEventType = "RIDE_WAIT_SLA_BREACHED"   // The ride has waited for a taxi longer than SLATargets.MaxAssignmentWait
+	RidePickupSLABreached RideEventType = "RIDE_PICKUP_SLA_BREACHED" // The assigned taxi is further than SLATargets.MaxPickupETA from the pickup
+)
+
+// RideEvent is sent to EventBus subscribers whenever something happens to a ride.
+type RideEvent struct {
+	Type     RideEventType     `json:"type"`               // What happened
+	RideID   int               `json:"ride_id"`            // ID of the ride
+	TaxiID   int               `json:"taxi_id"`            // ID of the taxi involved (0 if none)
+	Time     time.Time         `json:"time"`               // When it happened
+	TraceID  string            `json:"trace_id,omitempty"` // Trace ID of the ride's request
+	Metadata map[string]string `json:"metadata,omitempty"` // The ride's metadata (shared, never modify)
+}
+
+// SlowSubscriberPolicy says what the EventBus does with an event for a subscriber
+// whose buffer is full. Publish never waits for a subscriber, whatever the policy.
+type SlowSubscriberPolicy string
+
+const (
+	DropNewest SlowSubscriberPolicy = "drop_newest" // Drop the new event; the subscriber misses the latest events (default)
+	DropOldest SlowSubscriberPolicy = "drop_oldest" // Drop the oldest buffered event to make room; the subscriber misses the earliest
+	Disconnect SlowSubscriberPolicy = "disconnect"  // Unsubscribe and close the channel, so the subscriber can notice and resubscribe
+	KeepAll    SlowSubscriberPolicy = "keep_all"    // Queue the event beyond the buffer, without limit; the subscriber misses nothing but may use a lot of memory
+)
+
+// SubscribeOptions configures one EventBus subscription. Zero fields get defaults.
+type SubscribeOptions struct {
+	Name   string               // Shown in EventBusStats, e.g. "webhooks" (default "subscriber #N")
+	Buffer int                  // Events the channel holds before the policy applies (default 100)
+	Policy SlowSubscriberPolicy // What to do when the buffer is full (default DropNewest)
+}
+
+// SubscriberStats counts what the EventBus delivered to one subscriber.
+type SubscriberStats struct {
+	Name      string               `json:"name"`
+	Policy    SlowSubscriberPolicy `json:"policy"`
+	Buffer    int                  `json:"buffer"`
+	Queued    int                  `json:"queued"`    // Events waiting in the buffer now (and beyond it, with KeepAll)
+	Delivered int64                `json:"delivered"` // Events put in the buffer
+	Dropped   int64                `json:"dropped"`   // Events lost because the buffer was full
+}
+
+// EventBusStats counts the events the EventBus fanned out since the server started.
+type EventBusStats struct {
+	Published    int64             `json:"published"`    // Events published
+	Dropped      int64             `json:"dropped"`      // Events lost by any subscriber, current or gone
+	Disconnected int64             `json:"disconnected"` // Subscribers cut off by the Disconnect policy
+	Subscribers  []SubscriberStats `json:"subscribers"`  // Current subscribers, oldest first
+}
+
+// eventSubscriber is one subscription of the EventBus.
+type eventSubscriber struct {
+	id    int                     // Order of subscription
+	ch    chan RideEvent          // Buffered channel handed to the subscriber
+	relay *relay.Relay[RideEvent] // Feeds ch for the KeepAll policy (nil otherwise)
+	stats SubscriberStats         // Everything but Queued, kept up to date
+}
+
+// EventBus fans ride events out to every subscriber.
+// Each subscriber gets its own buffered channel and never holds up ride processing:
+// once its buffer is full, its SlowSubscriberPolicy decides which events it loses.
+// All methods are safe for concurrent access.
+type EventBus struct {
+	clock        taxi.Clock               // For event timestamps
+	mu           sync.Mutex               // Protects every field below
+	subscribers  map[int]*eventSubscriber // Subscription ID -> subscriber
+	nextID       int                      // ID of the next subscription
+	published    int64                    // Events published
+	dropped      int64                    // Events dropped, including by subscribers since gone
+	disconnected int64                    // Subscribers disconnected for falling behind
+}
+
+// NewEventBus creates an EventBus with no subscribers.
+func NewEventBus(clock taxi.Clock) *EventBus {
+	return &EventBus{clock: clock, subscribers: make(map[int]*eventSubscriber), nextID: 1}
+}
+
+// Subscribe returns a channel that receives every ride event published from now on,
+// with the default buffer and the DropNewest policy.
+func (eb *EventBus) Subscribe() <-chan RideEvent {
+	ch, _ := eb.SubscribeWith(SubscribeOptions{})
+	return ch
+}
+
+// SubscribeWith returns a channel that receives every ride event published from now
+// on, buffered and handled as the options say, and a function that unsubscribes and
+// closes the channel (safe to call more than once, and after a disconnect).
+// With KeepAll the channel is only closed once every event published before
+// unsubscribing has been read, so the subscriber must read it until then.
+func (eb *EventBus) SubscribeWith(options SubscribeOptions) (<-chan RideEvent, func()) {
+	if options.Buffer <= 0 {
+		options.Buffer = taxi.SubscriberBufferSize
+	}
+	if options.Policy == "" {
+		options.Policy = DropNewest
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	id := eb.nextID
+	eb.nextID++
+	if options.Name == "" {
+		options.Name = fmt.Sprintf("subscriber #%d", id)
+	}
+	subscriber := &eventSubscriber{
+		id:    id,
+		stats: SubscriberStats{Name: options.Name, Policy: options.Policy, Buffer: options.Buffer},
+	}
+	if options.Policy == KeepAll {
+		subscriber.relay = relay.New[RideEvent](options.Buffer)
+		subscriber.ch = subscriber.relay.C
+	} else {
+		subscriber.ch = make(chan RideEvent, options.Buffer)
+	}
+	eb.subscribers[id] = subscriber
+
+	unsubscribe := func() {
+		eb.mu.Lock()
+		defer eb.mu.Unlock()
+		eb.remove(id)
+	}
+	return subscriber.ch, unsubscribe
+}
+
+// remove unsubscribes and closes a subscriber's channel, if it is still subscribed.
+// Must be called with eb.mu held.
+func (eb *EventBus) remove(id int) {
+	subscriber, exists := eb.subscribers[id]
+	if !exists {
+		return
+	}
+	delete(eb.subscribers, id)
+	if subscriber.relay != nil {
+		subscriber.relay.Close()
+		return
+	}
+	close(subscriber.ch)
+}
+
+// Publish sends an event about ride to every subscriber without blocking.
+// Only fields fixed at creation are read, so ride.mu need not be held.
+func (eb *EventBus) Publish(eventType RideEventType, ride *Ride, taxiID int) {
+	event := RideEvent{
+		Type:     eventType,
+		RideID:   ride.ID,
+		TaxiID:   taxiID,
+		Time:     eb.clock.Now(),
+		TraceID:  ride.TraceID,
+		Metadata: ride.Metadata,
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.published++
+	for id, subscriber := range eb.subscribers {
+		if subscriber.relay != nil {
+			subscriber.relay.Push(event)
+			subscriber.stats.Delivered++
+			continue
+		}
+		select {
+		case subscriber.ch <- event:
+			subscriber.stats.Delivered++
+			continue
+		default:
+		}
+
+		subscriber.stats.Dropped++
+		eb.dropped++
+		switch subscriber.stats.Policy {
+		case DropOldest:
+			// Only Publish sends, under eb.mu, so after taking one event out there is room
+			select {
+			case oldest := <-subscriber.ch:
+				log.Printf("[EventBus] WARNING: %s is too far behind, dropped its oldest %s event for ride #%d\n",
+					subscriber.stats.Name, oldest.Type, oldest.RideID)
+			default: // The subscriber caught up meanwhile
+			}
+			subscriber.ch <- event
+			subscriber.stats.Delivered++
+		case Disconnect:
+			eb.disconnected++
+			log.Printf("[EventBus] WARNING: %s is too far behind, disconnected it at %s event for ride #%d\n",
+				subscriber.stats.Name, eventType, ride.ID)
+			eb.remove(id)
+		default:
+			log.Printf("[EventBus] WARNING: %s buffer full, dropped %s event for ride #%d\n", subscriber.stats.Name, eventType, ride.ID)
+		}
+	}
+}
+
+// Delivered returns how many events the subscription reading ch has been handed
+// (its SubscriberStats.Delivered), or 0 if ch is not subscribed.
+func (eb *EventBus) Delivered(ch <-chan RideEvent) int64 {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for _, subscriber := range eb.subscribers {
+		if subscriber.ch == ch {
+			return subscriber.stats.Delivered
+		}
+	}
+	return 0
+}
+
+// Stats returns how many events were published and dropped, and the counters of every
+// current subscriber.
+func (eb *EventBus) Stats() EventBusStats {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	stats := EventBusStats{Published: eb.published, Dropped: eb.dropped, Disconnected: eb.disconnected}
+	subscribers := make([]*eventSubscriber, 0, len(eb.subscribers))
+	for _, subscriber := range eb.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].id < subscribers[j].id })
+	stats.Subscribers = make([]SubscriberStats, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		subscriberStats := subscriber.stats
+		subscriberStats.Queued = len(subscriber.ch)
+		if subscriber.relay != nil {
+			subscriberStats.Queued = subscriber.relay.Len()
+		}
+		stats.Subscribers = append(stats.Subscribers, subscriberStats)
+	}
+	return stats
+}
@@ -0,0 +1,100 @@
+package ride
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// testStart is when the ManualClock of every test starts.
+var testStart = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+// testRides adds n rides to a new RideStore and returns them, to publish events about.
+func testRides(clock taxi.Clock, n int) (*RideStore, []*Ride) {
+	store := NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+	rides := make([]*Ride, 0, n)
+	for i := 0; i < n; i++ {
+		rides = append(rides, store.Add(RideRequest{StartLocation: taxi.Location{X: i % 100}, EndLocation: taxi.Location{X: i % 100, Y: 1}}))
+	}
+	return store, rides
+}
+
+func TestKeepAllNeverDropsAndKeepsOrder(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	bus := NewEventBus(clock)
+	_, rides := testRides(clock, 1000)
+	events, unsubscribe := bus.SubscribeWith(SubscribeOptions{Name: "journal", Buffer: 10, Policy: KeepAll})
+	lossy, _ := bus.SubscribeWith(SubscribeOptions{Name: "lossy", Buffer: 10})
+
+	// Nobody reads while the burst is published
+	for _, ride := range rides {
+		bus.Publish(RideCreated, ride, 0)
+	}
+	stats := bus.Stats()
+	unsubscribe()
+
+	if stats.Subscribers[0].Queued != len(rides) || stats.Subscribers[0].Dropped != 0 {
+		t.Errorf("KeepAll subscriber: %+v, want all %d events queued and none dropped", stats.Subscribers[0], len(rides))
+	}
+	if stats.Dropped != int64(len(rides)-10) {
+		t.Errorf("bus dropped %d events, want only the DropNewest subscriber's %d", stats.Dropped, len(rides)-10)
+	}
+	if len(lossy) != 10 {
+		t.Errorf("DropNewest subscriber holds %d events, want its buffer of 10", len(lossy))
+	}
+
+	// Every event comes out in order, then the channel closes
+	next := 0
+	timeout := time.After(5 * time.Second)
+	for next < len(rides) {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("channel closed after %d of %d events", next, len(rides))
+			}
+			if event.RideID != rides[next].ID {
+				t.Fatalf("event %d is for ride #%d, want #%d", next, event.RideID, rides[next].ID)
+			}
+			next++
+		case <-timeout:
+			t.Fatalf("only %d of %d events arrived", next, len(rides))
+		}
+	}
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("got an event after every published one")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("channel not closed after unsubscribing")
+	}
+}
+
+func TestKeepAllDeliversEventsPublishedWhileReading(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	bus := NewEventBus(clock)
+	_, rides := testRides(clock, 5000)
+	events, unsubscribe := bus.SubscribeWith(SubscribeOptions{Buffer: 1, Policy: KeepAll})
+
+	received := make(chan int)
+	go func() {
+		count, last := 0, 0
+		for event := range events {
+			if event.RideID <= last {
+				t.Errorf("ride #%d arrived after ride #%d", event.RideID, last)
+			}
+			last = event.RideID
+			count++
+		}
+		received <- count
+	}()
+	for _, ride := range rides {
+		bus.Publish(RideCreated, ride, 0)
+	}
+	unsubscribe()
+
+	if count := <-received; count != len(rides) {
+		t.Errorf("read %d events, want %d", count, len(rides))
+	}
+}
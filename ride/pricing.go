@@ -0,0 +1,179 @@
+// pricing.go - Fare calculation
+// Turns ride distances and times into fares, by the tariff of each ride's fleet and
+// pickup zone
+
+package ride
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Default night hours (UTC) of a tariff with a night surcharge and no hours of its own.
+const (
+	defaultNightFrom  = 22
+	defaultNightUntil = 6
+)
+
+// Tariff is how rides are priced: a base fare, a rate per distance unit and one per
+// minute of ride time, more at night. Amounts are in the minor unit of Currency (e.g.
+// cents), so fares are integers without floating point rounding issues.
+// A tariff with a Pool or a Zone only prices the rides of that pool or picked up in
+// that zone.
+type Tariff struct {
+	Name           string    `json:"name"`                 // e.g. "airport"
+	Currency       string    `json:"currency"`             // ISO 4217 code, e.g. "USD"
+	Pool           string    `json:"pool,omitempty"`       // Dispatch pool whose rides it prices ("" = any fleet)
+	Zone           taxi.Zone `json:"zone,omitzero"`        // Zone whose pickups it prices (zero = anywhere)
+	BaseFare       int       `json:"base_fare"`            // Flat fee for every ride
+	PerUnit        int       `json:"per_unit"`             // Fee per distance unit driven with the passenger
+	PerMinute      int       `json:"per_minute"`           // Fee per minute of ride time
+	NoShowFee      int       `json:"no_show_fee"`          // Charged when the passenger does not turn up at the pickup
+	NightSurcharge float64   `json:"night_surcharge"`      // Extra share of the fare of rides requested at night, e.g. 0.25 = 25% more
+	NightFrom      int       `json:"night_from,omitempty"` // Hour (UTC) night starts; with NightUntil equal, 22 to 6
+	NightUntil     int       `json:"night_until,omitempty"`
+}
+
+// DefaultTariff returns the tariff of rides no configured tariff applies to:
+// $2.50 base fare plus $0.20 per distance unit, and $5.00 for a no-show.
+func DefaultTariff() Tariff {
+	return Tariff{Name: "standard", Currency: "USD", BaseFare: 250, PerUnit: 20, NoShowFee: 500}
+}
+
+// Fare returns what a ride requested at requestedAt that drove distance with the
+// passenger in rideTime is charged.
+func (t Tariff) Fare(distance int, rideTime time.Duration, requestedAt time.Time) int {
+	fare := float64(t.BaseFare+distance*t.PerUnit) + float64(t.PerMinute)*rideTime.Minutes()
+	if t.NightSurcharge > 0 && t.night(requestedAt) {
+		fare *= 1 + t.NightSurcharge
+	}
+	return int(math.Round(fare))
+}
+
+// night reports whether at falls in the tariff's night hours.
+func (t Tariff) night(at time.Time) bool {
+	from, until := t.NightFrom, t.NightUntil
+	if from == until {
+		from, until = defaultNightFrom, defaultNightUntil
+	}
+	hour := at.UTC().Hour()
+	if from < until {
+		return hour >= from && hour < until
+	}
+	return hour >= from || hour < until
+}
+
+// appliesTo reports whether the tariff prices rides of pool picked up at pickup.
+func (t Tariff) appliesTo(pool string, pickup taxi.Location) bool {
+	return (t.Pool == "" || t.Pool == pool) && (t.Zone == taxi.Zone{} || t.Zone.Contains(pickup))
+}
+
+// Validate returns an error if the tariff cannot price rides.
+func (t Tariff) Validate() error {
+	if len(t.Currency) != 3 || !isUpper(t.Currency) {
+		return fmt.Errorf("tariff %q: currency must be a 3-letter code like \"USD\", got %q", t.Name, t.Currency)
+	}
+	if t.BaseFare < 0 || t.PerUnit < 0 || t.PerMinute < 0 || t.NoShowFee < 0 || t.NightSurcharge < 0 {
+		return fmt.Errorf("tariff %q: fares and surcharge must not be negative", t.Name)
+	}
+	if t.NightFrom < 0 || t.NightFrom > 23 || t.NightUntil < 0 || t.NightUntil > 23 {
+		return fmt.Errorf("tariff %q: night hours must be from 0 to 23", t.Name)
+	}
+	if t.Zone.Min.X > t.Zone.Max.X || t.Zone.Min.Y > t.Zone.Max.Y {
+		return fmt.Errorf("tariff %q: zone %q is empty: min %v is past max %v", t.Name, t.Zone.Name, t.Zone.Min, t.Zone.Max)
+	}
+	return nil
+}
+
+// isUpper reports whether s is only the letters A to Z.
+func isUpper(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// PricingService picks the tariff of each ride when it is requested and calculates
+// its fare with it. Rides keep their tariff, so changing the tariffs only affects
+// rides requested afterwards.
+// All methods are safe for concurrent access.
+type PricingService struct {
+	mu       sync.RWMutex // Protects tariffs
+	tariffs  []Tariff     // Checked in order: the first that applies to a ride prices it
+	fallback Tariff       // Prices rides none of tariffs applies to
+}
+
+// NewPricingService creates a PricingService that prices every ride with DefaultTariff.
+func NewPricingService() *PricingService {
+	return &PricingService{fallback: DefaultTariff()}
+}
+
+// SetTariffs replaces the tariffs rides are priced with, most specific first: each
+// ride gets the first one that applies to its pool and pickup, or DefaultTariff if
+// none does. A tariff without pool and zone applies to every ride.
+// Returns an error, leaving the tariffs as they were, if any tariff is invalid.
+func (ps *PricingService) SetTariffs(tariffs []Tariff) error {
+	for _, tariff := range tariffs {
+		if err := tariff.Validate(); err != nil {
+			return err
+		}
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.tariffs = slices.Clone(tariffs)
+	return nil
+}
+
+// Tariffs returns the tariffs set, in the order they are checked.
+func (ps *PricingService) Tariffs() []Tariff {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return slices.Clone(ps.tariffs)
+}
+
+// TariffFor returns the tariff a ride of pool picked up at pickup is priced with.
+func (ps *PricingService) TariffFor(pool string, pickup taxi.Location) Tariff {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	for _, tariff := range ps.tariffs {
+		if tariff.appliesTo(pool, pickup) {
+			return tariff
+		}
+	}
+	return ps.fallback
+}
+
+// RideFare returns what a ride that drove distance in rideTime is charged: the fare
+// of the quote it was booked with, or its tariff's fare without one.
+func (ps *PricingService) RideFare(ride *Ride, distance int, rideTime time.Duration) int {
+	if ride.QuotedFare > 0 {
+		return ride.QuotedFare
+	}
+	return ps.tariffOf(ride).Fare(distance, rideTime, ride.CreatedAt)
+}
+
+// NoShowFee returns what the passenger of a ride is charged for not turning up at the pickup.
+func (ps *PricingService) NoShowFee(ride *Ride) int {
+	return ps.tariffOf(ride).NoShowFee
+}
+
+// Currency returns the currency a ride is charged in.
+func (ps *PricingService) Currency(ride *Ride) string {
+	return ps.tariffOf(ride).Currency
+}
+
+// tariffOf returns the tariff a ride was requested with, or the default tariff for
+// rides without one (e.g. restored from a journal written before tariffs existed).
+func (ps *PricingService) tariffOf(ride *Ride) Tariff {
+	if ride.Tariff.Currency == "" {
+		return ps.fallback
+	}
+	return ride.Tariff
+}
@@ -0,0 +1,78 @@
+// receipt.go - Ride trip receipts
+// Summarizes a finished ride's timings, distance and fare
+
+package ride
+
+import (
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Receipt summarizes a finished ride for billing and wait-time analytics.
+type Receipt struct {
+	RideID    int           // ID of the ride
+	ClientID  int           // ID of the client who took the ride
+	TaxiID    int           // ID of the taxi that drove the ride
+	WaitTime  time.Duration // Time from request until a taxi was assigned
+	QueueWait time.Duration // Part of WaitTime spent in the scheduler's queues
+	RideTime  time.Duration // Time from ride start until drop-off
+	TotalTime time.Duration // Time from request until drop-off
+	Distance  int           // Distance from pickup to destination, through every waypoint
+	Legs      []int         // Distance of each leg: pickup to first stop, ..., last waypoint to destination
+	Fare      int           // Amount charged (minor units of Currency, e.g. cents)
+	Currency  string        // ISO 4217 code of Fare, from the ride's tariff
+	Tariff    string        // Name of the tariff the ride was priced with
+	NoShow    bool          // The passenger did not turn up: no trip was driven and Fare is the no-show fee
+}
+
+// TripEstimate quotes a trip before it is booked.
+// Distance and Fare are computed as on the Receipt of the finished ride, unless the
+// ride is booked with a quote (see Server.QuoteRide), whose fare it is charged.
+type TripEstimate struct {
+	Distance int           // Distance from pickup to destination, through every waypoint
+	Duration time.Duration // Expected time from pickup to destination, with current traffic
+	Fare     int           // Amount that will be charged (minor units of Currency)
+	Currency string        // ISO 4217 code of Fare
+}
+
+// NewReceipt builds a receipt from a finished ride, or a NO_SHOW one, which is charged
+// the no-show fee and has no trip.
+// The ride should be a snapshot (see RideStore.Snapshot) so no locking is needed.
+func NewReceipt(ride *Ride, locationService taxi.Router, pricing *PricingService) *Receipt {
+	if ride.Status() == NO_SHOW {
+		return &Receipt{
+			RideID:    ride.ID,
+			ClientID:  ride.ClientID,
+			TaxiID:    ride.TaxiID(),
+			WaitTime:  ride.AssignedAt.Sub(ride.CreatedAt),
+			QueueWait: ride.QueueWait,
+			TotalTime: ride.FinishedAt.Sub(ride.CreatedAt),
+			Fare:      pricing.NoShowFee(ride),
+			Currency:  pricing.Currency(ride),
+			Tariff:    pricing.tariffOf(ride).Name,
+			NoShow:    true,
+		}
+	}
+
+	legs := taxi.LegDistances(locationService, ride.Stops())
+	distance := 0
+	for _, leg := range legs {
+		distance += leg
+	}
+
+	return &Receipt{
+		RideID:    ride.ID,
+		ClientID:  ride.ClientID,
+		TaxiID:    ride.TaxiID(),
+		WaitTime:  ride.AssignedAt.Sub(ride.CreatedAt),
+		QueueWait: ride.QueueWait,
+		RideTime:  ride.FinishedAt.Sub(ride.StartedAt),
+		TotalTime: ride.FinishedAt.Sub(ride.CreatedAt),
+		Distance:  distance,
+		Legs:      legs,
+		Fare:      pricing.RideFare(ride, distance, ride.FinishedAt.Sub(ride.StartedAt)),
+		Currency:  pricing.Currency(ride),
+		Tariff:    pricing.tariffOf(ride).Name,
+	}
+}
@@ -0,0 +1,186 @@
+// ride_store.go - Thread-safe ride storage
+// Keeps every ride so clients can look up its status after requesting it
+
+package ride
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RideStore holds all rides with concurrent access protection.
+// Uses a map for O(1) lookup by RideID.
+// All public methods are safe for concurrent access from multiple goroutines.
+// This is the default RideStorage backend.
+type RideStore struct {
+	mu    sync.RWMutex     // Read-write mutex for concurrent access
+	rides map[int]*Ride    // Map from ride ID to Ride pointer
+	ids   taxi.IDGenerator // Hands out new ride IDs
+	clock taxi.Clock       // For creation timestamps
+}
+
+// NewRideStore creates and returns an initialized RideStore that takes IDs from ids.
+func NewRideStore(ids taxi.IDGenerator, clock taxi.Clock) *RideStore {
+	return &RideStore{
+		rides: make(map[int]*Ride),
+		ids:   ids,
+		clock: clock,
+	}
+}
+
+// Add creates a new ride in CREATED status from a request and returns it.
+// The request's waypoints and metadata are copied, so the caller may reuse them.
+func (rs *RideStore) Add(request RideRequest) *Ride {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	id := rs.ids.NextID()
+
+	ride := &Ride{
+		ID:            id,
+		ClientID:      request.ClientID,
+		StartLocation: request.StartLocation,
+		EndLocation:   request.EndLocation,
+		Waypoints:     slices.Clone(request.Waypoints),
+		Requirements:  request.Requirements,
+		status:        CREATED,
+		CreatedAt:     rs.clock.Now(),
+		ExpiresAt:     request.ExpiresAt,
+		TraceID:       request.TraceID,
+		Pool:          request.Pool,
+		Metadata:      maps.Clone(request.Metadata),
+		QuotedFare:    request.QuotedFare,
+		Tariff:        request.Tariff,
+	}
+	rs.rides[id] = ride
+
+	return ride
+}
+
+// Get retrieves a ride by ID. Returns nil if not found.
+// The returned ride is live: read its status and taxi with Status and TaxiID, and lock
+// ride.mu before reading the other fields that change.
+func (rs *RideStore) Get(id int) *Ride {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.rides[id]
+}
+
+// Snapshot returns a copy of the ride that is safe to read without locking.
+// Returns nil if the ride was not found.
+func (rs *RideStore) Snapshot(id int) *Ride {
+	ride := rs.Get(id)
+	if ride == nil {
+		return nil
+	}
+	return ride.Snapshot()
+}
+
+// List returns snapshot copies of every ride, oldest first.
+func (rs *RideStore) List() []*Ride {
+	rs.mu.RLock()
+	ids := make([]int, 0, len(rs.rides))
+	for id := range rs.rides {
+		ids = append(ids, id)
+	}
+	rs.mu.RUnlock()
+	sort.Ints(ids)
+
+	rides := make([]*Ride, 0, len(ids))
+	for _, id := range ids {
+		if ride := rs.Snapshot(id); ride != nil {
+			rides = append(rides, ride)
+		}
+	}
+	return rides
+}
+
+// ListByStatus returns snapshot copies of every ride in the given status, oldest first.
+func (rs *RideStore) ListByStatus(status RideStatus) []*Ride {
+	rides := make([]*Ride, 0)
+	for _, ride := range rs.List() {
+		if ride.Status() == status {
+			rides = append(rides, ride)
+		}
+	}
+	return rides
+}
+
+// RequestFor rebuilds the scheduler request for an existing ride,
+// used when a ride has to be queued again. Only reads fields fixed at creation.
+func RequestFor(ride *Ride) RideRequest {
+	return RideRequest{
+		RideID:        ride.ID,
+		ClientID:      ride.ClientID,
+		StartLocation: ride.StartLocation,
+		EndLocation:   ride.EndLocation,
+		Waypoints:     ride.Waypoints,
+		Requirements:  ride.Requirements,
+		ExpiresAt:     ride.ExpiresAt,
+		TraceID:       ride.TraceID,
+		Pool:          ride.Pool,
+		Metadata:      ride.Metadata,
+	}
+}
+
+// Link marks two rides as the outbound and return legs of one round trip.
+// Returns false if either ride was not found.
+func (rs *RideStore) Link(outboundID, returnID int) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	outbound, okOut := rs.rides[outboundID]
+	inbound, okIn := rs.rides[returnID]
+	if !okOut || !okIn {
+		return false
+	}
+	outbound.mu.Lock()
+	outbound.LinkedRideID = returnID
+	outbound.mu.Unlock()
+
+	inbound.mu.Lock()
+	inbound.LinkedRideID = outboundID
+	inbound.mu.Unlock()
+	return true
+}
+
+// Restore puts previously persisted rides back into the store, replacing any with the same ID.
+// A sequential ID generator is moved past the restored IDs so new rides get fresh ones.
+func (rs *RideStore) Restore(rides map[int]*Ride) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	highest := 0
+	for id, ride := range rides {
+		rs.rides[id] = ride
+		if id > highest {
+			highest = id
+		}
+	}
+	if sequential, ok := rs.ids.(*taxi.SequentialIDGenerator); ok {
+		sequential.Advance(highest)
+	}
+}
+
+// Remove forgets a ride, e.g. once it has been archived. Returns false if it was not found.
+func (rs *RideStore) Remove(id int) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, exists := rs.rides[id]; !exists {
+		return false
+	}
+	delete(rs.rides, id)
+	return true
+}
+
+// Count returns the total number of rides in the store.
+func (rs *RideStore) Count() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.rides)
+}
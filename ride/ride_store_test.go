@@ -0,0 +1,62 @@
+package ride
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Run with -race: rides are added, read and moved through their statuses all at once.
+func TestRideStoreStress(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	store := NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+	const (
+		workers = 8
+		rides   = 200
+	)
+
+	ids := make([][]int, workers) // Ride IDs each worker got from Add
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rides; i++ {
+				ride := store.Add(RideRequest{StartLocation: taxi.Location{X: i % 50, Y: 10}, EndLocation: taxi.Location{X: i % 50, Y: 60}})
+				ids[worker] = append(ids[worker], ride.ID)
+				ride.AssignTaxi(worker+1, clock.Now())
+				ride.SetStatus(IN_PROGRESS, clock.Now(), ASSIGNED)
+
+				if got := store.Get(ride.ID); got != ride {
+					t.Errorf("Get(%d) returned another ride", ride.ID)
+				}
+				if snapshot := store.Snapshot(ride.ID); snapshot == nil || snapshot == ride {
+					t.Errorf("Snapshot(%d) is not a copy", ride.ID)
+				}
+				if i%20 == 0 {
+					_ = store.ListByStatus(IN_PROGRESS)
+					_ = store.List()
+				}
+				ride.SetTaxiStatus(worker+1, FINISHED, clock.Now(), IN_PROGRESS)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, workerIDs := range ids {
+		for _, id := range workerIDs {
+			if seen[id] {
+				t.Fatalf("ride ID %d handed out twice", id)
+			}
+			seen[id] = true
+		}
+	}
+	if count := store.Count(); count != workers*rides {
+		t.Errorf("store holds %d rides, want %d", count, workers*rides)
+	}
+	if finished := len(store.ListByStatus(FINISHED)); finished != workers*rides {
+		t.Errorf("%d rides FINISHED, want all %d", finished, workers*rides)
+	}
+}
@@ -0,0 +1,22 @@
+// storage.go - Ride storage interface
+// The ride state every component works against, so the in-memory store can be
+// swapped for another backend without touching them
+
+package ride
+
+// RideStorage holds every ride requested so far.
+// RideStore (in memory) is the default; pass another one as ServerConfig.Rides.
+// Get hands out the live *Ride whose mu guards its changing fields, so a backend must
+// return the same pointer for a ride every time (e.g. by caching rides it loads).
+// Implementations must be safe for concurrent use.
+type RideStorage interface {
+	Add(request RideRequest) *Ride
+	Get(id int) *Ride
+	Snapshot(id int) *Ride
+	List() []*Ride
+	ListByStatus(status RideStatus) []*Ride
+	Link(outboundID, returnID int) bool
+	Restore(rides map[int]*Ride)
+	Remove(id int) bool
+	Count() int
+}
@@ -0,0 +1,26 @@
+// trace.go - Ride trace IDs
+// Every ride request gets a random trace ID that appears in its log lines and events,
+// so one ride's path can be filtered out of interleaved output (e.g. grep "trace 3f9c20ab")
+
+package ride
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewTraceID returns a random 8 character hex ID for a new ride request.
+func NewTraceID() string {
+	var b [4]byte
+	rand.Read(b[:]) // Never fails (see crypto/rand.Read)
+	return hex.EncodeToString(b[:])
+}
+
+// TraceTag formats a trace ID for the start of a log line, after the component prefix.
+// Returns "" for rides without a trace ID (e.g. restored from an old journal).
+func TraceTag(traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	return "[trace " + traceID + "] "
+}
@@ -0,0 +1,268 @@
+// types.go - Core ride data structures
+// Rides, ride requests and the states a ride goes through
+
+// Package ride holds rides and ride requests, the RideStore that keeps them, the
+// events published as they change, and how requests are validated and priced.
+package ride
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RideStatus represents the lifecycle state of a ride.
+// A ride progresses through these states in order: CREATED -> ASSIGNED -> IN_PROGRESS -> FINISHED
+// When driver confirmation is required, ASSIGNED -> ACCEPTED -> IN_PROGRESS instead;
+// a declined or unanswered offer sends the ride back to CREATED.
+// A ride with a deadline that is still CREATED when the deadline passes becomes EXPIRED.
+// A ride that had a taxi when the server stopped becomes FAILED on restart if it cannot be resumed,
+// as does a new ride that Shutdown kept from being queued.
+// A ride IN_PROGRESS whose passenger is not at the pickup when the taxi arrives becomes NO_SHOW.
+// New states are appended at the end so existing values never change.
+type RideStatus int
+
+const (
+	CREATED     RideStatus = iota // Ride has been requested, awaiting taxi assignment
+	ASSIGNED                      // Taxi has been assigned, ride not yet started
+	IN_PROGRESS                   // Ride is currently happening
+	FINISHED                      // Ride has been completed
+	ACCEPTED                      // Driver confirmed the assignment, ride about to start
+	EXPIRED                       // No taxi was assigned before the request's deadline
+	FAILED                        // Interrupted by a restart and could not be resumed, or by Shutdown before it was queued
+	NO_SHOW                       // The passenger did not turn up at the pickup
+)
+
+// UNKNOWN is not a state any ride is in: lookups return it, together with an error,
+// when there is no such ride, so a caller ignoring the error does not see CREATED.
+const UNKNOWN RideStatus = -1
+
+// String returns the status name, so it prints nicely in log lines.
+func (s RideStatus) String() string {
+	switch s {
+	case CREATED:
+		return "CREATED"
+	case ASSIGNED:
+		return "ASSIGNED"
+	case IN_PROGRESS:
+		return "IN_PROGRESS"
+	case FINISHED:
+		return "FINISHED"
+	case ACCEPTED:
+		return "ACCEPTED"
+	case EXPIRED:
+		return "EXPIRED"
+	case FAILED:
+		return "FAILED"
+	case NO_SHOW:
+		return "NO_SHOW"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseRideStatus returns the status with the given name (see String).
+// Returns false if no status has that name.
+func ParseRideStatus(name string) (RideStatus, bool) {
+	for status := CREATED; status <= NO_SHOW; status++ {
+		if status.String() == name {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+// RidePriority is how much a ride matters when the fleet cannot serve every request.
+type RidePriority string
+
+const (
+	PriorityLow    RidePriority = "low"    // Shed first while the fleet is saturated (see LoadSheddingPolicy)
+	PriorityNormal RidePriority = "normal" // The default, also for an empty priority
+	PriorityHigh   RidePriority = "high"   // Queued ahead of normal rides, like round trip return legs
+)
+
+// Ride represents a ride request and its current state.
+// The mu mutex protects concurrent access to every field that changes after creation
+// (status, taxiID, LinkedRideID, QueueWait and the lifecycle timestamps). The status
+// and the assigned taxi are only reached through Status, TaxiID, AssignTaxi and
+// SetStatus, which take the lock themselves; Snapshot copies every field at once.
+type Ride struct {
+	mu            sync.Mutex          // Protects fields that change after creation
+	ID            int                 // Unique identifier for the ride
+	ClientID      int                 // ID of the client who requested the ride
+	taxiID        int                 // ID of the assigned taxi (0 if unassigned)
+	StartLocation taxi.Location       // Pickup point
+	EndLocation   taxi.Location       // Destination
+	Waypoints     []taxi.Location     // Stops between pickup and destination, in order (fixed at creation, never modify)
+	Requirements  taxi.TaxiAttributes // Attributes the assigned taxi must have
+	status        RideStatus          // Current lifecycle state
+	LinkedRideID  int                 // Other leg of a round trip (0 if one-way)
+	CreatedAt     time.Time           // When the ride was requested
+	AssignedAt    time.Time           // When a taxi was assigned (zero until ASSIGNED)
+	StartedAt     time.Time           // When the ride began (zero until IN_PROGRESS)
+	FinishedAt    time.Time           // When the ride ended (zero until FINISHED or NO_SHOW)
+	ExpiresAt     time.Time           // Deadline for assigning a taxi (zero for none)
+	QueueWait     time.Duration       // Time spent in the scheduler's queues, summed over every time it was queued
+	TraceID       string              // Tags the ride's log lines and events (see trace.go)
+	Pool          string              // Only taxis in this dispatch pool may serve the ride ("" = general fleet)
+	Metadata      map[string]string   // Application data from the request, e.g. "luggage": "2" (fixed at creation, never modify)
+	QuotedFare    int                 // Fare locked by the quote the ride was booked with (0 = priced from the trip when it ends)
+	Tariff        Tariff              // Prices the ride, picked when it was requested (see PricingService.TariffFor)
+}
+
+// RideRequest is what clients submit to Server.RequestRide, and what is sent
+// through the rideRequests channel for processing.
+// The Ride itself is created in the RideStore when the request is submitted.
+type RideRequest struct {
+	RideID          int                 // ID of the ride created for this request (set by the Server)
+	ClientID        int                 // ID of the requesting client (set by the Server from Token)
+	Token           string              // API token of the requesting client (see Server.RegisterClient)
+	StartLocation   taxi.Location       // Pickup point
+	EndLocation     taxi.Location       // Destination
+	Waypoints       []taxi.Location     // Stops to make between pickup and destination, in order (nil for none)
+	StartPlace      string              // Named pickup, e.g. "Airport"; the Server sets StartLocation from it ("" = use StartLocation)
+	EndPlace        string              // Named destination; the Server sets EndLocation from it ("" = use EndLocation)
+	Requirements    taxi.TaxiAttributes // Attributes the taxi must have (0 for any taxi)
+	PreferredTaxiID int                 // Taxi to try first before falling back to the best-scoring one (0 for none)
+	ExcludedTaxiIDs []int               // Taxis that must not be assigned (e.g. they declined this ride)
+	ExpiresAt       time.Time           // Give up if no taxi is assigned by then (zero for no deadline)
+	Attempts        int                 // Times the dispatcher found no taxi for the ride (see RideScheduler.SetMaxAttempts)
+	EnqueuedAt      time.Time           // When the request last entered a scheduler queue (set when queued)
+	TraceID         string              // Correlates the request's logs and events (set by the Server unless given)
+	Pool            string              // Dispatch pool to serve the ride from, e.g. "corporate" ("" = general fleet)
+	Priority        RidePriority        // How much the ride matters under load ("" = PriorityNormal)
+	Metadata        map[string]string   // Application data carried with the ride, e.g. "pet": "dog" (nil for none)
+	QuoteID         int                 // Quote to book the ride at, from Server.QuoteRide (0 for none)
+	QuotedFare      int                 // Fare of the quote booked (set by the Server)
+	Tariff          Tariff              // Tariff the ride is priced with (set by the Server)
+}
+
+// Stops returns the ride's stops in driving order: pickup, waypoints, destination.
+// Only reads fields fixed at creation, so no locking is needed.
+func (ride *Ride) Stops() []taxi.Location {
+	return taxi.TripStops(ride.StartLocation, ride.Waypoints, ride.EndLocation)
+}
+
+// Status returns the ride's current lifecycle state.
+func (ride *Ride) Status() RideStatus {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	return ride.status
+}
+
+// TaxiID returns the ID of the assigned taxi (0 if unassigned).
+func (ride *Ride) TaxiID() int {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	return ride.taxiID
+}
+
+// Snapshot returns a copy of the ride that is safe to read without locking.
+func (ride *Ride) Snapshot() *Ride {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	return &Ride{
+		ID:            ride.ID,
+		ClientID:      ride.ClientID,
+		taxiID:        ride.taxiID,
+		StartLocation: ride.StartLocation,
+		EndLocation:   ride.EndLocation,
+		Waypoints:     ride.Waypoints,
+		Requirements:  ride.Requirements,
+		status:        ride.status,
+		LinkedRideID:  ride.LinkedRideID,
+		CreatedAt:     ride.CreatedAt,
+		AssignedAt:    ride.AssignedAt,
+		StartedAt:     ride.StartedAt,
+		FinishedAt:    ride.FinishedAt,
+		ExpiresAt:     ride.ExpiresAt,
+		QueueWait:     ride.QueueWait,
+		TraceID:       ride.TraceID,
+		Pool:          ride.Pool,
+		Metadata:      ride.Metadata,
+		QuotedFare:    ride.QuotedFare,
+		Tariff:        ride.Tariff,
+	}
+}
+
+// AddQueueWait adds wait to the time the ride spent in the scheduler's queues.
+func (ride *Ride) AddQueueWait(wait time.Duration) {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	ride.QueueWait += wait
+}
+
+// AssignTaxi moves a CREATED ride to ASSIGNED with the given taxi at time at.
+// Returns false, changing nothing, if the ride is no longer CREATED.
+// Publishing TaxiAssigned is left to the caller, which knows when the ride is dispatched.
+func (ride *Ride) AssignTaxi(taxiID int, at time.Time) bool {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	if ride.status != CREATED {
+		return false
+	}
+	ride.status = ASSIGNED
+	ride.taxiID = taxiID
+	ride.AssignedAt = at
+	return true
+}
+
+// SetStatus moves the ride to status at time at, if it is currently in one of the from
+// statuses (any status if none are given). Starting and finishing record StartedAt
+// and FinishedAt; going back to CREATED unassigns the taxi and clears the assignment
+// and start times. Use AssignTaxi to move a ride to ASSIGNED.
+// Returns false, changing nothing, if the ride was not in a from status.
+// Events are published by the caller, once the rest of the transition is done: a Ride
+// has no EventBus (journal replay changes rides no subscriber should hear about), and
+// the event must follow the caller's own bookkeeping, e.g. freeing the taxi.
+func (ride *Ride) SetStatus(status RideStatus, at time.Time, from ...RideStatus) bool {
+	return ride.SetTaxiStatus(0, status, at, from...)
+}
+
+// SetTaxiStatus is SetStatus for a ride that must still be assigned to taxiID, e.g. when
+// the taxi ends it; it returns false if the ride was given to another taxi meanwhile.
+// A taxiID of 0 does not check the taxi.
+func (ride *Ride) SetTaxiStatus(taxiID int, status RideStatus, at time.Time, from ...RideStatus) bool {
+	ride.mu.Lock()
+	defer ride.mu.Unlock()
+	if taxiID != 0 && ride.taxiID != taxiID {
+		return false
+	}
+	if len(from) > 0 && !slices.Contains(from, ride.status) {
+		return false
+	}
+
+	ride.status = status
+	switch status {
+	case IN_PROGRESS:
+		ride.StartedAt = at
+	case FINISHED, NO_SHOW:
+		ride.FinishedAt = at
+	case CREATED:
+		ride.taxiID = 0
+		ride.AssignedAt = time.Time{}
+		ride.StartedAt = time.Time{}
+	}
+	return true
+}
+
+// Stops returns the request's stops in driving order: pickup, waypoints, destination.
+func (request RideRequest) Stops() []taxi.Location {
+	return taxi.TripStops(request.StartLocation, request.Waypoints, request.EndLocation)
+}
+
+// ViaTag formats waypoints for log lines, e.g. " via (3,4), (5,6)" ("" for none).
+func ViaTag(waypoints []taxi.Location) string {
+	if len(waypoints) == 0 {
+		return ""
+	}
+	stops := make([]string, 0, len(waypoints))
+	for _, waypoint := range waypoints {
+		stops = append(stops, fmt.Sprintf("(%d,%d)", waypoint.X, waypoint.Y))
+	}
+	return " via " + strings.Join(stops, ", ")
+}
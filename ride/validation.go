@@ -0,0 +1,121 @@
+// validation.go - Ride request validation
+// A configurable chain of checks run by Server.RequestRide before a ride is created
+
+package ride
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// ErrInvalidRequest is wrapped by every validation error, so callers can use errors.Is.
+var ErrInvalidRequest = errors.New("invalid ride request")
+
+// RideValidator checks a ride request and returns a descriptive error if it must be rejected.
+type RideValidator func(request RideRequest) error
+
+// MaxWaypoints is how many waypoints a ride may have by default (see WaypointsValidator).
+const MaxWaypoints = 10
+
+// SameStartEndValidator rejects rides whose pickup and destination are the same point,
+// unless the ride goes somewhere in between (a trip out to waypoints and back).
+func SameStartEndValidator() RideValidator {
+	return func(request RideRequest) error {
+		if request.StartLocation == request.EndLocation && len(request.Waypoints) == 0 {
+			return fmt.Errorf("%w: start and end are both (%d, %d)",
+				ErrInvalidRequest, request.StartLocation.X, request.StartLocation.Y)
+		}
+		return nil
+	}
+}
+
+// WaypointsValidator rejects rides with more than max waypoints.
+func WaypointsValidator(max int) RideValidator {
+	return func(request RideRequest) error {
+		if len(request.Waypoints) > max {
+			return fmt.Errorf("%w: %d waypoints, at most %d allowed",
+				ErrInvalidRequest, len(request.Waypoints), max)
+		}
+		return nil
+	}
+}
+
+// ExpiryValidator rejects rides whose deadline has already passed.
+func ExpiryValidator(clock taxi.Clock) RideValidator {
+	return func(request RideRequest) error {
+		if !request.ExpiresAt.IsZero() && !request.ExpiresAt.After(clock.Now()) {
+			return fmt.Errorf("%w: deadline %s has already passed",
+				ErrInvalidRequest, request.ExpiresAt.Format(time.TimeOnly))
+		}
+		return nil
+	}
+}
+
+// BoundsValidator rejects rides with a pickup, waypoint or destination outside the min/max rectangle.
+func BoundsValidator(min, max taxi.Location) RideValidator {
+	area := taxi.Zone{Name: "service area", Min: min, Max: max}
+	return func(request RideRequest) error {
+		for _, location := range request.Stops() {
+			if !area.Contains(location) {
+				return fmt.Errorf("%w: (%d, %d) is outside the service area (%d, %d)-(%d, %d)",
+					ErrInvalidRequest, location.X, location.Y, min.X, min.Y, max.X, max.Y)
+			}
+		}
+		return nil
+	}
+}
+
+// MinDistanceValidator rejects rides shorter than minDistance, counting every leg.
+func MinDistanceValidator(router taxi.Router, minDistance int) RideValidator {
+	return func(request RideRequest) error {
+		distance := taxi.TripDistance(router, request.Stops())
+		if distance < minDistance {
+			return fmt.Errorf("%w: distance %d is below the minimum of %d",
+				ErrInvalidRequest, distance, minDistance)
+		}
+		return nil
+	}
+}
+
+// ClientBlacklist holds clients that are not allowed to request rides.
+// All methods are safe for concurrent access.
+type ClientBlacklist struct {
+	mu      sync.RWMutex // Protects clients
+	clients map[int]bool // Blacklisted client IDs
+}
+
+// NewClientBlacklist creates an empty blacklist.
+func NewClientBlacklist() *ClientBlacklist {
+	return &ClientBlacklist{clients: make(map[int]bool)}
+}
+
+// Add blacklists a client.
+func (cb *ClientBlacklist) Add(clientID int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.clients[clientID] = true
+}
+
+// Remove lifts a client's blacklisting.
+func (cb *ClientBlacklist) Remove(clientID int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	delete(cb.clients, clientID)
+}
+
+// Validator returns a RideValidator that rejects blacklisted clients.
+func (cb *ClientBlacklist) Validator() RideValidator {
+	return func(request RideRequest) error {
+		cb.mu.RLock()
+		defer cb.mu.RUnlock()
+
+		if cb.clients[request.ClientID] {
+			return fmt.Errorf("%w: client #%d is blacklisted", ErrInvalidRequest, request.ClientID)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+// anomaly.go - Ride anomaly detection
+// Flags suspicious rides and location updates into a review queue
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// AnomalyReason describes why a ride or location update was flagged.
+type AnomalyReason string
+
+const (
+	ReasonDurationExceeded AnomalyReason = "DURATION_EXCEEDED" // Ride took far longer than estimated
+	ReasonZeroDistance     AnomalyReason = "ZERO_DISTANCE"     // Pickup and destination are the same point
+	ReasonImpossibleSpeed  AnomalyReason = "IMPOSSIBLE_SPEED"  // Taxi moved faster than physically possible
+)
+
+// Anomaly is a single entry in the review queue.
+type Anomaly struct {
+	RideID     int           // ID of the flagged ride (0 for location updates)
+	TaxiID     int           // ID of the taxi involved
+	Reason     AnomalyReason // Why the entry was flagged
+	Details    string        // Human readable explanation
+	DetectedAt time.Time     // When the anomaly was detected
+}
+
+// AnomalyDetector inspects finished rides and taxi location updates.
+// Anything suspicious is appended to a review queue for an operator to look at.
+type AnomalyDetector struct {
+	mu              sync.Mutex        // Protects reviewQueue and lastFix
+	locationService taxi.Router       // For distance calculations
+	clock           taxi.Clock        // For timestamps and speed calculations
+	durationFactor  float64           // Flag rides taking longer than estimate * durationFactor
+	maxSpeed        float64           // Max plausible speed in distance units per second
+	reviewQueue     []Anomaly         // Flagged entries, oldest first
+	lastFix         map[int]time.Time // Last location update time per taxi
+}
+
+// NewAnomalyDetector creates an AnomalyDetector with default thresholds.
+// Rides are flagged at 3x their estimate, and speeds above 50 units/second are impossible.
+func NewAnomalyDetector(locationService taxi.Router, clock taxi.Clock) *AnomalyDetector {
+	return &AnomalyDetector{
+		locationService: locationService,
+		clock:           clock,
+		durationFactor:  3,
+		maxSpeed:        50,
+		reviewQueue:     make([]Anomaly, 0),
+		lastFix:         make(map[int]time.Time),
+	}
+}
+
+// CheckRide inspects a finished ride.
+// estimated is the expected duration; it is compared against the time between the
+// ride's StartedAt and FinishedAt, so delays in ending the ride count too.
+func (ad *AnomalyDetector) CheckRide(ride *ride.Ride, estimated time.Duration) {
+	finished := ride.Snapshot()
+	rideID, taxiID := finished.ID, finished.TaxiID()
+	startedAt, finishedAt := finished.StartedAt, finished.FinishedAt
+
+	// Zero-distance trips are usually test or fraudulent bookings
+	if ride.StartLocation == ride.EndLocation && len(ride.Waypoints) == 0 {
+		ad.flag(rideID, taxiID, ReasonZeroDistance,
+			fmt.Sprintf("start and end are both (%d, %d)", ride.StartLocation.X, ride.StartLocation.Y))
+	}
+
+	// Ride took far longer than we expected
+	if startedAt.IsZero() || finishedAt.IsZero() {
+		return
+	}
+	if actual := finishedAt.Sub(startedAt); estimated > 0 && float64(actual) > float64(estimated)*ad.durationFactor {
+		ad.flag(rideID, taxiID, ReasonDurationExceeded,
+			fmt.Sprintf("took %v, estimated %v", actual, estimated))
+	}
+}
+
+// CheckLocationUpdate inspects a taxi moving from one location to another.
+// The time since the taxi's previous update is used to compute its speed.
+func (ad *AnomalyDetector) CheckLocationUpdate(taxiID int, from, to taxi.Location) {
+	now := ad.clock.Now()
+
+	ad.mu.Lock()
+	last, seen := ad.lastFix[taxiID]
+	ad.lastFix[taxiID] = now
+	ad.mu.Unlock()
+
+	// First update for this taxi, nothing to compare against
+	if !seen {
+		return
+	}
+
+	// Standing still is always plausible
+	distance := ad.locationService.CalculateDistance(from, to)
+	if distance == 0 {
+		return
+	}
+
+	elapsed := now.Sub(last).Seconds()
+	if elapsed <= 0 || float64(distance)/elapsed > ad.maxSpeed {
+		ad.flag(0, taxiID, ReasonImpossibleSpeed,
+			fmt.Sprintf("moved %d units in %.2fs", distance, elapsed))
+	}
+}
+
+// ReviewQueue returns a copy of all flagged entries, oldest first.
+func (ad *AnomalyDetector) ReviewQueue() []Anomaly {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
+	queue := make([]Anomaly, len(ad.reviewQueue))
+	copy(queue, ad.reviewQueue)
+	return queue
+}
+
+// flag appends an entry to the review queue and logs it.
+func (ad *AnomalyDetector) flag(rideID, taxiID int, reason AnomalyReason, details string) {
+	ad.mu.Lock()
+	ad.reviewQueue = append(ad.reviewQueue, Anomaly{
+		RideID:     rideID,
+		TaxiID:     taxiID,
+		Reason:     reason,
+		Details:    details,
+		DetectedAt: ad.clock.Now(),
+	})
+	ad.mu.Unlock()
+
+	fmt.Printf("[AnomalyDetector] Flagged ride #%d / taxi #%d: %s (%s)\n", rideID, taxiID, reason, details)
+}
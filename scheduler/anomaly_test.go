@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// testStart is when the ManualClock of every test starts.
+var testStart = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+func TestCheckRideMeasuresTheRideItself(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	detector := NewAnomalyDetector(taxi.NewLocationService(), clock)
+	rides := ride.NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+
+	// finished returns a ride that ended took after it started; the clock never moves
+	finished := func(took time.Duration) *ride.Ride {
+		r := rides.Add(ride.RideRequest{StartLocation: taxi.Location{X: 0, Y: 0}, EndLocation: taxi.Location{X: 10, Y: 10}})
+		r.AssignTaxi(1, testStart)
+		r.SetStatus(ride.IN_PROGRESS, testStart)
+		r.SetStatus(ride.FINISHED, testStart.Add(took))
+		return r
+	}
+
+	detector.CheckRide(finished(2*time.Minute), time.Minute)
+	if queue := detector.ReviewQueue(); len(queue) != 0 {
+		t.Fatalf("ride within 3x its estimate flagged: %+v", queue)
+	}
+
+	slow := finished(10 * time.Minute)
+	detector.CheckRide(slow, time.Minute)
+	queue := detector.ReviewQueue()
+	if len(queue) != 1 || queue[0].RideID != slow.ID || queue[0].Reason != ReasonDurationExceeded {
+		t.Fatalf("ride 10x over its estimate: review queue is %+v, want one DURATION_EXCEEDED entry", queue)
+	}
+}
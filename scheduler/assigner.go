@@ -0,0 +1,374 @@
+// assigner.go - Taxi assignment logic
+// Assigns the best-scoring available taxi to ride requests (see scoring.go)
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiAssigner handles assigning taxis to rides.
+// Uses a Router for pickup distances and ScoringWeights to rank the available taxis.
+type TaxiAssigner struct {
+	store             taxi.TaxiStorage    // Reference to taxi storage
+	locationService   taxi.Router         // For distance calculations
+	audit             *AssignmentAudit    // Where every decision is recorded (nil for none)
+	holds             *TaxiHolds          // Taxis kept for particular clients (nil for none)
+	breaks            *TaxiBreaks         // Taxis whose drivers asked for a break (nil for none)
+	onboarding        *TaxiOnboarding     // Taxis still waiting for approval (nil to approve all)
+	maintenance       *ZoneMaintenance    // Zones whose taxis get no rides for now (nil for none)
+	clock             taxi.Clock          // For assignment timestamps
+	mu                sync.RWMutex        // Protects maxPickupDistance, weights and matching
+	maxPickupDistance int                 // Farthest a taxi may be sent for a pickup (0 = no limit)
+	weights           taxi.ScoringWeights // How candidate taxis are ranked
+	matching          BatchMatching       // How AssignBatch pairs rides with taxis
+}
+
+// NewTaxiAssigner creates a TaxiAssigner with the given dependencies.
+// audit may be nil to record no decisions, holds nil to ignore holds, breaks nil to ignore
+// breaks, onboarding nil to treat every taxi as approved, maintenance nil to ignore
+// zone maintenance.
+func NewTaxiAssigner(store taxi.TaxiStorage, locationService taxi.Router, audit *AssignmentAudit, holds *TaxiHolds, breaks *TaxiBreaks, onboarding *TaxiOnboarding, maintenance *ZoneMaintenance, clock taxi.Clock) *TaxiAssigner {
+	return &TaxiAssigner{
+		store:           store,
+		locationService: locationService,
+		audit:           audit,
+		holds:           holds,
+		breaks:          breaks,
+		onboarding:      onboarding,
+		maintenance:     maintenance,
+		clock:           clock,
+		weights:         taxi.DefaultScoringWeights(),
+		matching:        OptimalMatching,
+	}
+}
+
+// SetMaxPickupDistance limits how far away an assigned taxi may be (0 = no limit).
+// Rides with no taxi within the limit are left unassigned instead of pulling a taxi across the grid.
+func (ta *TaxiAssigner) SetMaxPickupDistance(distance int) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	ta.maxPickupDistance = distance
+}
+
+// SetScoringWeights changes how candidate taxis are ranked from the next assignment on.
+func (ta *TaxiAssigner) SetScoringWeights(weights taxi.ScoringWeights) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	ta.weights = weights
+}
+
+// SetBatchMatching changes how AssignBatch pairs rides with taxis from the next batch on.
+func (ta *TaxiAssigner) SetBatchMatching(matching BatchMatching) {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+	ta.matching = matching
+}
+
+// AssignBestTaxi finds and assigns the available taxi with the highest score to a ride
+// (see ScoringWeights). Taxis outside the ride's pool, missing any of the ride's required
+// attributes, listed in excluded, beyond the maximum pickup distance or held for another
+// client are skipped. A taxi held for the ride's client is tried first, however far away.
+// Updates the ride's TaxiID and Status fields and logs the winning score's breakdown.
+// With an audit log, every taxi the store offered and what became of it is recorded.
+// Returns a copy of the assigned taxi, or nil if no suitable taxis are available.
+func (ta *TaxiAssigner) AssignBestTaxi(r *ride.Ride, excluded []int) *taxi.Taxi {
+	if taxi, done := ta.assignHeldTaxi(r, excluded); done {
+		return taxi
+	}
+
+	ta.mu.RLock()
+	maxDistance := ta.maxPickupDistance
+	weights := ta.weights
+	ta.mu.RUnlock()
+
+	now := ta.clock.Now()
+	var trace *auditTrace
+	if ta.audit != nil {
+		trace = newAuditTrace()
+	}
+	rejection := ta.rejection(r, excluded)
+	eligible := func(taxi taxi.Taxi) bool {
+		reason := rejection(taxi)
+		if trace != nil {
+			trace.saw(taxi, reason)
+		}
+		return reason == ""
+	}
+	score := func(taxi taxi.Taxi, distance int) float64 {
+		breakdown := weights.Score(taxi, distance, now)
+		if trace != nil {
+			trace.score(taxi.ID, distance, breakdown)
+		}
+		return breakdown.Total()
+	}
+
+	// Find and reserve the best taxi in one step, so no other ride can grab it in between
+	taxi, distance, ok := ta.store.ReserveBest(r.StartLocation, ta.locationService, maxDistance, eligible, score)
+	assigned := ok && ta.markAssigned(r, taxi.ID)
+	if trace != nil {
+		decision := trace.decision(r, taxi.ID, distance, ta.locationService, maxDistance, now)
+		switch {
+		case !ok:
+			decision.Note = "no eligible taxi within reach"
+		case !assigned:
+			decision.TaxiID, decision.Distance = 0, 0
+			decision.Note = fmt.Sprintf("ride was already assigned, taxi #%d released", taxi.ID)
+		}
+		ta.audit.Record(decision)
+	}
+	if !ok {
+		if maxDistance > 0 {
+			fmt.Printf("[TaxiAssigner] %sNo taxis available within %d units of ride #%d\n", ride.TraceTag(r.TraceID), maxDistance, r.ID)
+		} else {
+			fmt.Printf("[TaxiAssigner] %sNo taxis available for ride #%d\n", ride.TraceTag(r.TraceID), r.ID)
+		}
+		return nil
+	}
+	if !assigned {
+		return nil
+	}
+
+	// The reserved copy is already unavailable; score it as the candidate it was
+	candidate := taxi
+	candidate.IsAvailable = true
+	breakdown := weights.Score(candidate, distance, now)
+	fmt.Printf("[TaxiAssigner] %sAssigned taxi #%d to ride #%d (distance: %d, score: %s)\n", ride.TraceTag(r.TraceID),
+		taxi.ID, r.ID, distance, breakdown)
+
+	return &taxi
+}
+
+// assignHeldTaxi tries the available taxis held for the ride's client, earliest hold first.
+// Returns done once the ride's assignment is settled: with the taxi, or with nil if the
+// ride turned out to be assigned already. Not done means AssignBestTaxi should search.
+func (ta *TaxiAssigner) assignHeldTaxi(r *ride.Ride, excluded []int) (*taxi.Taxi, bool) {
+	if ta.holds == nil {
+		return nil, false
+	}
+	eligible := ta.eligible(r, excluded)
+	for _, taxiID := range ta.holds.TaxisFor(r.ClientID, ta.clock.Now()) {
+		taxi, ok := ta.store.Reserve(taxiID, eligible)
+		if !ok {
+			continue // Busy with another of the client's rides, in maintenance, or unsuitable
+		}
+		assigned := ta.markAssigned(r, taxi.ID)
+		ta.recordSingle(r, AuditHeld, taxiID, true, assigned)
+		if !assigned {
+			return nil, true
+		}
+		fmt.Printf("[TaxiAssigner] %sAssigned taxi #%d, held for client #%d, to ride #%d\n", ride.TraceTag(r.TraceID), taxi.ID, r.ClientID, r.ID)
+		return &taxi, true
+	}
+	return nil, false
+}
+
+// AssignPreferredTaxi assigns a specific taxi to a ride if it is available,
+// meets the ride's requirements and belongs to the ride's pool.
+// Used for round trips, where the return leg should get the same taxi when possible.
+// Returns a copy of the assigned taxi, or nil if that taxi is busy or unknown.
+func (ta *TaxiAssigner) AssignPreferredTaxi(r *ride.Ride, taxiID int) *taxi.Taxi {
+	taxi, ok := ta.store.Reserve(taxiID, ta.eligible(r, nil))
+	assigned := ok && ta.markAssigned(r, taxi.ID)
+	ta.recordSingle(r, AuditPreferred, taxiID, ok, assigned)
+	if !assigned {
+		return nil
+	}
+	fmt.Printf("[TaxiAssigner] %sAssigned preferred taxi #%d to ride #%d\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+
+	return &taxi
+}
+
+// AssignChosenTaxi assigns the taxi an operator picked for a ride, skipping the
+// scoring search. The taxi must still be available and meet the ride's requirements.
+// Returns a copy of the assigned taxi, or nil if that taxi is busy, unsuitable or unknown.
+func (ta *TaxiAssigner) AssignChosenTaxi(r *ride.Ride, taxiID int) *taxi.Taxi {
+	taxi, ok := ta.store.Reserve(taxiID, ta.eligible(r, nil))
+	assigned := ok && ta.markAssigned(r, taxi.ID)
+	ta.recordSingle(r, AuditOperator, taxiID, ok, assigned)
+	if !assigned {
+		return nil
+	}
+	fmt.Printf("[TaxiAssigner] %sOperator assigned taxi #%d to ride #%d\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+
+	return &taxi
+}
+
+// ClosestArrival picks, among busy taxis about to finish a ride, the one whose drop-off
+// is closest to a ride's pickup, but only if it is strictly closer than every available
+// taxi that could take the ride. dropOffs maps taxi ID to where its current ride ends.
+// Taxis outside the ride's pool, missing required attributes, in maintenance, listed in
+// excluded, or beyond the maximum pickup distance are skipped.
+// Nothing is reserved. Returns the taxi ID and its pickup distance, or false.
+func (ta *TaxiAssigner) ClosestArrival(ride *ride.Ride, excluded []int, dropOffs map[int]taxi.Location) (int, int, bool) {
+	ta.mu.RLock()
+	maxDistance := ta.maxPickupDistance
+	ta.mu.RUnlock()
+
+	eligible := ta.eligible(ride, excluded)
+	reachable := func(distance int) bool {
+		return distance != taxi.Unreachable && (maxDistance == 0 || distance <= maxDistance)
+	}
+
+	bestID, bestDistance := 0, 0
+	for taxiID, dropOff := range dropOffs {
+		taxi, exists := ta.store.Get(taxiID)
+		if !exists || taxi.InMaintenance || !eligible(taxi) {
+			continue
+		}
+		distance := ta.locationService.CalculateDistance(dropOff, ride.StartLocation)
+		if !reachable(distance) {
+			continue
+		}
+		// Ties go to the lowest ID so the choice does not depend on map order
+		if bestID == 0 || distance < bestDistance || (distance == bestDistance && taxiID < bestID) {
+			bestID, bestDistance = taxiID, distance
+		}
+	}
+	if bestID == 0 {
+		return 0, 0, false
+	}
+
+	for _, taxi := range ta.store.GetAllAvailable() {
+		if !eligible(taxi) {
+			continue
+		}
+		if distance := ta.locationService.CalculateDistance(taxi.Location, ride.StartLocation); reachable(distance) && distance <= bestDistance {
+			return 0, 0, false
+		}
+	}
+	return bestID, bestDistance, true
+}
+
+// AssignReservedTaxi gives a taxi that is still reserved for the ride it just finished
+// straight to its pre-assigned next ride (see RideScheduler.SetLookAhead), so it never
+// becomes available in between. The taxi must not be in maintenance and must still meet
+// the ride's requirements and belong to its pool.
+// Returns a copy of the taxi, or nil if it cannot take the ride.
+func (ta *TaxiAssigner) AssignReservedTaxi(r *ride.Ride, taxiID int) *taxi.Taxi {
+	taxi, exists := ta.store.Get(taxiID)
+	usable := exists && !taxi.IsAvailable && !taxi.InMaintenance && ta.eligible(r, nil)(taxi)
+	assigned := usable && ta.markAssigned(r, taxi.ID)
+	ta.recordSingle(r, AuditPreAssigned, taxiID, usable, assigned)
+	if !assigned {
+		return nil
+	}
+	fmt.Printf("[TaxiAssigner] %sAssigned pre-assigned taxi #%d to ride #%d as it finished its last ride\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+
+	return &taxi
+}
+
+// eligible returns the filter deciding which taxis may serve a ride.
+// Pools are exclusive: a pool ride only gets taxis from its pool, and pool taxis
+// never serve rides for the general fleet or another pool. A held taxi only serves
+// rides of the client it is held for. A taxi pending approval serves none.
+func (ta *TaxiAssigner) eligible(ride *ride.Ride, excluded []int) func(taxi.Taxi) bool {
+	rejection := ta.rejection(ride, excluded)
+	return func(taxi taxi.Taxi) bool {
+		return rejection(taxi) == ""
+	}
+}
+
+// rejection is eligible with reasons: it returns why a taxi may not serve a ride,
+// or "" if it may.
+func (ta *TaxiAssigner) rejection(ride *ride.Ride, excluded []int) func(taxi.Taxi) string {
+	now := ta.clock.Now()
+	return func(taxi taxi.Taxi) string {
+		if ta.onboarding != nil && !ta.onboarding.Approved(taxi.ID) {
+			return "pending approval"
+		}
+		if !taxi.Attributes.Has(ride.Requirements) {
+			return "missing required attributes"
+		}
+		if taxi.Pool != ride.Pool {
+			return "in another dispatch pool"
+		}
+		for _, id := range excluded {
+			if taxi.ID == id {
+				return "excluded (declined or failed this ride before)"
+			}
+		}
+		if ta.holds != nil {
+			if holder, held := ta.holds.HeldFor(taxi.ID, now); held && holder != ride.ClientID {
+				return fmt.Sprintf("held for client #%d", holder)
+			}
+		}
+		if ta.breaks != nil && ta.breaks.Requested(taxi.ID) {
+			return "driver asked for a break"
+		}
+		if ta.maintenance != nil {
+			if window, closed := ta.maintenance.Closed(taxi.Location, now); closed {
+				return fmt.Sprintf("in zone %q under maintenance (window #%d)", window.Zone.Name, window.ID)
+			}
+		}
+		return ""
+	}
+}
+
+// recordSingle audits an assignment to one given taxi: whether the taxi could take
+// the ride (usable) and whether the ride was then still waiting for it (assigned).
+func (ta *TaxiAssigner) recordSingle(ride *ride.Ride, method string, taxiID int, usable, assigned bool) {
+	if ta.audit == nil {
+		return
+	}
+	decision := AssignmentDecision{RideID: ride.ID, At: ta.clock.Now(), Method: method, Considered: 1, Candidates: make([]AuditCandidate, 0)}
+	outcome := AuditAssigned
+	switch {
+	case !usable:
+		outcome = "busy, ineligible or unknown"
+		decision.Note = fmt.Sprintf("taxi #%d could not take the ride", taxiID)
+	case !assigned:
+		outcome = "released, ride was already assigned"
+		decision.Note = fmt.Sprintf("ride was already assigned, taxi #%d released", taxiID)
+	default:
+		decision.TaxiID = taxiID
+	}
+	candidate := AuditCandidate{TaxiID: taxiID, Distance: taxi.Unreachable, Outcome: outcome}
+	if taxi, exists := ta.store.Get(taxiID); exists {
+		candidate.Location = taxi.Location
+		candidate.Distance = ta.locationService.CalculateDistance(taxi.Location, ride.StartLocation)
+		decision.Distance = candidate.Distance
+	}
+	if assigned {
+		decision.Candidates = append(decision.Candidates, candidate)
+	} else {
+		decision.Rejections = []AuditCandidate{candidate}
+	}
+	ta.audit.Record(decision)
+}
+
+// markAssigned records the assigned taxi on the ride and moves it to ASSIGNED.
+// If the ride was assigned by someone else in the meantime (it is no longer CREATED),
+// the reserved taxi is released again and false is returned.
+func (ta *TaxiAssigner) markAssigned(r *ride.Ride, taxiID int) bool {
+	if !r.AssignTaxi(taxiID, ta.clock.Now()) {
+		fmt.Printf("[TaxiAssigner] %sRide #%d was already assigned, releasing taxi #%d\n", ride.TraceTag(r.TraceID), r.ID, taxiID)
+		ta.release(taxiID)
+		return false
+	}
+	return true
+}
+
+// release frees a taxi reserved for a ride it did not get, starting a break its
+// driver asked for in the meantime (see TaxiBreaks.Release).
+func (ta *TaxiAssigner) release(taxiID int) {
+	if ta.breaks != nil {
+		ta.breaks.Release(taxiID)
+		return
+	}
+	ta.store.SetAvailability(taxiID, true)
+}
+
+// RideDistance computes the total distance a taxi drives for a ride.
+// Distance = distance(taxi -> pickup) + distance(pickup -> each waypoint -> destination)
+// If the router finds no path for a leg, the straight Manhattan distance is used instead.
+// See TravelTimeModel for how long that takes.
+func (ta *TaxiAssigner) RideDistance(t *taxi.Taxi, ride *ride.Ride) int {
+	pickupDistance := taxi.RoutedDistance(ta.locationService, t.Location, ride.StartLocation)
+	rideDistance := taxi.TripDistance(ta.locationService, ride.Stops())
+	return pickupDistance + rideDistance
+}
@@ -0,0 +1,233 @@
+// audit.go - Assignment audit log
+// Records how every taxi assignment was decided: the taxis considered, their
+// distances and scores, the winner, and why each of the others lost or was ruled out
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Audit log limits, so long runs and large fleets stay within bounded memory.
+const (
+	auditRides            = 10000 // Rides whose decisions are kept, most recent first
+	auditDecisionsPerRide = 20    // Decisions kept per ride (retries on every fleet change add up)
+	auditCandidates       = 10    // Scored taxis kept per decision, best first
+	auditRejections       = 10    // Ruled-out taxis kept per decision, nearest to the pickup first
+)
+
+// Assignment methods recorded in AssignmentDecision.Method.
+const (
+	AuditBestScore   = "best score"   // The scoring search of AssignBestTaxi
+	AuditPreferred   = "preferred"    // The same taxi as the other leg of a round trip
+	AuditOperator    = "operator"     // Chosen by an operator
+	AuditPreAssigned = "pre-assigned" // Handed over by the taxi's previous ride
+	AuditHeld        = "held"         // Held for the ride's client (see TaxiHolds)
+	AuditBatch       = "batch"        // Matched together with the other rides of a batch (see AssignBatch)
+)
+
+// Outcomes of the taxis in an AssignmentDecision, other than the reasons for ruling a taxi out.
+const (
+	AuditAssigned   = "assigned"
+	AuditLowerScore = "lower score"
+	AuditTakenFirst = "reserved by another ride first"
+)
+
+// AuditCandidate is one taxi the assigner looked at.
+type AuditCandidate struct {
+	TaxiID   int           `json:"taxi_id"`
+	Location taxi.Location `json:"location"`
+	Distance int           `json:"distance"`        // Pickup distance (-1 if unreachable)
+	Score    string        `json:"score,omitempty"` // Score breakdown, for taxis that were scored
+	Outcome  string        `json:"outcome"`         // AuditAssigned, AuditLowerScore, AuditTakenFirst or why it was ruled out
+	total    float64       // Score total, for ordering
+}
+
+// AssignmentDecision is one attempt at assigning a taxi to a ride.
+type AssignmentDecision struct {
+	RideID     int              `json:"ride_id"`
+	At         time.Time        `json:"at"`
+	Method     string           `json:"method"`            // AuditBestScore, AuditPreferred, AuditOperator, AuditPreAssigned, AuditHeld or AuditBatch
+	TaxiID     int              `json:"taxi_id,omitempty"` // Winner (0 if no taxi was assigned)
+	Distance   int              `json:"distance,omitempty"`
+	Note       string           `json:"note,omitempty"`       // Why the attempt failed, if it did
+	Considered int              `json:"considered"`           // Taxis looked at
+	Rejected   map[string]int   `json:"rejected,omitempty"`   // Taxis ruled out, counted by reason
+	Candidates []AuditCandidate `json:"candidates"`           // Scored taxis, best first (up to auditCandidates)
+	Rejections []AuditCandidate `json:"rejections,omitempty"` // Ruled-out taxis, nearest first (up to auditRejections)
+}
+
+// AssignmentAudit keeps the assignment decisions of the most recent rides.
+// All methods are safe for concurrent access.
+type AssignmentAudit struct {
+	mu        sync.Mutex                   // Protects decisions and order
+	decisions map[int][]AssignmentDecision // Ride ID -> decisions, oldest first
+	order     []int                        // Ride IDs in the order they were first recorded
+}
+
+// NewAssignmentAudit creates an empty audit log.
+func NewAssignmentAudit() *AssignmentAudit {
+	return &AssignmentAudit{decisions: make(map[int][]AssignmentDecision)}
+}
+
+// Record adds a decision, forgetting the oldest ride once auditRides are kept.
+func (aa *AssignmentAudit) Record(decision AssignmentDecision) {
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+
+	decisions, exists := aa.decisions[decision.RideID]
+	if !exists {
+		aa.order = append(aa.order, decision.RideID)
+		if len(aa.order) > auditRides {
+			delete(aa.decisions, aa.order[0])
+			aa.order = aa.order[1:]
+		}
+	}
+	decisions = append(decisions, decision)
+	if len(decisions) > auditDecisionsPerRide {
+		decisions = decisions[len(decisions)-auditDecisionsPerRide:]
+	}
+	aa.decisions[decision.RideID] = decisions
+}
+
+// ForRide returns a ride's decisions, oldest first (nil if none were recorded).
+func (aa *AssignmentAudit) ForRide(rideID int) []AssignmentDecision {
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+	return append([]AssignmentDecision(nil), aa.decisions[rideID]...)
+}
+
+// auditTrace collects what the store showed the assigner during one ReserveBest call.
+// The store calls eligible and score under its lock, possibly for the same taxi more
+// than once (e.g. again when reserving); the last call wins.
+type auditTrace struct {
+	mu     sync.Mutex              // Protects taxis; stores may call back from several goroutines
+	taxis  map[int]*AuditCandidate // Taxi ID -> what is known about it
+	scored map[int]bool            // Taxis that were scored
+}
+
+// newAuditTrace creates an empty trace.
+func newAuditTrace() *auditTrace {
+	return &auditTrace{taxis: make(map[int]*AuditCandidate), scored: make(map[int]bool)}
+}
+
+// saw notes a taxi passed to eligible, with the reason it was ruled out ("" if it was not).
+func (at *auditTrace) saw(t taxi.Taxi, reason string) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	candidate, exists := at.taxis[t.ID]
+	if !exists {
+		candidate = &AuditCandidate{TaxiID: t.ID, Distance: taxi.Unreachable}
+		at.taxis[t.ID] = candidate
+	}
+	candidate.Location = t.Location
+	candidate.Outcome = reason
+}
+
+// score notes a taxi's distance and score.
+func (at *auditTrace) score(taxiID, distance int, breakdown taxi.ScoreBreakdown) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	candidate, exists := at.taxis[taxiID]
+	if !exists {
+		candidate = &AuditCandidate{TaxiID: taxiID}
+		at.taxis[taxiID] = candidate
+	}
+	candidate.Distance = distance
+	candidate.Score = breakdown.String()
+	candidate.total = breakdown.Total()
+	at.scored[taxiID] = true
+}
+
+// decision turns the trace into the decision for a ride won by winner (0 for none).
+// Eligible taxis that were never scored had no route to start or were too far away;
+// their distance is worked out here, outside the store lock.
+func (at *auditTrace) decision(ride *ride.Ride, winner, winnerDistance int, router taxi.Router, maxDistance int, now time.Time) AssignmentDecision {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	decision := AssignmentDecision{
+		RideID:     ride.ID,
+		At:         now,
+		Method:     AuditBestScore,
+		TaxiID:     winner,
+		Distance:   winnerDistance,
+		Considered: len(at.taxis),
+		Rejected:   make(map[string]int),
+		Candidates: make([]AuditCandidate, 0),
+	}
+	var winnerScore float64
+	if candidate, exists := at.taxis[winner]; exists {
+		winnerScore = candidate.total
+	}
+
+	var rejections []AuditCandidate
+	for id, candidate := range at.taxis {
+		switch {
+		case candidate.Outcome != "":
+			// Ruled out by eligible; the reason is already set
+		case !at.scored[id]:
+			candidate.Distance = router.CalculateDistance(candidate.Location, ride.StartLocation)
+			if candidate.Distance == taxi.Unreachable {
+				candidate.Outcome = "no route to the pickup"
+			} else {
+				candidate.Outcome = fmt.Sprintf("beyond the max pickup distance of %d", maxDistance)
+			}
+		case id == winner:
+			candidate.Outcome = AuditAssigned
+		case winner == 0 || candidate.total > winnerScore:
+			// Scored better (or nothing was assigned) yet lost: someone else reserved it in between
+			candidate.Outcome = AuditTakenFirst
+		default:
+			candidate.Outcome = AuditLowerScore
+		}
+
+		switch candidate.Outcome {
+		case AuditAssigned, AuditLowerScore, AuditTakenFirst:
+			decision.Candidates = append(decision.Candidates, *candidate)
+		default:
+			decision.Rejected[candidate.Outcome]++
+			rejections = append(rejections, *candidate)
+		}
+	}
+
+	// Ties go to the lowest ID so the order does not depend on map iteration
+	sort.Slice(decision.Candidates, func(i, j int) bool {
+		a, b := decision.Candidates[i], decision.Candidates[j]
+		if a.total != b.total {
+			return a.total > b.total
+		}
+		return a.TaxiID < b.TaxiID
+	})
+	if len(decision.Candidates) > auditCandidates {
+		decision.Candidates = decision.Candidates[:auditCandidates]
+	}
+	// Nearest by straight line, so a large fleet costs no routing for the ones left out
+	straight := taxi.NewLocationService()
+	sort.Slice(rejections, func(i, j int) bool {
+		a := straight.CalculateDistance(rejections[i].Location, ride.StartLocation)
+		b := straight.CalculateDistance(rejections[j].Location, ride.StartLocation)
+		if a != b {
+			return a < b
+		}
+		return rejections[i].TaxiID < rejections[j].TaxiID
+	})
+	if len(rejections) > auditRejections {
+		rejections = rejections[:auditRejections]
+	}
+	for i := range rejections {
+		if rejections[i].Distance == taxi.Unreachable {
+			rejections[i].Distance = router.CalculateDistance(rejections[i].Location, ride.StartLocation)
+		}
+	}
+	decision.Rejections = rejections
+	return decision
+}
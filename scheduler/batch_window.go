@@ -0,0 +1,89 @@
+// batch_window.go - Assignment batching window
+// Optionally lets each dispatch lane collect requests for a short window and match
+// them to taxis together, instead of greedily assigning each ride as it arrives
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// maxBatchSize is the most requests a lane matches together; more stay queued for
+// the next batch. It bounds the matching, whose cost grows with the cube of the batch.
+const maxBatchSize = laneBufferSize
+
+// SetBatchWindow turns batching on (window > 0) or off (0). When on, a lane that gets a
+// request waits window (simulated time) for more, then takes every request queued in
+// it (up to 150) and matches them to the available taxis together, for the least total
+// pickup distance (see TaxiAssigner.AssignBatch). Rides the matching leaves without a
+// taxi continue one by one, as without batching: look-ahead, best score, then pending.
+// A batch takes one dispatch slot of its lane.
+func (rs *RideScheduler) SetBatchWindow(window time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.batchWindow = window
+}
+
+// collectBatch waits window for more requests to join first in a lane, then returns
+// first and the requests queued in the lane by then, urgent ones first.
+func (rs *RideScheduler) collectBatch(lane *dispatchLane, first ride.RideRequest, window time.Duration) []ride.RideRequest {
+	rs.clock.Sleep(window)
+	batch := []ride.RideRequest{first}
+	for _, queue := range []chan ride.RideRequest{lane.urgent, lane.regular} {
+		for len(batch) < maxBatchSize {
+			select {
+			case request := <-queue:
+				batch = append(batch, request)
+				continue
+			default:
+			}
+			break
+		}
+	}
+	return batch
+}
+
+// processBatch handles the requests a lane collected in one batching window: round trip
+// return legs try their preferred taxi first, the other rides are matched together,
+// and rides still without a taxi go on like a single request.
+func (rs *RideScheduler) processBatch(requests []ride.RideRequest) {
+	if len(requests) == 1 {
+		rs.processRequest(requests[0])
+		return
+	}
+	fmt.Printf("[RideScheduler] Dispatching a batch of %d rides\n", len(requests))
+
+	var batch []ride.RideRequest
+	var rides []*ride.Ride
+	var excluded [][]int
+	for _, request := range requests {
+		ride, ok := rs.admit(request)
+		if !ok {
+			continue
+		}
+		if request.PreferredTaxiID != 0 {
+			if taxi := rs.assigner.AssignPreferredTaxi(ride, request.PreferredTaxiID); taxi != nil {
+				rs.dispatch(request, ride, taxi)
+				continue
+			}
+		}
+		batch = append(batch, request)
+		rides = append(rides, ride)
+		excluded = append(excluded, request.ExcludedTaxiIDs)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	for i, taxi := range rs.assigner.AssignBatch(rides, excluded) {
+		switch {
+		case taxi != nil:
+			rs.dispatch(batch[i], rides[i], taxi)
+		case rs.unassigned(rides[i]):
+			rs.assign(batch[i], rides[i], nil)
+		}
+	}
+}
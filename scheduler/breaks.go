@@ -0,0 +1,235 @@
+// breaks.go - Driver breaks and cooldowns
+// Lets a driver ask for a break: the taxi gets no new rides, finishes the one it has,
+// then goes ON_BREAK and becomes available again by itself when the break is over.
+// A fleet-wide cooldown likewise keeps every taxi out of dispatch for a while after
+// each drop-off, for the driver to rest or clean the car
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// BreakState is where a taxi's break stands.
+type BreakState string
+
+const (
+	BreakRequested BreakState = "REQUESTED"    // No new rides; the break starts when the current ride ends
+	OnBreak        BreakState = "ON_BREAK"     // Unavailable until the break ends
+	CoolingDown    BreakState = "COOLING_DOWN" // Just dropped a rider off; unavailable until the cooldown ends (see SetCooldown)
+)
+
+// TaxiBreak is a break a taxi's driver asked for.
+type TaxiBreak struct {
+	TaxiID      int           `json:"taxi_id"`
+	State       BreakState    `json:"state"`
+	Duration    taxi.Duration `json:"duration"`     // How long the taxi stays ON_BREAK
+	RequestedAt time.Time     `json:"requested_at"` // When the break was asked for
+	EndsAt      time.Time     `json:"ends_at"`      // When the taxi is available again (zero until ON_BREAK)
+}
+
+// TaxiBreaks keeps the breaks of the fleet's taxis, at most one per taxi, and the
+// cooldowns of taxis that just dropped a rider off.
+// The TaxiAssigner skips taxis with a requested break, and the RideScheduler calls
+// Release instead of making a taxi available when its ride ends, which starts the break.
+// A taxi can be cooling down and have a break requested at once; the break then starts
+// when the cooldown ends.
+// All methods are safe for concurrent access. TaxiBreaks never holds its lock while
+// calling the store, since the assigner asks Requested from inside store calls.
+type TaxiBreaks struct {
+	store    taxi.TaxiStorage     // For taking taxis out of dispatch and back
+	clock    taxi.Clock           // For break timing
+	mu       sync.RWMutex         // Protects breaks, cooldown and cooling
+	breaks   map[int]*TaxiBreak   // Taxi ID -> its break
+	cooldown time.Duration        // How long taxis rest after a drop-off (0 = not at all)
+	cooling  map[int]*coolingTaxi // Taxi ID -> its running cooldown
+}
+
+// coolingTaxi is a running cooldown and what happens to the taxi when it ends.
+type coolingTaxi struct {
+	brk  TaxiBreak
+	then func() // Called once the cooldown is over (or ended early)
+}
+
+// NewTaxiBreaks creates an empty set of breaks for the taxis of store, without cooldown.
+func NewTaxiBreaks(store taxi.TaxiStorage, clock taxi.Clock) *TaxiBreaks {
+	return &TaxiBreaks{store: store, clock: clock, breaks: make(map[int]*TaxiBreak), cooling: make(map[int]*coolingTaxi)}
+}
+
+// SetCooldown keeps every taxi unavailable for cooldown (simulated time) after each
+// drop-off; pass 0 to turn it off. Cooldowns already running keep their length.
+func (tb *TaxiBreaks) SetCooldown(cooldown time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.cooldown = cooldown
+}
+
+// Cooldown returns how long taxis rest after a drop-off.
+func (tb *TaxiBreaks) Cooldown() time.Duration {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return tb.cooldown
+}
+
+// coolDown keeps a reserved taxi that just dropped a rider off out of dispatch for
+// the cooldown, then calls then. Returns false, doing nothing, if there is no cooldown.
+func (tb *TaxiBreaks) coolDown(taxiID int, then func()) bool {
+	tb.mu.Lock()
+	cooldown := tb.cooldown
+	if cooldown <= 0 {
+		tb.mu.Unlock()
+		return false
+	}
+	now := tb.clock.Now()
+	cooling := &coolingTaxi{
+		brk:  TaxiBreak{TaxiID: taxiID, State: CoolingDown, Duration: taxi.Duration{Duration: cooldown}, RequestedAt: now, EndsAt: now.Add(cooldown)},
+		then: then,
+	}
+	tb.cooling[taxiID] = cooling
+	tb.mu.Unlock()
+
+	tb.clock.AfterFunc(cooldown, func() {
+		tb.cooledDown(taxiID, cooling)
+	})
+	return true
+}
+
+// cooledDown ends a cooldown that is still running and calls its then.
+// Does nothing if that cooldown was already ended early (see End).
+func (tb *TaxiBreaks) cooledDown(taxiID int, cooling *coolingTaxi) {
+	tb.mu.Lock()
+	if tb.cooling[taxiID] != cooling {
+		tb.mu.Unlock()
+		return
+	}
+	delete(tb.cooling, taxiID)
+	tb.mu.Unlock()
+	cooling.then()
+}
+
+// Request asks for a break of the given duration for a taxi. An available taxi goes
+// ON_BREAK straight away; a busy one once its ride ends (see Release).
+// Returns the break, or false if the taxi already has one.
+func (tb *TaxiBreaks) Request(taxiID int, duration time.Duration) (TaxiBreak, bool) {
+	tb.mu.Lock()
+	if _, exists := tb.breaks[taxiID]; exists {
+		tb.mu.Unlock()
+		return TaxiBreak{}, false
+	}
+	brk := &TaxiBreak{TaxiID: taxiID, State: BreakRequested, Duration: taxi.Duration{Duration: duration}, RequestedAt: tb.clock.Now()}
+	tb.breaks[taxiID] = brk
+	tb.mu.Unlock()
+
+	// Registered first, so a ride ending from here on starts the break in Release;
+	// a taxi that is free already never gets to Release and is reserved here instead
+	if _, reserved := tb.store.Reserve(taxiID, func(taxi.Taxi) bool { return true }); reserved {
+		tb.begin(taxiID)
+	}
+
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	return *brk, true
+}
+
+// Requested reports whether a taxi has a break that has not started yet.
+func (tb *TaxiBreaks) Requested(taxiID int) bool {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+	brk, exists := tb.breaks[taxiID]
+	return exists && brk.State == BreakRequested
+}
+
+// Release frees a reserved taxi whose ride ended or fell through: it starts the taxi's
+// requested break, or otherwise makes the taxi available.
+// Returns false if the taxi was not found.
+func (tb *TaxiBreaks) Release(taxiID int) bool {
+	if tb.begin(taxiID) {
+		return true
+	}
+	return tb.store.SetAvailability(taxiID, true)
+}
+
+// begin starts a requested break of a taxi that is reserved (unavailable) and has no ride.
+// Returns false if the taxi has no requested break.
+func (tb *TaxiBreaks) begin(taxiID int) bool {
+	tb.mu.Lock()
+	brk, exists := tb.breaks[taxiID]
+	if !exists || brk.State != BreakRequested {
+		tb.mu.Unlock()
+		return false
+	}
+	brk.State = OnBreak
+	brk.EndsAt = tb.clock.Now().Add(brk.Duration.Duration)
+	duration := brk.Duration.Duration
+	tb.mu.Unlock()
+
+	fmt.Printf("[TaxiBreaks] Taxi #%d ON_BREAK for %v\n", taxiID, duration)
+	tb.clock.AfterFunc(duration, func() {
+		tb.finish(taxiID, brk)
+	})
+	return true
+}
+
+// finish ends a break that is still running: the taxi becomes available again.
+// Does nothing if that break was already ended early (see End).
+func (tb *TaxiBreaks) finish(taxiID int, brk *TaxiBreak) {
+	tb.mu.Lock()
+	if tb.breaks[taxiID] != brk {
+		tb.mu.Unlock()
+		return
+	}
+	delete(tb.breaks, taxiID)
+	tb.mu.Unlock()
+
+	if !tb.store.SetAvailability(taxiID, true) {
+		fmt.Printf("[TaxiBreaks] Taxi #%d left the fleet during its break\n", taxiID)
+		return
+	}
+	fmt.Printf("[TaxiBreaks] Taxi #%d back from break and available\n", taxiID)
+}
+
+// End cancels a taxi's requested break, or ends a running one now. A taxi with no
+// break has its cooldown ended now instead, if it is cooling down.
+// Returns false if the taxi has neither.
+func (tb *TaxiBreaks) End(taxiID int) bool {
+	tb.mu.Lock()
+	brk, exists := tb.breaks[taxiID]
+	if !exists {
+		cooling, isCooling := tb.cooling[taxiID]
+		delete(tb.cooling, taxiID)
+		tb.mu.Unlock()
+		if isCooling {
+			cooling.then()
+		}
+		return isCooling
+	}
+	delete(tb.breaks, taxiID)
+	tb.mu.Unlock()
+
+	if brk.State == OnBreak {
+		tb.store.SetAvailability(taxiID, true)
+	}
+	return true
+}
+
+// GetAll returns every requested and running break and every running cooldown,
+// ordered by taxi ID (a taxi's cooldown before its break).
+func (tb *TaxiBreaks) GetAll() []TaxiBreak {
+	tb.mu.RLock()
+	defer tb.mu.RUnlock()
+
+	breaks := make([]TaxiBreak, 0, len(tb.cooling)+len(tb.breaks))
+	for _, cooling := range tb.cooling {
+		breaks = append(breaks, cooling.brk)
+	}
+	for _, brk := range tb.breaks {
+		breaks = append(breaks, *brk)
+	}
+	sort.SliceStable(breaks, func(i, j int) bool { return breaks[i].TaxiID < breaks[j].TaxiID })
+	return breaks
+}
@@ -0,0 +1,108 @@
+// chaos.go - Fault injection for simulations
+// Randomly injects failures so retry, timeout and reassignment logic can be exercised
+
+package scheduler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FaultConfig holds the probabilities used by the FaultInjector.
+// Each probability is between 0 (never) and 1 (always). The zero value disables all faults.
+type FaultConfig struct {
+	BreakdownProbability       float64       // Chance a taxi breaks down during a ride
+	HeartbeatDropProbability   float64       // Chance a taxi location update is lost
+	AssignmentDelayProbability float64       // Chance an assignment is delayed
+	MaxAssignmentDelay         time.Duration // Upper bound for an injected assignment delay
+	NoShowProbability          float64       // Chance a passenger is not at the pickup when the taxi arrives
+}
+
+// FaultInjector decides, at random, when to inject a fault.
+// All methods are safe for concurrent access.
+type FaultInjector struct {
+	mu     sync.RWMutex // Protects config
+	config FaultConfig  // Current fault probabilities
+	rngMu  sync.Mutex   // Protects rng (rand.Rand is not safe for concurrent use)
+	rng    *rand.Rand   // Source of every decision, so a seed replays the same faults
+}
+
+// NewFaultInjector creates a FaultInjector with all faults disabled.
+// The same seed gives the same sequence of faults (0 = random seed).
+func NewFaultInjector(seed int64) *FaultInjector {
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return &FaultInjector{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Configure replaces the fault probabilities. Takes effect immediately.
+func (fi *FaultInjector) Configure(config FaultConfig) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.config = config
+}
+
+// BreakdownPoint decides whether a taxi breaks down during a ride of the given duration.
+// Returns how far into the ride the breakdown happens, or false if the ride completes normally.
+func (fi *FaultInjector) BreakdownPoint(duration time.Duration) (time.Duration, bool) {
+	fi.mu.RLock()
+	probability := fi.config.BreakdownProbability
+	fi.mu.RUnlock()
+
+	if duration <= 0 || !fi.roll(probability) {
+		return 0, false
+	}
+	return time.Duration(fi.int63n(int64(duration))), true
+}
+
+// ShouldDropHeartbeat decides whether a taxi location update is lost.
+func (fi *FaultInjector) ShouldDropHeartbeat() bool {
+	fi.mu.RLock()
+	probability := fi.config.HeartbeatDropProbability
+	fi.mu.RUnlock()
+
+	return fi.roll(probability)
+}
+
+// AssignmentDelay returns how long to delay the next assignment (0 for no delay).
+func (fi *FaultInjector) AssignmentDelay() time.Duration {
+	fi.mu.RLock()
+	probability := fi.config.AssignmentDelayProbability
+	maxDelay := fi.config.MaxAssignmentDelay
+	fi.mu.RUnlock()
+
+	if maxDelay <= 0 || !fi.roll(probability) {
+		return 0
+	}
+	return time.Duration(fi.int63n(int64(maxDelay)))
+}
+
+// PassengerNoShow decides whether the passenger of a ride fails to turn up at the pickup.
+func (fi *FaultInjector) PassengerNoShow() bool {
+	fi.mu.RLock()
+	probability := fi.config.NoShowProbability
+	fi.mu.RUnlock()
+
+	return fi.roll(probability)
+}
+
+// roll returns true with the given probability.
+// A zero probability does not consume a random number, so enabling one kind
+// of fault does not shift the others.
+func (fi *FaultInjector) roll(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	fi.rngMu.Lock()
+	defer fi.rngMu.Unlock()
+	return fi.rng.Float64() < probability
+}
+
+// int63n returns a random number in [0, n).
+func (fi *FaultInjector) int63n(n int64) int64 {
+	fi.rngMu.Lock()
+	defer fi.rngMu.Unlock()
+	return fi.rng.Int63n(n)
+}
@@ -0,0 +1,107 @@
+// dead_letter.go - Dead-letter queue for unassignable rides
+// Keeps the rides the dispatcher gave up on (expired, or out of attempts) so an
+// operator can look at them and send them back to the queue
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// Reasons a ride ends up in the dead-letter queue.
+const (
+	DeadLetterExpired  = "expired"           // No taxi was assigned before the ride's deadline
+	DeadLetterAttempts = "attempts exceeded" // No eligible taxi in the last SetMaxAttempts dispatch attempts
+)
+
+// DeadLetter is a ride the dispatcher gave up on.
+type DeadLetter struct {
+	RideID   int              `json:"ride_id"`
+	Reason   string           `json:"reason"`   // DeadLetterExpired or DeadLetterAttempts
+	Attempts int              `json:"attempts"` // Dispatch attempts that found no taxi
+	At       time.Time        `json:"at"`       // When the ride was dead-lettered
+	request  ride.RideRequest // Request to queue again on Requeue
+}
+
+// SetMaxAttempts caps how many times the dispatcher tries a ride without finding
+// an eligible taxi before moving it to the dead-letter queue (0 = retry forever).
+// Every fleet change that retries the pending rides counts as an attempt.
+func (rs *RideScheduler) SetMaxAttempts(attempts int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.maxAttempts = attempts
+}
+
+// deadLetter stops dispatching a ride and keeps it for an operator.
+// A ride already in the queue is replaced, e.g. when it expires there.
+func (rs *RideScheduler) deadLetter(request ride.RideRequest, r *ride.Ride, reason string) {
+	rs.mu.Lock()
+	rs.deadLetters[r.ID] = DeadLetter{
+		RideID:   r.ID,
+		Reason:   reason,
+		Attempts: request.Attempts,
+		At:       rs.clock.Now(),
+		request:  request,
+	}
+	rs.mu.Unlock()
+
+	fmt.Printf("[RideScheduler] %sRide #%d moved to the dead-letter queue (%s after %d attempts)\n", ride.TraceTag(r.TraceID),
+		r.ID, reason, request.Attempts)
+}
+
+// DeadLetters returns the rides in the dead-letter queue, ordered by ride ID.
+func (rs *RideScheduler) DeadLetters() []DeadLetter {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	letters := make([]DeadLetter, 0, len(rs.deadLetters))
+	for _, letter := range rs.deadLetters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].RideID < letters[j].RideID })
+	return letters
+}
+
+// Requeue sends a dead-lettered ride back to the dispatcher, ahead of new requests,
+// with a fresh count of attempts and without its deadline. An expired ride is
+// CREATED again.
+// Returns an error if the ride is not in the dead-letter queue.
+func (rs *RideScheduler) Requeue(rideID int) error {
+	rs.mu.Lock()
+	letter, exists := rs.deadLetters[rideID]
+	delete(rs.deadLetters, rideID)
+	rs.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("ride #%d is not in the dead-letter queue", rideID)
+	}
+	r := rs.rides.Get(rideID)
+	if r == nil {
+		return fmt.Errorf("ride #%d not found", rideID)
+	}
+
+	if !r.SetStatus(ride.CREATED, rs.clock.Now(), ride.EXPIRED, ride.CREATED) {
+		// Assigned by hand in the meantime; nothing left to do
+		return fmt.Errorf("ride #%d is no longer waiting for a taxi", rideID)
+	}
+
+	rs.events.Publish(ride.RideRequeued, r, 0)
+	fmt.Printf("[RideScheduler] %sRide #%d requeued from the dead-letter queue\n", ride.TraceTag(r.TraceID), rideID)
+
+	request := letter.request
+	request.Attempts = 0
+	request.ExpiresAt = time.Time{} // The operator took over; the original deadline has passed or no longer matters
+	request.EnqueuedAt = rs.clock.Now()
+	rs.requeue(request)
+	return nil
+}
+
+// forgetDeadLetter drops a ride from the dead-letter queue, e.g. once it is assigned by hand.
+func (rs *RideScheduler) forgetDeadLetter(rideID int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	delete(rs.deadLetters, rideID)
+}
@@ -0,0 +1,116 @@
+// executor.go - Ride execution
+// Decides when a started ride has been driven, so the scheduler never waits on the
+// clock itself and tests can finish rides on demand
+
+package scheduler
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RideExecutor carries out the driving of rides the RideScheduler has started.
+// Implementations must be safe for concurrent use.
+type RideExecutor interface {
+	// Drive calls arrive once ride has been driven for duration (simulated time).
+	// It must not block: arrive runs later, on a goroutine of the executor's choosing.
+	Drive(ride *ride.Ride, duration time.Duration, arrive func())
+}
+
+// ClockExecutor drives every ride for its duration on a Clock, stretched by a time scale.
+type ClockExecutor struct {
+	clock taxi.Clock // For waiting out the drives
+	scale float64    // Drives take duration * scale
+}
+
+// NewClockExecutor creates an executor whose drives wait duration * scale on clock,
+// e.g. a scale of 0.5 finishes rides in half their drive time (1 if not positive).
+// The scale only changes how long rides take: ETAs and pre-assignment still expect
+// the unscaled drive time.
+func NewClockExecutor(clock taxi.Clock, scale float64) *ClockExecutor {
+	if scale <= 0 {
+		scale = 1
+	}
+	return &ClockExecutor{clock: clock, scale: scale}
+}
+
+// Drive waits out the scaled duration in a new goroutine, then calls arrive.
+func (ce *ClockExecutor) Drive(r *ride.Ride, duration time.Duration, arrive func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[ClockExecutor] %sERROR: Panic while ending ride #%d: %v\n", ride.TraceTag(r.TraceID), r.ID, err)
+			}
+		}()
+		ce.clock.Sleep(time.Duration(float64(duration) * ce.scale))
+		arrive()
+	}()
+}
+
+// ManualExecutor never finishes a drive by itself: rides stay where they are until
+// Complete or CompleteAll, like a ManualClock that never advances. Meant for tests
+// and checks that want to end rides at exact moments.
+// All methods are safe for concurrent access.
+type ManualExecutor struct {
+	mu     sync.Mutex     // Protects drives
+	drives map[int]func() // Ride ID -> arrive of the drive under way
+}
+
+// NewManualExecutor creates an executor with no drives under way.
+func NewManualExecutor() *ManualExecutor {
+	return &ManualExecutor{drives: make(map[int]func())}
+}
+
+// Drive holds the drive until Complete or CompleteAll. A ride driven again (e.g. after
+// a hand-off) replaces its earlier drive.
+func (me *ManualExecutor) Drive(ride *ride.Ride, duration time.Duration, arrive func()) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.drives[ride.ID] = arrive
+}
+
+// Pending returns the IDs of the rides being driven, lowest first.
+func (me *ManualExecutor) Pending() []int {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+
+	ids := make([]int, 0, len(me.drives))
+	for id := range me.drives {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Complete ends a ride's drive now, on the calling goroutine.
+// Returns false if the ride is not being driven.
+func (me *ManualExecutor) Complete(rideID int) bool {
+	me.mu.Lock()
+	arrive, exists := me.drives[rideID]
+	delete(me.drives, rideID)
+	me.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	arrive()
+	return true
+}
+
+// CompleteAll ends every drive under way now, lowest ride ID first. Drives started
+// while doing so (e.g. a pre-assigned ride taking over the taxi) are left for later.
+// Returns how many drives were ended.
+func (me *ManualExecutor) CompleteAll() int {
+	completed := 0
+	for _, id := range me.Pending() {
+		if me.Complete(id) {
+			completed++
+		}
+	}
+	return completed
+}
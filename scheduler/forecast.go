@@ -0,0 +1,196 @@
+// forecast.go - Demand forecasting
+// Learns how many rides start in each area per tick of simulated time and predicts the
+// next ticks, so idle taxis can be sent where demand is heading rather than where it was
+
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// ForecastConfig sets up a DemandForecaster.
+type ForecastConfig struct {
+	CellSize int           // Width and height of a zone in grid units, like the DemandHeatmap's cells
+	Tick     time.Duration // Length of the periods demand is counted and predicted in (simulated time)
+	Alpha    float64       // Weight of the latest tick in the smoothed demand, 0 < alpha <= 1
+	Beta     float64       // Weight of the latest change in the smoothed trend, 0 <= beta <= 1 (0 = no trend)
+	Horizon  int           // Ticks ahead counted when the forecast picks hotspots for repositioning
+}
+
+// DefaultForecastConfig returns a forecast over the heatmap's 10x10 cells, one-minute
+// ticks, looking five ticks ahead.
+func DefaultForecastConfig() ForecastConfig {
+	return ForecastConfig{CellSize: 10, Tick: time.Minute, Alpha: 0.5, Beta: 0.2, Horizon: 5}
+}
+
+// validate checks that a forecast can be made with the config.
+func (config ForecastConfig) validate() error {
+	switch {
+	case config.CellSize < 1:
+		return fmt.Errorf("forecast cell size must be at least 1, got %d", config.CellSize)
+	case config.Tick <= 0:
+		return fmt.Errorf("forecast tick must be positive, got %v", config.Tick)
+	case config.Alpha <= 0 || config.Alpha > 1:
+		return fmt.Errorf("forecast alpha must be in (0, 1], got %v", config.Alpha)
+	case config.Beta < 0 || config.Beta > 1:
+		return fmt.Errorf("forecast beta must be in [0, 1], got %v", config.Beta)
+	case config.Horizon < 1:
+		return fmt.Errorf("forecast horizon must be at least 1 tick, got %d", config.Horizon)
+	}
+	return nil
+}
+
+// ZoneForecast is the predicted demand of one zone.
+type ZoneForecast struct {
+	Zone   taxi.Zone `json:"zone"`   // Area of the zone (named "x,y" after its cell coordinates)
+	Demand []float64 `json:"demand"` // Rides expected to start in the zone in each of the next ticks
+	Total  float64   `json:"total"`  // Sum of Demand
+}
+
+// demandTrend is the smoothed demand of one zone.
+type demandTrend struct {
+	level float64 // Rides per tick
+	trend float64 // Change of level per tick
+}
+
+// DemandForecaster predicts ride demand per zone with double exponential smoothing
+// (Holt's method): after every tick each zone's level moves toward the rides counted in
+// it by Alpha, and its trend toward the change in level by Beta. The demand predicted k
+// ticks ahead is level + k*trend, never below zero.
+// Ticks are closed lazily when rides are observed or a forecast is asked for.
+// All methods are safe for concurrent access.
+type DemandForecaster struct {
+	config      ForecastConfig                 // Zone size, tick length and smoothing factors (fixed)
+	mu          sync.Mutex                     // Protects every field below
+	zones       map[taxi.Location]*demandTrend // Cell -> smoothed demand, for every cell that ever had a ride
+	counts      map[taxi.Location]int          // Cell -> rides observed in the open tick
+	tickStart   time.Time                      // When the open tick started (zero before the first ride)
+	ticksClosed int                            // Ticks the model has learned from
+	clock       taxi.Clock                     // For the time of live rides and forecasts
+}
+
+// NewDemandForecaster creates a forecaster that has seen no rides yet.
+// Returns an error if the config is invalid.
+func NewDemandForecaster(config ForecastConfig, clock taxi.Clock) (*DemandForecaster, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &DemandForecaster{config: config, zones: make(map[taxi.Location]*demandTrend), counts: make(map[taxi.Location]int), clock: clock}, nil
+}
+
+// Train learns from past ride starts, given as the rides' start locations and request
+// times. Starts before the ones already observed are ignored.
+func (df *DemandForecaster) Train(locations []taxi.Location, times []time.Time) {
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].Before(times[order[j]]) })
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	for _, i := range order {
+		df.observe(locations[i], times[i])
+	}
+}
+
+// Record counts a ride starting at location now.
+func (df *DemandForecaster) Record(location taxi.Location) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	df.observe(location, df.clock.Now())
+}
+
+// observe counts a ride start in the tick it falls in. Must be called with df.mu held.
+func (df *DemandForecaster) observe(location taxi.Location, at time.Time) {
+	if df.tickStart.IsZero() {
+		df.tickStart = at
+	}
+	df.closeTicks(at)
+	if at.Before(df.tickStart) {
+		return
+	}
+	df.counts[taxi.GridCell(location, df.config.CellSize)]++
+}
+
+// closeTicks folds every tick that ended by now into the model, empty ticks too.
+// Must be called with df.mu held.
+func (df *DemandForecaster) closeTicks(now time.Time) {
+	if df.tickStart.IsZero() {
+		return
+	}
+	for !now.Before(df.tickStart.Add(df.config.Tick)) {
+		for cell, zone := range df.zones {
+			observed := float64(df.counts[cell])
+			level := df.config.Alpha*observed + (1-df.config.Alpha)*(zone.level+zone.trend)
+			zone.trend = df.config.Beta*(level-zone.level) + (1-df.config.Beta)*zone.trend
+			zone.level = level
+		}
+		for cell, count := range df.counts {
+			if df.zones[cell] == nil {
+				df.zones[cell] = &demandTrend{level: float64(count)} // A zone's first tick sets its level, with no trend yet
+			}
+		}
+		clear(df.counts)
+		df.tickStart = df.tickStart.Add(df.config.Tick)
+		df.ticksClosed++
+	}
+}
+
+// Forecast returns the demand predicted for each of the next ticks, for every zone
+// expected to have any, busiest first. Ties are broken by zone position so the order
+// is stable.
+func (df *DemandForecaster) Forecast(ticks int) []ZoneForecast {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	df.closeTicks(df.clock.Now())
+
+	forecasts := make([]ZoneForecast, 0, len(df.zones))
+	for cell, zone := range df.zones {
+		forecast := ZoneForecast{Zone: demandZone(cell, df.config.CellSize), Demand: make([]float64, ticks)}
+		for k := range forecast.Demand {
+			forecast.Demand[k] = math.Max(0, zone.level+float64(k+1)*zone.trend)
+			forecast.Total += forecast.Demand[k]
+		}
+		if forecast.Total > 0 {
+			forecasts = append(forecasts, forecast)
+		}
+	}
+	sort.Slice(forecasts, func(i, j int) bool {
+		if forecasts[i].Total != forecasts[j].Total {
+			return forecasts[i].Total > forecasts[j].Total
+		}
+		return taxi.CellBefore(forecasts[i].Zone.Min, forecasts[j].Zone.Min)
+	})
+	return forecasts
+}
+
+// Hotspots returns up to n zones with the most rides predicted over the horizon,
+// busiest first, counting the predicted rides rounded to whole ones. Zones expected to
+// see less than half a ride are left out. It lets the forecast stand in for the
+// DemandHeatmap as a RepositioningAdvisor's DemandSource.
+func (df *DemandForecaster) Hotspots(n int) []Hotspot {
+	hotspots := make([]Hotspot, 0, n)
+	for _, forecast := range df.Forecast(df.config.Horizon) {
+		count := int(math.Round(forecast.Total))
+		if len(hotspots) == n || count == 0 {
+			break
+		}
+		hotspots = append(hotspots, Hotspot{Zone: forecast.Zone, Count: count})
+	}
+	return hotspots
+}
+
+// String describes the forecaster's settings and how much it has learned.
+func (df *DemandForecaster) String() string {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	return fmt.Sprintf("%dx%d zones, %v ticks, alpha %.2f, beta %.2f, %d-tick horizon, trained on %d ticks",
+		df.config.CellSize, df.config.CellSize, df.config.Tick, df.config.Alpha, df.config.Beta, df.config.Horizon, df.ticksClosed)
+}
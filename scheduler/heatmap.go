@@ -0,0 +1,132 @@
+// heatmap.go - Ride demand heatmap
+// Counts recent ride start locations per grid cell to show where demand is
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Hotspot is one heatmap cell and how many rides started in it recently.
+type Hotspot struct {
+	Zone  taxi.Zone // Area covered by the cell (named "x,y" after its cell coordinates)
+	Count int       // Rides requested from inside the cell within the window
+}
+
+// Center returns the middle of the hotspot's cell.
+func (h Hotspot) Center() taxi.Location {
+	return taxi.Location{
+		X: (h.Zone.Min.X + h.Zone.Max.X) / 2,
+		Y: (h.Zone.Min.Y + h.Zone.Max.Y) / 2,
+	}
+}
+
+// demandSample is one recorded ride start.
+type demandSample struct {
+	location taxi.Location // Where the ride starts
+	at       time.Time     // When it was requested
+}
+
+// DemandHeatmap aggregates ride start locations into square cells over a sliding window.
+// Samples older than the window are forgotten, so the map follows demand as it moves.
+// All methods are safe for concurrent access.
+type DemandHeatmap struct {
+	mu       sync.Mutex     // Protects samples
+	cellSize int            // Width and height of a cell in grid units
+	window   time.Duration  // How far back samples count (simulated time)
+	clock    taxi.Clock     // For timestamps and expiry
+	samples  []demandSample // Recorded ride starts, oldest first
+}
+
+// NewDemandHeatmap creates an empty heatmap with cells of cellSize x cellSize units
+// that counts ride starts from the last window of simulated time.
+func NewDemandHeatmap(cellSize int, window time.Duration, clock taxi.Clock) *DemandHeatmap {
+	if cellSize < 1 {
+		cellSize = 1
+	}
+	return &DemandHeatmap{
+		cellSize: cellSize,
+		window:   window,
+		clock:    clock,
+	}
+}
+
+// Record adds a ride start location to the heatmap.
+func (dh *DemandHeatmap) Record(location taxi.Location) {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+
+	dh.samples = append(dh.samples, demandSample{location: location, at: dh.clock.Now()})
+	dh.expire()
+}
+
+// Hotspots returns up to n cells with the most recent demand, busiest first.
+// Ties are broken by cell position so the order is stable. Empty cells are never returned.
+func (dh *DemandHeatmap) Hotspots(n int) []Hotspot {
+	dh.mu.Lock()
+	dh.expire()
+	counts := make(map[taxi.Location]int)
+	for _, sample := range dh.samples {
+		counts[dh.cellOf(sample.location)]++
+	}
+	dh.mu.Unlock()
+
+	cells := make([]taxi.Location, 0, len(counts))
+	for cell := range counts {
+		cells = append(cells, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if counts[cells[i]] != counts[cells[j]] {
+			return counts[cells[i]] > counts[cells[j]]
+		}
+		if cells[i].X != cells[j].X {
+			return cells[i].X < cells[j].X
+		}
+		return cells[i].Y < cells[j].Y
+	})
+
+	if n < len(cells) {
+		cells = cells[:n]
+	}
+	hotspots := make([]Hotspot, 0, len(cells))
+	for _, cell := range cells {
+		hotspots = append(hotspots, Hotspot{Zone: dh.zoneOf(cell), Count: counts[cell]})
+	}
+	return hotspots
+}
+
+// cellOf returns the cell coordinates containing a location.
+func (dh *DemandHeatmap) cellOf(location taxi.Location) taxi.Location {
+	return taxi.GridCell(location, dh.cellSize)
+}
+
+// zoneOf returns the grid area covered by a cell.
+func (dh *DemandHeatmap) zoneOf(cell taxi.Location) taxi.Zone {
+	return demandZone(cell, dh.cellSize)
+}
+
+// demandZone returns the grid area covered by a cell, named "x,y" after its coordinates.
+func demandZone(cell taxi.Location, cellSize int) taxi.Zone {
+	corner := taxi.Location{X: cell.X * cellSize, Y: cell.Y * cellSize}
+	return taxi.Zone{
+		Name: fmt.Sprintf("%d,%d", cell.X, cell.Y),
+		Min:  corner,
+		Max:  taxi.Location{X: corner.X + cellSize - 1, Y: corner.Y + cellSize - 1},
+	}
+}
+
+// expire drops samples that have fallen out of the window.
+// Must be called with dh.mu held.
+func (dh *DemandHeatmap) expire() {
+	cutoff := dh.clock.Now().Add(-dh.window)
+	expired := 0
+	for expired < len(dh.samples) && dh.samples[expired].at.Before(cutoff) {
+		expired++
+	}
+	dh.samples = dh.samples[expired:]
+}
@@ -0,0 +1,116 @@
+// holds.go - Taxi reservation holds
+// Lets an operator keep a taxi for one client (e.g. a VIP) during a time window:
+// nobody else gets the taxi then, and the holder's rides get it first
+
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiHold keeps a taxi for one client from From until Until.
+type TaxiHold struct {
+	ID       int       `json:"id"`
+	TaxiID   int       `json:"taxi_id"`
+	ClientID int       `json:"client_id"`
+	From     time.Time `json:"from"`
+	Until    time.Time `json:"until"`
+}
+
+// activeAt reports whether the hold is in force at a given time.
+func (hold TaxiHold) activeAt(at time.Time) bool {
+	return !at.Before(hold.From) && at.Before(hold.Until)
+}
+
+// TaxiHolds keeps the holds placed on taxis. Expired holds are dropped as they are found.
+// All methods are safe for concurrent access.
+type TaxiHolds struct {
+	mu     sync.RWMutex     // Protects holds and nextID
+	holds  map[int]TaxiHold // Hold ID -> hold
+	nextID int              // ID of the next hold placed
+	clock  taxi.Clock       // For dropping expired holds
+}
+
+// NewTaxiHolds creates an empty set of holds.
+func NewTaxiHolds(clock taxi.Clock) *TaxiHolds {
+	return &TaxiHolds{holds: make(map[int]TaxiHold), nextID: 1, clock: clock}
+}
+
+// Add places a hold and returns it with its ID set.
+func (th *TaxiHolds) Add(hold TaxiHold) TaxiHold {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	hold.ID = th.nextID
+	th.nextID++
+	th.holds[hold.ID] = hold
+	return hold
+}
+
+// Remove lifts a hold. Returns false if it was not found.
+func (th *TaxiHolds) Remove(id int) bool {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if _, exists := th.holds[id]; !exists {
+		return false
+	}
+	delete(th.holds, id)
+	return true
+}
+
+// GetAll returns the holds that have not expired yet, ordered by ID.
+func (th *TaxiHolds) GetAll() []TaxiHold {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	now := th.clock.Now()
+	holds := make([]TaxiHold, 0, len(th.holds))
+	for id, hold := range th.holds {
+		if !now.Before(hold.Until) {
+			delete(th.holds, id)
+			continue
+		}
+		holds = append(holds, hold)
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ID < holds[j].ID })
+	return holds
+}
+
+// HeldFor returns the client a taxi is held for at a given time, or false if it is not held.
+// If holds overlap, the earliest placed wins.
+func (th *TaxiHolds) HeldFor(taxiID int, at time.Time) (int, bool) {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+
+	clientID, holdID := 0, 0
+	for _, hold := range th.holds {
+		if hold.TaxiID == taxiID && hold.activeAt(at) && (holdID == 0 || hold.ID < holdID) {
+			clientID, holdID = hold.ClientID, hold.ID
+		}
+	}
+	return clientID, holdID != 0
+}
+
+// TaxisFor returns the taxis held for a client at a given time, earliest placed hold first.
+func (th *TaxiHolds) TaxisFor(clientID int, at time.Time) []int {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+
+	var holds []TaxiHold
+	for _, hold := range th.holds {
+		if hold.ClientID == clientID && hold.activeAt(at) {
+			holds = append(holds, hold)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ID < holds[j].ID })
+	taxiIDs := make([]int, 0, len(holds))
+	for _, hold := range holds {
+		taxiIDs = append(taxiIDs, hold.TaxiID)
+	}
+	return taxiIDs
+}
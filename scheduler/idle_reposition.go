@@ -0,0 +1,177 @@
+// idle_reposition.go - Idle timeout repositioning
+// Sends taxis that have waited too long for a ride back to their home zone, or
+// toward the nearest demand hotspot, driving them there one cell at a time
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// idleCheckInterval is how often the IdleRepositioner looks for taxis idle too long (simulated time).
+const idleCheckInterval = 5 * time.Second
+
+// idleHotspots is how many of the busiest hotspots an idle taxi without a home zone may head for.
+const idleHotspots = 5
+
+// IdleRepositioner moves taxis that have been available longer than a timeout.
+// A taxi with a home zone drives back into it; any other taxi drives to the nearest
+// of the busiest demand hotspots. Taxis already there stay put, and a taxi that has
+// just been moved waits another full timeout before it is moved again.
+// Every step goes through TaxiStorage.MoveIfAvailable, so subscribers see each
+// location change and a taxi that gets a ride on the way stops right where it is.
+// All methods are safe for concurrent access.
+type IdleRepositioner struct {
+	store           taxi.TaxiStorage  // For idle taxis and moving them
+	heatmap         *DemandHeatmap    // Where demand is
+	locationService taxi.Router       // For routes and distances
+	travelTime      TravelTimeModel   // How long each step of a drive takes
+	clock           taxi.Clock        // For idle times, the check ticker and driving
+	mu              sync.Mutex        // Protects homes, driving, movedAt and running
+	homes           map[int]taxi.Zone // Taxi ID -> zone the taxi returns to when idle
+	driving         map[int]bool      // Taxis currently on their way, so they are not sent twice
+	movedAt         map[int]time.Time // Taxi ID -> when its last repositioning drive ended
+	running         bool              // True once Start has been called
+}
+
+// NewIdleRepositioner creates a repositioner with the given dependencies.
+func NewIdleRepositioner(store taxi.TaxiStorage, heatmap *DemandHeatmap, locationService taxi.Router, travelTime TravelTimeModel, clock taxi.Clock) *IdleRepositioner {
+	return &IdleRepositioner{
+		store:           store,
+		heatmap:         heatmap,
+		locationService: locationService,
+		travelTime:      travelTime,
+		clock:           clock,
+		homes:           make(map[int]taxi.Zone),
+		driving:         make(map[int]bool),
+		movedAt:         make(map[int]time.Time),
+	}
+}
+
+// SetHome gives a taxi a zone to return to when idle, replacing any previous one.
+func (ir *IdleRepositioner) SetHome(taxiID int, zone taxi.Zone) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	ir.homes[taxiID] = zone
+}
+
+// Start checks for taxis idle longer than timeout every idleCheckInterval, forever.
+// Returns false if the repositioner is already running.
+func (ir *IdleRepositioner) Start(timeout time.Duration) bool {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	if ir.running {
+		return false
+	}
+	ir.running = true
+
+	go func() {
+		ticker := ir.clock.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ir.check(timeout)
+		}
+	}()
+	return true
+}
+
+// check sends every taxi idle longer than timeout on its way, if it is not where it should be.
+func (ir *IdleRepositioner) check(timeout time.Duration) {
+	hotspots := ir.heatmap.Hotspots(idleHotspots)
+	for _, t := range ir.store.GetAllAvailable() {
+		ir.mu.Lock()
+		home, hasHome := ir.homes[t.ID]
+		busy := ir.driving[t.ID]
+		idleFrom := t.IdleSince
+		if moved := ir.movedAt[t.ID]; moved.After(idleFrom) {
+			idleFrom = moved
+		}
+		ir.mu.Unlock()
+		if busy || ir.clock.Since(idleFrom) < timeout {
+			continue
+		}
+
+		var target taxi.Location
+		var reason string
+		if hasHome {
+			if home.Contains(t.Location) {
+				continue
+			}
+			target = nearestIn(home, t.Location)
+			reason = fmt.Sprintf("home zone %q", home.Name)
+		} else {
+			hotspot, found := ir.nearestHotspot(t.Location, hotspots)
+			if !found {
+				continue
+			}
+			target = hotspot.Center()
+			reason = fmt.Sprintf("hotspot %s (%d rides)", hotspot.Zone.Name, hotspot.Count)
+		}
+
+		route := ir.locationService.Route(t.Location, target)
+		if len(route) < 2 {
+			continue
+		}
+		ir.mu.Lock()
+		ir.driving[t.ID] = true
+		ir.mu.Unlock()
+		fmt.Printf("[IdleRepositioner] Taxi #%d idle for %v, driving (%d,%d) -> (%d,%d) toward %s\n",
+			t.ID, ir.clock.Since(idleFrom).Round(time.Second),
+			t.Location.X, t.Location.Y, target.X, target.Y, reason)
+		go ir.drive(t.ID, route)
+	}
+}
+
+// nearestHotspot returns the hotspot closest to location, or false if there is none
+// or location already lies inside one of them.
+func (ir *IdleRepositioner) nearestHotspot(location taxi.Location, hotspots []Hotspot) (Hotspot, bool) {
+	var nearest Hotspot
+	nearestDistance := -1 // -1 indicates no hotspot found yet
+	for _, hotspot := range hotspots {
+		if hotspot.Zone.Contains(location) {
+			return Hotspot{}, false
+		}
+		distance := ir.locationService.CalculateDistance(location, hotspot.Center())
+		if distance == taxi.Unreachable {
+			continue
+		}
+		if nearestDistance == -1 || distance < nearestDistance {
+			nearestDistance = distance
+			nearest = hotspot
+		}
+	}
+	return nearest, nearestDistance != -1
+}
+
+// drive moves a taxi along route, one cell at a time at the pace of the travel time model.
+// Stops early if the taxi is given a ride or removed on the way.
+func (ir *IdleRepositioner) drive(taxiID int, route []taxi.Location) {
+	defer func() {
+		ir.mu.Lock()
+		delete(ir.driving, taxiID)
+		ir.movedAt[taxiID] = ir.clock.Now()
+		ir.mu.Unlock()
+	}()
+
+	for i, location := range route[1:] {
+		ir.clock.Sleep(ir.travelTime.Sample(1, route[i], ir.clock.Now()))
+		if !ir.store.MoveIfAvailable(taxiID, location) {
+			fmt.Printf("[IdleRepositioner] Taxi #%d stopped repositioning, no longer available\n", taxiID)
+			return
+		}
+	}
+	arrived := route[len(route)-1]
+	fmt.Printf("[IdleRepositioner] Taxi #%d arrived at (%d,%d)\n", taxiID, arrived.X, arrived.Y)
+}
+
+// nearestIn returns the location inside zone closest to location.
+func nearestIn(zone taxi.Zone, location taxi.Location) taxi.Location {
+	return taxi.Location{
+		X: min(max(location.X, zone.Min.X), zone.Max.X),
+		Y: min(max(location.Y, zone.Min.Y), zone.Max.Y),
+	}
+}
@@ -0,0 +1,216 @@
+// lanes.go - Per-zone dispatch rate limits
+// Each zone gets its own dispatch lane with its own pace, so busy areas are not held
+// back by the single global rate limit
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// DefaultDispatchInterval is the pace of the default lane, which serves every
+// ride that does not start in a zone with its own rate.
+const DefaultDispatchInterval = 3 * time.Second
+
+// laneBufferSize is how many requests each lane queue can hold.
+const laneBufferSize = 150
+
+// DefaultMinDispatchInterval is the fastest pace an adaptive lane may reach when
+// AdaptiveRate.MinInterval is not set.
+const DefaultMinDispatchInterval = 500 * time.Millisecond
+
+// AdaptiveRate lets busy lanes dispatch faster than their configured pace.
+// While more than Threshold requests wait in a lane, its interval is halved at every
+// dispatch down to MinInterval; once the lane drains to half of Threshold or less,
+// the interval doubles back up to the configured pace.
+// The zero value keeps every lane at its configured pace.
+type AdaptiveRate struct {
+	Threshold   int           `json:"threshold"`    // Waiting requests above which a lane speeds up (0 = fixed pace)
+	MinInterval taxi.Duration `json:"min_interval"` // Fastest pace a busy lane may reach (default 500ms)
+}
+
+// dispatchLane processes the ride requests starting in one zone, one per interval.
+// Reassigned, priority and retried rides go to urgent and are served before regular ones.
+type dispatchLane struct {
+	zone     *taxi.Zone            // Area served (nil for the default lane; protected by RideScheduler.mu)
+	interval time.Duration         // Minimum time between two dispatches (protected by RideScheduler.mu)
+	current  time.Duration         // Pace in effect, below interval while adapting to a backlog (protected by RideScheduler.mu)
+	urgent   chan ride.RideRequest // Requests that jump the lane's queue
+	regular  chan ride.RideRequest // New ride requests
+}
+
+// newDispatchLane creates a lane for zone (nil for the default lane).
+func newDispatchLane(zone *taxi.Zone, interval time.Duration) *dispatchLane {
+	return &dispatchLane{
+		zone:     zone,
+		interval: interval,
+		current:  interval,
+		urgent:   make(chan ride.RideRequest, laneBufferSize),
+		regular:  make(chan ride.RideRequest, laneBufferSize),
+	}
+}
+
+// next blocks until the lane has a request, preferring urgent ones.
+func (dl *dispatchLane) next() ride.RideRequest {
+	select {
+	case request := <-dl.urgent:
+		return request
+	default:
+	}
+	select {
+	case request := <-dl.urgent:
+		return request
+	case request := <-dl.regular:
+		return request
+	}
+}
+
+// depth returns how many requests are waiting in the lane.
+func (dl *dispatchLane) depth() int {
+	return len(dl.urgent) + len(dl.regular)
+}
+
+// SetZoneRate gives rides starting in zone their own dispatch lane, processing
+// one ride every interval. Calling it again for a zone with the same name changes its pace
+// and bounds; requests already in its lane stay there.
+// Zones are matched in the order they were added; rides outside every zone use the default lane.
+func (rs *RideScheduler) SetZoneRate(zone taxi.Zone, interval time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for _, lane := range rs.lanes {
+		if lane.zone != nil && lane.zone.Name == zone.Name {
+			lane.zone = &zone
+			lane.interval = interval
+			lane.current = interval
+			fmt.Printf("[RideScheduler] Zone %q now dispatches every %v\n", zone.Name, interval)
+			return
+		}
+	}
+
+	lane := newDispatchLane(&zone, interval)
+	// Keep the default lane last so zones are checked first
+	rs.lanes = append(rs.lanes[:len(rs.lanes)-1], lane, rs.lanes[len(rs.lanes)-1])
+	go rs.runLane(lane)
+	fmt.Printf("[RideScheduler] Zone %q dispatches every %v\n", zone.Name, interval)
+}
+
+// SetDefaultRate changes the pace of the default lane, which serves every ride
+// outside the zones added with SetZoneRate.
+func (rs *RideScheduler) SetDefaultRate(interval time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.lanes[len(rs.lanes)-1].interval = interval
+	rs.lanes[len(rs.lanes)-1].current = interval
+}
+
+// SetAdaptiveRate makes every lane speed up while it has a backlog (see AdaptiveRate).
+// Pass a zero AdaptiveRate to go back to the configured paces.
+func (rs *RideScheduler) SetAdaptiveRate(rate AdaptiveRate) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.adaptive = rate
+	if rate.Threshold <= 0 {
+		for _, lane := range rs.lanes {
+			lane.current = lane.interval
+		}
+	}
+}
+
+// adaptInterval returns the lane's pace for its next dispatch, adjusted to how many
+// requests are waiting in it. Must be called with rs.mu held.
+func (rs *RideScheduler) adaptInterval(lane *dispatchLane) time.Duration {
+	if rs.adaptive.Threshold <= 0 {
+		return lane.interval
+	}
+	minInterval := rs.adaptive.MinInterval.Duration
+	if minInterval <= 0 {
+		minInterval = DefaultMinDispatchInterval
+	}
+	minInterval = min(minInterval, lane.interval)
+
+	previous := lane.current
+	depth := lane.depth()
+	switch {
+	case depth > rs.adaptive.Threshold:
+		lane.current = max(lane.current/2, minInterval)
+	case depth <= rs.adaptive.Threshold/2:
+		lane.current = min(lane.current*2, lane.interval)
+	}
+	if lane.current != previous {
+		fmt.Printf("[RideScheduler] Lane %s has %d waiting: dispatching every %v\n", lane.name(), depth, lane.current)
+	}
+	return lane.current
+}
+
+// name returns the lane's zone name, or "default" for the default lane.
+func (dl *dispatchLane) name() string {
+	if dl.zone == nil {
+		return "default"
+	}
+	return fmt.Sprintf("%q", dl.zone.Name)
+}
+
+// Zones returns the zones that have their own dispatch lane, in matching order.
+func (rs *RideScheduler) Zones() []taxi.Zone {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	zones := make([]taxi.Zone, 0, len(rs.lanes)-1)
+	for _, lane := range rs.lanes {
+		if lane.zone != nil {
+			zones = append(zones, *lane.zone)
+		}
+	}
+	return zones
+}
+
+// laneFor returns the lane serving rides that start at location.
+func (rs *RideScheduler) laneFor(location taxi.Location) *dispatchLane {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for _, lane := range rs.lanes {
+		if lane.zone == nil || lane.zone.Contains(location) {
+			return lane
+		}
+	}
+	return rs.lanes[len(rs.lanes)-1]
+}
+
+// runLane processes a lane's requests at the lane's pace, forever.
+// Slots are at least an interval apart, counted from the lane's start for the first one.
+// With a batching window (see SetBatchWindow), every slot dispatches a batch.
+func (rs *RideScheduler) runLane(lane *dispatchLane) {
+	last := rs.clock.Now()
+	for {
+		request := lane.next()
+
+		// Wait for this lane's next slot
+		rs.mu.Lock()
+		interval := rs.adaptInterval(lane)
+		rs.mu.Unlock()
+		if wait := interval - rs.clock.Since(last); wait > 0 {
+			rs.clock.Sleep(wait)
+		}
+
+		// A pause may have started while we were waiting; hold the request until resumed
+		rs.waitWhilePaused()
+		rs.mu.Lock()
+		window := rs.batchWindow
+		rs.mu.Unlock()
+		if window > 0 {
+			batch := rs.collectBatch(lane, request, window)
+			last = rs.clock.Now()
+			rs.processBatch(batch)
+			continue
+		}
+		last = rs.clock.Now()
+		rs.processRequest(request)
+	}
+}
@@ -0,0 +1,195 @@
+// ledger.go - Taxi utilization and earnings ledger
+// Keeps running per-taxi totals: rides, distance, idle time and earnings,
+// plus every finished ride for driver payout reports
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiLedger holds the running totals for one taxi.
+type TaxiLedger struct {
+	TaxiID         int           // ID of the taxi
+	RidesCompleted int           // Number of finished rides
+	NoShows        int           // Number of rides whose passenger did not turn up
+	DistanceDriven int           // Pickup plus trip distance over all rides
+	IdleTime       time.Duration // Total time spent available without a ride (see Taxi.TotalIdle)
+	Earnings       int           // Total fares and no-show fees earned (minor units, e.g. cents, of every currency charged; see GetPayoutReport per currency)
+}
+
+// LedgerRide is one finished ride as recorded in the ledger.
+type LedgerRide struct {
+	TaxiID   int       // Taxi that drove the ride
+	DriverID int       // Driver of the taxi when the ride finished (0 for none)
+	At       time.Time // When the ride finished
+	Distance int       // Pickup plus trip distance
+	Fare     int       // Fare for the trip, or the no-show fee if NoShow (minor units of Currency)
+	Currency string    // ISO 4217 code of Fare
+	NoShow   bool      // The passenger did not turn up; only the pickup leg was driven, and Fare is no fare
+}
+
+// Ledger tracks utilization and earnings for every taxi.
+// Ride totals are recorded by the RideScheduler; idle time is read from the fleet,
+// whose store keeps it as availability changes. A removed taxi keeps the idle time
+// the ledger last read. All methods are safe for concurrent access.
+type Ledger struct {
+	mu      sync.Mutex           // Protects entries and rides
+	taxis   taxi.TaxiStorage     // For each taxi's idle time
+	pricing *ride.PricingService // For turning trip distance into earnings
+	drivers *taxi.DriverStore    // For crediting each ride to the taxi's driver
+	clock   taxi.Clock           // For the idle stretch still running
+	entries map[int]*TaxiLedger  // Taxi ID -> running totals
+	rides   []LedgerRide         // Every finished ride, oldest first
+}
+
+// NewLedger creates an empty Ledger for the fleet in taxis that prices rides with
+// the given PricingService and credits them to the drivers in drivers.
+func NewLedger(taxis taxi.TaxiStorage, pricing *ride.PricingService, drivers *taxi.DriverStore, clock taxi.Clock) *Ledger {
+	return &Ledger{
+		taxis:   taxis,
+		pricing: pricing,
+		drivers: drivers,
+		clock:   clock,
+		entries: make(map[int]*TaxiLedger),
+	}
+}
+
+// RecordRide adds a finished ride to a taxi's totals.
+// Earnings are the ride's fare for the trip distance and ride time (see
+// PricingService.RideFare); the pickup leg is driven but not paid.
+func (l *Ledger) RecordRide(ride *ride.Ride, taxiID, pickupDistance, tripDistance int, rideTime time.Duration) {
+	fare := l.pricing.RideFare(ride, tripDistance, rideTime)
+	driver, _ := l.drivers.ForTaxi(taxiID) // Zero Driver (ID 0) for a taxi without one
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entry(taxiID)
+	entry.RidesCompleted++
+	entry.DistanceDriven += pickupDistance + tripDistance
+	entry.Earnings += fare
+	l.rides = append(l.rides, LedgerRide{
+		TaxiID:   taxiID,
+		DriverID: driver.ID,
+		At:       l.clock.Now(),
+		Distance: pickupDistance + tripDistance,
+		Fare:     fare,
+		Currency: l.pricing.Currency(ride),
+	})
+}
+
+// RecordNoShow adds a ride whose passenger did not turn up to a taxi's totals.
+// The taxi drove the pickup leg and earns the ride's no-show fee for it.
+func (l *Ledger) RecordNoShow(ride *ride.Ride, taxiID, pickupDistance int) {
+	fee := l.pricing.NoShowFee(ride)
+	driver, _ := l.drivers.ForTaxi(taxiID) // Zero Driver (ID 0) for a taxi without one
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entry(taxiID)
+	entry.NoShows++
+	entry.DistanceDriven += pickupDistance
+	entry.Earnings += fee
+	l.rides = append(l.rides, LedgerRide{
+		TaxiID:   taxiID,
+		DriverID: driver.ID,
+		At:       l.clock.Now(),
+		Distance: pickupDistance,
+		Fare:     fee,
+		Currency: l.pricing.Currency(ride),
+		NoShow:   true,
+	})
+}
+
+// RidesBetween returns copies of the rides that finished at or after from and
+// before to (zero for no bound), oldest first.
+func (l *Ledger) RidesBetween(from, to time.Time) []LedgerRide {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rides := make([]LedgerRide, 0)
+	for _, ride := range l.rides {
+		if (from.IsZero() || !ride.At.Before(from)) && (to.IsZero() || ride.At.Before(to)) {
+			rides = append(rides, ride)
+		}
+	}
+	return rides
+}
+
+// Get returns a copy of one taxi's totals, including its current idle stretch.
+// Returns false if the taxi is neither in the fleet nor in the ledger.
+func (l *Ledger) Get(taxiID int) (TaxiLedger, bool) {
+	taxi, inFleet := l.taxis.Get(taxiID)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.entries[taxiID]; !exists && !inFleet {
+		return TaxiLedger{}, false
+	}
+	if inFleet {
+		l.readIdle(taxi, l.clock.Now())
+	}
+	return *l.entry(taxiID), true
+}
+
+// TopEarners returns up to n taxis with the highest earnings, highest first.
+// Ties are broken by lowest taxi ID so the order is stable.
+func (l *Ledger) TopEarners(n int) []TaxiLedger {
+	fleet := l.taxis.Snapshot()
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	for _, taxi := range fleet.All() {
+		l.readIdle(taxi, now)
+	}
+	board := make([]TaxiLedger, 0, len(l.entries))
+	for _, entry := range l.entries {
+		board = append(board, *entry)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(board, func(i, j int) bool {
+		if board[i].Earnings != board[j].Earnings {
+			return board[i].Earnings > board[j].Earnings
+		}
+		return board[i].TaxiID < board[j].TaxiID
+	})
+
+	if n < len(board) {
+		board = board[:n]
+	}
+	return board
+}
+
+// readIdle copies a taxi's idle time up to now into its totals.
+// Must be called with l.mu held.
+func (l *Ledger) readIdle(taxi taxi.Taxi, now time.Time) {
+	l.entry(taxi.ID).IdleTime = taxi.TotalIdle(now)
+}
+
+// entry returns the totals for a taxi, creating them on first use.
+// Must be called with l.mu held.
+func (l *Ledger) entry(taxiID int) *TaxiLedger {
+	entry, exists := l.entries[taxiID]
+	if !exists {
+		entry = &TaxiLedger{TaxiID: taxiID}
+		l.entries[taxiID] = entry
+	}
+	return entry
+}
+
+// String formats the totals for log lines, with earnings in major units (e.g. dollars).
+func (tl TaxiLedger) String() string {
+	return fmt.Sprintf("taxi #%d: %d rides, %d no-shows, %d units, idle %v, earned %d.%02d",
+		tl.TaxiID, tl.RidesCompleted, tl.NoShows, tl.DistanceDriven, tl.IdleTime.Round(time.Second),
+		tl.Earnings/100, tl.Earnings%100)
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+func TestLedgerIdleTimeFollowsAvailabilityAtOnce(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	taxis := taxi.NewTaxiStore(taxi.NewSequentialIDGenerator(1), clock)
+	ledger := NewLedger(taxis, ride.NewPricingService(), taxi.NewDriverStore(taxi.NewSequentialIDGenerator(1)), clock)
+
+	// Nobody reads the taxi change feed: idle time must not depend on it
+	const fleet = 500
+	for i := 0; i < fleet; i++ {
+		taxis.Add(taxi.Location{X: i % 100, Y: i / 100}, 0)
+	}
+	clock.Advance(10 * time.Minute)
+	for id := 1; id <= fleet; id++ {
+		if _, ok := taxis.Reserve(id, func(taxi.Taxi) bool { return true }); !ok {
+			t.Fatalf("could not reserve taxi #%d", id)
+		}
+	}
+	clock.Advance(5 * time.Minute) // Busy, not idle
+	for id := 1; id <= fleet; id++ {
+		taxis.SetAvailability(id, true)
+	}
+	clock.Advance(3 * time.Minute)
+
+	for id := 1; id <= fleet; id++ {
+		entry, ok := ledger.Get(id)
+		if !ok || entry.IdleTime != 13*time.Minute {
+			t.Fatalf("taxi #%d: %+v, %v; want 13m idle", id, entry, ok)
+		}
+	}
+	if board := ledger.TopEarners(fleet); len(board) != fleet || board[0].IdleTime != 13*time.Minute {
+		t.Errorf("TopEarners lists %d taxis, first %+v; want all %d with 13m idle", len(board), board[0], fleet)
+	}
+
+	// A removed taxi keeps the idle time the ledger last read
+	taxis.Remove(1)
+	clock.Advance(time.Minute)
+	if entry, ok := ledger.Get(1); !ok || entry.IdleTime != 13*time.Minute {
+		t.Errorf("removed taxi #1: %+v, %v; want its 13m idle", entry, ok)
+	}
+	if _, ok := ledger.Get(fleet + 1); ok {
+		t.Error("ledger has totals for a taxi that never existed")
+	}
+}
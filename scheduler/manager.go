@@ -0,0 +1,172 @@
+// manager.go - Taxi management operations
+// Provides a business logic layer over TaxiStorage and DriverStore for taxi and driver CRUD operations
+
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiManager handles taxi and driver creation, update, and deletion.
+// Acts as a wrapper around TaxiStorage and DriverStore with validation and logging.
+type TaxiManager struct {
+	store    taxi.TaxiStorage  // Reference to the underlying taxi storage
+	drivers  *taxi.DriverStore // Driver profiles and their taxis
+	detector *AnomalyDetector  // For flagging impossible location jumps
+	faults   *FaultInjector    // For simulating lost location updates
+}
+
+// NewTaxiManager creates a TaxiManager with the given dependencies.
+func NewTaxiManager(store taxi.TaxiStorage, drivers *taxi.DriverStore, detector *AnomalyDetector, faults *FaultInjector) *TaxiManager {
+	return &TaxiManager{store: store, drivers: drivers, detector: detector, faults: faults}
+}
+
+// CreateTaxi registers a new taxi at the given location with the given attributes.
+// Returns the new taxi's ID.
+func (tm *TaxiManager) CreateTaxi(location taxi.Location, attributes taxi.TaxiAttributes) int {
+	id := tm.store.Add(location, attributes)
+	fmt.Printf("[TaxiManager] Created taxi #%d at (%d, %d), attributes %04b\n", id, location.X, location.Y, attributes)
+	return id
+}
+
+// GetTaxi returns a copy of the taxi with the given ID.
+// Returns false if the taxi was not found.
+func (tm *TaxiManager) GetTaxi(id int) (taxi.Taxi, bool) {
+	return tm.store.Get(id)
+}
+
+// UpdateTaxiLocation moves a taxi to a new location.
+// Location updates are treated as GPS fixes and checked for impossible speeds.
+// Returns an error if the taxi was not found.
+func (tm *TaxiManager) UpdateTaxiLocation(id int, location taxi.Location) error {
+	taxi, exists := tm.store.Get(id)
+	if !exists {
+		return fmt.Errorf("taxi #%d not found", id)
+	}
+	previous := taxi.Location
+
+	// Chaos mode: pretend the update never arrived
+	if tm.faults.ShouldDropHeartbeat() {
+		fmt.Printf("[TaxiManager] Dropped location update for taxi #%d (fault injected)\n", id)
+		return nil
+	}
+
+	if !tm.store.UpdateLocation(id, location) {
+		return fmt.Errorf("taxi #%d not found", id)
+	}
+	tm.detector.CheckLocationUpdate(id, previous, location)
+	fmt.Printf("[TaxiManager] Taxi #%d moved to (%d, %d)\n", id, location.X, location.Y)
+	return nil
+}
+
+// DeleteTaxi removes a taxi from the system. Its driver, if any, is left without a taxi.
+// Returns an error if the taxi was not found.
+func (tm *TaxiManager) DeleteTaxi(id int) error {
+	if !tm.store.Remove(id) {
+		return fmt.Errorf("taxi #%d not found", id)
+	}
+	fmt.Printf("[TaxiManager] Deleted taxi #%d\n", id)
+	if driverID, released := tm.drivers.ReleaseTaxi(id); released {
+		fmt.Printf("[TaxiManager] Driver #%d no longer has a taxi\n", driverID)
+	}
+	return nil
+}
+
+// GetAvailableTaxis returns copies of all taxis that can accept rides.
+func (tm *TaxiManager) GetAvailableTaxis() []taxi.Taxi {
+	return tm.store.GetAllAvailable()
+}
+
+// CreateDriver adds a driver profile, not yet assigned to a taxi.
+// Returns the new driver's ID, or an error if the name or license is missing.
+func (tm *TaxiManager) CreateDriver(name, license, phone string) (int, error) {
+	if err := validateDriver(name, license); err != nil {
+		return 0, err
+	}
+	id := tm.drivers.Add(name, license, phone)
+	fmt.Printf("[TaxiManager] Created driver #%d (%s)\n", id, name)
+	return id, nil
+}
+
+// GetDriver returns a copy of the driver with the given ID.
+// Returns false if the driver was not found.
+func (tm *TaxiManager) GetDriver(id int) (taxi.Driver, bool) {
+	return tm.drivers.Get(id)
+}
+
+// GetDrivers returns copies of every driver, ordered by ID.
+func (tm *TaxiManager) GetDrivers() []taxi.Driver {
+	return tm.drivers.GetAll()
+}
+
+// GetTaxiDriver returns a copy of the driver currently assigned to a taxi.
+// Returns false if the taxi has no driver.
+func (tm *TaxiManager) GetTaxiDriver(taxiID int) (taxi.Driver, bool) {
+	return tm.drivers.ForTaxi(taxiID)
+}
+
+// UpdateDriver changes a driver's name, license and phone number.
+// Returns an error if the name or license is missing or the driver was not found.
+func (tm *TaxiManager) UpdateDriver(id int, name, license, phone string) error {
+	if err := validateDriver(name, license); err != nil {
+		return err
+	}
+	if !tm.drivers.Update(id, name, license, phone) {
+		return fmt.Errorf("driver #%d not found", id)
+	}
+	fmt.Printf("[TaxiManager] Updated driver #%d (%s)\n", id, name)
+	return nil
+}
+
+// AssignDriver puts a driver in a taxi, or takes them out of their taxi with taxiID 0.
+// A driver drives one taxi at a time, so any previous taxi is left without a driver.
+// Returns an error if the driver or taxi was not found, or the taxi already has another driver.
+func (tm *TaxiManager) AssignDriver(driverID, taxiID int) error {
+	if taxiID != 0 {
+		if _, exists := tm.store.Get(taxiID); !exists {
+			return fmt.Errorf("taxi #%d not found", taxiID)
+		}
+	}
+	other, exists := tm.drivers.Assign(driverID, taxiID)
+	if !exists {
+		return fmt.Errorf("driver #%d not found", driverID)
+	}
+	if other != 0 {
+		return fmt.Errorf("taxi #%d already has driver #%d", taxiID, other)
+	}
+	// The taxi may have been deleted in the meantime, before its driver could be released
+	if _, exists := tm.store.Get(taxiID); taxiID != 0 && !exists {
+		tm.drivers.ReleaseTaxi(taxiID)
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	if taxiID == 0 {
+		fmt.Printf("[TaxiManager] Driver #%d no longer has a taxi\n", driverID)
+	} else {
+		fmt.Printf("[TaxiManager] Driver #%d now drives taxi #%d\n", driverID, taxiID)
+	}
+	return nil
+}
+
+// DeleteDriver removes a driver profile, leaving their taxi without a driver.
+// Returns an error if the driver was not found.
+func (tm *TaxiManager) DeleteDriver(id int) error {
+	if !tm.drivers.Remove(id) {
+		return fmt.Errorf("driver #%d not found", id)
+	}
+	fmt.Printf("[TaxiManager] Deleted driver #%d\n", id)
+	return nil
+}
+
+// validateDriver rejects driver profiles without a name or license number.
+func validateDriver(name, license string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("driver name is required")
+	}
+	if strings.TrimSpace(license) == "" {
+		return fmt.Errorf("driver license is required")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// matching.go - Batch assignment
+// Matches a batch of rides to the available taxis all at once, for the least total
+// pickup distance over the batch rather than the best taxi for each ride in turn
+
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// noMatch marks a ride and taxi pair in a cost matrix that must not be matched.
+const noMatch = -1
+
+// BatchMatching is how AssignBatch pairs the rides of a batch with taxis.
+type BatchMatching string
+
+const (
+	OptimalMatching BatchMatching = "optimal" // Least total pickup distance over the batch (Hungarian algorithm, the default)
+	GreedyMatching  BatchMatching = "greedy"  // Each ride in turn takes the closest taxi left, as without batching
+)
+
+// ParseBatchMatching returns the matching with the given name ("" for OptimalMatching).
+// Returns an error for any other name.
+func ParseBatchMatching(name string) (BatchMatching, error) {
+	switch matching := BatchMatching(name); matching {
+	case "":
+		return OptimalMatching, nil
+	case OptimalMatching, GreedyMatching:
+		return matching, nil
+	}
+	return "", fmt.Errorf("unknown batch matching %q, want %s or %s", name, OptimalMatching, GreedyMatching)
+}
+
+// Match returns the column matched to each row of costs (see minCostMatching).
+func (matching BatchMatching) Match(costs [][]int, columns int) []int {
+	if matching == GreedyMatching {
+		return greedyMatching(costs, columns)
+	}
+	return minCostMatching(costs, columns)
+}
+
+// greedyMatching matches the rows of costs in order, each to the cheapest column no
+// earlier row took (the lowest on ties), like assigning rides one at a time to the
+// closest taxi. Returns the column matched to each row, or -1 if none was left.
+// Runs in O(rows × columns) time, but can cost far more in total than minCostMatching:
+// an early ride may take the only taxi near a later one.
+func greedyMatching(costs [][]int, columns int) []int {
+	matched := make([]int, len(costs))
+	taken := make([]bool, columns)
+	for i, row := range costs {
+		matched[i] = -1
+		for j, cost := range row {
+			if cost != noMatch && !taken[j] && (matched[i] < 0 || cost < row[matched[i]]) {
+				matched[i] = j
+			}
+		}
+		if matched[i] >= 0 {
+			taken[matched[i]] = true
+		}
+	}
+	return matched
+}
+
+// minCostMatching solves the assignment problem with the Hungarian algorithm: given the
+// cost of giving column j (a taxi) to row i (a ride), or noMatch where that is not
+// allowed, it matches as many rows as possible to distinct columns, and among those
+// matchings returns one with the least total cost. Every row of costs has columns
+// entries. Returns the column matched to each row, or -1 for rows left unmatched.
+// Runs in O(rows² × columns) time for rows <= columns.
+func minCostMatching(costs [][]int, columns int) []int {
+	rows := len(costs)
+	matched := make([]int, rows)
+	for i := range matched {
+		matched[i] = -1
+	}
+	if rows == 0 || columns == 0 {
+		return matched
+	}
+
+	// Pairs that must not match cost more than any matching of allowed pairs, so the
+	// fewest of them are used; extra columns let rows go unmatched when taxis run out
+	highest := 0
+	for _, row := range costs {
+		for _, cost := range row {
+			highest = max(highest, cost)
+		}
+	}
+	forbidden := int64(highest+1) * int64(rows)
+	width := max(columns, rows)
+	cost := func(i, j int) int64 {
+		if j >= columns || costs[i][j] == noMatch {
+			return forbidden
+		}
+		return int64(costs[i][j])
+	}
+
+	// Shortest augmenting paths with potentials u (rows) and v (columns), indexed from 1;
+	// owner[j] is the row matched to column j, column 0 being the row being added
+	u := make([]int64, rows+1)
+	v := make([]int64, width+1)
+	owner := make([]int, width+1)
+	previous := make([]int, width+1)
+	for i := 1; i <= rows; i++ {
+		owner[0] = i
+		column := 0
+		slack := make([]int64, width+1)
+		for j := range slack {
+			slack[j] = math.MaxInt64
+		}
+		visited := make([]bool, width+1)
+		for owner[column] != 0 {
+			visited[column] = true
+			row := owner[column]
+			delta, next := int64(math.MaxInt64), 0
+			for j := 1; j <= width; j++ {
+				if visited[j] {
+					continue
+				}
+				if reduced := cost(row-1, j-1) - u[row] - v[j]; reduced < slack[j] {
+					slack[j], previous[j] = reduced, column
+				}
+				if slack[j] < delta {
+					delta, next = slack[j], j
+				}
+			}
+			for j := 0; j <= width; j++ {
+				if visited[j] {
+					u[owner[j]] += delta
+					v[j] -= delta
+				} else {
+					slack[j] -= delta
+				}
+			}
+			column = next
+		}
+		// Flip the augmenting path ending at the free column found
+		for column != 0 {
+			from := previous[column]
+			owner[column] = owner[from]
+			column = from
+		}
+	}
+
+	for j := 1; j <= columns; j++ {
+		if i := owner[j] - 1; i >= 0 && costs[i][j-1] != noMatch {
+			matched[i] = j - 1
+		}
+	}
+	return matched
+}
+
+// AssignBatch assigns taxis to a batch of rides together: the available taxis are
+// matched to the rides so that as many rides as possible get one, with the least total
+// pickup distance over the batch (see minCostMatching), instead of each ride taking
+// the best-scoring taxi in turn. With GreedyMatching (see SetBatchMatching) each ride
+// takes the closest taxi left instead, for comparison. Taxis are eligible as for AssignBestTaxi, within the
+// maximum pickup distance, but scoring weights play no part. A taxi held for a ride's
+// client is still tried first. excluded[i] lists the taxis rides[i] must not get.
+// Returns the taxi assigned to each ride, nil for rides left without one (e.g. more
+// rides than taxis, or a matched taxi taken by another lane first), which the caller
+// can try one by one.
+func (ta *TaxiAssigner) AssignBatch(rides []*ride.Ride, excluded [][]int) []*taxi.Taxi {
+	result := make([]*taxi.Taxi, len(rides))
+	open := make([]int, 0, len(rides)) // Indexes of the rides to match
+	for i, ride := range rides {
+		if taxi, done := ta.assignHeldTaxi(ride, excluded[i]); done {
+			result[i] = taxi
+			continue
+		}
+		open = append(open, i)
+	}
+	if len(open) == 0 {
+		return result
+	}
+
+	ta.mu.RLock()
+	maxDistance := ta.maxPickupDistance
+	matching := ta.matching
+	ta.mu.RUnlock()
+
+	// Taxis in ID order, so a seeded run matches the same way every time
+	taxis := ta.store.GetAllAvailable()
+	sort.Slice(taxis, func(i, j int) bool { return taxis[i].ID < taxis[j].ID })
+	costs := make([][]int, len(open))
+	for row, i := range open {
+		eligible := ta.eligible(rides[i], excluded[i])
+		costs[row] = make([]int, len(taxis))
+		for column, t := range taxis {
+			costs[row][column] = noMatch
+			if !eligible(t) {
+				continue
+			}
+			distance := ta.locationService.CalculateDistance(t.Location, rides[i].StartLocation)
+			if distance != taxi.Unreachable && (maxDistance == 0 || distance <= maxDistance) {
+				costs[row][column] = distance
+			}
+		}
+	}
+
+	matches, total := 0, 0
+	for row, column := range matching.Match(costs, len(taxis)) {
+		if column < 0 {
+			continue
+		}
+		i := open[row]
+		r, distance := rides[i], costs[row][column]
+		// The snapshot may be stale: the taxi must still be available and eligible now
+		taxi, ok := ta.store.Reserve(taxis[column].ID, ta.eligible(r, excluded[i]))
+		assigned := ok && ta.markAssigned(r, taxi.ID)
+		ta.recordBatch(r, taxis[column], distance, len(taxis), ok, assigned)
+		if !assigned {
+			continue
+		}
+		matches++
+		total += distance
+		fmt.Printf("[TaxiAssigner] %sAssigned taxi #%d to ride #%d in a batch of %d (distance: %d)\n", ride.TraceTag(r.TraceID),
+			taxi.ID, r.ID, len(open), distance)
+		result[i] = &taxi
+	}
+	fmt.Printf("[TaxiAssigner] Matched %d of %d batched rides to %d available taxis (%s), total pickup distance %d\n",
+		matches, len(open), len(taxis), matching, total)
+	return result
+}
+
+// recordBatch audits the taxi a ride was matched to, out of considered available taxis:
+// whether that taxi could still be reserved (usable) and whether the ride was then still
+// waiting for it (assigned).
+func (ta *TaxiAssigner) recordBatch(ride *ride.Ride, taxi taxi.Taxi, distance, considered int, usable, assigned bool) {
+	if ta.audit == nil {
+		return
+	}
+	decision := AssignmentDecision{RideID: ride.ID, At: ta.clock.Now(), Method: AuditBatch, Considered: considered, Candidates: make([]AuditCandidate, 0)}
+	candidate := AuditCandidate{TaxiID: taxi.ID, Location: taxi.Location, Distance: distance, Outcome: AuditAssigned}
+	switch {
+	case !usable:
+		candidate.Outcome = AuditTakenFirst
+		decision.Note = fmt.Sprintf("matched taxi #%d could no longer take the ride", taxi.ID)
+		decision.Rejections = []AuditCandidate{candidate}
+	case !assigned:
+		candidate.Outcome = "released, ride was already assigned"
+		decision.Note = fmt.Sprintf("ride was already assigned, taxi #%d released", taxi.ID)
+		decision.Rejections = []AuditCandidate{candidate}
+	default:
+		decision.TaxiID, decision.Distance = taxi.ID, distance
+		decision.Candidates = append(decision.Candidates, candidate)
+	}
+	ta.audit.Record(decision)
+}
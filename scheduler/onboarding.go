@@ -0,0 +1,172 @@
+// onboarding.go - Taxi onboarding approval
+// Lets operators vet new taxis before they drive: while approval is required, a newly
+// registered taxi gets no rides until an admin approves it, or is removed if rejected
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/internal/relay"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// OnboardingStatus is how far a taxi is through onboarding approval.
+type OnboardingStatus string
+
+const (
+	PendingApproval OnboardingStatus = "PENDING_APPROVAL" // Registered, waiting for an admin; gets no rides
+	Approved        OnboardingStatus = "APPROVED"         // May serve rides
+	Rejected        OnboardingStatus = "REJECTED"         // Turned down and removed from the fleet
+)
+
+// OnboardingEventType describes what happened to a taxi's onboarding.
+type OnboardingEventType string
+
+const (
+	TaxiPendingApproval OnboardingEventType = "TAXI_PENDING_APPROVAL" // A taxi registered and waits for approval
+	TaxiApproved        OnboardingEventType = "TAXI_APPROVED"         // An admin approved the taxi
+	TaxiRejected        OnboardingEventType = "TAXI_REJECTED"         // An admin rejected the taxi
+)
+
+// OnboardingEvent is sent to TaxiOnboarding subscribers whenever a taxi's onboarding changes.
+type OnboardingEvent struct {
+	Type   OnboardingEventType `json:"type"`             // What happened
+	TaxiID int                 `json:"taxi_id"`          // ID of the taxi
+	Time   time.Time           `json:"time"`             // When it happened
+	Reason string              `json:"reason,omitempty"` // Why the taxi was rejected
+}
+
+// TaxiApplication is where one taxi registered while approval was required stands.
+type TaxiApplication struct {
+	TaxiID     int              `json:"taxi_id"`
+	Status     OnboardingStatus `json:"status"`
+	Registered time.Time        `json:"registered"`
+	Decided    time.Time        `json:"decided,omitzero"` // When it was approved or rejected
+	Reason     string           `json:"reason,omitempty"` // Why it was rejected
+}
+
+// TaxiOnboarding keeps the applications of taxis registered while approval is required.
+// Until approval is required every taxi is approved; once it is, only taxis already in
+// the fleet then and taxis approved since are. It never calls the TaxiStore, so the
+// assigner may ask it from inside store locks.
+// All methods are safe for concurrent access.
+type TaxiOnboarding struct {
+	mu           sync.RWMutex                    // Protects every field below
+	required     bool                            // Whether new taxis need approval
+	approved     map[int]bool                    // Taxis in the fleet when approval became required
+	applications map[int]TaxiApplication         // Taxi ID -> application
+	subscribers  []*relay.Relay[OnboardingEvent] // Notified of every event, one per Subscribe call
+	clock        taxi.Clock                      // For registration and decision times
+}
+
+// NewTaxiOnboarding creates an onboarding that approves every taxi until RequireApproval.
+func NewTaxiOnboarding(clock taxi.Clock) *TaxiOnboarding {
+	return &TaxiOnboarding{approved: make(map[int]bool), applications: make(map[int]TaxiApplication), clock: clock}
+}
+
+// RequireApproval makes taxis registered from now on wait for approval. The taxis
+// given are the fleet so far, which stays approved.
+func (to *TaxiOnboarding) RequireApproval(fleet []taxi.Taxi) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	to.required = true
+	for _, taxi := range fleet {
+		to.approved[taxi.ID] = true
+	}
+}
+
+// Approved reports whether a taxi may serve rides.
+func (to *TaxiOnboarding) Approved(taxiID int) bool {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+	return !to.required || to.approved[taxiID] || to.applications[taxiID].Status == Approved
+}
+
+// Apply records a newly registered taxi as pending approval.
+// Returns false if approval is not required, so the taxi needs none.
+func (to *TaxiOnboarding) Apply(taxiID int) bool {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	if !to.required {
+		return false
+	}
+	now := to.clock.Now()
+	to.applications[taxiID] = TaxiApplication{TaxiID: taxiID, Status: PendingApproval, Registered: now}
+	to.publish(OnboardingEvent{Type: TaxiPendingApproval, TaxiID: taxiID, Time: now})
+	return true
+}
+
+// Decide approves or rejects a pending taxi.
+// Returns an error if the taxi is not pending approval.
+func (to *TaxiOnboarding) Decide(taxiID int, approve bool, reason string) error {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	application, exists := to.applications[taxiID]
+	if !exists || application.Status != PendingApproval {
+		return fmt.Errorf("taxi #%d is not pending approval", taxiID)
+	}
+	application.Decided = to.clock.Now()
+	event := OnboardingEvent{Type: TaxiApproved, TaxiID: taxiID, Time: application.Decided}
+	if approve {
+		application.Status = Approved
+	} else {
+		application.Status, application.Reason = Rejected, reason
+		event.Type, event.Reason = TaxiRejected, reason
+	}
+	to.applications[taxiID] = application
+	to.publish(event)
+	return nil
+}
+
+// Forget drops what is known about a taxi that left the fleet. Rejections are kept,
+// so operators can still see why a taxi was turned down.
+func (to *TaxiOnboarding) Forget(taxiID int) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	delete(to.approved, taxiID)
+	if to.applications[taxiID].Status != Rejected {
+		delete(to.applications, taxiID)
+	}
+}
+
+// Applications returns the applications with a given status ("" for all), ordered by taxi ID.
+func (to *TaxiOnboarding) Applications(status OnboardingStatus) []TaxiApplication {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+
+	applications := make([]TaxiApplication, 0, len(to.applications))
+	for _, application := range to.applications {
+		if status == "" || application.Status == status {
+			applications = append(applications, application)
+		}
+	}
+	sort.Slice(applications, func(i, j int) bool { return applications[i].TaxiID < applications[j].TaxiID })
+	return applications
+}
+
+// Subscribe returns a channel that receives every onboarding event published from now on.
+// As with TaxiStore.Subscribe, a subscriber that falls behind gets every event later, in order.
+func (to *TaxiOnboarding) Subscribe() <-chan OnboardingEvent {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	subscriber := relay.New[OnboardingEvent](taxi.SubscriberBufferSize)
+	to.subscribers = append(to.subscribers, subscriber)
+	return subscriber.C
+}
+
+// publish hands an event to every subscriber without blocking or dropping it.
+// Must be called with mu held, so events go out in order.
+func (to *TaxiOnboarding) publish(event OnboardingEvent) {
+	for _, subscriber := range to.subscribers {
+		subscriber.Push(event)
+	}
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+func TestOnboardingSubscriberFallingBehindMissesNothing(t *testing.T) {
+	onboarding := NewTaxiOnboarding(taxi.NewManualClock(testStart))
+	onboarding.RequireApproval(nil)
+	events := onboarding.Subscribe()
+
+	// Nobody reads until every taxi applied and was decided: twice the buffer each
+	const taxis = 2 * taxi.SubscriberBufferSize
+	for id := 1; id <= taxis; id++ {
+		onboarding.Apply(id)
+	}
+	for id := 1; id <= taxis; id++ {
+		if err := onboarding.Decide(id, id%2 == 0, "no license"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < 2*taxis; i++ {
+		var event OnboardingEvent
+		select {
+		case event = <-events:
+		case <-timeout:
+			t.Fatalf("got %d of %d events", i, 2*taxis)
+		}
+		wantType, wantTaxi := TaxiPendingApproval, i+1
+		if i >= taxis {
+			wantTaxi = i - taxis + 1
+			wantType = TaxiRejected
+			if wantTaxi%2 == 0 {
+				wantType = TaxiApproved
+			}
+		}
+		if event.Type != wantType || event.TaxiID != wantTaxi {
+			t.Fatalf("event %d is %s for taxi #%d, want %s for taxi #%d", i, event.Type, event.TaxiID, wantType, wantTaxi)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// queue_wait.go - Queue wait time metrics
+// Tracks how long ride requests sit in the scheduler's queues before they are
+// processed, and summarizes recent waits as percentiles
+
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// queueWaitSamples is how many of the most recent waits the percentiles are taken over.
+const queueWaitSamples = 1000
+
+// QueueWaitStats summarizes the most recent queue waits.
+type QueueWaitStats struct {
+	Samples int           `json:"samples"` // Waits the percentiles are taken over (up to queueWaitSamples)
+	P50     taxi.Duration `json:"p50"`     // Median wait
+	P95     taxi.Duration `json:"p95"`     // 95th percentile wait
+	P99     taxi.Duration `json:"p99"`     // 99th percentile wait
+}
+
+// QueueWaitTracker records queue waits in a ring buffer, so the percentiles follow
+// the current load rather than the whole run.
+// All methods are safe for concurrent access.
+type QueueWaitTracker struct {
+	mu    sync.Mutex      // Protects waits and next
+	waits []time.Duration // Most recent waits, up to queueWaitSamples
+	next  int             // Slot the next wait overwrites once waits is full
+}
+
+// NewQueueWaitTracker creates an empty tracker.
+func NewQueueWaitTracker() *QueueWaitTracker {
+	return &QueueWaitTracker{waits: make([]time.Duration, 0, queueWaitSamples)}
+}
+
+// Record adds one wait, replacing the oldest once the buffer is full.
+func (qt *QueueWaitTracker) Record(wait time.Duration) {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+
+	if len(qt.waits) < queueWaitSamples {
+		qt.waits = append(qt.waits, wait)
+		return
+	}
+	qt.waits[qt.next] = wait
+	qt.next = (qt.next + 1) % queueWaitSamples
+}
+
+// Stats returns the percentiles of the recorded waits (all zero before the first one).
+func (qt *QueueWaitTracker) Stats() QueueWaitStats {
+	qt.mu.Lock()
+	waits := append([]time.Duration(nil), qt.waits...)
+	qt.mu.Unlock()
+
+	if len(waits) == 0 {
+		return QueueWaitStats{}
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	percentile := func(p int) taxi.Duration {
+		// Nearest rank: the smallest wait at least p percent of the samples do not exceed
+		rank := (p*len(waits) + 99) / 100
+		return taxi.Duration{Duration: waits[rank-1]}
+	}
+	return QueueWaitStats{
+		Samples: len(waits),
+		P50:     percentile(50),
+		P95:     percentile(95),
+		P99:     percentile(99),
+	}
+}
@@ -0,0 +1,82 @@
+// recovery.go - Restart recovery of in-flight rides
+// After rides are restored from the journal, picks up the ones that had a taxi where
+// they left off, or fails them and frees the taxi, so no taxi stays reserved for a
+// ride nobody is driving any more
+
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// RecoverRide takes over a ride restored with a taxi (ASSIGNED, ACCEPTED or IN_PROGRESS)
+// that no goroutine of this process is driving yet.
+// If its taxi is still in the fleet, still reserved and not in maintenance, the ride
+// carries on: a ride in progress ends when it was expected to, counted from its original
+// start (straight away if that time has passed); an accepted ride starts now; an assigned
+// ride starts now, or is offered to the driver again in confirmation mode.
+// Otherwise the ride becomes FAILED, and a taxi still reserved for it available again.
+// Returns true if the ride was resumed.
+func (rs *RideScheduler) RecoverRide(r *ride.Ride) bool {
+	current := r.Snapshot()
+	status, taxiID, startedAt := current.Status(), current.TaxiID(), current.StartedAt
+	if status != ride.ASSIGNED && status != ride.ACCEPTED && status != ride.IN_PROGRESS {
+		return false
+	}
+
+	rs.mu.Lock()
+	_, onRide := rs.activeRides[taxiID]
+	rs.mu.Unlock()
+
+	taxi, exists := rs.store.Get(taxiID)
+	switch {
+	case !exists:
+		rs.failRide(r, taxiID, false, fmt.Sprintf("taxi #%d is no longer in the fleet", taxiID))
+		return false
+	case taxi.IsAvailable || onRide:
+		// A fresh fleet reuses taxi IDs; this taxi was never reserved for the ride
+		rs.failRide(r, taxiID, false, fmt.Sprintf("taxi #%d is not reserved for it", taxiID))
+		return false
+	case taxi.InMaintenance:
+		rs.failRide(r, taxiID, true, fmt.Sprintf("taxi #%d is in maintenance", taxiID))
+		return false
+	}
+
+	rs.mu.Lock()
+	rs.activeRides[taxi.ID] = r.ID
+	timeout := rs.confirmTimeout
+	rs.mu.Unlock()
+
+	// No movement is simulated, so the taxi is still where its pickup leg began
+	distance := rs.assigner.RideDistance(&taxi, r)
+	switch {
+	case status == ride.IN_PROGRESS:
+		estimated := rs.travelTime.Estimate(distance, r.StartLocation, startedAt)
+		remaining := max(startedAt.Add(estimated).Sub(rs.clock.Now()), 0)
+		fmt.Printf("[RideScheduler] %sRide #%d RESUMED in progress - taxi #%d, %v left\n", ride.TraceTag(r.TraceID),
+			r.ID, taxi.ID, remaining.Round(100*time.Millisecond))
+		rs.completeAfter(r, &taxi, startedAt, estimated, remaining)
+	case status == ride.ASSIGNED && timeout > 0:
+		fmt.Printf("[RideScheduler] %sRide #%d RESUMED - offering it to taxi #%d again\n", ride.TraceTag(r.TraceID), r.ID, taxi.ID)
+		go rs.awaitConfirmation(ride.RequestFor(r), r, &taxi, distance, timeout)
+	default:
+		fmt.Printf("[RideScheduler] %sRide #%d RESUMED - taxi #%d starts it now\n", ride.TraceTag(r.TraceID), r.ID, taxi.ID)
+		rs.startRide(r, &taxi, distance)
+	}
+	return true
+}
+
+// failRide marks an interrupted ride of a taxi FAILED, making the taxi available again if free is set.
+func (rs *RideScheduler) failRide(r *ride.Ride, taxiID int, free bool, reason string) {
+	r.SetStatus(ride.FAILED, rs.clock.Now())
+
+	if free && !rs.breaks.Release(taxiID) {
+		log.Printf("[RideScheduler] ERROR: Failed to set availability for taxi #%d\n", taxiID)
+	}
+	rs.events.Publish(ride.RideFailed, r, taxiID)
+	fmt.Printf("[RideScheduler] %sRide #%d FAILED after restart: %s\n", ride.TraceTag(r.TraceID), r.ID, reason)
+}
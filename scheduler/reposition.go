@@ -0,0 +1,152 @@
+// reposition.go - Idle taxi repositioning
+// Suggests moving idle taxis toward high-demand areas to shorten pickups
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Suggestion proposes moving one idle taxi to the center of a hotspot.
+type Suggestion struct {
+	TaxiID  int           // Taxi to move
+	From    taxi.Location // Where the taxi is now
+	To      taxi.Location // Center of the hotspot
+	Hotspot Hotspot       // The demand the move is meant to cover
+}
+
+// DemandSource tells where rides are wanted: a DemandHeatmap (recent demand) or a
+// DemandForecaster (predicted demand).
+type DemandSource interface {
+	// Hotspots returns up to n areas with the most demand, busiest first.
+	Hotspots(n int) []Hotspot
+}
+
+// RepositioningAdvisor matches idle taxis to the busiest cells of a DemandSource.
+// Each hotspot gets at most one taxi: the closest idle taxi, unless an idle taxi already
+// waits inside it. In simulation the advisor can also apply its own suggestions periodically.
+type RepositioningAdvisor struct {
+	store           taxi.TaxiStorage // For idle taxis and moving them
+	demand          DemandSource     // Where demand is
+	locationService taxi.Router      // For picking the closest taxi to each hotspot
+	clock           taxi.Clock       // For the auto-move ticker
+	mu              sync.Mutex       // Protects demand and running
+	running         bool             // True once auto-move has started
+}
+
+// NewRepositioningAdvisor creates an advisor with the given dependencies.
+func NewRepositioningAdvisor(store taxi.TaxiStorage, demand DemandSource, locationService taxi.Router, clock taxi.Clock) *RepositioningAdvisor {
+	return &RepositioningAdvisor{
+		store:           store,
+		demand:          demand,
+		locationService: locationService,
+		clock:           clock,
+	}
+}
+
+// Suggest returns the moves that would put an idle taxi in each hotspot, busiest first.
+// Nothing is moved; use Apply or StartAutoMove for that.
+func (ra *RepositioningAdvisor) Suggest() []Suggestion {
+	ra.mu.Lock()
+	demand := ra.demand
+	ra.mu.Unlock()
+
+	idle := ra.store.GetAllAvailable()
+	hotspots := demand.Hotspots(len(idle))
+
+	used := make(map[int]bool)
+	suggestions := make([]Suggestion, 0)
+	for _, hotspot := range hotspots {
+		// A taxi already waiting in the hotspot covers it
+		covered := false
+		for _, taxi := range idle {
+			if !used[taxi.ID] && hotspot.Zone.Contains(taxi.Location) {
+				used[taxi.ID] = true
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+
+		// Otherwise send the closest idle taxi that is not needed elsewhere
+		target := hotspot.Center()
+		var closest *taxi.Taxi
+		closestDistance := -1 // -1 indicates no taxi found yet
+		for i := range idle {
+			if used[idle[i].ID] {
+				continue
+			}
+			distance := ra.locationService.CalculateDistance(idle[i].Location, target)
+			if distance == taxi.Unreachable {
+				continue
+			}
+			if closestDistance == -1 || distance < closestDistance {
+				closestDistance = distance
+				closest = &idle[i]
+			}
+		}
+		if closest == nil {
+			continue
+		}
+
+		used[closest.ID] = true
+		suggestions = append(suggestions, Suggestion{
+			TaxiID:  closest.ID,
+			From:    closest.Location,
+			To:      target,
+			Hotspot: hotspot,
+		})
+	}
+	return suggestions
+}
+
+// SetDemandSource changes where the advisor looks for demand from the next suggestion on.
+func (ra *RepositioningAdvisor) SetDemandSource(demand DemandSource) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	ra.demand = demand
+}
+
+// Apply moves the taxis of the given suggestions.
+// Taxis that were given a ride in the meantime are left where they are.
+// Returns the number of taxis moved.
+func (ra *RepositioningAdvisor) Apply(suggestions []Suggestion) int {
+	moved := 0
+	for _, suggestion := range suggestions {
+		if !ra.store.MoveIfAvailable(suggestion.TaxiID, suggestion.To) {
+			continue
+		}
+		moved++
+		fmt.Printf("[RepositioningAdvisor] Moved taxi #%d (%d,%d) -> (%d,%d) for hotspot %s (%d rides)\n",
+			suggestion.TaxiID, suggestion.From.X, suggestion.From.Y, suggestion.To.X, suggestion.To.Y,
+			suggestion.Hotspot.Zone.Name, suggestion.Hotspot.Count)
+	}
+	return moved
+}
+
+// StartAutoMove applies fresh suggestions every interval of simulated time, forever.
+// Meant for simulation, where taxis can simply be teleported.
+// Returns false if auto-move is already running.
+func (ra *RepositioningAdvisor) StartAutoMove(interval time.Duration) bool {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.running {
+		return false
+	}
+	ra.running = true
+
+	go func() {
+		ticker := ra.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ra.Apply(ra.Suggest())
+		}
+	}()
+	return true
+}
@@ -0,0 +1,1023 @@
+// scheduler.go - Ride request processing
+// Handles ride lifecycle from CREATED to FINISHED with rate limiting
+
+// Package scheduler decides which taxi serves which ride: the RideScheduler queues
+// requests in dispatch lanes and drives each ride through its lifecycle, the
+// TaxiAssigner picks its taxi, and holds, breaks, maintenance windows, repositioning
+// and forecasts shape those choices.
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// arrival is where and when a taxi on a ride will be free again.
+type arrival struct {
+	location taxi.Location // Drop-off point of the current ride
+	at       time.Time     // When the ride is expected to finish
+}
+
+// offer is an assignment waiting for the driver to accept or decline.
+type offer struct {
+	taxiID   int       // Taxi the ride was offered to
+	response chan bool // Receives true for accept, false for decline (buffered, size 1)
+}
+
+// RideScheduler processes ride requests from a channel.
+// Rate-limited to handle 1 new ride every 3 seconds, or at the pace set per zone (see SetZoneRate).
+type RideScheduler struct {
+	rideRequests    <-chan ride.RideRequest      // Input channel for ride requests
+	priorityRides   <-chan ride.RideRequest      // Input channel for requests served before rideRequests
+	assigner        *TaxiAssigner                // For assigning taxis to rides
+	store           taxi.TaxiStorage             // For updating taxi state after rides
+	rides           ride.RideStorage             // For looking up rides created by the Server
+	locationService taxi.Router                  // For calculating ride durations
+	detector        *AnomalyDetector             // For flagging suspicious rides
+	faults          *FaultInjector               // For injecting delays and breakdowns
+	events          *ride.EventBus               // For publishing ride events
+	travelTime      TravelTimeModel              // How long rides take (speed, traffic, variance)
+	ledger          *Ledger                      // For per-taxi ride and earnings totals
+	breaks          *TaxiBreaks                  // Breaks to start instead of freeing a taxi after its ride
+	executor        RideExecutor                 // Decides when started rides have been driven
+	clock           taxi.Clock                   // For rate limiting, ride timing and timestamps
+	taxiChanges     <-chan taxi.TaxiChangedEvent // Store notifications, used to spot failed taxis
+	queueWaits      *QueueWaitTracker            // How long requests were queued before processRequest took them
+	reassignments   chan ride.RideRequest        // Rides whose taxi failed, served before new requests
+	retries         chan ride.RideRequest        // Pending rides given another try, served before new requests
+	mu              sync.Mutex                   // Protects activeRides, arrivals, queued, lookAhead, pending, paused, resumed, offers, confirmTimeout, lanes, adaptive, batchWindow, maxAttempts and deadLetters
+	lanes           []*dispatchLane              // Per-zone dispatch lanes, the default lane (no zone) last
+	adaptive        AdaptiveRate                 // How lanes speed up under a backlog (zero = fixed paces)
+	batchWindow     time.Duration                // How long lanes collect requests to match together (0 = one at a time)
+	pending         []ride.RideRequest           // Rides no taxi could take, waiting for the fleet to change
+	maxAttempts     int                          // Attempts without a taxi before a ride is dead-lettered (0 = unlimited)
+	deadLetters     map[int]DeadLetter           // Ride ID -> ride the dispatcher gave up on, waiting for an operator
+	activeRides     map[int]int                  // Taxi ID -> ID of the ride it is currently driving
+	arrivals        map[int]arrival              // Taxi ID -> where and when its ride in progress ends
+	queued          map[int]ride.RideRequest     // Taxi ID -> ride pre-assigned to it, started when its current ride ends
+	lookAhead       time.Duration                // How soon a busy taxi must be free to be pre-assigned a ride (0 = off)
+	offers          map[int]offer                // Ride ID -> offer waiting for the driver's answer
+	confirmTimeout  time.Duration                // How long drivers have to accept (0 = no confirmation needed)
+	paused          bool                         // When true, no new requests are dispatched
+	resumed         chan struct{}                // Closed when dispatching resumes after a pause
+}
+
+// NewRideScheduler creates a RideScheduler with the given dependencies.
+func NewRideScheduler(
+	rideRequests <-chan ride.RideRequest,
+	priorityRides <-chan ride.RideRequest,
+	assigner *TaxiAssigner,
+	store taxi.TaxiStorage,
+	rides ride.RideStorage,
+	locationService taxi.Router,
+	detector *AnomalyDetector,
+	faults *FaultInjector,
+	events *ride.EventBus,
+	travelTime TravelTimeModel,
+	ledger *Ledger,
+	breaks *TaxiBreaks,
+	executor RideExecutor,
+	clock taxi.Clock,
+) *RideScheduler {
+	return &RideScheduler{
+		rideRequests:    rideRequests,
+		priorityRides:   priorityRides,
+		assigner:        assigner,
+		store:           store,
+		rides:           rides,
+		locationService: locationService,
+		detector:        detector,
+		faults:          faults,
+		events:          events,
+		travelTime:      travelTime,
+		ledger:          ledger,
+		breaks:          breaks,
+		executor:        executor,
+		clock:           clock,
+		taxiChanges:     store.Subscribe(),
+		queueWaits:      NewQueueWaitTracker(),
+		reassignments:   make(chan ride.RideRequest, 50),
+		retries:         make(chan ride.RideRequest, 150),
+		activeRides:     make(map[int]int),
+		arrivals:        make(map[int]arrival),
+		queued:          make(map[int]ride.RideRequest),
+		offers:          make(map[int]offer),
+		deadLetters:     make(map[int]DeadLetter),
+		lanes:           []*dispatchLane{newDispatchLane(nil, DefaultDispatchInterval)},
+	}
+}
+
+// Start begins processing ride requests from the channel.
+// This method blocks and should be run as a goroutine.
+// Each request is handed to the dispatch lane of the zone it starts in, which
+// processes one ride per interval (every 3 seconds by default).
+// Priority requests are always taken before regular ones within a lane.
+func (rs *RideScheduler) Start() {
+	fmt.Println("[RideScheduler] Started - waiting for ride requests...")
+
+	// Watch for taxis that disappear while driving a ride
+	go rs.watchTaxis()
+
+	// The default lane serves every ride outside the zones added with SetZoneRate
+	rs.mu.Lock()
+	defaultLane := rs.lanes[len(rs.lanes)-1]
+	rs.mu.Unlock()
+	go rs.runLane(defaultLane)
+
+	for {
+		rs.waitWhilePaused()
+		request, urgent, ok := rs.nextRequest()
+		if !ok {
+			break
+		}
+		rs.route(request, urgent)
+	}
+
+	fmt.Println("[RideScheduler] Channel closed, no new requests (queued rides still dispatch)")
+
+	// Rides already in the system still get reassigned, requeued and retried,
+	// and whoever hands them over must never wait for room that is not coming
+	for {
+		rs.waitWhilePaused()
+		rs.route(rs.nextUrgentRequest(), true)
+	}
+}
+
+// route hands a request to the dispatch lane of the zone it starts in.
+func (rs *RideScheduler) route(request ride.RideRequest, urgent bool) {
+	lane := rs.laneFor(request.StartLocation)
+	if urgent {
+		lane.urgent <- request
+	} else {
+		lane.regular <- request
+	}
+}
+
+// Pause stops dispatching new requests. Rides already in progress still finish.
+// Requests keep queueing up and are processed once Resume is called.
+func (rs *RideScheduler) Pause() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.paused {
+		return
+	}
+	rs.paused = true
+	rs.resumed = make(chan struct{})
+	fmt.Println("[RideScheduler] Dispatching PAUSED")
+}
+
+// Resume restarts dispatching after a Pause, and retries the pending rides, among
+// them those requeued while the queue was full (see requeue).
+func (rs *RideScheduler) Resume() {
+	rs.mu.Lock()
+	if !rs.paused {
+		rs.mu.Unlock()
+		return
+	}
+	rs.paused = false
+	close(rs.resumed)
+	rs.mu.Unlock()
+	fmt.Println("[RideScheduler] Dispatching RESUMED")
+
+	rs.retryPending()
+}
+
+// QueueDepth returns how many requests are waiting to be dispatched,
+// across the regular, priority, reassignment and retry queues, the dispatch lanes,
+// the pending rides and the rides pre-assigned to busy taxis.
+func (rs *RideScheduler) QueueDepth() int {
+	rs.mu.Lock()
+	waiting := len(rs.pending) + len(rs.queued)
+	for _, lane := range rs.lanes {
+		waiting += lane.depth()
+	}
+	rs.mu.Unlock()
+	return len(rs.rideRequests) + len(rs.priorityRides) + len(rs.reassignments) + len(rs.retries) + waiting
+}
+
+// QueueSnapshot breaks QueueDepth down by queue.
+type QueueSnapshot struct {
+	Paused        bool           `json:"paused"`        // Dispatching is paused (see Pause)
+	Incoming      int            `json:"incoming"`      // New requests not yet routed to a lane
+	Priority      int            `json:"priority"`      // Priority requests not yet routed to a lane
+	Reassignments int            `json:"reassignments"` // Rides whose taxi failed, not yet routed to a lane
+	Retries       int            `json:"retries"`       // Pending rides given another try, not yet routed to a lane
+	Pending       []int          `json:"pending"`       // IDs of rides no taxi could take yet, oldest first
+	PreAssigned   []int          `json:"pre_assigned"`  // IDs of rides waiting for a busy taxi to finish (see SetLookAhead)
+	DeadLetters   int            `json:"dead_letters"`  // Rides the dispatcher gave up on (see DeadLetters)
+	Lanes         []LaneSnapshot `json:"lanes"`         // Dispatch lanes in matching order, the default lane last
+}
+
+// LaneSnapshot describes one dispatch lane in a QueueSnapshot.
+type LaneSnapshot struct {
+	Zone     string        `json:"zone"`     // Zone name ("" for the default lane)
+	Interval taxi.Duration `json:"interval"` // Configured time between two dispatches
+	Current  taxi.Duration `json:"current"`  // Time between dispatches in effect, shorter while adapting to a backlog (see AdaptiveRate)
+	Urgent   int           `json:"urgent"`   // Reassigned, priority and retried requests waiting
+	Regular  int           `json:"regular"`  // New requests waiting
+}
+
+// Queue returns a snapshot of every queue feeding the dispatcher.
+func (rs *RideScheduler) Queue() QueueSnapshot {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	snapshot := QueueSnapshot{
+		Paused:        rs.paused,
+		Incoming:      len(rs.rideRequests),
+		Priority:      len(rs.priorityRides),
+		Reassignments: len(rs.reassignments),
+		Retries:       len(rs.retries),
+		Pending:       make([]int, 0, len(rs.pending)),
+		PreAssigned:   make([]int, 0, len(rs.queued)),
+		DeadLetters:   len(rs.deadLetters),
+		Lanes:         make([]LaneSnapshot, 0, len(rs.lanes)),
+	}
+	for _, request := range rs.pending {
+		snapshot.Pending = append(snapshot.Pending, request.RideID)
+	}
+	for _, request := range rs.queued {
+		snapshot.PreAssigned = append(snapshot.PreAssigned, request.RideID)
+	}
+	sort.Ints(snapshot.PreAssigned)
+	for _, lane := range rs.lanes {
+		name := ""
+		if lane.zone != nil {
+			name = lane.zone.Name
+		}
+		snapshot.Lanes = append(snapshot.Lanes, LaneSnapshot{
+			Zone:     name,
+			Interval: taxi.Duration{Duration: lane.interval},
+			Current:  taxi.Duration{Duration: lane.current},
+			Urgent:   len(lane.urgent),
+			Regular:  len(lane.regular),
+		})
+	}
+	return snapshot
+}
+
+// ExpectedDropOff returns when taxiID is expected to finish ride rideID,
+// or false if the taxi is not driving that ride.
+func (rs *RideScheduler) ExpectedDropOff(rideID, taxiID int) (time.Time, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if current, onRide := rs.activeRides[taxiID]; !onRide || current != rideID {
+		return time.Time{}, false
+	}
+	next, exists := rs.arrivals[taxiID]
+	return next.at, exists
+}
+
+// ActiveRideCount returns how many rides currently have a taxi driving them.
+func (rs *RideScheduler) ActiveRideCount() int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return len(rs.activeRides)
+}
+
+// QueueWaitStats returns the percentiles of how long recent requests were queued
+// before being processed.
+func (rs *RideScheduler) QueueWaitStats() QueueWaitStats {
+	return rs.queueWaits.Stats()
+}
+
+// waitWhilePaused blocks until dispatching is not paused.
+func (rs *RideScheduler) waitWhilePaused() {
+	rs.mu.Lock()
+	paused, resumed := rs.paused, rs.resumed
+	rs.mu.Unlock()
+
+	if paused {
+		<-resumed
+	}
+}
+
+// nextRequest blocks until a request is available.
+// Reassigned rides come first, then priority requests, then retried pending rides, then regular ones.
+// urgent is true for everything except regular requests.
+// Returns false once the regular rideRequests channel has been closed.
+func (rs *RideScheduler) nextRequest() (ride.RideRequest, bool, bool) {
+	// Take a waiting reassignment or priority request first, if there is one
+	select {
+	case request := <-rs.reassignments:
+		return request, true, true
+	default:
+	}
+	select {
+	case request := <-rs.priorityRides:
+		return request, true, true
+	default:
+	}
+	select {
+	case request := <-rs.retries:
+		return request, true, true
+	default:
+	}
+
+	// Otherwise wait for whichever arrives first
+	select {
+	case request := <-rs.reassignments:
+		return request, true, true
+	case request := <-rs.priorityRides:
+		return request, true, true
+	case request := <-rs.retries:
+		return request, true, true
+	case request, ok := <-rs.rideRequests:
+		return request, false, ok
+	}
+}
+
+// nextUrgentRequest is nextRequest once rideRequests is closed: it blocks until a
+// reassigned, priority or retried request is available, in that order.
+func (rs *RideScheduler) nextUrgentRequest() ride.RideRequest {
+	select {
+	case request := <-rs.reassignments:
+		return request
+	default:
+	}
+	select {
+	case request := <-rs.priorityRides:
+		return request
+	default:
+	}
+
+	select {
+	case request := <-rs.reassignments:
+		return request
+	case request := <-rs.priorityRides:
+		return request
+	case request := <-rs.retries:
+		return request
+	}
+}
+
+// processRequest handles a single ride request.
+// Looks up the ride created by the Server, assigns a taxi, and starts the ride simulation.
+func (rs *RideScheduler) processRequest(request ride.RideRequest) {
+	ride, ok := rs.admit(request)
+	if !ok {
+		return
+	}
+
+	// Try the preferred taxi first (round trip return legs), then the best-scoring one
+	var taxi *taxi.Taxi
+	if request.PreferredTaxiID != 0 {
+		taxi = rs.assigner.AssignPreferredTaxi(ride, request.PreferredTaxiID)
+	}
+	rs.assign(request, ride, taxi)
+}
+
+// admit takes a request off the queue: it records how long it was queued and returns
+// its ride if that is still waiting for a taxi, expiring it if its deadline passed.
+func (rs *RideScheduler) admit(request ride.RideRequest) (*ride.Ride, bool) {
+	r := rs.rides.Get(request.RideID)
+	if r == nil {
+		log.Printf("[RideScheduler] ERROR: Ride #%d not found in store\n", request.RideID)
+		return nil, false
+	}
+	if !request.EnqueuedAt.IsZero() {
+		wait := rs.clock.Since(request.EnqueuedAt)
+		rs.queueWaits.Record(wait)
+		r.AddQueueWait(wait)
+	}
+	// An operator may have assigned the ride by hand, or it expired, while it was queued
+	if !rs.unassigned(r) {
+		fmt.Printf("[RideScheduler] %sRide #%d no longer waiting, skipping\n", ride.TraceTag(r.TraceID), r.ID)
+		return nil, false
+	}
+	if !request.ExpiresAt.IsZero() && !rs.clock.Now().Before(request.ExpiresAt) {
+		rs.Expire(r.ID)
+		return nil, false
+	}
+
+	fmt.Printf("[RideScheduler] %sProcessing ride #%d for client #%d: (%d,%d) -> (%d,%d)%s\n", ride.TraceTag(r.TraceID),
+		r.ID, r.ClientID,
+		r.StartLocation.X, r.StartLocation.Y,
+		r.EndLocation.X, r.EndLocation.Y, ride.ViaTag(r.Waypoints))
+
+	// Chaos mode: simulate a slow assignment
+	if delay := rs.faults.AssignmentDelay(); delay > 0 {
+		fmt.Printf("[RideScheduler] %sDelaying assignment of ride #%d by %v (fault injected)\n", ride.TraceTag(r.TraceID), r.ID, delay)
+		rs.clock.Sleep(delay)
+	}
+	return r, true
+}
+
+// assign dispatches an admitted ride to taxi, or without one (nil) holds it for a busy
+// taxi about to finish or gives it the best-scoring available taxi. A ride no taxi can
+// take goes to pending, or to the dead-letter queue once out of attempts.
+func (rs *RideScheduler) assign(request ride.RideRequest, r *ride.Ride, taxi *taxi.Taxi) {
+	if taxi == nil && rs.preAssign(request, r) {
+		return
+	}
+	if taxi == nil {
+		taxi = rs.assigner.AssignBestTaxi(r, request.ExcludedTaxiIDs)
+	}
+	if taxi == nil {
+		if !rs.unassigned(r) {
+			return
+		}
+		request.Attempts++
+		rs.mu.Lock()
+		exhausted := rs.maxAttempts > 0 && request.Attempts >= rs.maxAttempts
+		rs.mu.Unlock()
+		if exhausted {
+			rs.deadLetter(request, r, DeadLetterAttempts)
+			return
+		}
+		fmt.Printf("[RideScheduler] %sRide #%d could not be assigned, pending until a taxi frees up\n", ride.TraceTag(r.TraceID), r.ID)
+		request.EnqueuedAt = rs.clock.Now() // Waiting in pending counts as queued until the retry is processed
+		rs.mu.Lock()
+		rs.pending = append(rs.pending, request)
+		rs.mu.Unlock()
+		return
+	}
+
+	rs.dispatch(request, r, taxi)
+}
+
+// SetLookAhead turns pre-assignment on (window > 0) or off (0).
+// When on, a new ride may be held for a busy taxi that will finish its current ride
+// within window (its cooldown included, see TaxiBreaks.SetCooldown), if its drop-off is
+// closer to the pickup than every available taxi. The ride then starts as soon as that
+// taxi is done and cooled down, without it becoming available in between.
+func (rs *RideScheduler) SetLookAhead(window time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.lookAhead = window
+}
+
+// preAssign holds a ride for the busy taxi best placed to take it next (see SetLookAhead).
+// Returns false if look-ahead is off or no busy taxi beats the available ones.
+func (rs *RideScheduler) preAssign(request ride.RideRequest, r *ride.Ride) bool {
+	cooldown := rs.breaks.Cooldown() // The taxi only takes the next ride once it has cooled down
+	rs.mu.Lock()
+	window := rs.lookAhead
+	now := rs.clock.Now()
+	soon := make(map[int]taxi.Location)
+	for taxiID, next := range rs.arrivals {
+		if _, taken := rs.queued[taxiID]; !taken && next.at.Add(cooldown).Sub(now) <= window {
+			soon[taxiID] = next.location
+		}
+	}
+	rs.mu.Unlock()
+	if window <= 0 || len(soon) == 0 {
+		return false
+	}
+
+	taxiID, distance, ok := rs.assigner.ClosestArrival(r, request.ExcludedTaxiIDs, soon)
+	if !ok {
+		return false
+	}
+
+	// The taxi may have finished, or been given another ride, while we were looking
+	rs.mu.Lock()
+	_, busy := rs.arrivals[taxiID]
+	_, taken := rs.queued[taxiID]
+	if !busy || taken {
+		rs.mu.Unlock()
+		return false
+	}
+	rs.queued[taxiID] = request
+	rs.mu.Unlock()
+
+	fmt.Printf("[RideScheduler] %sRide #%d PRE-ASSIGNED to taxi #%d, which finishes its ride %d units from the pickup\n", ride.TraceTag(r.TraceID),
+		r.ID, taxiID, distance)
+	return true
+}
+
+// handOff starts the ride pre-assigned to a taxi that just finished, if there is one.
+// The taxi goes straight from one ride to the next without becoming available in between.
+// Returns false if nothing was waiting or the taxi can no longer take it; a ride that
+// is still waiting then goes back to the queue.
+func (rs *RideScheduler) handOff(request ride.RideRequest, taxiID int) bool {
+	r := rs.rides.Get(request.RideID)
+	if r == nil || !rs.unassigned(r) {
+		return false
+	}
+	if taxi := rs.assigner.AssignReservedTaxi(r, taxiID); taxi != nil {
+		rs.dispatch(request, r, taxi)
+		return true
+	}
+	fmt.Printf("[RideScheduler] %sTaxi #%d can no longer take pre-assigned ride #%d, returning it to the queue\n", ride.TraceTag(r.TraceID), taxiID, r.ID)
+	request.EnqueuedAt = rs.clock.Now()
+	rs.requeue(request)
+	return false
+}
+
+// requeuePreAssigned puts a ride that was waiting for a removed taxi back in the queue.
+func (rs *RideScheduler) requeuePreAssigned(request ride.RideRequest, taxiID int) {
+	r := rs.rides.Get(request.RideID)
+	if r == nil || !rs.unassigned(r) {
+		return
+	}
+	fmt.Printf("[RideScheduler] %sPre-assigned taxi #%d of ride #%d was removed, returning it to the queue\n", ride.TraceTag(r.TraceID), taxiID, r.ID)
+	request.EnqueuedAt = rs.clock.Now()
+	rs.requeue(request)
+}
+
+// AssignManually lets an operator give a waiting ride to a specific taxi,
+// skipping the queue and the taxi scoring. The ride then continues as
+// if the scheduler had assigned it (including driver confirmation, if enabled).
+// Returns an error if the ride is unknown or no longer waiting, or the taxi cannot take it.
+func (rs *RideScheduler) AssignManually(rideID, taxiID int) error {
+	r := rs.rides.Get(rideID)
+	if r == nil {
+		return fmt.Errorf("ride #%d not found", rideID)
+	}
+	if !rs.unassigned(r) {
+		return fmt.Errorf("ride #%d is already assigned", rideID)
+	}
+	if _, exists := rs.store.Get(taxiID); !exists {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+
+	taxi := rs.assigner.AssignChosenTaxi(r, taxiID)
+	if taxi == nil {
+		return fmt.Errorf("taxi #%d cannot take ride #%d (busy, missing required features, or ride already assigned)", taxiID, rideID)
+	}
+
+	// Leave no stale copy in the pending list; queued copies are skipped by processRequest
+	rs.mu.Lock()
+	waiting := rs.pending[:0]
+	for _, request := range rs.pending {
+		if request.RideID != rideID {
+			waiting = append(waiting, request)
+		}
+	}
+	rs.pending = waiting
+	rs.mu.Unlock()
+	rs.forgetDeadLetter(rideID)
+
+	rs.dispatch(ride.RequestFor(r), r, taxi)
+	return nil
+}
+
+// WatchExpiry expires a ride at its deadline if no taxi has been assigned by then,
+// wherever it is waiting (queue, retry queue or pending). Does nothing for rides without one.
+func (rs *RideScheduler) WatchExpiry(ride *ride.Ride) {
+	if ride.ExpiresAt.IsZero() {
+		return
+	}
+	rs.clock.AfterFunc(ride.ExpiresAt.Sub(rs.clock.Now()), func() {
+		rs.Expire(ride.ID)
+	})
+}
+
+// Expire moves a ride that is still waiting for a taxi to EXPIRED and tells subscribers.
+// Rides that already have a taxi, or have ended, are left alone.
+func (rs *RideScheduler) Expire(rideID int) {
+	r := rs.rides.Get(rideID)
+	if r == nil {
+		return
+	}
+
+	if !r.SetStatus(ride.EXPIRED, rs.clock.Now(), ride.CREATED) {
+		return
+	}
+
+	// Move it from the pending list to the dead-letter queue, keeping its attempts;
+	// queued copies are skipped by processRequest
+	expired := ride.RequestFor(r)
+	rs.mu.Lock()
+	waiting := rs.pending[:0]
+	for _, request := range rs.pending {
+		if request.RideID != rideID {
+			waiting = append(waiting, request)
+		} else {
+			expired = request
+		}
+	}
+	rs.pending = waiting
+	if letter, exists := rs.deadLetters[rideID]; exists {
+		expired = letter.request
+	}
+	rs.mu.Unlock()
+
+	rs.events.Publish(ride.RideExpired, r, 0)
+	fmt.Printf("[RideScheduler] %sRide #%d EXPIRED, no taxi assigned before its deadline\n", ride.TraceTag(r.TraceID), rideID)
+	rs.deadLetter(expired, r, DeadLetterExpired)
+}
+
+// unassigned reports whether a ride is still waiting for a taxi.
+func (rs *RideScheduler) unassigned(r *ride.Ride) bool {
+	return r.Status() == ride.CREATED
+}
+
+// dispatch takes a freshly assigned ride to its start: it announces the assignment,
+// then either starts the ride or, in confirmation mode, offers it to the driver first.
+func (rs *RideScheduler) dispatch(request ride.RideRequest, r *ride.Ride, taxi *taxi.Taxi) {
+	rs.events.Publish(ride.TaxiAssigned, r, taxi.ID)
+
+	// Remember which ride this taxi is on, in case the taxi fails
+	rs.mu.Lock()
+	rs.activeRides[taxi.ID] = r.ID
+	rs.mu.Unlock()
+
+	// A taxi deleted since it was reserved was forgotten before the line above, with no
+	// ride to reassign; whichever of ForgetTaxi and this check removes the entry reassigns
+	if _, exists := rs.store.Get(taxi.ID); !exists {
+		rs.mu.Lock()
+		rideID, onRide := rs.activeRides[taxi.ID]
+		onRide = onRide && rideID == r.ID
+		if onRide {
+			delete(rs.activeRides, taxi.ID)
+		}
+		rs.mu.Unlock()
+		if onRide {
+			rs.reassign(r.ID, taxi.ID)
+		}
+		return
+	}
+
+	// The taxi drives to the pickup, then to the destination
+	distance := rs.assigner.RideDistance(taxi, r)
+
+	// In confirmation mode the driver must accept first; wait without blocking dispatch
+	rs.mu.Lock()
+	timeout := rs.confirmTimeout
+	rs.mu.Unlock()
+	if timeout > 0 {
+		go rs.awaitConfirmation(request, r, taxi, distance, timeout)
+		return
+	}
+	rs.startRide(r, taxi, distance)
+}
+
+// SetConfirmationTimeout turns the driver confirmation handshake on (timeout > 0) or off (0).
+// When on, every assignment is offered to the taxi, which must call RespondToOffer in time.
+func (rs *RideScheduler) SetConfirmationTimeout(timeout time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.confirmTimeout = timeout
+}
+
+// RespondToOffer records a driver's answer to a ride offer.
+// Returns an error if there is no pending offer of that ride to that taxi
+// (for example because it already timed out).
+func (rs *RideScheduler) RespondToOffer(rideID, taxiID int, accept bool) error {
+	rs.mu.Lock()
+	pending, exists := rs.offers[rideID]
+	rs.mu.Unlock()
+
+	if !exists || pending.taxiID != taxiID {
+		return fmt.Errorf("no pending offer of ride #%d to taxi #%d", rideID, taxiID)
+	}
+
+	select {
+	case pending.response <- accept:
+		return nil
+	default:
+		return fmt.Errorf("ride #%d offer was already answered", rideID)
+	}
+}
+
+// awaitConfirmation offers an assigned ride to its taxi and waits for the answer.
+// On accept the ride starts; on decline or timeout the taxi is released and the
+// ride goes back to the queue, never to be offered to this taxi again.
+func (rs *RideScheduler) awaitConfirmation(request ride.RideRequest, r *ride.Ride, taxi *taxi.Taxi, distance int, timeout time.Duration) {
+	response := make(chan bool, 1)
+	rs.mu.Lock()
+	rs.offers[r.ID] = offer{taxiID: taxi.ID, response: response}
+	rs.mu.Unlock()
+
+	rs.events.Publish(ride.RideOffered, r, taxi.ID)
+	fmt.Printf("[RideScheduler] %sRide #%d offered to taxi #%d, waiting up to %v\n", ride.TraceTag(r.TraceID), r.ID, taxi.ID, timeout)
+
+	accepted := false
+	select {
+	case accepted = <-response:
+	case <-rs.clock.After(timeout):
+		fmt.Printf("[RideScheduler] %sTaxi #%d did not answer offer for ride #%d in time\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+	}
+
+	rs.mu.Lock()
+	delete(rs.offers, r.ID)
+	rs.mu.Unlock()
+
+	// The taxi may have been removed (and the ride reassigned) while we waited
+	// Declining keeps the ride ASSIGNED to the taxi until declineOffer requeues it
+	next := ride.ASSIGNED
+	if accepted {
+		next = ride.ACCEPTED
+	}
+	if !r.SetTaxiStatus(taxi.ID, next, rs.clock.Now(), ride.ASSIGNED) {
+		return
+	}
+
+	if accepted {
+		rs.events.Publish(ride.RideAccepted, r, taxi.ID)
+		fmt.Printf("[RideScheduler] %sTaxi #%d ACCEPTED ride #%d\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+		rs.startRide(r, taxi, distance)
+		return
+	}
+	rs.declineOffer(request, r, taxi)
+}
+
+// declineOffer releases a taxi that declined (or ignored) an offer and requeues the ride.
+// Does nothing if the ride was taken away from the taxi meanwhile (see reassign).
+func (rs *RideScheduler) declineOffer(request ride.RideRequest, r *ride.Ride, taxi *taxi.Taxi) {
+	if !r.SetTaxiStatus(taxi.ID, ride.CREATED, rs.clock.Now(), ride.ASSIGNED) {
+		return
+	}
+
+	rs.mu.Lock()
+	delete(rs.activeRides, taxi.ID)
+	rs.mu.Unlock()
+
+	if !rs.breaks.Release(taxi.ID) {
+		log.Printf("[RideScheduler] ERROR: Failed to set availability for taxi #%d\n", taxi.ID)
+	}
+
+	rs.events.Publish(ride.RideDeclined, r, taxi.ID)
+	fmt.Printf("[RideScheduler] %sTaxi #%d DECLINED ride #%d, returning it to the queue\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+
+	// Never offer this ride to the same taxi again
+	retry := ride.RequestFor(r)
+	retry.ExcludedTaxiIDs = append(append([]int{}, request.ExcludedTaxiIDs...), taxi.ID)
+	retry.EnqueuedAt = rs.clock.Now()
+	rs.requeue(retry)
+}
+
+// startRide begins a ride over distance units (pickup leg included) and schedules its completion.
+// The RideExecutor then drives it for as long as the travel time model says.
+// Does nothing if the ride was taken away from the taxi meanwhile (see reassign).
+func (rs *RideScheduler) startRide(r *ride.Ride, taxi *taxi.Taxi, distance int) {
+	if !r.SetTaxiStatus(taxi.ID, ride.IN_PROGRESS, rs.clock.Now(), ride.ASSIGNED, ride.ACCEPTED) {
+		fmt.Printf("[RideScheduler] %sRide #%d no longer belongs to taxi #%d, not starting it\n", ride.TraceTag(r.TraceID), r.ID, taxi.ID)
+		return
+	}
+	rs.events.Publish(ride.RideStarted, r, taxi.ID)
+
+	startedAt := rs.clock.Now()
+	estimated := rs.travelTime.Estimate(distance, r.StartLocation, startedAt)
+	actual := rs.travelTime.Sample(distance, r.StartLocation, startedAt)
+
+	fmt.Printf("[RideScheduler] %sRide #%d IN_PROGRESS - taxi #%d, %d units, about %v\n", ride.TraceTag(r.TraceID),
+		r.ID, taxi.ID, distance, estimated.Round(100*time.Millisecond))
+
+	rs.completeAfter(r, taxi, startedAt, estimated, actual)
+}
+
+// completeAfter ends a ride in progress that started at startedAt and is expected to
+// take estimated, once the executor has driven it for actual. In chaos mode the taxi may
+// break down on the way, or the passenger may not be at the pickup when the taxi gets there.
+func (rs *RideScheduler) completeAfter(ride *ride.Ride, taxi *taxi.Taxi, startedAt time.Time, estimated, actual time.Duration) {
+	// Remember where and when the taxi will be free, for pre-assignment
+	rs.mu.Lock()
+	rs.arrivals[taxi.ID] = arrival{location: ride.EndLocation, at: startedAt.Add(estimated)}
+	rs.mu.Unlock()
+
+	// Chaos mode: the passenger may not turn up, or the taxi may break down part way through
+	if rs.faults.PassengerNoShow() {
+		rs.executor.Drive(ride, rs.pickupTime(ride, taxi, actual), func() { rs.noShow(ride, taxi) })
+		return
+	}
+	if after, broken := rs.faults.BreakdownPoint(actual); broken {
+		rs.executor.Drive(ride, after, func() { rs.breakDown(ride, taxi) })
+		return
+	}
+
+	rs.executor.Drive(ride, actual, func() {
+		if rs.endRide(ride, taxi) {
+			// Compare how long the ride actually took against the estimate
+			rs.detector.CheckRide(ride, estimated)
+		}
+	})
+}
+
+// endRide completes a ride and frees the taxi.
+// Updates the taxi's location to the ride destination and marks it available,
+// unless a ride was pre-assigned to it, which then starts straight away, or its
+// driver asked for a break, which then begins (see TaxiBreaks).
+// Does nothing and returns false if the ride was taken away from this taxi in the
+// meantime (see reassign).
+func (rs *RideScheduler) endRide(r *ride.Ride, t *taxi.Taxi) bool {
+	if !r.SetTaxiStatus(t.ID, ride.FINISHED, rs.clock.Now(), ride.IN_PROGRESS) {
+		fmt.Printf("[RideScheduler] %sRide #%d no longer belongs to taxi #%d, ignoring completion\n", ride.TraceTag(r.TraceID), r.ID, t.ID)
+		return false
+	}
+	rs.events.Publish(ride.RideFinished, r, t.ID)
+
+	// Taking the pre-assigned ride together with the arrival means preAssign can never
+	// queue a ride for this taxi once we have looked
+	rs.mu.Lock()
+	delete(rs.activeRides, t.ID)
+	delete(rs.arrivals, t.ID)
+	next, preAssigned := rs.queued[t.ID]
+	delete(rs.queued, t.ID)
+	rs.mu.Unlock()
+
+	finished := r.Snapshot()
+	rideTime := finished.FinishedAt.Sub(finished.StartedAt)
+
+	// taxi is the copy taken at assignment, so its Location is where the pickup leg began
+	rs.ledger.RecordRide(r, t.ID,
+		taxi.RoutedDistance(rs.locationService, t.Location, r.StartLocation),
+		taxi.TripDistance(rs.locationService, r.Stops()), rideTime)
+
+	rs.freeTaxi(r, t.ID, r.EndLocation, next, preAssigned)
+	return true
+}
+
+// pickupTime returns the part of a ride's actual duration the taxi spends driving to
+// the pickup, in proportion to the pickup leg's share of the ride's distance.
+func (rs *RideScheduler) pickupTime(ride *ride.Ride, t *taxi.Taxi, actual time.Duration) time.Duration {
+	pickup := taxi.RoutedDistance(rs.locationService, t.Location, ride.StartLocation)
+	total := pickup + taxi.TripDistance(rs.locationService, ride.Stops())
+	if total <= 0 {
+		return 0
+	}
+	return actual * time.Duration(pickup) / time.Duration(total)
+}
+
+// noShow ends a ride whose passenger was not at the pickup when the taxi arrived: the
+// ride becomes NO_SHOW, the taxi earns the no-show fee for the pickup leg and is freed
+// at the pickup like after a drop-off (see endRide).
+// Does nothing if the ride was taken away from this taxi in the meantime (see reassign).
+func (rs *RideScheduler) noShow(r *ride.Ride, t *taxi.Taxi) {
+	if !r.SetTaxiStatus(t.ID, ride.NO_SHOW, rs.clock.Now(), ride.IN_PROGRESS) {
+		fmt.Printf("[RideScheduler] %sRide #%d no longer belongs to taxi #%d, ignoring no-show\n", ride.TraceTag(r.TraceID), r.ID, t.ID)
+		return
+	}
+	rs.events.Publish(ride.RideNoShow, r, t.ID)
+
+	rs.mu.Lock()
+	delete(rs.activeRides, t.ID)
+	delete(rs.arrivals, t.ID)
+	next, preAssigned := rs.queued[t.ID]
+	delete(rs.queued, t.ID)
+	rs.mu.Unlock()
+
+	rs.ledger.RecordNoShow(r, t.ID, taxi.RoutedDistance(rs.locationService, t.Location, r.StartLocation))
+
+	rs.freeTaxi(r, t.ID, r.StartLocation, next, preAssigned)
+}
+
+// freeTaxi moves a taxi whose ride just ended to where it ended and marks it available,
+// unless a ride was pre-assigned to it, which then starts straight away, its driver
+// asked for a break, which then begins, or a cooldown keeps it out of dispatch first.
+func (rs *RideScheduler) freeTaxi(r *ride.Ride, taxiID int, at taxi.Location, next ride.RideRequest, preAssigned bool) {
+	ended := r.Status()
+	if !rs.store.UpdateLocation(taxiID, at) {
+		log.Printf("[RideScheduler] ERROR: Failed to update location for taxi #%d\n", taxiID)
+	}
+	if preAssigned && rs.breaks.coolDown(taxiID, func() { rs.handOffCooled(next, taxiID) }) {
+		fmt.Printf("[RideScheduler] %sRide #%d %s - taxi #%d now at (%d, %d), cooling down before pre-assigned ride #%d\n", ride.TraceTag(r.TraceID),
+			r.ID, ended, taxiID, at.X, at.Y, next.RideID)
+		return
+	}
+	if preAssigned && rs.handOff(next, taxiID) {
+		fmt.Printf("[RideScheduler] %sRide #%d %s - taxi #%d now at (%d, %d), went straight on to ride #%d\n", ride.TraceTag(r.TraceID),
+			r.ID, ended, taxiID, at.X, at.Y, next.RideID)
+		return
+	}
+	if rs.breaks.begin(taxiID) {
+		fmt.Printf("[RideScheduler] %sRide #%d %s - taxi #%d now at (%d, %d), driver going on break\n", ride.TraceTag(r.TraceID),
+			r.ID, ended, taxiID, at.X, at.Y)
+		return
+	}
+	if rs.breaks.coolDown(taxiID, func() { rs.releaseCooled(taxiID) }) {
+		fmt.Printf("[RideScheduler] %sRide #%d %s - taxi #%d now at (%d, %d), cooling down for %v\n", ride.TraceTag(r.TraceID),
+			r.ID, ended, taxiID, at.X, at.Y, rs.breaks.Cooldown())
+		return
+	}
+	if !rs.store.SetAvailability(taxiID, true) {
+		log.Printf("[RideScheduler] ERROR: Failed to set availability for taxi #%d\n", taxiID)
+	}
+
+	fmt.Printf("[RideScheduler] %sRide #%d %s - taxi #%d now at (%d, %d) and available\n", ride.TraceTag(r.TraceID),
+		r.ID, ended, taxiID, at.X, at.Y)
+}
+
+// handOffCooled starts the ride pre-assigned to a taxi once the taxi's cooldown is over,
+// or frees the taxi if it can no longer take it (the ride then goes back to the queue).
+func (rs *RideScheduler) handOffCooled(request ride.RideRequest, taxiID int) {
+	if !rs.handOff(request, taxiID) {
+		rs.releaseCooled(taxiID)
+	}
+}
+
+// releaseCooled frees a taxi whose cooldown is over: it starts a break its driver asked
+// for in the meantime, or makes the taxi available.
+func (rs *RideScheduler) releaseCooled(taxiID int) {
+	if !rs.breaks.Release(taxiID) {
+		fmt.Printf("[RideScheduler] Taxi #%d left the fleet during its cooldown\n", taxiID)
+		return
+	}
+	fmt.Printf("[RideScheduler] Taxi #%d cooled down\n", taxiID)
+}
+
+// breakDown handles a taxi breaking down in the middle of a ride.
+// The taxi is taken out of the fleet and the ride reassigned.
+func (rs *RideScheduler) breakDown(r *ride.Ride, taxi *taxi.Taxi) {
+	fmt.Printf("[RideScheduler] %sTaxi #%d BROKE DOWN during ride #%d (fault injected)\n", ride.TraceTag(r.TraceID), taxi.ID, r.ID)
+
+	if !rs.store.Remove(taxi.ID) {
+		log.Printf("[RideScheduler] ERROR: Failed to remove broken down taxi #%d\n", taxi.ID)
+	}
+	rs.ForgetTaxi(taxi.ID)
+}
+
+// ForgetTaxi is called once a taxi has been removed from the store: the ride it was
+// driving and the ride pre-assigned to it go back to the queue.
+// Calling it again for the same taxi does nothing.
+func (rs *RideScheduler) ForgetTaxi(taxiID int) {
+	rs.mu.Lock()
+	rideID, onRide := rs.activeRides[taxiID]
+	delete(rs.activeRides, taxiID)
+	delete(rs.arrivals, taxiID)
+	next, preAssigned := rs.queued[taxiID]
+	delete(rs.queued, taxiID)
+	rs.mu.Unlock()
+
+	if onRide {
+		rs.reassign(rideID, taxiID)
+	}
+	if preAssigned {
+		rs.requeuePreAssigned(next, taxiID)
+	}
+}
+
+// watchTaxis listens for store changes. Whenever a taxi becomes available, or an
+// available taxi moves, pending rides are retried. Taxis removed by another instance
+// sharing the store (see RedisTaxiStore) are forgotten here; local removals already were.
+// Runs as a goroutine for the lifetime of the scheduler. A scheduler cannot be stopped,
+// so nothing unsubscribes rs.taxiChanges; stopping one must (see TaxiStorage.Unsubscribe),
+// which also ends this loop.
+func (rs *RideScheduler) watchTaxis() {
+	for change := range rs.taxiChanges {
+		if change.Kind == taxi.TaxiRemoved {
+			rs.ForgetTaxi(change.TaxiID)
+		} else if change.IsAvailable {
+			rs.retryPending()
+		}
+	}
+}
+
+// retryPending moves pending rides to the retry queue so the dispatcher tries them again.
+// Never blocks: rides that do not fit in the queue stay pending for the next change.
+func (rs *RideScheduler) retryPending() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	waiting := rs.pending[:0]
+	for _, request := range rs.pending {
+		select {
+		case rs.retries <- request:
+		default:
+			waiting = append(waiting, request)
+		}
+	}
+	rs.pending = waiting
+}
+
+// requeue puts a ride back in the queue ahead of new requests without blocking, as it
+// is called by goroutines dispatch may be waiting on. Nothing drains the queue while
+// dispatch is paused, so once it is full the ride goes to the retry queue or, if that is
+// full too, waits with the pending rides until Resume or the next fleet change.
+func (rs *RideScheduler) requeue(request ride.RideRequest) {
+	select {
+	case rs.reassignments <- request:
+		return
+	default:
+	}
+	select {
+	case rs.retries <- request:
+		return
+	default:
+	}
+	rs.mu.Lock()
+	rs.pending = append(rs.pending, request)
+	rs.mu.Unlock()
+}
+
+// reassign takes a ride away from a failed taxi and puts it back in the queue.
+// The simulation does not track where a taxi is mid-ride, so the new taxi
+// always starts from the original pickup point.
+func (rs *RideScheduler) reassign(rideID, failedTaxiID int) {
+	r := rs.rides.Get(rideID)
+	if r == nil {
+		log.Printf("[RideScheduler] ERROR: Ride #%d not found for reassignment\n", rideID)
+		return
+	}
+
+	if !r.SetTaxiStatus(failedTaxiID, ride.CREATED, rs.clock.Now(), ride.ASSIGNED, ride.ACCEPTED, ride.IN_PROGRESS) {
+		return
+	}
+
+	fmt.Printf("[RideScheduler] %sRide #%d lost taxi #%d, returning it to the queue\n", ride.TraceTag(r.TraceID), rideID, failedTaxiID)
+	rs.events.Publish(ride.RideReassigned, r, failedTaxiID)
+
+	retry := ride.RequestFor(r)
+	retry.EnqueuedAt = rs.clock.Now()
+	rs.requeue(retry)
+}
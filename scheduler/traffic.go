@@ -0,0 +1,94 @@
+// traffic.go - Traffic model
+// Applies congestion multipliers to ride durations by time of day and by zone
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RushHour slows down every ride between StartHour (inclusive) and EndHour (exclusive).
+type RushHour struct {
+	StartHour  int     // Hour of day the rush hour begins (0-23)
+	EndHour    int     // Hour of day the rush hour ends (0-24)
+	Multiplier float64 // Duration multiplier, e.g. 1.5 = 50% slower
+}
+
+// CongestedZone slows down rides starting inside a zone.
+type CongestedZone struct {
+	Zone       taxi.Zone // Area affected
+	Multiplier float64   // Duration multiplier, e.g. 2 = twice as slow
+}
+
+// TrafficService scales ride durations to account for congestion.
+// Multipliers from matching rush hours and zones are multiplied together.
+// All methods are safe for concurrent access.
+type TrafficService struct {
+	mu          sync.RWMutex     // Protects rushHours, zones and maintenance
+	rushHours   []RushHour       // Time-of-day congestion
+	zones       []CongestedZone  // Area-based congestion
+	maintenance *ZoneMaintenance // Zones under maintenance, slowed down while their windows are open (nil for none)
+}
+
+// NewTrafficService creates a TrafficService with no congestion (multiplier 1).
+func NewTrafficService() *TrafficService {
+	return &TrafficService{}
+}
+
+// AddRushHour registers a time window during which all rides are slower.
+func (ts *TrafficService) AddRushHour(rushHour RushHour) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.rushHours = append(ts.rushHours, rushHour)
+	fmt.Printf("[TrafficService] Rush hour %02d:00-%02d:00 x%.2f\n",
+		rushHour.StartHour, rushHour.EndHour, rushHour.Multiplier)
+}
+
+// AddCongestedZone registers a zone in which rides are slower.
+func (ts *TrafficService) AddCongestedZone(zone CongestedZone) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.zones = append(ts.zones, zone)
+	fmt.Printf("[TrafficService] Congested zone %q x%.2f\n", zone.Zone.Name, zone.Multiplier)
+}
+
+// SetZoneMaintenance slows rides starting in zones under maintenance by their windows' slowdown.
+func (ts *TrafficService) SetZoneMaintenance(maintenance *ZoneMaintenance) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.maintenance = maintenance
+}
+
+// CongestedZones returns a copy of the registered congested zones.
+func (ts *TrafficService) CongestedZones() []CongestedZone {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return append([]CongestedZone(nil), ts.zones...)
+}
+
+// Multiplier returns the combined congestion multiplier at a location and time.
+func (ts *TrafficService) Multiplier(location taxi.Location, at time.Time) float64 {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	multiplier := 1.0
+	hour := at.Hour()
+	for _, rh := range ts.rushHours {
+		if hour >= rh.StartHour && hour < rh.EndHour {
+			multiplier *= rh.Multiplier
+		}
+	}
+	for _, cz := range ts.zones {
+		if cz.Zone.Contains(location) {
+			multiplier *= cz.Multiplier
+		}
+	}
+	if ts.maintenance != nil {
+		multiplier *= ts.maintenance.Slowdown(location, at)
+	}
+	return multiplier
+}
@@ -0,0 +1,78 @@
+// travel_time.go - Travel time model
+// Turns driven distances into durations, so the scheduler, ETAs and every other
+// simulated drive agree on how fast taxis are
+
+package scheduler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// DefaultTaxiSpeed is how many distance units a taxi drives per second (simulated time)
+// when no other speed is configured: one unit every 100ms.
+const DefaultTaxiSpeed = 10.0
+
+// TravelTimeModel decides how long driving a distance takes.
+// Implementations must be safe for concurrent use.
+type TravelTimeModel interface {
+	// Estimate returns the expected time to drive distance units starting at from at time at.
+	// Used for ETAs, pre-assignment and as the baseline for slow ride detection.
+	Estimate(distance int, from taxi.Location, at time.Time) time.Duration
+	// Sample returns how long one simulated drive actually takes, the estimate plus any variance.
+	Sample(distance int, from taxi.Location, at time.Time) time.Duration
+}
+
+// SpeedModel drives at a constant speed, slowed down by the traffic at the start of
+// the drive, and lets simulated drives take up to a random fraction longer or shorter.
+// All methods are safe for concurrent access.
+type SpeedModel struct {
+	unitsPerSecond float64         // Free-flow speed
+	variance       float64         // Simulated drives take estimate * (1 ± variance), 0 for exact
+	traffic        *TrafficService // Congestion at the start of a drive (nil for none)
+	rngMu          sync.Mutex      // Protects rng (rand.Rand is not safe for concurrent use)
+	rng            *rand.Rand      // Source of the variance
+}
+
+// NewSpeedModel creates a model driving unitsPerSecond (DefaultTaxiSpeed if not positive),
+// with drives varying by up to variance (e.g. 0.2 for ±20%) and slowed by traffic (may be nil).
+// The same seed gives the same drive times (0 = random seed).
+func NewSpeedModel(unitsPerSecond, variance float64, traffic *TrafficService, seed int64) *SpeedModel {
+	if unitsPerSecond <= 0 {
+		unitsPerSecond = DefaultTaxiSpeed
+	}
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return &SpeedModel{
+		unitsPerSecond: unitsPerSecond,
+		variance:       min(max(variance, 0), 1),
+		traffic:        traffic,
+		rng:            rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Estimate returns the time to drive distance at the model's speed, scaled by the
+// congestion at from at time at.
+func (sm *SpeedModel) Estimate(distance int, from taxi.Location, at time.Time) time.Duration {
+	seconds := float64(distance) / sm.unitsPerSecond
+	if sm.traffic != nil {
+		seconds *= sm.traffic.Multiplier(from, at)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Sample returns the estimate, made up to variance longer or shorter at random.
+func (sm *SpeedModel) Sample(distance int, from taxi.Location, at time.Time) time.Duration {
+	estimate := sm.Estimate(distance, from, at)
+	if sm.variance == 0 {
+		return estimate
+	}
+	sm.rngMu.Lock()
+	factor := 1 + sm.variance*(2*sm.rng.Float64()-1)
+	sm.rngMu.Unlock()
+	return time.Duration(float64(estimate) * factor)
+}
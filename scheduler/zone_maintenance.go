@@ -0,0 +1,114 @@
+// zone_maintenance.go - Scheduled maintenance windows per zone
+// Models road closures and events: while a window is open, taxis inside its zone get
+// no rides, so rides starting there are served from outside, and take longer
+
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// MaintenanceWindow closes a zone from From until Until.
+type MaintenanceWindow struct {
+	ID       int       `json:"id"`
+	Zone     taxi.Zone `json:"zone"`
+	From     time.Time `json:"from"`
+	Until    time.Time `json:"until"`
+	Slowdown float64   `json:"slowdown"`         // Duration multiplier of rides starting in the zone meanwhile, e.g. 1.5 = 50% slower
+	Reason   string    `json:"reason,omitempty"` // e.g. "Marathon"
+}
+
+// activeAt reports whether the window is open at a given time.
+func (window MaintenanceWindow) activeAt(at time.Time) bool {
+	return !at.Before(window.From) && at.Before(window.Until)
+}
+
+// ZoneMaintenance keeps the maintenance windows scheduled for zones. Expired windows
+// are dropped as they are found.
+// All methods are safe for concurrent access.
+type ZoneMaintenance struct {
+	mu      sync.RWMutex              // Protects windows and nextID
+	windows map[int]MaintenanceWindow // Window ID -> window
+	nextID  int                       // ID of the next window scheduled
+	clock   taxi.Clock                // For dropping expired windows
+}
+
+// NewZoneMaintenance creates a schedule without windows.
+func NewZoneMaintenance(clock taxi.Clock) *ZoneMaintenance {
+	return &ZoneMaintenance{windows: make(map[int]MaintenanceWindow), nextID: 1, clock: clock}
+}
+
+// Add schedules a window and returns it with its ID set.
+func (zm *ZoneMaintenance) Add(window MaintenanceWindow) MaintenanceWindow {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+
+	window.ID = zm.nextID
+	zm.nextID++
+	zm.windows[window.ID] = window
+	return window
+}
+
+// Remove cancels a window. Returns false if it was not found.
+func (zm *ZoneMaintenance) Remove(id int) bool {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+
+	if _, exists := zm.windows[id]; !exists {
+		return false
+	}
+	delete(zm.windows, id)
+	return true
+}
+
+// GetAll returns the windows that have not ended yet, ordered by ID.
+func (zm *ZoneMaintenance) GetAll() []MaintenanceWindow {
+	zm.mu.Lock()
+	defer zm.mu.Unlock()
+
+	now := zm.clock.Now()
+	windows := make([]MaintenanceWindow, 0, len(zm.windows))
+	for id, window := range zm.windows {
+		if !now.Before(window.Until) {
+			delete(zm.windows, id)
+			continue
+		}
+		windows = append(windows, window)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].ID < windows[j].ID })
+	return windows
+}
+
+// Closed returns the window a location is closed by at a given time, or false if
+// it is open. If windows overlap, the earliest scheduled wins.
+func (zm *ZoneMaintenance) Closed(location taxi.Location, at time.Time) (MaintenanceWindow, bool) {
+	zm.mu.RLock()
+	defer zm.mu.RUnlock()
+
+	var closing MaintenanceWindow
+	for _, window := range zm.windows {
+		if window.activeAt(at) && window.Zone.Contains(location) && (closing.ID == 0 || window.ID < closing.ID) {
+			closing = window
+		}
+	}
+	return closing, closing.ID != 0
+}
+
+// Slowdown returns the combined duration multiplier of the windows open at a location
+// and time (1 if none are).
+func (zm *ZoneMaintenance) Slowdown(location taxi.Location, at time.Time) float64 {
+	zm.mu.RLock()
+	defer zm.mu.RUnlock()
+
+	slowdown := 1.0
+	for _, window := range zm.windows {
+		if window.activeAt(at) && window.Zone.Contains(location) {
+			slowdown *= window.Slowdown
+		}
+	}
+	return slowdown
+}
@@ -0,0 +1,195 @@
+// admin.go - Admin HTTP endpoints
+// JSON snapshots of live fleet and ride state, so operators can inspect the system
+// without attaching a debugger
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// AdminStats is the summary returned by GET /admin/stats.
+type AdminStats struct {
+	Metrics
+	TotalRides         int            `json:"total_rides"`          // Rides ever requested
+	RidesByStatus      map[string]int `json:"rides_by_status"`      // Status name -> number of rides in it
+	TaxisInMaintenance int            `json:"taxis_in_maintenance"` // Taxis out of dispatch for maintenance
+	FlaggedRides       int            `json:"flagged_rides"`        // Anomalies waiting for review
+}
+
+// GetAdminStats returns the live metrics together with ride and fleet totals.
+func (s *Server) GetAdminStats() AdminStats {
+	taxis := s.GetTaxiSnapshot() // One fleet for the metrics and the maintenance count
+	stats := AdminStats{
+		Metrics:            s.metrics(taxis),
+		RidesByStatus:      make(map[string]int),
+		TaxisInMaintenance: taxis.CountInMaintenance(),
+		FlaggedRides:       len(s.GetFlaggedRides()),
+	}
+	for _, ride := range s.GetRides() {
+		stats.TotalRides++
+		stats.RidesByStatus[ride.Status().String()]++
+	}
+	return stats
+}
+
+// handleAdminTaxis serves GET /admin/taxis.
+func (s *Server) handleAdminTaxis(w http.ResponseWriter, r *http.Request) {
+	taxis := make([]TaxiV1, 0)
+	for _, taxi := range s.GetTaxiSnapshot().All() {
+		taxis = append(taxis, newTaxiV1(taxi))
+	}
+	writeJSON(w, taxis)
+}
+
+// handleAdminRides serves GET /admin/rides, filtered and paged by the query (see parseRideFilter).
+// The body is the page of rides; X-Total-Count says how many match across every page.
+func (s *Server) handleAdminRides(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseRideFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := s.SearchRides(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	views := make([]RideV1, 0, len(page.Rides))
+	for _, ride := range page.Rides {
+		views = append(views, newRideV1(ride))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	writeJSON(w, views)
+}
+
+// handleAdminQueue serves GET /admin/queue.
+func (s *Server) handleAdminQueue(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetQueue())
+}
+
+// handleAdminDeadLetters serves GET /admin/dead-letters.
+func (s *Server) handleAdminDeadLetters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetDeadLetterRides())
+}
+
+// handleAdminStats serves GET /admin/stats.
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetAdminStats())
+}
+
+// handleAdminFixture serves POST /admin/fixture and answers with the created IDs.
+func (s *Server) handleAdminFixture(w http.ResponseWriter, r *http.Request) {
+	var fixture Fixture
+	if err := json.NewDecoder(r.Body).Decode(&fixture); err != nil {
+		http.Error(w, fmt.Sprintf("parsing fixture: %v", err), http.StatusBadRequest)
+		return
+	}
+	result, err := s.ApplyFixture(&fixture)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleAdminPause serves POST /admin/pause.
+func (s *Server) handleAdminPause(w http.ResponseWriter, r *http.Request) {
+	s.PauseDispatch()
+	writeJSON(w, map[string]bool{"paused": true})
+}
+
+// handleAdminResume serves POST /admin/resume.
+func (s *Server) handleAdminResume(w http.ResponseWriter, r *http.Request) {
+	s.ResumeDispatch()
+	writeJSON(w, map[string]bool{"paused": false})
+}
+
+// handleAdminAssign serves POST /admin/rides/{id}/assign with a body of {"taxi_id": N}.
+func (s *Server) handleAdminAssign(w http.ResponseWriter, r *http.Request) {
+	rideID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid ride ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		TaxiID int `json:"taxi_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("parsing assignment: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.AssignRideToTaxi(rideID, body.TaxiID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, map[string]int{"ride_id": rideID, "taxi_id": body.TaxiID})
+}
+
+// handleAdminRequeue serves POST /admin/dead-letters/{id}/requeue.
+func (s *Server) handleAdminRequeue(w http.ResponseWriter, r *http.Request) {
+	rideID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid ride ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.RequeueDeadLetterRide(rideID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"ride_id": rideID})
+}
+
+// handleAdminDeleteTaxi serves DELETE /admin/taxis/{id}.
+func (s *Server) handleAdminDeleteTaxi(w http.ResponseWriter, r *http.Request) {
+	taxiID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid taxi ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.DeleteTaxi(taxiID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"deleted": taxiID})
+}
+
+// TaxiRegistration is the body of POST /admin/taxis.
+type TaxiRegistration struct {
+	Location   LocationV1 `json:"location"`
+	Attributes uint       `json:"attributes"` // Bit flags, see TaxiAttributes
+}
+
+// handleAdminRegisterTaxi serves POST /admin/taxis and answers with the new taxi's ID.
+func (s *Server) handleAdminRegisterTaxi(w http.ResponseWriter, r *http.Request) {
+	var registration TaxiRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, fmt.Sprintf("parsing taxi: %v", err), http.StatusBadRequest)
+		return
+	}
+	location := registration.Location.location()
+	if !taxi.GridArea.Contains(location) {
+		http.Error(w, fmt.Sprintf("location (%d, %d) is outside the grid", location.X, location.Y), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]int{"taxi_id": s.RegisterTaxi(location, taxi.TaxiAttributes(registration.Attributes))})
+}
+
+// writeJSON sends value as an indented JSON response.
+func writeJSON(w http.ResponseWriter, value any) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		log.Printf("[Server] ERROR: Failed to encode response: %v\n", err)
+		http.Error(w, "encoding failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,221 @@
+// api_v1.go - Version 1 of the HTTP API's JSON types
+// Keeps the JSON that API consumers read and write apart from the internal types, with
+// converters between them, so internals can change without breaking the API
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// APIVersion is the current version of the HTTP API. Every route is served under
+// /<version>/ (e.g. POST /v1/rides) and, for consumers written before versioning,
+// without a prefix as well; both answer alike.
+//
+// The types below are the v1 wire format of rides, ride orders, taxis, ride events and
+// locations. They must only change in backwards-compatible ways, such as new optional
+// fields: anything else needs V2 types and /v2/ routes next to these. Internal types
+// (Ride, Taxi, RideEvent, Location) may change freely as long as the converters here
+// keep producing the same JSON.
+//
+// Only these bodies are versioned: ride orders (POST /quotes, /rides, /rides/batch),
+// GET /admin/taxis, GET /admin/rides, GET /rides/{id}/wait, the ride archive, and ride
+// events sent to webhooks and /notifications/ws. Every other body, e.g. /admin/queue,
+// holds, breaks, payouts, stats, onboarding, dead-letters, audit and the taxi change
+// stream, is the JSON of its Go type and changes with it.
+const APIVersion = "v1"
+
+// stripAPIVersion is a Middleware that serves /v1/... like the unversioned path, so the
+// routes and the middleware after it only see unversioned paths.
+func stripAPIVersion(next http.Handler) http.Handler {
+	prefix := "/" + APIVersion
+	versioned := http.StripPrefix(prefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, prefix+"/") {
+			versioned.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LocationV1 is a point on the grid, {"X": 3, "Y": 4} (lower case keys are accepted too).
+type LocationV1 struct {
+	X int `json:"X"`
+	Y int `json:"Y"`
+}
+
+// newLocationV1 converts a location to its v1 form.
+func newLocationV1(location taxi.Location) LocationV1 {
+	return LocationV1{X: location.X, Y: location.Y}
+}
+
+// location converts a v1 location to the internal type.
+func (l LocationV1) location() taxi.Location {
+	return taxi.Location{X: l.X, Y: l.Y}
+}
+
+// newLocationsV1 converts a list of locations, keeping nil as nil.
+func newLocationsV1(locations []taxi.Location) []LocationV1 {
+	if locations == nil {
+		return nil
+	}
+	converted := make([]LocationV1, len(locations))
+	for i, location := range locations {
+		converted[i] = newLocationV1(location)
+	}
+	return converted
+}
+
+// locationsV1 converts v1 locations to the internal type, keeping nil as nil.
+func locationsV1(locations []LocationV1) []taxi.Location {
+	if locations == nil {
+		return nil
+	}
+	converted := make([]taxi.Location, len(locations))
+	for i, location := range locations {
+		converted[i] = location.location()
+	}
+	return converted
+}
+
+// TaxiV1 is a taxi as returned by GET /admin/taxis.
+type TaxiV1 struct {
+	ID            int        `json:"id"`
+	Location      LocationV1 `json:"location"`
+	Available     bool       `json:"available"`
+	InMaintenance bool       `json:"in_maintenance"`
+	Attributes    uint       `json:"attributes"`          // Bit flags, see TaxiAttributes
+	IdleSince     time.Time  `json:"idle_since,omitzero"` // Only set while available
+	Rating        float64    `json:"rating"`
+	EnergyLevel   int        `json:"energy_level"`
+	Pool          string     `json:"pool,omitempty"`
+}
+
+// newTaxiV1 converts a taxi snapshot to its v1 form.
+func newTaxiV1(taxi taxi.Taxi) TaxiV1 {
+	view := TaxiV1{
+		ID:            taxi.ID,
+		Location:      newLocationV1(taxi.Location),
+		Available:     taxi.IsAvailable,
+		InMaintenance: taxi.InMaintenance,
+		Attributes:    uint(taxi.Attributes),
+		Rating:        taxi.Rating,
+		EnergyLevel:   taxi.EnergyLevel,
+		Pool:          taxi.Pool,
+	}
+	if taxi.IsAvailable {
+		view.IdleSince = taxi.IdleSince
+	}
+	return view
+}
+
+// RideV1 is a ride as returned by GET /admin/rides and GET /rides/{id}/wait, and as
+// written to the ride archive.
+type RideV1 struct {
+	ID           int               `json:"id"`
+	ClientID     int               `json:"client_id"`
+	TaxiID       int               `json:"taxi_id,omitempty"` // 0 until a taxi is assigned
+	Start        LocationV1        `json:"start"`
+	End          LocationV1        `json:"end"`
+	Waypoints    []LocationV1      `json:"waypoints,omitempty"`
+	Requirements uint              `json:"requirements"` // Bit flags, see TaxiAttributes
+	Status       string            `json:"status"`
+	LinkedRideID int               `json:"linked_ride_id,omitempty"` // Other leg of a round trip (0 for none)
+	CreatedAt    time.Time         `json:"created_at"`
+	AssignedAt   time.Time         `json:"assigned_at,omitzero"`
+	StartedAt    time.Time         `json:"started_at,omitzero"`
+	FinishedAt   time.Time         `json:"finished_at,omitzero"`
+	ExpiresAt    time.Time         `json:"expires_at,omitzero"`
+	TraceID      string            `json:"trace_id,omitempty"`
+	Pool         string            `json:"pool,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// newRideV1 converts a ride snapshot to its v1 form.
+func newRideV1(ride *ride.Ride) RideV1 {
+	return RideV1{
+		ID:           ride.ID,
+		ClientID:     ride.ClientID,
+		TaxiID:       ride.TaxiID(),
+		Start:        newLocationV1(ride.StartLocation),
+		End:          newLocationV1(ride.EndLocation),
+		Waypoints:    newLocationsV1(ride.Waypoints),
+		Requirements: uint(ride.Requirements),
+		Status:       ride.Status().String(),
+		LinkedRideID: ride.LinkedRideID,
+		CreatedAt:    ride.CreatedAt,
+		AssignedAt:   ride.AssignedAt,
+		StartedAt:    ride.StartedAt,
+		FinishedAt:   ride.FinishedAt,
+		ExpiresAt:    ride.ExpiresAt,
+		TraceID:      ride.TraceID,
+		Pool:         ride.Pool,
+		Metadata:     ride.Metadata,
+	}
+}
+
+// RideOrderV1 is the body of POST /rides and POST /quotes, and an element of
+// POST /rides/batch. Give either a location or a place name (e.g. "Airport", or one of
+// the rider's favorites) for each end.
+type RideOrderV1 struct {
+	Start        LocationV1        `json:"start"`
+	End          LocationV1        `json:"end"`
+	From         string            `json:"from"`         // Named pickup, used instead of start
+	To           string            `json:"to"`           // Named destination, used instead of end
+	Waypoints    []LocationV1      `json:"waypoints"`    // Stops between start and end, in order
+	Requirements uint              `json:"requirements"` // Bit flags the taxi must have
+	Pool         string            `json:"pool"`         // Dispatch pool to serve the ride from ("" = general fleet)
+	Priority     string            `json:"priority"`     // "low", "normal" (default) or "high"
+	ExpiresIn    taxi.Duration     `json:"expires_in"`   // Deadline from now, e.g. "2m" (zero for none)
+	Metadata     map[string]string `json:"metadata"`
+	QuoteID      int               `json:"quote_id"` // Quote from POST /quotes to book the ride at (0 for none)
+}
+
+// orderRequest turns a ride order into the RideRequest of the client with the given token.
+func (s *Server) orderRequest(order RideOrderV1, token string) ride.RideRequest {
+	request := ride.RideRequest{
+		Token:         token,
+		StartLocation: order.Start.location(),
+		EndLocation:   order.End.location(),
+		Waypoints:     locationsV1(order.Waypoints),
+		StartPlace:    order.From,
+		EndPlace:      order.To,
+		Requirements:  taxi.TaxiAttributes(order.Requirements),
+		Pool:          order.Pool,
+		Priority:      ride.RidePriority(order.Priority),
+		Metadata:      order.Metadata,
+		QuoteID:       order.QuoteID,
+	}
+	if order.ExpiresIn.Duration > 0 {
+		request.ExpiresAt = s.clock.Now().Add(order.ExpiresIn.Duration)
+	}
+	return request
+}
+
+// RideEventV1 is a ride event as delivered to webhooks and notification WebSockets.
+type RideEventV1 struct {
+	Type     string            `json:"type"`               // What happened, e.g. "TAXI_ASSIGNED"
+	RideID   int               `json:"ride_id"`            // ID of the ride
+	TaxiID   int               `json:"taxi_id"`            // ID of the taxi involved (0 if none)
+	Time     time.Time         `json:"time"`               // When it happened
+	TraceID  string            `json:"trace_id,omitempty"` // Trace ID of the ride's request
+	Metadata map[string]string `json:"metadata,omitempty"` // The ride's metadata
+}
+
+// newRideEventV1 converts a ride event to its v1 form.
+func newRideEventV1(event ride.RideEvent) RideEventV1 {
+	return RideEventV1{
+		Type:     string(event.Type),
+		RideID:   event.RideID,
+		TaxiID:   event.TaxiID,
+		Time:     event.Time,
+		TraceID:  event.TraceID,
+		Metadata: event.Metadata,
+	}
+}
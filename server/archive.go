@@ -0,0 +1,189 @@
+// archive.go - Ride retention and archival
+// Moves rides that ended long enough ago out of memory into a gzip-compressed
+// JSON Lines file, so a long-running server does not grow without bound
+
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// archivedStatuses are the states a ride never leaves, so it may be archived.
+// EXPIRED rides are kept: an operator may still requeue them from the dead-letter queue.
+var archivedStatuses = []ride.RideStatus{ride.FINISHED, ride.FAILED, ride.NO_SHOW}
+
+// RideArchiver moves ended rides older than a retention age from a RideStorage to an archive file.
+// Each pass appends one gzip member holding a ride per line (see RideV1), so the
+// file stays one valid gzip stream however often rides are archived.
+type RideArchiver struct {
+	rides   ride.RideStorage // Where rides are taken from
+	events  *ride.EventBus   // For RIDE_ARCHIVED events, so the journal forgets archived rides
+	path    string           // Archive file, appended to
+	maxAge  time.Duration    // How long a ride stays in memory after it ended
+	clock   taxi.Clock       // For ride ages and the archiving interval
+	mu      sync.Mutex       // Protects running and serializes passes
+	running bool             // Set once Start has launched the archiving loop
+}
+
+// NewRideArchiver creates an archiver of rides that ended more than maxAge ago into the file at path.
+func NewRideArchiver(rides ride.RideStorage, events *ride.EventBus, path string, maxAge time.Duration, clock taxi.Clock) *RideArchiver {
+	return &RideArchiver{rides: rides, events: events, path: path, maxAge: maxAge, clock: clock}
+}
+
+// endedAt returns when a ride reached its final state. FAILED rides never finished,
+// so their request time is used instead.
+func endedAt(ride *ride.Ride) time.Time {
+	if ride.FinishedAt.IsZero() {
+		return ride.CreatedAt
+	}
+	return ride.FinishedAt
+}
+
+// ArchiveOld writes every ride that ended more than maxAge ago to the archive, then
+// removes it from the store. Rides are only removed once they were written.
+// Returns how many rides were archived.
+func (ra *RideArchiver) ArchiveOld() (int, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	cutoff := ra.clock.Now().Add(-ra.maxAge)
+	var old []*ride.Ride
+	for _, status := range archivedStatuses {
+		for _, ride := range ra.rides.ListByStatus(status) {
+			if endedAt(ride).Before(cutoff) {
+				old = append(old, ride)
+			}
+		}
+	}
+	if len(old) == 0 {
+		return 0, nil
+	}
+
+	if err := ra.write(old); err != nil {
+		return 0, err
+	}
+	for _, r := range old {
+		ra.events.Publish(ride.RideArchived, r, r.TaxiID())
+		ra.rides.Remove(r.ID)
+	}
+	return len(old), nil
+}
+
+// write appends rides to the archive as one gzip member.
+func (ra *RideArchiver) write(rides []*ride.Ride) error {
+	file, err := os.OpenFile(ra.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening ride archive: %w", err)
+	}
+	defer file.Close()
+
+	compressed := gzip.NewWriter(file)
+	encoder := json.NewEncoder(compressed)
+	for _, ride := range rides {
+		if err := encoder.Encode(newRideV1(ride)); err != nil {
+			return fmt.Errorf("writing ride archive: %w", err)
+		}
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("writing ride archive: %w", err)
+	}
+	return file.Sync()
+}
+
+// Start archives old rides every interval (simulated time) until the program exits.
+// Returns false if it was already started.
+func (ra *RideArchiver) Start(interval time.Duration) bool {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.running {
+		return false
+	}
+	ra.running = true
+
+	go func() {
+		ticker := ra.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			archived, err := ra.ArchiveOld()
+			if err != nil {
+				log.Printf("[RideArchiver] ERROR: %v\n", err)
+				continue
+			}
+			if archived > 0 {
+				fmt.Printf("[RideArchiver] Archived %d rides to %s\n", archived, ra.path)
+			}
+		}
+	}()
+	return true
+}
+
+// ReadArchive loads every ride of an archive file, in the order they were archived.
+// A missing file is not an error and yields no rides.
+func ReadArchive(path string) ([]RideV1, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening ride archive: %w", err)
+	}
+	defer file.Close()
+
+	rides := make([]RideV1, 0)
+	compressed, err := gzip.NewReader(bufio.NewReader(file))
+	if errors.Is(err, io.EOF) {
+		return rides, nil // Created but nothing archived yet
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ride archive: %w", err)
+	}
+	defer compressed.Close()
+
+	decoder := json.NewDecoder(compressed) // Reads on across gzip members
+	for {
+		var ride RideV1
+		err := decoder.Decode(&ride)
+		if errors.Is(err, io.EOF) {
+			return rides, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading ride archive: %w", err)
+		}
+		rides = append(rides, ride)
+	}
+}
+
+// EnableArchival keeps rides in memory for maxAge after they finished (or failed), then
+// moves them to the gzip-compressed archive at path (see ReadArchive). Archived rides
+// are gone from GetRide, searches and receipts; the ledger and payouts still count them.
+// Checks run every quarter of maxAge (simulated time), at most once a second.
+// Returns an error if maxAge is not positive or archival was already enabled.
+func (s *Server) EnableArchival(path string, maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return fmt.Errorf("ride retention must be positive, got %v", maxAge)
+	}
+	archiver := NewRideArchiver(s.rideStore, s.events, path, maxAge, s.clock)
+	s.mu.Lock()
+	if s.archiver != nil {
+		s.mu.Unlock()
+		return errors.New("ride archival is already enabled")
+	}
+	s.archiver = archiver
+	s.mu.Unlock()
+
+	archiver.Start(max(maxAge/4, time.Second))
+	fmt.Printf("[Server] Archiving rides ended more than %v ago to %s\n", maxAge, path)
+	return nil
+}
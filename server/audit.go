@@ -0,0 +1,41 @@
+// audit.go - Assignment audit API
+// Serves the scheduler's record of how each ride's taxi was chosen to operators
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+)
+
+// GetAssignmentAudit returns the recorded assignment decisions of a ride, oldest first.
+// A ride still waiting for its first attempt has none.
+// Returns an error if the ride was not found.
+func (s *Server) GetAssignmentAudit(rideID int) ([]scheduler.AssignmentDecision, error) {
+	if _, err := s.GetRide(rideID); err != nil {
+		return nil, err
+	}
+	decisions := s.audit.ForRide(rideID)
+	if decisions == nil {
+		decisions = make([]scheduler.AssignmentDecision, 0)
+	}
+	return decisions, nil
+}
+
+// handleAdminRideAudit serves GET /admin/rides/{id}/audit.
+func (s *Server) handleAdminRideAudit(w http.ResponseWriter, r *http.Request) {
+	rideID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid ride ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	decisions, err := s.GetAssignmentAudit(rideID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, decisions)
+}
@@ -0,0 +1,182 @@
+// batch.go - Batched ride requests
+// Books several rides in one call, e.g. the shuttles of a corporate event, so that
+// either every ride is created and queued, one after another, or none is
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// maxRideBatch is the most rides one RequestRides call may book. Keeps the queue
+// lock, which blocks every other request while a batch is queued, short.
+const maxRideBatch = 100
+
+// ErrBatchTooLarge is returned by RequestRides for more than maxRideBatch requests.
+var ErrBatchTooLarge = errors.New("ride batch too large")
+
+// BatchFailure is why one request of a batch was rejected.
+type BatchFailure struct {
+	Index int   // Position of the request in the batch
+	Err   error // What RequestRide would have returned for it
+}
+
+// BatchError is returned by RequestRides when some requests of a batch are rejected.
+// The whole batch is then refused: no ride of it was created.
+// errors.Is and errors.As see through it to each failure, e.g.
+// errors.Is(err, ErrInvalidRequest) is true if any request failed validation.
+type BatchError struct {
+	Size     int            // Requests in the batch
+	Failures []BatchFailure // The rejected ones, in batch order
+}
+
+// Error lists every rejected request, e.g. "2 of 5 ride requests rejected: #1: ...; #4: ...".
+func (e *BatchError) Error() string {
+	reasons := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		reasons = append(reasons, fmt.Sprintf("#%d: %v", failure.Index, failure.Err))
+	}
+	return fmt.Sprintf("%d of %d ride requests rejected: %s", len(e.Failures), e.Size, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the error of every rejected request.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+// RequestRides books every request of a batch as RequestRide would, all or nothing.
+// Every request is authenticated, geocoded and validated first; if any fails, no ride
+// is created and a *BatchError tells which requests failed and why. Otherwise the rides
+// are created and queued in batch order, with no other request queued in between, and
+// their IDs are returned in the same order.
+// Each request carries its own Token, so one batch may book rides for several riders.
+// Low-priority requests are shed like RequestRide's: rejected ones fail the batch with
+// an *OverloadError, deferred ones are created but held back.
+// Returns a *BatchError, ErrShuttingDown, or an error wrapping ErrBatchTooLarge; none
+// of them leaves any ride behind, except that rides already created when Shutdown
+// interrupts a batch waiting for room in a full queue are FAILED.
+func (s *Server) RequestRides(requests []ride.RideRequest) ([]int, error) {
+	if len(requests) == 0 {
+		return []int{}, nil
+	}
+	if len(requests) > maxRideBatch {
+		return nil, fmt.Errorf("%w: %d rides, at most %d", ErrBatchTooLarge, len(requests), maxRideBatch)
+	}
+	requests = slices.Clone(requests) // The caller's requests are left as they were
+
+	var failures []BatchFailure
+	quotes := make([]RideQuote, 0)
+	for i := range requests {
+		err := s.authenticate(&requests[i])
+		if err == nil {
+			err = s.resolvePlaces(&requests[i])
+		}
+		if err == nil {
+			var quote RideQuote
+			quote, err = s.priceRequest(&requests[i])
+			quotes = append(quotes, quote)
+		}
+		if err != nil {
+			failures = append(failures, BatchFailure{Index: i, Err: err})
+		}
+		if requests[i].TraceID == "" {
+			requests[i].TraceID = ride.NewTraceID()
+		}
+	}
+
+	// Exclusive, so no other request is queued between the rides of the batch and
+	// Shutdown cannot close the queue halfway through it
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.shutdown {
+		fmt.Printf("[Server] Rejecting batch of %d ride requests, server is shutting down\n", len(requests))
+		s.restoreQuotes(quotes...)
+		return nil, ErrShuttingDown
+	}
+
+	for i, request := range requests {
+		if slices.ContainsFunc(failures, func(failure BatchFailure) bool { return failure.Index == i }) {
+			continue
+		}
+		if err := s.validate(request); err != nil {
+			failures = append(failures, BatchFailure{Index: i, Err: err})
+		}
+	}
+	deferrals := make([]time.Duration, len(requests))
+	reasons := make([]error, len(requests))
+	for i, request := range requests {
+		if len(failures) > 0 {
+			break // Rejected anyway, so nothing is counted as shed
+		}
+		every, err := s.shedder.Admit(request)
+		if err != nil && every == 0 {
+			failures = append(failures, BatchFailure{Index: i, Err: err})
+		}
+		deferrals[i], reasons[i] = every, err
+	}
+	if len(failures) > 0 {
+		slices.SortFunc(failures, func(a, b BatchFailure) int { return a.Index - b.Index })
+		err := &BatchError{Size: len(requests), Failures: failures}
+		fmt.Printf("[Server] Rejecting batch: %v\n", err)
+		s.restoreQuotes(quotes...)
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(requests))
+	now := s.clock.Now()
+	for i, request := range requests {
+		s.record(TraceEntry{Kind: TraceRideRequested, Request: traceRequest(request, now)})
+		if deferrals[i] > 0 {
+			ids = append(ids, s.deferRide(request, deferrals[i], reasons[i]))
+			continue
+		}
+		id, err := s.enqueueRide(request)
+		if err != nil {
+			// Shutdown started with the queue full: none of the batch's rides goes ahead
+			s.failUnqueued(ids...)
+			s.restoreQuotes(quotes...)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	fmt.Printf("[Server] Queued batch of %d rides: %v\n", len(ids), ids)
+	return ids, nil
+}
+
+// handleRequestRides serves POST /rides/batch: a JSON array of RideOrderV1, all booked
+// for the rider of the Bearer token, or none of them. Answers with the new ride IDs in order.
+func (s *Server) handleRequestRides(w http.ResponseWriter, r *http.Request) {
+	var orders []RideOrderV1
+	if err := json.NewDecoder(r.Body).Decode(&orders); err != nil {
+		http.Error(w, fmt.Sprintf("parsing ride requests: %v", err), http.StatusBadRequest)
+		return
+	}
+	requests := make([]ride.RideRequest, 0, len(orders))
+	for _, order := range orders {
+		requests = append(requests, s.orderRequest(order, bearerToken(r)))
+	}
+
+	ids, err := s.RequestRides(requests)
+	switch {
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrForbidden):
+		writeAuthError(w, err) // Every order has the same token, so every one failed alike
+	case errors.Is(err, ErrShuttingDown), errors.Is(err, ErrOverloaded):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, map[string][]int{"ride_ids": ids})
+	}
+}
@@ -0,0 +1,112 @@
+// breaks.go - Driver breaks and cooldowns API
+// Lets drivers ask for and end breaks, and operators set the fleet-wide cooldown
+// and see who is on a break
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RequestTaxiBreak stops offering new rides to a taxi and, once its current ride (if
+// any) is dropped off, takes it ON_BREAK for duration (simulated time), after which it
+// is available again. A ride pre-assigned to the taxi goes back to the queue instead.
+// Returns an error for an unknown taxi, one in maintenance, one already on a break,
+// or a duration that is not positive.
+func (s *Server) RequestTaxiBreak(taxiID int, duration time.Duration) (scheduler.TaxiBreak, error) {
+	if duration <= 0 {
+		return scheduler.TaxiBreak{}, fmt.Errorf("break duration must be positive, got %v", duration)
+	}
+	taxi, exists := s.taxiStore.Get(taxiID)
+	if !exists {
+		return scheduler.TaxiBreak{}, fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	if taxi.InMaintenance {
+		return scheduler.TaxiBreak{}, fmt.Errorf("taxi #%d is in maintenance", taxiID)
+	}
+	brk, ok := s.breaks.Request(taxiID, duration)
+	if !ok {
+		return scheduler.TaxiBreak{}, fmt.Errorf("taxi #%d already has a break", taxiID)
+	}
+	if brk.State == scheduler.BreakRequested {
+		fmt.Printf("[Server] Taxi #%d asked for a %v break after its current ride\n", taxiID, duration)
+	}
+	return brk, nil
+}
+
+// EndTaxiBreak cancels a taxi's requested break, or ends its break (or else its
+// cooldown) now. Returns an error if the taxi has neither.
+func (s *Server) EndTaxiBreak(taxiID int) error {
+	if !s.breaks.End(taxiID) {
+		return fmt.Errorf("taxi #%d has no break or cooldown", taxiID)
+	}
+	fmt.Printf("[Server] Break of taxi #%d ended early\n", taxiID)
+	return nil
+}
+
+// SetTaxiCooldown keeps every taxi out of dispatch for cooldown (simulated time) after
+// each drop-off, for the driver to rest or clean the car, before it is available again
+// or starts a ride pre-assigned to it. Pass 0 to turn it off. Cooling taxis are listed
+// by GetTaxiBreaks as COOLING_DOWN.
+func (s *Server) SetTaxiCooldown(cooldown time.Duration) {
+	s.breaks.SetCooldown(cooldown)
+	fmt.Printf("[Server] Taxi cooldown after each drop-off: %v\n", cooldown)
+}
+
+// GetTaxiBreaks returns every requested and running break and every running cooldown,
+// ordered by taxi ID.
+func (s *Server) GetTaxiBreaks() []scheduler.TaxiBreak {
+	return s.breaks.GetAll()
+}
+
+// BreakRequest is the body of POST /driver/break.
+type BreakRequest struct {
+	Duration taxi.Duration `json:"duration"` // e.g. "15m"
+}
+
+// handleDriverBreak serves POST /driver/break for the taxi of the driver's token.
+func (s *Server) handleDriverBreak(w http.ResponseWriter, r *http.Request) {
+	taxiID, ok := s.driverTaxi(w, r)
+	if !ok {
+		return
+	}
+	var request BreakRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("parsing break request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.Duration.Duration <= 0 {
+		http.Error(w, "duration must be positive, e.g. \"15m\"", http.StatusBadRequest)
+		return
+	}
+	brk, err := s.RequestTaxiBreak(taxiID, request.Duration.Duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, brk)
+}
+
+// handleDriverEndBreak serves DELETE /driver/break: back to work before the break is over.
+func (s *Server) handleDriverEndBreak(w http.ResponseWriter, r *http.Request) {
+	taxiID, ok := s.driverTaxi(w, r)
+	if !ok {
+		return
+	}
+	if err := s.EndTaxiBreak(taxiID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"taxi_id": taxiID})
+}
+
+// handleAdminBreaks serves GET /admin/breaks.
+func (s *Server) handleAdminBreaks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetTaxiBreaks())
+}
@@ -0,0 +1,428 @@
+// broadcasts.go - Operator broadcasts to drivers
+// Lets operators message every driver, or those in a zone, e.g. "surge in zone 3",
+// over the drivers' WebSockets, and tracks who got and read each message
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// defaultBroadcastTTL is how long drivers who connect after a broadcast still get it,
+// unless the operator says otherwise (simulated time).
+const defaultBroadcastTTL = time.Hour
+
+// maxBroadcastLength is the longest broadcast message, in bytes.
+const maxBroadcastLength = 500
+
+// maxBroadcasts is how many broadcasts are kept for delivery tracking; older ones are dropped.
+const maxBroadcasts = 1000
+
+// BroadcastDelivery is how far one broadcast got with one driver.
+type BroadcastDelivery struct {
+	DriverID       int       `json:"driver_id"`
+	DeliveredAt    time.Time `json:"delivered_at,omitzero"`    // First written to one of the driver's WebSockets (zero = pending)
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitzero"` // The driver confirmed reading it (zero = not yet)
+}
+
+// Broadcast is a message an operator sent to drivers, with its delivery to each.
+type Broadcast struct {
+	ID           int                 `json:"id"`
+	Message      string              `json:"message"`
+	Zone         taxi.Zone           `json:"zone,omitzero"` // Sent to the drivers whose taxi was in it (zero = every driver)
+	SentAt       time.Time           `json:"sent_at"`
+	ExpiresAt    time.Time           `json:"expires_at"` // Drivers who connect later get it until then
+	Delivered    int                 `json:"delivered"`  // Recipients it was delivered to
+	Acknowledged int                 `json:"acknowledged"`
+	Recipients   []BroadcastDelivery `json:"recipients"` // Ordered by driver ID
+}
+
+// DriverBroadcast is a broadcast as its drivers receive it, e.g. on GET /driver/ws.
+type DriverBroadcast struct {
+	Type      string    `json:"type"` // Always "broadcast"
+	ID        int       `json:"id"`   // Acknowledge with POST /driver/broadcasts/{id}/ack
+	Message   string    `json:"message"`
+	SentAt    time.Time `json:"sent_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// message returns the broadcast as drivers receive it.
+func (b *Broadcast) message() DriverBroadcast {
+	return DriverBroadcast{Type: "broadcast", ID: b.ID, Message: b.Message, SentAt: b.SentAt, ExpiresAt: b.ExpiresAt}
+}
+
+// copy returns a copy of the broadcast with its delivery counts filled in.
+func (b *Broadcast) copy() Broadcast {
+	copied := *b
+	copied.Recipients = make([]BroadcastDelivery, len(b.Recipients))
+	copy(copied.Recipients, b.Recipients)
+	copied.Delivered, copied.Acknowledged = 0, 0
+	for _, delivery := range b.Recipients {
+		if !delivery.DeliveredAt.IsZero() {
+			copied.Delivered++
+		}
+		if !delivery.AcknowledgedAt.IsZero() {
+			copied.Acknowledged++
+		}
+	}
+	return copied
+}
+
+// delivery returns the delivery of the broadcast to a driver, or nil if the driver is
+// not a recipient.
+func (b *Broadcast) delivery(driverID int) *BroadcastDelivery {
+	i := sort.Search(len(b.Recipients), func(i int) bool { return b.Recipients[i].DriverID >= driverID })
+	if i == len(b.Recipients) || b.Recipients[i].DriverID != driverID {
+		return nil
+	}
+	return &b.Recipients[i]
+}
+
+// DriverBroadcasts keeps the broadcasts sent to drivers and the drivers' open
+// WebSockets, and delivers each broadcast to its recipients' connections, including
+// ones opened later while it has not expired.
+// All methods are safe for concurrent access.
+type DriverBroadcasts struct {
+	mu          sync.Mutex                                // Protects broadcasts, nextID and subscribers
+	broadcasts  map[int]*Broadcast                        // Broadcast ID -> broadcast
+	nextID      int                                       // ID of the next broadcast sent
+	subscribers map[int]map[chan DriverBroadcast]struct{} // Driver ID -> its open WebSocket streams
+	clock       taxi.Clock                                // For timestamps and expiry
+}
+
+// NewDriverBroadcasts creates a hub without broadcasts or subscribers.
+func NewDriverBroadcasts(clock taxi.Clock) *DriverBroadcasts {
+	return &DriverBroadcasts{
+		broadcasts:  make(map[int]*Broadcast),
+		nextID:      1,
+		subscribers: make(map[int]map[chan DriverBroadcast]struct{}),
+		clock:       clock,
+	}
+}
+
+// Send stores a broadcast to driverIDs and queues it on their open streams.
+// Returns the broadcast with its ID set.
+func (db *DriverBroadcasts) Send(message string, zone taxi.Zone, ttl time.Duration, driverIDs []int) Broadcast {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := db.clock.Now()
+	broadcast := &Broadcast{ID: db.nextID, Message: message, Zone: zone, SentAt: now, ExpiresAt: now.Add(ttl)}
+	db.nextID++
+	sort.Ints(driverIDs)
+	for _, id := range driverIDs {
+		broadcast.Recipients = append(broadcast.Recipients, BroadcastDelivery{DriverID: id})
+		db.queue(broadcast, id)
+	}
+	db.broadcasts[broadcast.ID] = broadcast
+	delete(db.broadcasts, broadcast.ID-maxBroadcasts)
+	return broadcast.copy()
+}
+
+// queue offers a broadcast to every open stream of a driver. A full stream misses it;
+// the broadcast stays pending for the driver's next connection.
+// Must be called with db.mu held.
+func (db *DriverBroadcasts) queue(broadcast *Broadcast, driverID int) {
+	for ch := range db.subscribers[driverID] {
+		select {
+		case ch <- broadcast.message():
+		default:
+			log.Printf("[DriverBroadcasts] WARNING: A WebSocket of driver #%d is too far behind, broadcast #%d left pending\n", driverID, broadcast.ID)
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the broadcasts to driverID, starting with
+// those still pending for it, and a function that closes the channel again.
+func (db *DriverBroadcasts) Subscribe(driverID int) (<-chan DriverBroadcast, func()) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ch := make(chan DriverBroadcast, notifyBufferSize)
+	if db.subscribers[driverID] == nil {
+		db.subscribers[driverID] = make(map[chan DriverBroadcast]struct{})
+	}
+	db.subscribers[driverID][ch] = struct{}{}
+
+	now := db.clock.Now()
+	pending := make([]*Broadcast, 0)
+	for _, broadcast := range db.broadcasts {
+		if delivery := broadcast.delivery(driverID); delivery != nil && delivery.DeliveredAt.IsZero() && now.Before(broadcast.ExpiresAt) {
+			pending = append(pending, broadcast)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	for _, broadcast := range pending {
+		select {
+		case ch <- broadcast.message():
+		default: // Left pending for the next connection
+		}
+	}
+
+	unsubscribe := func() {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if _, open := db.subscribers[driverID][ch]; !open {
+			return // Already unsubscribed
+		}
+		delete(db.subscribers[driverID], ch)
+		if len(db.subscribers[driverID]) == 0 {
+			delete(db.subscribers, driverID)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// MarkDelivered records that a broadcast reached one of a driver's connections.
+// Only the first delivery counts.
+func (db *DriverBroadcasts) MarkDelivered(broadcastID, driverID int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if broadcast, exists := db.broadcasts[broadcastID]; exists {
+		if delivery := broadcast.delivery(driverID); delivery != nil && delivery.DeliveredAt.IsZero() {
+			delivery.DeliveredAt = db.clock.Now()
+		}
+	}
+}
+
+// Acknowledge records that a driver read a broadcast, which also counts as delivered.
+// Returns false if the broadcast is unknown or was not sent to the driver.
+func (db *DriverBroadcasts) Acknowledge(broadcastID, driverID int) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	broadcast, exists := db.broadcasts[broadcastID]
+	if !exists {
+		return false
+	}
+	delivery := broadcast.delivery(driverID)
+	if delivery == nil {
+		return false
+	}
+	now := db.clock.Now()
+	if delivery.DeliveredAt.IsZero() {
+		delivery.DeliveredAt = now
+	}
+	if delivery.AcknowledgedAt.IsZero() {
+		delivery.AcknowledgedAt = now
+	}
+	return true
+}
+
+// Get returns a copy of a broadcast. Returns false if it is unknown.
+func (db *DriverBroadcasts) Get(id int) (Broadcast, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	broadcast, exists := db.broadcasts[id]
+	if !exists {
+		return Broadcast{}, false
+	}
+	return broadcast.copy(), true
+}
+
+// GetAll returns copies of the broadcasts kept, newest first.
+func (db *DriverBroadcasts) GetAll() []Broadcast {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	broadcasts := make([]Broadcast, 0, len(db.broadcasts))
+	for _, broadcast := range db.broadcasts {
+		broadcasts = append(broadcasts, broadcast.copy())
+	}
+	sort.Slice(broadcasts, func(i, j int) bool { return broadcasts[i].ID > broadcasts[j].ID })
+	return broadcasts
+}
+
+// BroadcastToDrivers sends a message to every driver, or, with a non-zero zone, to the
+// drivers whose taxi is in it now. It is pushed to the drivers' open WebSockets
+// (GET /driver/ws) and to those they open until ttl has passed (0 for an hour), and
+// each recipient's delivery and acknowledgement is tracked (see GetBroadcast).
+// Returns an error if the message is empty or too long, the zone is empty, or ttl is negative.
+func (s *Server) BroadcastToDrivers(message string, zone taxi.Zone, ttl time.Duration) (Broadcast, error) {
+	message = strings.TrimSpace(message)
+	if message == "" || len(message) > maxBroadcastLength {
+		return Broadcast{}, fmt.Errorf("message must be 1 to %d bytes, got %d", maxBroadcastLength, len(message))
+	}
+	if zone.Min.X > zone.Max.X || zone.Min.Y > zone.Max.Y {
+		return Broadcast{}, fmt.Errorf("zone %q is empty: min %v is past max %v", zone.Name, zone.Min, zone.Max)
+	}
+	if ttl < 0 {
+		return Broadcast{}, fmt.Errorf("ttl must not be negative, got %v", ttl)
+	}
+	if ttl == 0 {
+		ttl = defaultBroadcastTTL
+	}
+
+	recipients := make([]int, 0)
+	for _, driver := range s.taxiManager.GetDrivers() {
+		if zone == (taxi.Zone{}) {
+			recipients = append(recipients, driver.ID)
+			continue
+		}
+		if taxi, exists := s.taxiStore.Get(driver.TaxiID); exists && zone.Contains(taxi.Location) {
+			recipients = append(recipients, driver.ID)
+		}
+	}
+
+	broadcast := s.broadcasts.Send(message, zone, ttl, recipients)
+	target := "every driver"
+	if zone != (taxi.Zone{}) {
+		target = fmt.Sprintf("drivers in zone %q", zone.Name)
+	}
+	fmt.Printf("[Server] Broadcast #%d to %s (%d): %s\n", broadcast.ID, target, len(recipients), message)
+	return broadcast, nil
+}
+
+// GetBroadcast returns a broadcast with its delivery to each recipient.
+// Returns an error if it is unknown (or so old it was dropped).
+func (s *Server) GetBroadcast(id int) (Broadcast, error) {
+	broadcast, exists := s.broadcasts.Get(id)
+	if !exists {
+		return Broadcast{}, fmt.Errorf("broadcast #%d not found", id)
+	}
+	return broadcast, nil
+}
+
+// GetBroadcasts returns the last 1000 broadcasts with their deliveries, newest first.
+func (s *Server) GetBroadcasts() []Broadcast {
+	return s.broadcasts.GetAll()
+}
+
+// SubscribeDriverBroadcasts returns a channel receiving the broadcasts to a driver,
+// starting with those still pending for it, as GET /driver/ws does for driver apps,
+// and a function that closes the channel again. Call AcknowledgeBroadcast once the
+// driver has read one; receiving it does not count as delivered by itself.
+func (s *Server) SubscribeDriverBroadcasts(driverID int) (<-chan DriverBroadcast, func()) {
+	return s.broadcasts.Subscribe(driverID)
+}
+
+// AcknowledgeBroadcast records that a driver read a broadcast.
+// Returns an error if the broadcast is unknown or was not sent to the driver.
+func (s *Server) AcknowledgeBroadcast(driverID, broadcastID int) error {
+	if !s.broadcasts.Acknowledge(broadcastID, driverID) {
+		return fmt.Errorf("broadcast #%d not found for driver #%d", broadcastID, driverID)
+	}
+	return nil
+}
+
+// BroadcastRequest is the body of POST /admin/broadcasts.
+type BroadcastRequest struct {
+	Message string        `json:"message"`
+	Zone    taxi.Zone     `json:"zone"` // Omit for every driver
+	TTL     taxi.Duration `json:"ttl"`  // Default 1h
+}
+
+// handleAdminBroadcast serves POST /admin/broadcasts.
+func (s *Server) handleAdminBroadcast(w http.ResponseWriter, r *http.Request) {
+	var request BroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("parsing broadcast: %v", err), http.StatusBadRequest)
+		return
+	}
+	broadcast, err := s.BroadcastToDrivers(request.Message, request.Zone, request.TTL.Duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, broadcast)
+}
+
+// handleAdminBroadcasts serves GET /admin/broadcasts.
+func (s *Server) handleAdminBroadcasts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetBroadcasts())
+}
+
+// handleAdminGetBroadcast serves GET /admin/broadcasts/{id}.
+func (s *Server) handleAdminGetBroadcast(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid broadcast ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	broadcast, err := s.GetBroadcast(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, broadcast)
+}
+
+// handleDriverAcknowledge serves POST /driver/broadcasts/{id}/ack for the driver of the Bearer token.
+func (s *Server) handleDriverAcknowledge(w http.ResponseWriter, r *http.Request) {
+	account, err := s.clients.Authorize(bearerToken(r), RoleDriver)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid broadcast ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.AcknowledgeBroadcast(account.DriverID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"acknowledged": id})
+}
+
+// handleDriverSocket serves GET /driver/ws: a WebSocket that receives the broadcasts
+// to the driver of the token as DriverBroadcast JSON text messages, starting with the
+// ones sent while the driver was offline. As on /notifications/ws, the token may also
+// be passed as ?token=.
+func (s *Server) handleDriverSocket(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	account, err := s.clients.Authorize(token, RoleDriver)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		if !errors.Is(err, errNotUpgradable) {
+			log.Printf("[Server] ERROR: WebSocket for driver #%d: %v\n", account.DriverID, err)
+		}
+		return
+	}
+	defer ws.Close()
+
+	broadcasts, unsubscribe := s.broadcasts.Subscribe(account.DriverID)
+	defer unsubscribe()
+	closed := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case broadcast := <-broadcasts:
+			body, err := json.Marshal(broadcast)
+			if err != nil {
+				log.Printf("[Server] ERROR: Failed to encode broadcast #%d: %v\n", broadcast.ID, err)
+				continue
+			}
+			if ws.WriteText(body) != nil {
+				return // Client went away; the read loop ends with the connection
+			}
+			s.broadcasts.MarkDelivered(broadcast.ID, account.DriverID)
+		}
+	}
+}
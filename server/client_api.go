@@ -0,0 +1,115 @@
+// client_api.go - Client HTTP endpoints
+// Lets riders sign up and request rides, and drivers answer ride offers, over HTTP,
+// authenticated with the API token of their account
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ClientRegistration is the body of POST /clients.
+type ClientRegistration struct {
+	Name string `json:"name"`
+}
+
+// ClientCredentials is the answer to POST /clients.
+type ClientCredentials struct {
+	ClientID int    `json:"client_id"`
+	Token    string `json:"token"` // Send as "Authorization: Bearer <token>"
+}
+
+// handleRegisterClient serves POST /clients.
+func (s *Server) handleRegisterClient(w http.ResponseWriter, r *http.Request) {
+	var registration ClientRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, fmt.Sprintf("parsing registration: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(registration.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	id, token := s.RegisterClient(registration.Name)
+	writeJSON(w, ClientCredentials{ClientID: id, Token: token})
+}
+
+// handleRequestRide serves POST /rides with a RideOrderV1 for the client whose token is
+// in the Authorization header, and answers with the new ride's ID.
+func (s *Server) handleRequestRide(w http.ResponseWriter, r *http.Request) {
+	var order RideOrderV1
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, fmt.Sprintf("parsing ride request: %v", err), http.StatusBadRequest)
+		return
+	}
+	request := s.orderRequest(order, bearerToken(r))
+	request.TraceID = RequestID(r.Context())
+	id, err := s.RequestRide(request)
+	switch {
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrForbidden):
+		writeAuthError(w, err)
+	case errors.Is(err, ErrShuttingDown), errors.Is(err, ErrOverloaded):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, map[string]int{"ride_id": id})
+	}
+}
+
+// handleDriverAnswer serves POST /driver/offers/{ride}/accept (accept) or .../decline,
+// answering for the taxi the token's driver is assigned to.
+func (s *Server) handleDriverAnswer(accept bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taxiID, ok := s.driverTaxi(w, r)
+		if !ok {
+			return
+		}
+		rideID, err := strconv.Atoi(r.PathValue("ride"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid ride ID %q", r.PathValue("ride")), http.StatusBadRequest)
+			return
+		}
+
+		if accept {
+			err = s.AcceptRide(taxiID, rideID)
+		} else {
+			err = s.DeclineRide(taxiID, rideID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, map[string]int{"ride_id": rideID, "taxi_id": taxiID})
+	}
+}
+
+// driverTaxi returns the taxi of the driver whose Bearer token the request carries,
+// or writes the error response and returns false.
+func (s *Server) driverTaxi(w http.ResponseWriter, r *http.Request) (int, bool) {
+	account, err := s.clients.Authorize(bearerToken(r), RoleDriver)
+	if err != nil {
+		writeAuthError(w, err)
+		return 0, false
+	}
+	driver, err := s.GetDriver(account.DriverID)
+	if err != nil || driver.TaxiID == 0 {
+		http.Error(w, fmt.Sprintf("driver #%d has no taxi", account.DriverID), http.StatusConflict)
+		return 0, false
+	}
+	return driver.TaxiID, true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header ("" if none).
+func bearerToken(r *http.Request) string {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
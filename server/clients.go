@@ -0,0 +1,261 @@
+// clients.go - Client accounts and API tokens
+// Registers the riders, drivers and admins allowed to use the API and issues each one
+// a secret token, so a request proves who it comes from instead of naming any client ID
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// ErrUnauthorized is returned for requests without a valid client token.
+var ErrUnauthorized = errors.New("unknown client or invalid token")
+
+// ErrForbidden is returned for requests whose token belongs to an account of the wrong role.
+var ErrForbidden = errors.New("not allowed for this account")
+
+// Role decides which parts of the API an account may use.
+type Role int
+
+const (
+	RoleRider  Role = iota // Requests rides
+	RoleDriver             // Answers ride offers for the taxi of one Driver
+	RoleAdmin              // Runs operator actions (pause, manual assignment, fixtures, taxi deletion)
+)
+
+// String returns the role's name, e.g. "admin".
+func (r Role) String() string {
+	switch r {
+	case RoleRider:
+		return "rider"
+	case RoleDriver:
+		return "driver"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// Client is a registered account that may use the API.
+type Client struct {
+	ID           int                      // Unique identifier, used as RideRequest.ClientID for riders
+	Name         string                   // Display name, e.g. "Ana Lima"
+	Role         Role                     // What the account may do
+	DriverID     int                      // Driver profile a driver account acts for (0 for other roles)
+	RegisteredAt time.Time                // When the account was created
+	Notify       NotificationPrefs        // How the client hears about its rides (zero value: every event, on every push channel)
+	Favorites    map[string]FavoritePlace // Saved places of a rider by normalized name, e.g. "home" (see SetFavorite)
+}
+
+// ClientManager keeps the registered clients and their API tokens.
+// Several Servers may share one ClientManager (see ServerConfig.Clients), so a token
+// works in every region.
+// All methods are safe for concurrent access.
+type ClientManager struct {
+	mu      sync.RWMutex     // Protects clients and tokens
+	clients map[int]*Client  // Map from client ID to Client pointer
+	tokens  map[string]int   // API token -> client ID
+	ids     taxi.IDGenerator // Hands out new client IDs
+	clock   taxi.Clock       // For registration timestamps
+}
+
+// NewClientManager creates an empty registry that takes client IDs from ids.
+func NewClientManager(ids taxi.IDGenerator, clock taxi.Clock) *ClientManager {
+	return &ClientManager{
+		clients: make(map[int]*Client),
+		tokens:  make(map[string]int),
+		ids:     ids,
+		clock:   clock,
+	}
+}
+
+// Register creates an account with the given role and returns its ID and API token.
+// driverID links a driver account to its Driver profile (0 for other roles).
+// The token is only handed out here; keep it to call the API.
+func (cm *ClientManager) Register(name string, role Role, driverID int) (int, string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	id := cm.ids.NextID()
+	cm.clients[id] = &Client{ID: id, Name: name, Role: role, DriverID: driverID, RegisteredAt: cm.clock.Now()}
+	token := newClientToken()
+	cm.tokens[token] = id
+	fmt.Printf("[ClientManager] Registered %s #%d (%s)\n", role, id, name)
+	return id, token
+}
+
+// Authenticate returns a copy of the account a token belongs to.
+// Returns false for unknown or revoked tokens.
+func (cm *ClientManager) Authenticate(token string) (Client, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	id, ok := cm.tokens[token]
+	if !ok {
+		return Client{}, false
+	}
+	return *cm.clients[id], true
+}
+
+// Authorize returns the account a token belongs to if it has the given role.
+// Returns ErrUnauthorized for unknown tokens and ErrForbidden for other roles.
+func (cm *ClientManager) Authorize(token string, role Role) (Client, error) {
+	client, ok := cm.Authenticate(token)
+	if !ok {
+		return Client{}, ErrUnauthorized
+	}
+	if err := client.hasRole(role); err != nil {
+		return Client{}, err
+	}
+	return client, nil
+}
+
+// hasRole returns ErrForbidden, wrapped, unless the account has role.
+func (client Client) hasRole(role Role) error {
+	if client.Role != role {
+		return fmt.Errorf("%w: %s account, needs %s", ErrForbidden, client.Role, role)
+	}
+	return nil
+}
+
+// Get returns a copy of a registered client.
+// Returns false if the client was not found.
+func (cm *ClientManager) Get(id int) (Client, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	client, exists := cm.clients[id]
+	if !exists {
+		return Client{}, false
+	}
+	return *client, true
+}
+
+// GetAll returns copies of every registered client, ordered by ID.
+func (cm *ClientManager) GetAll() []Client {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	clients := make([]Client, 0, len(cm.clients))
+	for _, client := range cm.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients
+}
+
+// SetNotificationPrefs replaces how a client is told about its rides.
+// Returns false if the client was not found.
+func (cm *ClientManager) SetNotificationPrefs(id int, prefs NotificationPrefs) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	client, exists := cm.clients[id]
+	if !exists {
+		return false
+	}
+	prefs.Channels = slices.Clone(prefs.Channels) // Copies of the client share it, so it is never modified
+	client.Notify = prefs
+	return true
+}
+
+// SetFavorite saves a named location for a client, replacing any favorite of the same
+// name (ignoring case). A client keeps at most maxFavorites.
+// Returns an error if the client was not found or already has too many favorites.
+func (cm *ClientManager) SetFavorite(id int, favorite FavoritePlace) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	client, exists := cm.clients[id]
+	if !exists {
+		return fmt.Errorf("client #%d not found", id)
+	}
+	key := normalizePlace(favorite.Name)
+	if _, replacing := client.Favorites[key]; !replacing && len(client.Favorites) >= maxFavorites {
+		return fmt.Errorf("client #%d already has %d favorite places, remove one first", id, maxFavorites)
+	}
+	favorites := maps.Clone(client.Favorites) // Copies of the client share the map, so it is never modified
+	if favorites == nil {
+		favorites = make(map[string]FavoritePlace)
+	}
+	favorites[key] = favorite
+	client.Favorites = favorites
+	return nil
+}
+
+// RemoveFavorite forgets a client's favorite place (name ignoring case).
+// Returns false if the client or the favorite was not found.
+func (cm *ClientManager) RemoveFavorite(id int, name string) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	client, exists := cm.clients[id]
+	if !exists {
+		return false
+	}
+	key := normalizePlace(name)
+	if _, saved := client.Favorites[key]; !saved {
+		return false
+	}
+	favorites := maps.Clone(client.Favorites)
+	delete(favorites, key)
+	client.Favorites = favorites
+	return true
+}
+
+// RotateToken replaces a client's token with a new one, revoking the old one.
+// Returns false if the client was not found.
+func (cm *ClientManager) RotateToken(id int) (string, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, exists := cm.clients[id]; !exists {
+		return "", false
+	}
+	cm.revoke(id)
+	token := newClientToken()
+	cm.tokens[token] = id
+	return token, true
+}
+
+// Remove deletes a client account and revokes its token.
+// Rides the client already requested are unaffected.
+// Returns false if the client was not found.
+func (cm *ClientManager) Remove(id int) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, exists := cm.clients[id]; !exists {
+		return false
+	}
+	cm.revoke(id)
+	delete(cm.clients, id)
+	fmt.Printf("[ClientManager] Removed client #%d\n", id)
+	return true
+}
+
+// revoke forgets every token of a client. Must be called with cm.mu held for writing.
+func (cm *ClientManager) revoke(id int) {
+	for token, owner := range cm.tokens {
+		if owner == id {
+			delete(cm.tokens, token)
+		}
+	}
+}
+
+// newClientToken returns a random 32 character hex API token.
+func newClientToken() string {
+	var b [16]byte
+	rand.Read(b[:]) // Never fails (see crypto/rand.Read)
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,368 @@
+// compare.go - Strategy comparison
+// Replays one recorded trace against several assignment strategies, each on a fresh
+// Server and ManualClock, and reports side by side how riders and taxis fared
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Strategy is one way of assigning taxis to compare. Zero fields keep the Server's defaults.
+type Strategy struct {
+	Name              string                  `json:"name"`
+	Weights           *taxi.ScoringWeights    `json:"weights,omitempty"`             // How taxis are ranked (nil = DefaultScoringWeights)
+	LookAhead         taxi.Duration           `json:"lookahead,omitzero"`            // Pre-assignment window (see Server.EnableLookAhead)
+	MaxPickupDistance int                     `json:"max_pickup_distance,omitempty"` // Farthest taxi sent to a pickup (0 = no limit)
+	BatchWindow       taxi.Duration           `json:"batch_window,omitzero"`         // Collect requests this long and match them together (see Server.EnableBatching)
+	BatchMatching     scheduler.BatchMatching `json:"batch_matching,omitempty"`      // How batches are matched: optimal (default) or greedy
+}
+
+// apply sets the strategy on a server.
+func (strategy Strategy) apply(server *Server) {
+	if strategy.Weights != nil {
+		server.SetScoringWeights(*strategy.Weights)
+	}
+	if strategy.LookAhead.Duration > 0 {
+		server.EnableLookAhead(strategy.LookAhead.Duration)
+	}
+	if strategy.MaxPickupDistance > 0 {
+		server.SetMaxPickupDistance(strategy.MaxPickupDistance)
+	}
+	if strategy.BatchWindow.Duration > 0 {
+		server.EnableBatching(strategy.BatchWindow.Duration)
+		server.SetBatchMatching(strategy.BatchMatching) // Checked by ParseStrategies
+	}
+}
+
+// BuiltinStrategies returns the strategies -compare knows by name.
+func BuiltinStrategies() map[string]Strategy {
+	return map[string]Strategy{
+		"default":      {Name: "default"},
+		"nearest":      {Name: "nearest", Weights: &taxi.ScoringWeights{Distance: 1}},
+		"idle":         {Name: "idle", Weights: &taxi.ScoringWeights{Distance: 1, IdleTime: 5}},
+		"lookahead":    {Name: "lookahead", LookAhead: taxi.Duration{Duration: 30 * time.Second}},
+		"batched":      {Name: "batched", BatchWindow: taxi.Duration{Duration: 500 * time.Millisecond}, BatchMatching: scheduler.OptimalMatching},
+		"batch-greedy": {Name: "batch-greedy", BatchWindow: taxi.Duration{Duration: 500 * time.Millisecond}, BatchMatching: scheduler.GreedyMatching},
+	}
+}
+
+// ParseStrategies reads the -compare flag: a JSON file holding an array of Strategy
+// objects if it names one, or else a comma-separated list of built-in strategy names.
+// At least two strategies are needed for a comparison.
+func ParseStrategies(spec string) ([]Strategy, error) {
+	var strategies []Strategy
+	if strings.HasSuffix(spec, ".json") {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, fmt.Errorf("reading strategies: %w", err)
+		}
+		if err := json.Unmarshal(data, &strategies); err != nil {
+			return nil, fmt.Errorf("parsing strategies %s: %w", spec, err)
+		}
+		for i, strategy := range strategies {
+			if strategy.Name == "" {
+				return nil, fmt.Errorf("strategies %s: strategy %d has no name", spec, i)
+			}
+			if strategy.LookAhead.Duration < 0 || strategy.MaxPickupDistance < 0 || strategy.BatchWindow.Duration < 0 {
+				return nil, fmt.Errorf("strategies %s: %s: lookahead, max_pickup_distance and batch_window must not be negative", spec, strategy.Name)
+			}
+			if _, err := scheduler.ParseBatchMatching(string(strategy.BatchMatching)); err != nil {
+				return nil, fmt.Errorf("strategies %s: %s: %w", spec, strategy.Name, err)
+			}
+		}
+	} else {
+		builtin := BuiltinStrategies()
+		for _, name := range strings.Split(spec, ",") {
+			strategy, known := builtin[strings.TrimSpace(name)]
+			if !known {
+				return nil, fmt.Errorf("unknown strategy %q, want default, nearest, idle, lookahead, batched, batch-greedy or a .json file", name)
+			}
+			strategies = append(strategies, strategy)
+		}
+	}
+
+	if len(strategies) < 2 {
+		return nil, fmt.Errorf("comparing needs at least two strategies, got %d", len(strategies))
+	}
+	return strategies, nil
+}
+
+// StrategyResult is how one strategy served the replayed demand.
+type StrategyResult struct {
+	Strategy        Strategy
+	Summary         TraceSummary  // Rides requested, finished and expired
+	Unfinished      int           // Rides neither finished nor expired when the run stopped
+	MeanWait        time.Duration // Average time from request until the taxi reached the pickup, over finished rides
+	PickupDistance  int           // Distance driven to pickups over every finished ride
+	MeanUtilization float64       // Average share of its time in the fleet a taxi spent driving rides
+	Variance        float64       // Variance of that share over the fleet; lower means work is spread more evenly
+	Simulated       time.Duration // Simulated time until every ride had ended (or the run gave up)
+}
+
+// ComparisonReport holds the result of every strategy, in the order given.
+type ComparisonReport struct {
+	Results []StrategyResult
+}
+
+// String formats the report as a table, one strategy per row, followed by the best
+// strategy for each measure. Only the strategies that finished the most rides compete
+// for best, as leaving rides unserved makes every measure look better.
+func (r ComparisonReport) String() string {
+	if len(r.Results) == 0 {
+		return "no strategies compared"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-12s %9s %8s %10s %9s %12s %10s %10s\n",
+		"strategy", "finished", "expired", "unfinished", "avg wait", "pickup dist", "util mean", "util var")
+	for _, result := range r.Results {
+		fmt.Fprintf(&b, "%-12s %9d %8d %10d %9v %12d %9.1f%% %10.4f\n",
+			result.Strategy.Name, result.Summary.Finished, result.Summary.Expired, result.Unfinished,
+			result.MeanWait.Round(100*time.Millisecond), result.PickupDistance,
+			100*result.MeanUtilization, result.Variance)
+	}
+
+	mostFinished := 0
+	for _, result := range r.Results {
+		mostFinished = max(mostFinished, result.Summary.Finished)
+	}
+	best := func(better func(a, b StrategyResult) bool) string {
+		var winner *StrategyResult
+		for i, result := range r.Results {
+			if result.Summary.Finished == mostFinished && (winner == nil || better(result, *winner)) {
+				winner = &r.Results[i]
+			}
+		}
+		return winner.Strategy.Name
+	}
+	fmt.Fprintf(&b, "Shortest wait: %s, least pickup distance: %s, most even utilization: %s",
+		best(func(a, b StrategyResult) bool { return a.MeanWait < b.MeanWait }),
+		best(func(a, b StrategyResult) bool { return a.PickupDistance < b.PickupDistance }),
+		best(func(a, b StrategyResult) bool { return a.Variance < b.Variance }))
+	return b.String()
+}
+
+// CompareStrategies replays the inputs of a recorded trace once per strategy and
+// reports how each did. Every run gets a fresh Server on a ManualClock with the same
+// seed, so the strategy is all that differs between them, and runs as fast as the
+// machine allows. A run stops once every replayed ride has finished or expired, or an
+// hour of simulated time after the last input.
+// Progress logging is discarded, as with RunEndToEnd, so os.Stdout stays pointed at the
+// null device afterwards: callers keep the original os.Stdout and print the report there.
+func CompareStrategies(entries []TraceEntry, strategies []Strategy, seed int64) ComparisonReport {
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+	}
+
+	var report ComparisonReport
+	for _, strategy := range strategies {
+		report.Results = append(report.Results, runStrategy(entries, strategy, seed))
+	}
+	return report
+}
+
+// runStrategy replays the trace against a fresh Server running strategy.
+func runStrategy(entries []TraceEntry, strategy Strategy, seed int64) StrategyResult {
+	clock := taxi.NewManualClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	server := NewServerWithConfig(ServerConfig{Clock: clock, Seed: seed})
+	strategy.apply(server)
+	usage := newFleetUsage(server)
+	defer usage.stop()
+	start := clock.Now()
+
+	replayer := NewTraceReplayer(server, entries)
+	replayed := make(chan struct{}) // Closed once every input was sent
+	go func() {
+		defer close(replayed)
+		replayer.Start()
+	}()
+
+	limit := time.Hour
+	if len(entries) > 0 {
+		limit += entries[len(entries)-1].At.Duration
+	}
+	done := false
+	clock.Settle()
+	for !done && clock.Since(start) < limit {
+		clock.Advance(time.Second)
+		clock.Settle()
+
+		select {
+		case <-replayed:
+			summary := replayer.Summary()
+			done = summary.Finished+summary.Expired+summary.NoShows == summary.Requested
+		default:
+		}
+	}
+
+	result := StrategyResult{Strategy: strategy, Summary: replayer.Summary(), Simulated: clock.Since(start)}
+	result.Unfinished = result.Summary.Requested - result.Summary.Finished - result.Summary.Expired - result.Summary.NoShows
+	result.PickupDistance = pickupDistance(server)
+	result.MeanWait = usage.meanWait(server)
+	result.MeanUtilization, result.Variance = usage.utilization(clock.Now())
+	if done {
+		server.Shutdown() // A replay still blocked on a full queue would hold it up
+	}
+	return result
+}
+
+// pickupDistance sums the distance driven to pickups for every finished ride: the
+// distance the ledger credits the taxis with, less the trips themselves.
+func pickupDistance(server *Server) int {
+	total := 0
+	for _, taxi := range server.GetAllTaxis() {
+		if entry, ok := server.ledger.Get(taxi.ID); ok {
+			total += entry.DistanceDriven
+		}
+	}
+	for _, ride := range server.GetRidesByStatus(ride.FINISHED) {
+		total -= taxi.TripDistance(server.locationService, ride.Stops())
+	}
+	return total
+}
+
+// fleetUsage follows when each taxi joined the fleet and how long it spent driving
+// rides (from RIDE_STARTED, which covers the pickup leg, until the ride ended or the
+// taxi lost it), and how long each rider waited to be picked up.
+// All methods are safe for concurrent access.
+type fleetUsage struct {
+	mu      sync.Mutex
+	server  *Server
+	joined  map[int]time.Time     // Taxi ID -> when it was registered
+	driving map[int]time.Time     // Taxi ID -> when its current ride started (absent if not driving)
+	busy    map[int]time.Duration // Taxi ID -> time spent driving rides so far
+	waits   map[int]time.Duration // Ride ID -> time from request to pickup, for the last time it started
+	lastEnd time.Time             // When the latest ride finished, expired or failed
+
+	taxiChanges    <-chan taxi.TaxiChangedEvent // Subscription to the server's fleet
+	stopRideEvents func()                       // Unsubscribes from the server's ride events
+}
+
+// newFleetUsage starts following the taxis and rides of server.
+func newFleetUsage(server *Server) *fleetUsage {
+	fu := &fleetUsage{
+		server:  server,
+		joined:  make(map[int]time.Time),
+		driving: make(map[int]time.Time),
+		busy:    make(map[int]time.Duration),
+		waits:   make(map[int]time.Duration),
+	}
+	fu.taxiChanges = server.taxiStore.Subscribe()
+	rideEvents, stopRideEvents := server.SubscribeRideEventsWith(ride.SubscribeOptions{})
+	fu.stopRideEvents = stopRideEvents
+	go fu.trackTaxis(fu.taxiChanges)
+	go fu.trackRides(rideEvents)
+	return fu
+}
+
+// stop stops following the server, which may outlive the comparison run.
+func (fu *fleetUsage) stop() {
+	fu.server.taxiStore.Unsubscribe(fu.taxiChanges)
+	fu.stopRideEvents()
+}
+
+// trackTaxis notes when each taxi joins the fleet.
+func (fu *fleetUsage) trackTaxis(changes <-chan taxi.TaxiChangedEvent) {
+	for change := range changes {
+		if change.Kind == taxi.TaxiAdded {
+			fu.mu.Lock()
+			fu.joined[change.TaxiID] = fu.server.Clock().Now()
+			fu.mu.Unlock()
+		}
+	}
+}
+
+// trackRides adds up how long each taxi drives rides, and works out when each ride's
+// taxi reaches the pickup as it starts (taxis stay where the pickup leg began until the
+// ride ends, see SLAMonitor).
+func (fu *fleetUsage) trackRides(events <-chan ride.RideEvent) {
+	for event := range events {
+		var wait time.Duration
+		r := fu.server.rideStore.Get(event.RideID)
+		t, exists := fu.server.taxiStore.Get(event.TaxiID)
+		if event.Type == ride.RideStarted && r != nil && exists {
+			pickup := taxi.RoutedDistance(fu.server.locationService, t.Location, r.StartLocation)
+			wait = event.Time.Sub(r.CreatedAt) + fu.server.travelTime.Estimate(pickup, t.Location, event.Time)
+		}
+
+		fu.mu.Lock()
+		switch event.Type {
+		case ride.RideStarted:
+			fu.driving[event.TaxiID] = event.Time
+			fu.waits[event.RideID] = wait
+		case ride.RideFinished, ride.RideReassigned, ride.RideFailed, ride.RideNoShow:
+			if since, ok := fu.driving[event.TaxiID]; ok {
+				fu.busy[event.TaxiID] += event.Time.Sub(since)
+				delete(fu.driving, event.TaxiID)
+			}
+		}
+		if event.Type == ride.RideFinished || event.Type == ride.RideExpired || event.Type == ride.RideFailed || event.Type == ride.RideNoShow {
+			fu.lastEnd = event.Time
+		}
+		fu.mu.Unlock()
+	}
+}
+
+// meanWait averages the pickup waits of the server's finished rides.
+func (fu *fleetUsage) meanWait(server *Server) time.Duration {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	var total time.Duration
+	finished := server.GetRidesByStatus(ride.FINISHED)
+	for _, ride := range finished {
+		total += fu.waits[ride.ID]
+	}
+	if len(finished) == 0 {
+		return 0
+	}
+	return total / time.Duration(len(finished))
+}
+
+// utilization returns the mean and variance over the fleet of the share of its time
+// in the fleet each taxi spent driving rides, up to when the last ride ended (or now, if
+// none has), so rides a strategy never serves do not drag the run out.
+func (fu *fleetUsage) utilization(now time.Time) (float64, float64) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	if !fu.lastEnd.IsZero() {
+		now = fu.lastEnd
+	}
+
+	shares := make([]float64, 0, len(fu.joined))
+	for taxiID, joined := range fu.joined {
+		inFleet := now.Sub(joined)
+		if inFleet <= 0 {
+			continue
+		}
+		busy := fu.busy[taxiID]
+		if since, ok := fu.driving[taxiID]; ok {
+			busy += now.Sub(since)
+		}
+		shares = append(shares, float64(busy)/float64(inFleet))
+	}
+	if len(shares) == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+	for _, share := range shares {
+		mean += share
+	}
+	mean /= float64(len(shares))
+	variance := 0.0
+	for _, share := range shares {
+		variance += (share - mean) * (share - mean)
+	}
+	return mean, variance / float64(len(shares))
+}
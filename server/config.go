@@ -0,0 +1,211 @@
+// config.go - Hot-reloadable runtime configuration
+// Settings that are safe to change while rides are running, read from a JSON file
+// and re-applied whenever the file changes or the process receives SIGHUP
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// configPollInterval is how often WatchConfig checks the file for changes (real time).
+const configPollInterval = 2 * time.Second
+
+// ZoneRateConfig sets the dispatch pace of one zone (see Server.SetZoneDispatchRate).
+type ZoneRateConfig struct {
+	Zone     taxi.Zone     `json:"zone"`
+	Interval taxi.Duration `json:"interval"`
+}
+
+// RuntimeConfig holds the settings that can be changed without a restart.
+// Zero values mean "default".
+type RuntimeConfig struct {
+	DispatchInterval    taxi.Duration          `json:"dispatch_interval"`    // Pace outside every zone (default 3s)
+	ZoneRates           []ZoneRateConfig       `json:"zone_rates"`           // Per-zone paces; zones dropped from the file keep their last pace
+	AdaptiveDispatch    scheduler.AdaptiveRate `json:"adaptive_dispatch"`    // Faster dispatch under a backlog (zero = fixed paces)
+	MaxPickupDistance   int                    `json:"max_pickup_distance"`  // 0 = no limit
+	MaxAttempts         int                    `json:"max_attempts"`         // Dispatch attempts before a ride is dead-lettered (0 = no limit)
+	ConfirmationTimeout taxi.Duration          `json:"confirmation_timeout"` // 0 = drivers do not confirm
+	ScoringWeights      *taxi.ScoringWeights   `json:"scoring_weights"`      // nil = DefaultScoringWeights
+	SLA                 SLATargets             `json:"sla"`                  // Service levels rides are checked against (zero = none)
+	LoadShedding        LoadSheddingPolicy     `json:"load_shedding"`        // When low-priority rides are shed (zero = never)
+	Cooldown            taxi.Duration          `json:"cooldown"`             // Rest after each drop-off before a taxi is dispatched again (0 = none)
+	Tariffs             []ride.Tariff          `json:"tariffs"`              // Per fleet and zone, most specific first (none = DefaultTariff for every ride)
+}
+
+// LoadRuntimeConfig reads a runtime configuration from a JSON file.
+func LoadRuntimeConfig(path string) (RuntimeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RuntimeConfig{}, fmt.Errorf("reading config: %w", err)
+	}
+
+	var config RuntimeConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return RuntimeConfig{}, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if config.DispatchInterval.Duration < 0 || config.ConfirmationTimeout.Duration < 0 || config.MaxPickupDistance < 0 || config.MaxAttempts < 0 ||
+		config.AdaptiveDispatch.Threshold < 0 || config.AdaptiveDispatch.MinInterval.Duration < 0 ||
+		config.SLA.MaxAssignmentWait.Duration < 0 || config.SLA.MaxPickupETA.Duration < 0 ||
+		config.LoadShedding.MinAvailableTaxis < 0 || config.LoadShedding.MaxQueueDepth < 0 || config.LoadShedding.Defer.Duration < 0 ||
+		config.Cooldown.Duration < 0 {
+		return RuntimeConfig{}, fmt.Errorf("config %s: values must not be negative", path)
+	}
+	for _, rate := range config.ZoneRates {
+		if rate.Interval.Duration <= 0 {
+			return RuntimeConfig{}, fmt.Errorf("config %s: zone %q needs a positive interval", path, rate.Zone.Name)
+		}
+	}
+	for _, tariff := range config.Tariffs {
+		if err := tariff.Validate(); err != nil {
+			return RuntimeConfig{}, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return config, nil
+}
+
+// ApplyConfig changes the running Server to match config, logging every setting that changed.
+// Settings equal to the previously applied ones are left alone.
+func (s *Server) ApplyConfig(config RuntimeConfig) {
+	s.mu.Lock()
+	previous := s.config
+	s.config = config
+	s.mu.Unlock()
+
+	if current, old := dispatchInterval(config), dispatchInterval(previous); current != old {
+		fmt.Printf("[Server] Config changed: dispatch_interval %v -> %v\n", old, current)
+		s.scheduler.SetDefaultRate(current)
+	}
+
+	oldRates := make(map[string]ZoneRateConfig)
+	for _, rate := range previous.ZoneRates {
+		oldRates[rate.Zone.Name] = rate
+	}
+	for _, rate := range config.ZoneRates {
+		if old, exists := oldRates[rate.Zone.Name]; exists && old == rate {
+			continue
+		}
+		fmt.Printf("[Server] Config changed: zone %q dispatch interval -> %v\n", rate.Zone.Name, rate.Interval)
+		s.SetZoneDispatchRate(rate.Zone, rate.Interval.Duration)
+	}
+
+	if config.AdaptiveDispatch != previous.AdaptiveDispatch {
+		fmt.Printf("[Server] Config changed: adaptive_dispatch %+v -> %+v\n", previous.AdaptiveDispatch, config.AdaptiveDispatch)
+		s.EnableAdaptiveDispatch(config.AdaptiveDispatch)
+	}
+
+	if config.MaxPickupDistance != previous.MaxPickupDistance {
+		fmt.Printf("[Server] Config changed: max_pickup_distance %d -> %d\n", previous.MaxPickupDistance, config.MaxPickupDistance)
+		s.SetMaxPickupDistance(config.MaxPickupDistance)
+	}
+
+	if config.MaxAttempts != previous.MaxAttempts {
+		fmt.Printf("[Server] Config changed: max_attempts %d -> %d\n", previous.MaxAttempts, config.MaxAttempts)
+		s.SetMaxDispatchAttempts(config.MaxAttempts)
+	}
+
+	if current, old := scoringWeights(config), scoringWeights(previous); current != old {
+		fmt.Printf("[Server] Config changed: scoring_weights %+v -> %+v\n", old, current)
+		s.SetScoringWeights(current)
+	}
+
+	if config.ConfirmationTimeout != previous.ConfirmationTimeout {
+		fmt.Printf("[Server] Config changed: confirmation_timeout %v -> %v\n", previous.ConfirmationTimeout, config.ConfirmationTimeout)
+		s.RequireConfirmation(config.ConfirmationTimeout.Duration)
+	}
+
+	if config.SLA != previous.SLA {
+		fmt.Printf("[Server] Config changed: sla %+v -> %+v\n", previous.SLA, config.SLA)
+		s.SetSLATargets(config.SLA)
+	}
+
+	if config.LoadShedding != previous.LoadShedding {
+		fmt.Printf("[Server] Config changed: load_shedding %+v -> %+v\n", previous.LoadShedding, config.LoadShedding)
+		s.SetLoadShedding(config.LoadShedding)
+	}
+
+	if config.Cooldown != previous.Cooldown {
+		fmt.Printf("[Server] Config changed: cooldown %v -> %v\n", previous.Cooldown, config.Cooldown)
+		s.SetTaxiCooldown(config.Cooldown.Duration)
+	}
+
+	if !slices.Equal(config.Tariffs, previous.Tariffs) {
+		fmt.Printf("[Server] Config changed: tariffs (%d -> %d)\n", len(previous.Tariffs), len(config.Tariffs))
+		if err := s.SetTariffs(config.Tariffs); err != nil {
+			log.Printf("[Server] ERROR: Keeping previous tariffs: %v\n", err)
+		}
+	}
+}
+
+// dispatchInterval returns the configured default pace, filling in the default.
+func dispatchInterval(config RuntimeConfig) time.Duration {
+	if config.DispatchInterval.Duration == 0 {
+		return scheduler.DefaultDispatchInterval
+	}
+	return config.DispatchInterval.Duration
+}
+
+// scoringWeights returns the configured scoring weights, filling in the defaults.
+func scoringWeights(config RuntimeConfig) taxi.ScoringWeights {
+	if config.ScoringWeights == nil {
+		return taxi.DefaultScoringWeights()
+	}
+	return *config.ScoringWeights
+}
+
+// WatchConfig applies the config file at path now, then again whenever the file is
+// modified or the process receives SIGHUP. A file that fails to load is logged and
+// skipped, leaving the last good configuration in place.
+// Returns an error if the file cannot be loaded the first time.
+func (s *Server) WatchConfig(path string) error {
+	config, err := LoadRuntimeConfig(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	s.ApplyConfig(config)
+	fmt.Printf("[Server] Watching config %s (edit it or send SIGHUP to reload)\n", path)
+
+	go func() {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+
+		modified := info.ModTime()
+		for {
+			select {
+			case <-hangup:
+				fmt.Println("[Server] SIGHUP received, reloading config")
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || !info.ModTime().After(modified) {
+					continue
+				}
+				modified = info.ModTime()
+			}
+
+			config, err := LoadRuntimeConfig(path)
+			if err != nil {
+				log.Printf("[Server] ERROR: Keeping previous config: %v\n", err)
+				continue
+			}
+			s.ApplyConfig(config)
+		}
+	}()
+	return nil
+}
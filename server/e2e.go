@@ -0,0 +1,225 @@
+// e2e.go - End-to-end regression harness
+// Runs a whole Server in-process on a ManualClock, steps simulated time forward
+// and checks invariants that must hold for every run
+
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// maxEndToEndViolations stops a broken run from reporting the same problem thousands of times.
+const maxEndToEndViolations = 20
+
+// EndToEndConfig sizes an end-to-end run.
+type EndToEndConfig struct {
+	Taxis int           // Taxis registered before the first ride
+	Rides int           // Ride requests submitted at the start
+	Seed  int64         // Seed for taxi and ride locations and chaos faults
+	Step  time.Duration // Simulated time per Advance (default: 1s)
+
+	LookAhead   time.Duration // Pre-assignment window (0 = off, see Server.EnableLookAhead)
+	RedisAddr   string        // Keep the fleet in this Redis server instead of memory ("" = in memory)
+	StoreShards int           // Shards of the in-memory fleet (0 or 1 = one TaxiStore)
+	KeepStdout  bool          // Leave os.Stdout alone, e.g. under go test, which only shows it on failure
+}
+
+// EndToEndResult reports how an end-to-end run went.
+type EndToEndResult struct {
+	Config     EndToEndConfig
+	Simulated  time.Duration // Simulated time until every ride finished (or the run gave up)
+	Elapsed    time.Duration // Wall time the run took
+	Finished   int           // Rides that reached FINISHED
+	Violations []string      // Broken invariants, empty when the run passed
+}
+
+// Passed reports whether every invariant held.
+func (r EndToEndResult) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// String formats the result as a short report.
+func (r EndToEndResult) String() string {
+	verdict := "PASSED"
+	if !r.Passed() {
+		verdict = fmt.Sprintf("FAILED (%d violations)\n  %s", len(r.Violations), strings.Join(r.Violations, "\n  "))
+	}
+	return fmt.Sprintf("%d taxis, %d rides: %d finished after %v simulated (%v wall): %s",
+		r.Config.Taxis, r.Config.Rides, r.Finished,
+		r.Simulated, r.Elapsed.Round(time.Millisecond), verdict)
+}
+
+// RunEndToEnd registers the taxis, submits every ride, then advances the clock one step
+// at a time until all rides have finished or the simulated time limit is reached.
+// After every step it checks that no taxi is both available and on a ride; at the end
+// it checks that every ride finished, every taxi is free again and no ride event was dropped.
+// Progress logging is discarded (unless KeepStdout) so the report stays readable. The server's goroutines
+// outlive the run, so os.Stdout stays pointed at the null device afterwards: callers
+// keep the original os.Stdout and print the report there.
+func RunEndToEnd(config EndToEndConfig) EndToEndResult {
+	if config.Step <= 0 {
+		config.Step = time.Second
+	}
+	start := time.Now()
+	result := EndToEndResult{Config: config}
+
+	// Silence the per-ride log lines; restoring os.Stdout later would race with them
+	if !config.KeepStdout {
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout = devNull
+		}
+	}
+
+	clock := taxi.NewManualClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	serverConfig := ServerConfig{Clock: clock, Seed: config.Seed, StoreShards: config.StoreShards}
+	if config.RedisAddr != "" {
+		// A fresh namespace per run, so leftovers of earlier runs never count
+		prefix := fmt.Sprintf("taxischeduler-e2e-%d", start.UnixNano())
+		taxis, err := taxi.NewRedisTaxiStore(config.RedisAddr, prefix, clock)
+		if err != nil {
+			result.violation("%v", err)
+			result.Elapsed = time.Since(start)
+			return result
+		}
+		serverConfig.Taxis = taxis
+	}
+	server := NewServerWithConfig(serverConfig)
+	if config.LookAhead > 0 {
+		server.EnableLookAhead(config.LookAhead)
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+
+	for i := 0; i < config.Taxis; i++ {
+		server.RegisterTaxi(taxi.RandomLocation(rng, nil), 0)
+	}
+	requests := make([]ride.RideRequest, 0, config.Rides)
+	for i := 0; i < config.Rides; i++ {
+		clientID, token := server.RegisterClient(fmt.Sprintf("e2e client %d", i+1))
+		request := ride.RideRequest{ClientID: clientID, Token: token, StartLocation: taxi.RandomLocation(rng, nil), EndLocation: taxi.RandomLocation(rng, nil)}
+		for request.EndLocation == request.StartLocation {
+			request.EndLocation = taxi.RandomLocation(rng, nil)
+		}
+		requests = append(requests, request)
+	}
+	// Submitted in the background: a full queue only drains as the clock moves
+	rejected := make(chan string, config.Rides)
+	submitted := make(chan struct{}) // Closed once every request was sent
+	go func() {
+		defer close(submitted)
+		for _, request := range requests {
+			if _, err := server.RequestRide(request); err != nil {
+				rejected <- fmt.Sprintf("ride for client #%d rejected: %v", request.ClientID, err)
+			}
+		}
+	}()
+
+	// Every ride waits for one dispatch slot, plus time to drive it
+	limit := time.Duration(config.Rides)*2*scheduler.DefaultDispatchInterval + 10*time.Minute
+	clock.Settle()
+	for result.Simulated < limit {
+		clock.Advance(config.Step)
+		result.Simulated += config.Step
+		clock.Settle()
+
+		checkTaxisOnRides(server, &result)
+		if server.rideStore.Count() == config.Rides && len(server.GetRidesByStatus(ride.FINISHED)) == config.Rides {
+			break
+		}
+	}
+
+	// A submitter still blocked on a full queue holds up Shutdown, so leave the server running then
+	select {
+	case <-submitted:
+		server.Shutdown()
+		close(rejected)
+		for reason := range rejected {
+			result.violation("%s", reason)
+		}
+	case <-time.After(time.Second):
+		result.violation("request queue stayed full, not every ride was submitted")
+	}
+
+	// Final state: every ride done and every taxi free again
+	for _, r := range server.GetRides() {
+		if r.Status() == ride.FINISHED {
+			result.Finished++
+			continue
+		}
+		result.violation("ride #%d ended the run %s", r.ID, r.Status())
+	}
+	if submitted := server.rideStore.Count(); submitted != config.Rides {
+		result.violation("%d of %d rides were submitted", submitted, config.Rides)
+	}
+	for _, taxi := range server.GetAllTaxis() {
+		if !taxi.IsAvailable {
+			result.violation("taxi #%d still unavailable after all rides", taxi.ID)
+		}
+	}
+	if active := server.scheduler.ActiveRideCount(); active != 0 {
+		result.violation("scheduler still tracks %d active rides", active)
+	}
+	if depth := server.scheduler.QueueDepth(); depth != 0 {
+		result.violation("%d requests still queued", depth)
+	}
+	// A component that missed an event may look fine above and still be wrong
+	for _, subscriber := range server.events.Stats().Subscribers {
+		if subscriber.Dropped > 0 {
+			result.violation("%s dropped %d ride events", subscriber.Name, subscriber.Dropped)
+		}
+	}
+
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+// checkTaxisOnRides records a violation for every taxi that is available while a ride
+// still has it assigned, or that is assigned to two rides at once.
+// A store outside the process (Redis) can still be busy after Settle, so the rides are
+// read before and after the taxis, and only rides on the same taxi both times count.
+func checkTaxisOnRides(server *Server, result *EndToEndResult) {
+	before := make(map[int]int) // Ride ID -> taxi ID, for rides on a taxi
+	for _, ride := range server.GetRides() {
+		if isOnTaxi(ride) {
+			before[ride.ID] = ride.TaxiID()
+		}
+	}
+	taxis := make(map[int]taxi.Taxi)
+	for _, taxi := range server.GetAllTaxis() {
+		taxis[taxi.ID] = taxi
+	}
+
+	onRide := make(map[int]int) // Taxi ID -> ride ID
+	for _, ride := range server.GetRides() {
+		if taxiID, stayed := before[ride.ID]; !isOnTaxi(ride) || !stayed || taxiID != ride.TaxiID() {
+			continue
+		}
+		if other, busy := onRide[ride.TaxiID()]; busy {
+			result.violation("taxi #%d on rides #%d and #%d at once", ride.TaxiID(), other, ride.ID)
+		}
+		onRide[ride.TaxiID()] = ride.ID
+		if taxi, exists := taxis[ride.TaxiID()]; !exists || taxi.IsAvailable {
+			result.violation("taxi #%d available while %s on ride #%d", ride.TaxiID(), ride.Status(), ride.ID)
+		}
+	}
+}
+
+// isOnTaxi reports whether a ride holds its taxi (assigned, accepted or driving).
+func isOnTaxi(r *ride.Ride) bool {
+	status := r.Status()
+	return status == ride.ASSIGNED || status == ride.ACCEPTED || status == ride.IN_PROGRESS
+}
+
+// violation records a broken invariant, up to maxEndToEndViolations.
+func (r *EndToEndResult) violation(format string, args ...interface{}) {
+	if len(r.Violations) < maxEndToEndViolations {
+		r.Violations = append(r.Violations, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+)
+
+func TestEndToEnd(t *testing.T) {
+	configs := map[string]EndToEndConfig{
+		"default":   {Taxis: 10, Rides: 50, Seed: 1},
+		"sharded":   {Taxis: 10, Rides: 50, Seed: 2, StoreShards: 4},
+		"lookahead": {Taxis: 5, Rides: 50, Seed: 3, LookAhead: 5 * scheduler.DefaultDispatchInterval},
+		// Bursts far beyond every subscriber buffer: nothing may be dropped
+		"burst": {Taxis: 200, Rides: 300, Seed: 4},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			config.KeepStdout = true
+			result := RunEndToEnd(config)
+			if !result.Passed() {
+				t.Fatal(result)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// event_log.go - Ride event export
+// Appends every ride lifecycle event to a JSON Lines or CSV file for offline analysis
+
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// csvHeader is written once at the top of a new CSV event log.
+var csvHeader = []string{"time", "type", "ride_id", "taxi_id", "trace_id", "metadata"}
+
+// EventLogger writes ride events to a file.
+// The format is picked from the file extension: ".csv" writes CSV, anything else JSON Lines.
+type EventLogger struct {
+	file    *os.File        // Destination file, opened in append mode
+	csv     bool            // true for CSV output, false for JSON Lines
+	written int64           // Events taken off the channel so far, only touched by Run
+	flushes chan eventFlush // Flush requests, served by Run
+	stopped chan struct{}   // Closed when Run returns
+}
+
+// eventFlush asks Run to write the first upTo events and sync the file.
+type eventFlush struct {
+	upTo int64      // Events to have written before syncing
+	done chan error // Receives the result of the sync
+}
+
+// NewEventLogger opens (or creates) the file at path for appending events.
+func NewEventLogger(path string) (*EventLogger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening event log: %w", err)
+	}
+
+	logger := &EventLogger{
+		file:    file,
+		csv:     filepath.Ext(path) == ".csv",
+		flushes: make(chan eventFlush),
+		stopped: make(chan struct{}),
+	}
+
+	// Only write the CSV header into an empty file, so appending to an old log keeps it valid
+	if info, err := file.Stat(); err == nil && info.Size() == 0 && logger.csv {
+		if err := logger.writeCSV(csvHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return logger, nil
+}
+
+// Run writes every event from the channel to the file until the channel is closed.
+// This method blocks and should be run as a goroutine.
+func (el *EventLogger) Run(events <-chan ride.RideEvent) {
+	defer close(el.stopped)
+	defer el.file.Close()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			el.log(event)
+		case flush := <-el.flushes:
+			for el.written < flush.upTo {
+				event, ok := <-events
+				if !ok {
+					break
+				}
+				el.log(event)
+			}
+			flush.done <- el.file.Sync()
+		}
+	}
+}
+
+// Flush waits until the first upTo events from Run's channel are written, then syncs the
+// file to disk. Events after those keep being written. Returns at once if Run has ended.
+func (el *EventLogger) Flush(upTo int64) error {
+	flush := eventFlush{upTo: upTo, done: make(chan error, 1)}
+	select {
+	case el.flushes <- flush:
+		return <-flush.done
+	case <-el.stopped:
+		return nil
+	}
+}
+
+// log writes one event, logging a failure; the event counts as written either way.
+func (el *EventLogger) log(event ride.RideEvent) {
+	el.written++
+	if err := el.write(event); err != nil {
+		log.Printf("[EventLogger] ERROR: Failed to write %s event for ride #%d: %v\n", event.Type, event.RideID, err)
+	}
+}
+
+// write appends a single event in the configured format.
+func (el *EventLogger) write(event ride.RideEvent) error {
+	if el.csv {
+		return el.writeCSV([]string{
+			event.Time.Format(time.RFC3339Nano),
+			string(event.Type),
+			strconv.Itoa(event.RideID),
+			strconv.Itoa(event.TaxiID),
+			event.TraceID,
+			csvMetadata(event.Metadata),
+		})
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = el.file.Write(append(line, '\n'))
+	return err
+}
+
+// writeCSV appends one CSV record and flushes it straight to the file.
+func (el *EventLogger) writeCSV(record []string) error {
+	w := csv.NewWriter(el.file)
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvMetadata encodes a ride's metadata as one JSON object for the CSV metadata column
+// (empty when the ride has none). Keys come out sorted, so equal metadata gives equal text.
+func csvMetadata(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
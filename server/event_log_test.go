@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// countLines returns how many lines the file at path has.
+func countLines(t testing.TB, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestShutdownFlushesEveryExportedEvent(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := server.ExportEvents(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Far more events at once than the subscriber buffer holds
+	const rides = 2000
+	for i := 0; i < rides; i++ {
+		r := server.rideStore.Add(testRide("", i))
+		server.events.Publish(ride.RideCreated, r, 0)
+	}
+	server.Shutdown()
+	if lines := countLines(t, path); lines != rides {
+		t.Fatalf("%d events in the file after Shutdown, want all %d", lines, rides)
+	}
+	if dropped := subscriberStats(t, server, "event export").Dropped; dropped != 0 {
+		t.Errorf("event export lost %d events", dropped)
+	}
+
+	// Rides still finishing after Shutdown are exported too
+	server.events.Publish(ride.RideFinished, server.rideStore.Get(1), 0)
+	waitFor(t, "the event published after Shutdown", func() bool { return countLines(t, path) == rides+1 })
+}
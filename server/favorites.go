@@ -0,0 +1,125 @@
+// favorites.go - Rider favorite places
+// Lets riders save named locations such as "home" and "work" and use the names as the
+// pickup or destination of their ride requests
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Limits on a rider's favorite places.
+const (
+	maxFavorites       = 20 // Favorites per client
+	maxFavoriteNameLen = 40 // Characters in a favorite's name
+)
+
+// FavoritePlace is a location a rider saved under a name of their own.
+type FavoritePlace struct {
+	Name     string        `json:"name"` // As given, e.g. "Home"; matched ignoring case
+	Location taxi.Location `json:"location"`
+}
+
+// Geocode returns the location of one of the client's favorite places, so a client can
+// come first in a GeocoderChain for its own requests.
+// Returns an error wrapping ErrUnknownPlace for any other name.
+func (client Client) Geocode(place string) (taxi.Location, error) {
+	favorite, saved := client.Favorites[normalizePlace(place)]
+	if !saved {
+		return taxi.Location{}, fmt.Errorf("%w %q", ErrUnknownPlace, place)
+	}
+	return favorite.Location, nil
+}
+
+// SetFavorite saves a location under a name for a client, e.g. "home", replacing the
+// favorite of that name if there is one. The name can then be used as the request's
+// StartPlace or EndPlace of the client's rides, and takes precedence over landmarks and
+// the Server's Geocoder.
+// Returns an error for an empty or overlong name, a location outside the grid, an
+// unknown client, or a client that already has 20 favorites.
+func (s *Server) SetFavorite(clientID int, name string, location taxi.Location) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("a favorite place needs a name")
+	}
+	if len([]rune(name)) > maxFavoriteNameLen {
+		return fmt.Errorf("favorite place names are at most %d characters, %q is longer", maxFavoriteNameLen, name)
+	}
+	if !taxi.GridArea.Contains(location) {
+		return fmt.Errorf("location (%d, %d) is outside the grid", location.X, location.Y)
+	}
+	if err := s.clients.SetFavorite(clientID, FavoritePlace{Name: name, Location: location}); err != nil {
+		return err
+	}
+	fmt.Printf("[Server] Client #%d saved favorite %q at (%d, %d)\n", clientID, name, location.X, location.Y)
+	return nil
+}
+
+// RemoveFavorite forgets a client's favorite place.
+// Returns an error if the client or the favorite was not found.
+func (s *Server) RemoveFavorite(clientID int, name string) error {
+	if !s.clients.RemoveFavorite(clientID, name) {
+		return fmt.Errorf("client #%d has no favorite place %q", clientID, strings.TrimSpace(name))
+	}
+	fmt.Printf("[Server] Client #%d removed favorite %q\n", clientID, strings.TrimSpace(name))
+	return nil
+}
+
+// GetFavorites returns a client's favorite places, sorted by name.
+// Returns an error for an unknown client.
+func (s *Server) GetFavorites(clientID int) ([]FavoritePlace, error) {
+	client, exists := s.clients.Get(clientID)
+	if !exists {
+		return nil, fmt.Errorf("client #%d not found", clientID)
+	}
+	favorites := make([]FavoritePlace, 0, len(client.Favorites))
+	for _, favorite := range client.Favorites {
+		favorites = append(favorites, favorite)
+	}
+	sort.Slice(favorites, func(i, j int) bool { return normalizePlace(favorites[i].Name) < normalizePlace(favorites[j].Name) })
+	return favorites, nil
+}
+
+// handleGetFavorites serves GET /favorites for the rider of the Bearer token.
+func (s *Server) handleGetFavorites(w http.ResponseWriter, r *http.Request) {
+	account, _ := requestAccount(r)
+	favorites, err := s.GetFavorites(account.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, favorites)
+}
+
+// handleSetFavorite serves PUT /favorites/{name} with the location in the body, e.g.
+// {"x": 3, "y": 4}, for the rider of the Bearer token, and answers with the favorite.
+func (s *Server) handleSetFavorite(w http.ResponseWriter, r *http.Request) {
+	account, _ := requestAccount(r)
+	var location taxi.Location
+	if err := json.NewDecoder(r.Body).Decode(&location); err != nil {
+		http.Error(w, fmt.Sprintf("parsing location: %v", err), http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(r.PathValue("name"))
+	if err := s.SetFavorite(account.ID, name, location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, FavoritePlace{Name: name, Location: location})
+}
+
+// handleRemoveFavorite serves DELETE /favorites/{name} for the rider of the Bearer token.
+func (s *Server) handleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	account, _ := requestAccount(r)
+	if err := s.RemoveFavorite(account.ID, r.PathValue("name")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"removed": strings.TrimSpace(r.PathValue("name"))})
+}
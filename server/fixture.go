@@ -0,0 +1,165 @@
+// fixture.go - Fleet and ride fixtures
+// Loads a fixed set of taxis and ride requests from JSON in one go, so demos and
+// experiments can start from the same rich state every time
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// FixtureTaxi is one taxi in a fixture.
+type FixtureTaxi struct {
+	Location      taxi.Location       `json:"location"`
+	Attributes    taxi.TaxiAttributes `json:"attributes"`     // Bit flags, see TaxiAttributes
+	Pool          string              `json:"pool"`           // Dispatch pool ("" = general fleet)
+	Rating        float64             `json:"rating"`         // Driver rating, 1 to 5 stars (0 = top rating)
+	EnergyLevel   *int                `json:"energy_level"`   // Fuel or charge left, 0 to 100 percent (nil = full)
+	InMaintenance bool                `json:"in_maintenance"` // Registered straight into maintenance
+}
+
+// FixtureRide is one ride request in a fixture.
+type FixtureRide struct {
+	ClientID     int                 `json:"client_id"` // Rides with the same client_id come from one client, registered on load
+	Start        taxi.Location       `json:"start"`
+	End          taxi.Location       `json:"end"`
+	Waypoints    []taxi.Location     `json:"waypoints"`    // Stops between start and end, in order
+	Requirements taxi.TaxiAttributes `json:"requirements"` // Bit flags the taxi must have
+	Pool         string              `json:"pool"`         // Dispatch pool to serve the ride from ("" = general fleet)
+	ExpiresIn    taxi.Duration       `json:"expires_in"`   // Deadline after loading, e.g. "2m" (zero for none)
+	Metadata     map[string]string   `json:"metadata"`     // Application data, e.g. {"luggage": "2"}
+}
+
+// Fixture is a fleet and a batch of ride requests, loaded all at once.
+type Fixture struct {
+	Taxis []FixtureTaxi `json:"taxis"`
+	Rides []FixtureRide `json:"rides"`
+}
+
+// FixtureResult lists what a fixture created, in fixture order.
+type FixtureResult struct {
+	TaxiIDs   []int       `json:"taxi_ids"`
+	RideIDs   []int       `json:"ride_ids"`
+	ClientIDs map[int]int `json:"client_ids"` // Fixture client_id -> ID of the client registered for it
+}
+
+// ReadFixture reads a fixture from a JSON file and checks it for mistakes.
+func ReadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("parsing fixture %s: %w", path, err)
+	}
+	if err := fixture.validate(); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// validate rejects locations outside the grid and out-of-range taxi settings,
+// so a bad fixture is refused before anything is loaded.
+func (f *Fixture) validate() error {
+	for i, t := range f.Taxis {
+		if !taxi.GridArea.Contains(t.Location) {
+			return fmt.Errorf("taxi %d: location (%d, %d) is outside the grid", i, t.Location.X, t.Location.Y)
+		}
+		if t.Rating != 0 && (t.Rating < taxi.MinTaxiRating || t.Rating > taxi.MaxTaxiRating) {
+			return fmt.Errorf("taxi %d: rating %.1f is outside %v to %v stars", i, t.Rating, taxi.MinTaxiRating, taxi.MaxTaxiRating)
+		}
+		if t.EnergyLevel != nil && (*t.EnergyLevel < 0 || *t.EnergyLevel > 100) {
+			return fmt.Errorf("taxi %d: energy level %d%% is outside 0 to 100", i, *t.EnergyLevel)
+		}
+	}
+	for i, ride := range f.Rides {
+		for _, stop := range taxi.TripStops(ride.Start, ride.Waypoints, ride.End) {
+			if !taxi.GridArea.Contains(stop) {
+				return fmt.Errorf("ride %d: (%d, %d) is outside the grid", i, stop.X, stop.Y)
+			}
+		}
+		if ride.Start == ride.End && len(ride.Waypoints) == 0 {
+			return fmt.Errorf("ride %d: start and end are the same", i)
+		}
+		if ride.ExpiresIn.Duration < 0 {
+			return fmt.Errorf("ride %d: expires_in must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// LoadFixture reads a fixture file and loads it (see ApplyFixture).
+func (s *Server) LoadFixture(path string) (FixtureResult, error) {
+	fixture, err := ReadFixture(path)
+	if err != nil {
+		return FixtureResult{}, err
+	}
+	return s.ApplyFixture(fixture)
+}
+
+// ApplyFixture registers every taxi of the fixture, then requests every ride, without
+// any of the delays of the simulated clients. Each client_id of the rides is registered
+// as a new client first. Rides go through the normal validators and queue, so they are
+// dispatched at the usual pace.
+// A ride the server rejects stops the load; the result lists what was created until then.
+func (s *Server) ApplyFixture(fixture *Fixture) (FixtureResult, error) {
+	if err := fixture.validate(); err != nil {
+		return FixtureResult{}, err
+	}
+
+	result := FixtureResult{ClientIDs: make(map[int]int)}
+	for _, taxi := range fixture.Taxis {
+		id := s.RegisterTaxi(taxi.Location, taxi.Attributes)
+		result.TaxiIDs = append(result.TaxiIDs, id)
+
+		// The taxi was just registered, so these only fail on values validate already checked
+		if taxi.Pool != "" {
+			s.SetTaxiPool(id, taxi.Pool)
+		}
+		if taxi.Rating != 0 {
+			s.SetTaxiRating(id, taxi.Rating)
+		}
+		if taxi.EnergyLevel != nil {
+			s.SetTaxiEnergyLevel(id, *taxi.EnergyLevel)
+		}
+		if taxi.InMaintenance {
+			s.SetTaxiMaintenance(id, true)
+		}
+	}
+
+	tokens := make(map[int]string) // Fixture client_id -> token of the registered client
+	for i, r := range fixture.Rides {
+		if _, registered := tokens[r.ClientID]; !registered {
+			id, token := s.RegisterClient(fmt.Sprintf("fixture client %d", r.ClientID))
+			result.ClientIDs[r.ClientID] = id
+			tokens[r.ClientID] = token
+		}
+		request := ride.RideRequest{
+			Token:         tokens[r.ClientID],
+			StartLocation: r.Start,
+			EndLocation:   r.End,
+			Waypoints:     r.Waypoints,
+			Requirements:  r.Requirements,
+			Pool:          r.Pool,
+			Metadata:      r.Metadata,
+		}
+		if r.ExpiresIn.Duration > 0 {
+			request.ExpiresAt = s.clock.Now().Add(r.ExpiresIn.Duration)
+		}
+		id, err := s.RequestRide(request)
+		if err != nil {
+			return result, fmt.Errorf("fixture ride %d: %w", i, err)
+		}
+		result.RideIDs = append(result.RideIDs, id)
+	}
+
+	fmt.Printf("[Server] Loaded fixture: %d taxis, %d rides\n", len(result.TaxiIDs), len(result.RideIDs))
+	return result, nil
+}
@@ -0,0 +1,85 @@
+// forecast.go - Demand forecast API
+// Turns demand forecasting on, feeds it every ride request and serves its predictions
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// EnableDemandForecast trains a DemandForecaster on the rides in memory, keeps it
+// learning from every ride requested from now on, and has auto repositioning (see
+// EnableAutoRepositioning) send idle taxis to the zones it predicts will be busiest
+// over the horizon instead of the busiest of the last 15 minutes.
+// Replaces the forecast of an earlier call. Returns an error if the config is invalid.
+func (s *Server) EnableDemandForecast(config scheduler.ForecastConfig) error {
+	forecaster, err := scheduler.NewDemandForecaster(config, s.clock)
+	if err != nil {
+		return err
+	}
+	rides := s.rideStore.List()
+	locations := make([]taxi.Location, 0, len(rides))
+	times := make([]time.Time, 0, len(rides))
+	for _, ride := range rides {
+		locations = append(locations, ride.StartLocation)
+		times = append(times, ride.CreatedAt)
+	}
+	forecaster.Train(locations, times)
+
+	s.mu.Lock()
+	s.forecaster = forecaster
+	s.mu.Unlock()
+	s.advisor.SetDemandSource(forecaster)
+	fmt.Printf("[Server] Demand forecast enabled: %s\n", forecaster)
+	return nil
+}
+
+// GetDemandForecast returns the demand predicted for each of the next ticks, per zone,
+// busiest first. Returns an error unless EnableDemandForecast was called.
+func (s *Server) GetDemandForecast(ticks int) ([]scheduler.ZoneForecast, error) {
+	s.mu.Lock()
+	forecaster := s.forecaster
+	s.mu.Unlock()
+	if forecaster == nil {
+		return nil, fmt.Errorf("demand forecast is not enabled")
+	}
+	return forecaster.Forecast(ticks), nil
+}
+
+// recordDemand feeds a ride start to the demand forecast, if there is one.
+func (s *Server) recordDemand(location taxi.Location) {
+	s.mu.Lock()
+	forecaster := s.forecaster
+	s.mu.Unlock()
+	if forecaster != nil {
+		forecaster.Record(location)
+	}
+}
+
+// maxForecastTicks is the furthest GET /admin/forecast looks ahead.
+const maxForecastTicks = 1000
+
+// handleAdminForecast serves GET /admin/forecast, optionally ?ticks=N (default 5).
+func (s *Server) handleAdminForecast(w http.ResponseWriter, r *http.Request) {
+	ticks := 5
+	if value := r.URL.Query().Get("ticks"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxForecastTicks {
+			http.Error(w, fmt.Sprintf("ticks must be a number from 1 to %d, got %q", maxForecastTicks, value), http.StatusBadRequest)
+			return
+		}
+		ticks = parsed
+	}
+	forecast, err := s.GetDemandForecast(ticks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, forecast)
+}
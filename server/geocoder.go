@@ -0,0 +1,152 @@
+// geocoder.go - Place name lookup
+// Maps named places ("Airport", "Central Station") to grid locations, so ride
+// requests can name their pickup and destination instead of giving coordinates
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// geocoderTimeout bounds how long an external geocoding lookup may take (real time).
+const geocoderTimeout = 2 * time.Second
+
+// ErrUnknownPlace is wrapped by geocoders that do not know a place name.
+var ErrUnknownPlace = errors.New("unknown place")
+
+// Geocoder resolves a place name to a location on the grid.
+// Implementations must be safe for concurrent use.
+type Geocoder interface {
+	Geocode(place string) (taxi.Location, error)
+}
+
+// DefaultPlaces are the landmarks every Server knows out of the box.
+var DefaultPlaces = map[string]taxi.Location{
+	"Airport":         {X: 85, Y: 25},
+	"Central Station": {X: 50, Y: 50},
+	"Harbor":          {X: 10, Y: 90},
+	"University":      {X: 20, Y: 30},
+	"Stadium":         {X: 70, Y: 75},
+}
+
+// StaticGeocoder looks places up in a fixed in-memory table.
+// Names are matched ignoring case and surrounding spaces.
+// All methods are safe for concurrent access.
+type StaticGeocoder struct {
+	mu     sync.RWMutex             // Protects places
+	places map[string]taxi.Location // Normalized name -> location
+	names  map[string]string        // Normalized name -> name as added, for listing
+}
+
+// NewStaticGeocoder creates a geocoder that knows the given places.
+func NewStaticGeocoder(places map[string]taxi.Location) *StaticGeocoder {
+	sg := &StaticGeocoder{places: make(map[string]taxi.Location), names: make(map[string]string)}
+	for name, location := range places {
+		sg.Add(name, location)
+	}
+	return sg
+}
+
+// Add teaches the geocoder a place, replacing any place of the same name.
+func (sg *StaticGeocoder) Add(name string, location taxi.Location) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	key := normalizePlace(name)
+	sg.places[key] = location
+	sg.names[key] = strings.TrimSpace(name)
+}
+
+// Geocode returns the location of a known place.
+// Returns an error wrapping ErrUnknownPlace for any other name.
+func (sg *StaticGeocoder) Geocode(place string) (taxi.Location, error) {
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+
+	location, known := sg.places[normalizePlace(place)]
+	if !known {
+		return taxi.Location{}, fmt.Errorf("%w %q", ErrUnknownPlace, place)
+	}
+	return location, nil
+}
+
+// Places returns the names of every known place, sorted.
+func (sg *StaticGeocoder) Places() []string {
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+
+	names := make([]string, 0, len(sg.names))
+	for _, name := range sg.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizePlace folds a place name for lookups: "  central station" matches "Central Station".
+func normalizePlace(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// HTTPGeocoder asks an external geocoding service. For a place it sends
+// GET <baseURL>?q=<place> and expects {"x": 12, "y": 34} back; a 404 means the
+// place is unknown.
+type HTTPGeocoder struct {
+	baseURL string       // Endpoint of the service, e.g. "http://geo.internal/lookup"
+	client  *http.Client // Shared client with geocoderTimeout
+}
+
+// NewHTTPGeocoder creates a geocoder for the service at baseURL.
+func NewHTTPGeocoder(baseURL string) *HTTPGeocoder {
+	return &HTTPGeocoder{baseURL: baseURL, client: &http.Client{Timeout: geocoderTimeout}}
+}
+
+// Geocode looks a place up with the external service.
+// Returns an error wrapping ErrUnknownPlace if the service does not know it, or
+// another error if the service could not be reached or answered nonsense.
+func (hg *HTTPGeocoder) Geocode(place string) (taxi.Location, error) {
+	response, err := hg.client.Get(hg.baseURL + "?q=" + url.QueryEscape(strings.TrimSpace(place)))
+	if err != nil {
+		return taxi.Location{}, fmt.Errorf("geocoding %q: %w", place, err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return taxi.Location{}, fmt.Errorf("%w %q", ErrUnknownPlace, place)
+	default:
+		return taxi.Location{}, fmt.Errorf("geocoding %q: service answered %s", place, response.Status)
+	}
+	var location taxi.Location
+	if err := json.NewDecoder(response.Body).Decode(&location); err != nil {
+		return taxi.Location{}, fmt.Errorf("geocoding %q: %w", place, err)
+	}
+	return location, nil
+}
+
+// GeocoderChain tries each geocoder in turn and returns the first location found,
+// e.g. the static landmarks first and an external service for everything else.
+type GeocoderChain []Geocoder
+
+// Geocode returns the answer of the first geocoder that knows the place.
+// A geocoder that fails for another reason stops the chain with its error.
+func (gc GeocoderChain) Geocode(place string) (taxi.Location, error) {
+	for _, geocoder := range gc {
+		location, err := geocoder.Geocode(place)
+		if err == nil || !errors.Is(err, ErrUnknownPlace) {
+			return location, err
+		}
+	}
+	return taxi.Location{}, fmt.Errorf("%w %q", ErrUnknownPlace, place)
+}
@@ -0,0 +1,174 @@
+// geojson.go - GeoJSON export
+// Emits taxis, active ride routes and zones as GeoJSON FeatureCollections, so the
+// live state can be dropped into standard mapping tools (geojson.io, QGIS, Leaflet)
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// GeoJSON layers served by GET /admin/geojson?layer=<name>.
+const (
+	GeoJSONTaxis = "taxis" // One Point per taxi
+	GeoJSONRides = "rides" // One LineString per active ride, along its route
+	GeoJSONZones = "zones" // One Polygon per dispatch lane zone and congested zone
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection (RFC 7946).
+type FeatureCollection struct {
+	Type     string    `json:"type"` // Always "FeatureCollection"
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature: one geometry with free-form properties.
+type Feature struct {
+	Type       string         `json:"type"` // Always "Feature"
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry. Coordinates is a position for a Point, a list of
+// positions for a LineString and a list of rings for a Polygon.
+type Geometry struct {
+	Type        string `json:"type"` // "Point", "LineString" or "Polygon"
+	Coordinates any    `json:"coordinates"`
+}
+
+// geoJSONPosition maps a grid location onto a [longitude, latitude] position.
+// The grid is placed at the origin with the same scale as the Redis GEO set
+// (GeoDegreesPerUnit), so one cell is roughly 100 meters on the map.
+func geoJSONPosition(location taxi.Location) []float64 {
+	return []float64{float64(location.X) * taxi.GeoDegreesPerUnit, float64(location.Y) * taxi.GeoDegreesPerUnit}
+}
+
+// newFeature creates a Feature from a geometry and its properties.
+func newFeature(geometryType string, coordinates any, properties map[string]any) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   Geometry{Type: geometryType, Coordinates: coordinates},
+		Properties: properties,
+	}
+}
+
+// GetGeoJSON returns one layer (GeoJSONTaxis, GeoJSONRides or GeoJSONZones) as a
+// FeatureCollection, or every layer together for "".
+// Returns an error for an unknown layer.
+func (s *Server) GetGeoJSON(layer string) (FeatureCollection, error) {
+	collection := FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0)}
+	switch layer {
+	case GeoJSONTaxis:
+		collection.Features = s.taxiFeatures()
+	case GeoJSONRides:
+		collection.Features = s.rideFeatures()
+	case GeoJSONZones:
+		collection.Features = s.zoneFeatures()
+	case "":
+		collection.Features = append(collection.Features, s.zoneFeatures()...)
+		collection.Features = append(collection.Features, s.rideFeatures()...)
+		collection.Features = append(collection.Features, s.taxiFeatures()...)
+	default:
+		return FeatureCollection{}, fmt.Errorf("unknown GeoJSON layer %q", layer)
+	}
+	return collection, nil
+}
+
+// taxiFeatures returns a Point for every taxi at its current location.
+func (s *Server) taxiFeatures() []Feature {
+	features := make([]Feature, 0)
+	for _, taxi := range s.GetTaxiSnapshot().All() {
+		features = append(features, newFeature("Point", geoJSONPosition(taxi.Location), map[string]any{
+			"layer":          GeoJSONTaxis,
+			"id":             taxi.ID,
+			"available":      taxi.IsAvailable,
+			"in_maintenance": taxi.InMaintenance,
+			"attributes":     taxi.Attributes,
+			"pool":           taxi.Pool,
+			"x":              taxi.Location.X,
+			"y":              taxi.Location.Y,
+		}))
+	}
+	return features
+}
+
+// rideFeatures returns a LineString from pickup through every waypoint to destination
+// for every ride with a taxi on it (ASSIGNED, ACCEPTED or IN_PROGRESS), following the
+// router's route. Legs the router cannot route are drawn as a straight line.
+func (s *Server) rideFeatures() []Feature {
+	features := make([]Feature, 0)
+	for _, status := range []ride.RideStatus{ride.ASSIGNED, ride.ACCEPTED, ride.IN_PROGRESS} {
+		for _, ride := range s.GetRidesByStatus(status) {
+			stops := ride.Stops()
+			route := []taxi.Location{ride.StartLocation}
+			for i := 1; i < len(stops); i++ {
+				leg := s.locationService.Route(stops[i-1], stops[i])
+				if len(leg) < 2 {
+					// A LineString needs two positions, even for a leg that does not move
+					leg = []taxi.Location{stops[i-1], stops[i]}
+				}
+				route = append(route, leg[1:]...) // Each leg starts where the last one ended
+			}
+			line := make([][]float64, 0, len(route))
+			for _, location := range route {
+				line = append(line, geoJSONPosition(location))
+			}
+			features = append(features, newFeature("LineString", line, map[string]any{
+				"layer":     GeoJSONRides,
+				"id":        ride.ID,
+				"status":    ride.Status().String(),
+				"taxi_id":   ride.TaxiID(),
+				"client_id": ride.ClientID,
+				"pool":      ride.Pool,
+			}))
+		}
+	}
+	return features
+}
+
+// zoneFeatures returns a Polygon for every zone with its own dispatch lane and every
+// congested zone.
+func (s *Server) zoneFeatures() []Feature {
+	features := make([]Feature, 0)
+	for _, zone := range s.scheduler.Zones() {
+		features = append(features, newFeature("Polygon", zoneRing(zone), map[string]any{
+			"layer": GeoJSONZones,
+			"kind":  "dispatch",
+			"name":  zone.Name,
+		}))
+	}
+	for _, congested := range s.traffic.CongestedZones() {
+		features = append(features, newFeature("Polygon", zoneRing(congested.Zone), map[string]any{
+			"layer":      GeoJSONZones,
+			"kind":       "congestion",
+			"name":       congested.Zone.Name,
+			"multiplier": congested.Multiplier,
+		}))
+	}
+	return features
+}
+
+// zoneRing returns a zone's outline as a closed counterclockwise polygon ring.
+func zoneRing(zone taxi.Zone) [][][]float64 {
+	return [][][]float64{{
+		geoJSONPosition(zone.Min),
+		geoJSONPosition(taxi.Location{X: zone.Max.X, Y: zone.Min.Y}),
+		geoJSONPosition(zone.Max),
+		geoJSONPosition(taxi.Location{X: zone.Min.X, Y: zone.Max.Y}),
+		geoJSONPosition(zone.Min),
+	}}
+}
+
+// handleAdminGeoJSON serves GET /admin/geojson, optionally limited to one layer
+// with ?layer=taxis, ?layer=rides or ?layer=zones.
+func (s *Server) handleAdminGeoJSON(w http.ResponseWriter, r *http.Request) {
+	collection, err := s.GetGeoJSON(r.URL.Query().Get("layer"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, collection)
+}
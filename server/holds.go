@@ -0,0 +1,97 @@
+// holds.go - Taxi reservation holds API
+// Lets operators place, list and remove holds that keep a taxi for one client
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+)
+
+// PlaceTaxiHold keeps a taxi for a client from from until until: during the window the
+// assigner gives the taxi to nobody else and tries it first for the client's rides.
+// Returns an error if the taxi or client was not found, or the window is empty or already over.
+func (s *Server) PlaceTaxiHold(taxiID, clientID int, from, until time.Time) (scheduler.TaxiHold, error) {
+	if _, exists := s.taxiStore.Get(taxiID); !exists {
+		return scheduler.TaxiHold{}, fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	if _, exists := s.clients.Get(clientID); !exists {
+		return scheduler.TaxiHold{}, fmt.Errorf("client #%d not found", clientID)
+	}
+	if !from.Before(until) {
+		return scheduler.TaxiHold{}, fmt.Errorf("hold must end after it starts")
+	}
+	if !s.clock.Now().Before(until) {
+		return scheduler.TaxiHold{}, fmt.Errorf("hold ending at %s is already over", until.Format(time.RFC3339))
+	}
+
+	hold := s.holds.Add(scheduler.TaxiHold{TaxiID: taxiID, ClientID: clientID, From: from, Until: until})
+	fmt.Printf("[Server] Taxi #%d held for client #%d from %s until %s (hold #%d)\n", taxiID, clientID,
+		from.Format(time.RFC3339), until.Format(time.RFC3339), hold.ID)
+	return hold, nil
+}
+
+// GetTaxiHolds returns the holds that have not expired yet, ordered by ID.
+func (s *Server) GetTaxiHolds() []scheduler.TaxiHold {
+	return s.holds.GetAll()
+}
+
+// RemoveTaxiHold lifts a hold. A ride already given the taxi keeps it.
+// Returns an error if the hold was not found.
+func (s *Server) RemoveTaxiHold(id int) error {
+	if !s.holds.Remove(id) {
+		return fmt.Errorf("hold #%d not found", id)
+	}
+	fmt.Printf("[Server] Hold #%d lifted\n", id)
+	return nil
+}
+
+// TaxiHoldRequest is the body of POST /admin/holds. From defaults to now.
+type TaxiHoldRequest struct {
+	TaxiID   int       `json:"taxi_id"`
+	ClientID int       `json:"client_id"`
+	From     time.Time `json:"from"`
+	Until    time.Time `json:"until"`
+}
+
+// handleAdminPlaceHold serves POST /admin/holds.
+func (s *Server) handleAdminPlaceHold(w http.ResponseWriter, r *http.Request) {
+	var request TaxiHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("parsing hold: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.From.IsZero() {
+		request.From = s.clock.Now()
+	}
+	hold, err := s.PlaceTaxiHold(request.TaxiID, request.ClientID, request.From, request.Until)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, hold)
+}
+
+// handleAdminHolds serves GET /admin/holds.
+func (s *Server) handleAdminHolds(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetTaxiHolds())
+}
+
+// handleAdminRemoveHold serves DELETE /admin/holds/{id}.
+func (s *Server) handleAdminRemoveHold(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid hold ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.RemoveTaxiHold(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"removed": id})
+}
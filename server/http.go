@@ -0,0 +1,204 @@
+// http.go - HTTP API layer
+// Exposes the Server over HTTP for dashboards and operators
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// metricsStreamInterval is how often the SSE endpoint pushes metrics.
+// Real time, not simulated time: dashboards refresh on the wall clock.
+const metricsStreamInterval = time.Second
+
+// Handler returns an http.Handler serving the Server's HTTP API. Every route is also
+// served under /v1/, e.g. POST /v1/rides; some of its JSON types are versioned (see APIVersion).
+//
+//	POST /clients            Register a rider and get its API token (see ClientRegistration)
+//	POST /quotes             Price a RideOrderV1's trip for the rider of the Bearer token, held for 5 minutes (see QuoteRide)
+//	POST /rides              Request a ride as the rider of the Bearer token (see RideOrderV1), at a quote's fare with "quote_id"
+//	POST /rides/batch        Request a JSON array of rides, all or none of them (see RequestRides)
+//	GET /rides/{id}/wait     Long poll: answers once the ride's status is not ?since=ASSIGNED, or after &timeout=30s (rider's own rides or admin; see WaitForRide)
+//	GET /taxis/near          Available taxis around ?x=3&y=4, nearest first, within &radius=10 (rider token; see FindTaxisNear)
+//	GET /favorites           The rider's saved places, usable as "from" and "to" of their rides (rider token; see SetFavorite)
+//	PUT /favorites/{name}    Save a place for the rider: {"x": 3, "y": 4}, e.g. at /favorites/home (rider token)
+//	DELETE /favorites/{name} Forget a saved place (rider token)
+//
+// Webhooks, for the Bearer token of a rider (their own rides) or an admin (every ride):
+//
+//	POST /webhooks         Have the events of those rides POSTed to {"url": "..."} (see WebhookPayload)
+//	GET /webhooks          The webhooks the token may manage
+//	DELETE /webhooks/{id}  Stop delivering to a webhook
+//
+// Notifications, for the Bearer token of a rider (see NotificationPrefs):
+//
+//	GET /notifications/preferences  Where and which of the rider's ride events are sent
+//	PUT /notifications/preferences  Choose them: {"channels": ["webhook", "websocket", "log"], "severity": "all|terminal"}
+//	GET /notifications/ws           WebSocket receiving the rider's events as WebhookPayload JSON (token may be ?token=)
+//
+// Driver endpoints, for the Bearer token of a driver account (see RegisterDriverAccount):
+//
+//	POST /driver/offers/{ride}/accept   Accept a ride offered to the driver's taxi
+//	POST /driver/offers/{ride}/decline  Turn it down
+//	POST /driver/break                  No new rides; after drop-off go ON_BREAK for {"duration": "15m"}
+//	DELETE /driver/break                Cancel the break, or end it now
+//	GET /driver/ws                      WebSocket of operator broadcasts to the driver, as DriverBroadcast JSON (token also as ?token=)
+//	POST /driver/broadcasts/{id}/ack    Confirm reading a broadcast
+//
+// Monitoring and operator actions need the Bearer token of an admin account (see RegisterAdmin):
+//
+//	GET /metrics/stream                    Server-Sent Events stream of Metrics, one event per second
+//	GET /admin/taxis                       Every taxi
+//	GET /admin/rides                       Every ride, or a page of them: ?status=IN_PROGRESS,ASSIGNED&client_id=&taxi_id=&from=&to=&offset=&limit=
+//	GET /admin/rides/{id}/audit            How the ride's taxi was chosen: every assignment attempt, its candidates and rejections
+//	GET /admin/queue                       Requests waiting in each dispatcher queue
+//	GET /admin/dead-letters                Rides the dispatcher gave up on (expired or out of attempts)
+//	GET /admin/stats                       Metrics plus ride counts by status
+//	GET /admin/geojson                     Taxis, active ride routes and zones as GeoJSON, or one of them with ?layer=taxis|rides|zones
+//	GET /admin/forecast                    Rides expected per zone in each of the next ?ticks=5 (see EnableDemandForecast)
+//	GET /admin/holds                       Taxis held for particular clients (see PlaceTaxiHold)
+//	GET /admin/maintenance                 Maintenance windows of zones that have not ended (see ScheduleZoneMaintenance)
+//	GET /admin/breaks                      Breaks drivers asked for or are on (see RequestTaxiBreak)
+//	GET /admin/onboarding                  Taxis registered while approval is required: ?status=PENDING_APPROVAL|APPROVED|REJECTED
+//	GET /admin/roads                       Blocked, one-way and slowed cells of the road network (see RoadNetwork; needs -road-grid)
+//	GET /admin/broadcasts                  Messages sent to drivers, newest first, with who got and read them (/{id} for one; see BroadcastToDrivers)
+//	GET /admin/tariffs                     Tariffs rides are priced with, most specific first, and the default (see SetTariffs)
+//	GET /admin/payouts                     Driver earnings per day or week and currency: ?period=daily|weekly&from=&to=&format=json|csv
+//	POST /admin/fixture                    Load the taxis and rides of a JSON fixture in the body (see Fixture)
+//	POST /admin/pause                      Stop dispatching new rides
+//	POST /admin/resume                     Dispatch again
+//	POST /admin/rides/{id}/assign          Give a waiting ride to the taxi in {"taxi_id": 3}
+//	POST /admin/dead-letters/{id}/requeue  Send a dead-lettered ride back to the dispatcher
+//	POST /admin/taxis                      Register a taxi: {"location": {"X": 3, "Y": 4}, "attributes": 1}
+//	DELETE /admin/taxis/{id}               Remove a taxi from the fleet
+//	POST /admin/taxis/{id}/approve         Let a taxi pending approval serve rides (see RequireTaxiApproval)
+//	POST /admin/taxis/{id}/reject          Turn it down and remove it from the fleet: {"reason": "..."} (optional)
+//	POST /admin/holds                      Keep a taxi for a client: {"taxi_id": 3, "client_id": 7, "from": "...", "until": "..."} (from defaults to now)
+//	DELETE /admin/holds/{id}               Lift a hold
+//	POST /admin/maintenance                Close a zone: {"zone": {"Name": "Stadium", "Min": {"X": 0, "Y": 0}, "Max": {"X": 9, "Y": 9}}, "until": "...", "slowdown": 2, "reason": "..."}
+//	DELETE /admin/maintenance/{id}         Cancel a maintenance window
+//	POST /admin/broadcasts                 Message drivers: {"message": "Surge downtown", "zone": {...}, "ttl": "30m"} (zone optional, ttl defaults to 1h)
+//	PATCH /admin/roads                     Edit the road network, all or nothing: [{"cell": {"X": 3, "Y": 4}, "blocked": true, "speed": 0.5, "one_way": "north"}]
+//
+// Every request goes through the middleware chain first (see Server.middleware): it gets
+// an X-Request-ID, is logged, answered 500 if its handler panics and 429 over the rate
+// limit (see SetRateLimit).
+func (s *Server) Handler() http.Handler {
+	return s.handler()
+}
+
+// handler returns the HTTP API behind the middleware chain, with extra middleware
+// innermost (e.g. driverCertsRequired). The API version is stripped from paths before
+// extra, so it sees /driver/... for /v1/driver/... too.
+func (s *Server) handler(extra ...Middleware) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics/stream", s.adminOnly(s.handleMetricsStream))
+	mux.HandleFunc("GET /admin/taxis", s.adminOnly(s.handleAdminTaxis))
+	mux.HandleFunc("GET /admin/rides", s.adminOnly(s.handleAdminRides))
+	mux.HandleFunc("GET /admin/rides/{id}/audit", s.adminOnly(s.handleAdminRideAudit))
+	mux.HandleFunc("GET /admin/queue", s.adminOnly(s.handleAdminQueue))
+	mux.HandleFunc("GET /admin/dead-letters", s.adminOnly(s.handleAdminDeadLetters))
+	mux.HandleFunc("GET /admin/stats", s.adminOnly(s.handleAdminStats))
+	mux.HandleFunc("GET /admin/geojson", s.adminOnly(s.handleAdminGeoJSON))
+	mux.HandleFunc("GET /admin/forecast", s.adminOnly(s.handleAdminForecast))
+	mux.HandleFunc("GET /admin/payouts", s.adminOnly(s.handleAdminPayouts))
+	mux.HandleFunc("GET /admin/holds", s.adminOnly(s.handleAdminHolds))
+	mux.HandleFunc("GET /admin/maintenance", s.adminOnly(s.handleAdminMaintenance))
+	mux.HandleFunc("GET /admin/breaks", s.adminOnly(s.handleAdminBreaks))
+	mux.HandleFunc("GET /admin/onboarding", s.adminOnly(s.handleAdminOnboarding))
+	mux.HandleFunc("GET /admin/roads", s.adminOnly(s.handleGetRoads))
+	mux.HandleFunc("GET /admin/tariffs", s.adminOnly(s.handleAdminTariffs))
+	mux.HandleFunc("GET /admin/broadcasts", s.adminOnly(s.handleAdminBroadcasts))
+	mux.HandleFunc("GET /admin/broadcasts/{id}", s.adminOnly(s.handleAdminGetBroadcast))
+	mux.HandleFunc("POST /clients", s.handleRegisterClient)
+	mux.HandleFunc("POST /quotes", s.handleQuoteRide)
+	mux.HandleFunc("POST /rides", s.handleRequestRide)
+	mux.HandleFunc("POST /rides/batch", s.handleRequestRides)
+	mux.HandleFunc("GET /rides/{id}/wait", s.handleRideWait)
+	mux.Handle("GET /taxis/near", s.requireRole(RoleRider)(http.HandlerFunc(s.handleTaxisNear)))
+	mux.Handle("GET /favorites", s.requireRole(RoleRider)(http.HandlerFunc(s.handleGetFavorites)))
+	mux.Handle("PUT /favorites/{name}", s.requireRole(RoleRider)(http.HandlerFunc(s.handleSetFavorite)))
+	mux.Handle("DELETE /favorites/{name}", s.requireRole(RoleRider)(http.HandlerFunc(s.handleRemoveFavorite)))
+	mux.HandleFunc("POST /webhooks", s.handleRegisterWebhook)
+	mux.HandleFunc("GET /webhooks", s.handleListWebhooks)
+	mux.HandleFunc("DELETE /webhooks/{id}", s.handleRemoveWebhook)
+	mux.HandleFunc("GET /notifications/preferences", s.handleGetNotificationPrefs)
+	mux.HandleFunc("PUT /notifications/preferences", s.handleSetNotificationPrefs)
+	mux.HandleFunc("GET /notifications/ws", s.handleNotificationSocket)
+	mux.HandleFunc("POST /driver/offers/{ride}/accept", s.handleDriverAnswer(true))
+	mux.HandleFunc("POST /driver/offers/{ride}/decline", s.handleDriverAnswer(false))
+	mux.HandleFunc("POST /driver/break", s.handleDriverBreak)
+	mux.HandleFunc("DELETE /driver/break", s.handleDriverEndBreak)
+	mux.HandleFunc("GET /driver/ws", s.handleDriverSocket)
+	mux.HandleFunc("POST /driver/broadcasts/{id}/ack", s.handleDriverAcknowledge)
+	mux.HandleFunc("POST /admin/fixture", s.adminOnly(s.handleAdminFixture))
+	mux.HandleFunc("POST /admin/pause", s.adminOnly(s.handleAdminPause))
+	mux.HandleFunc("POST /admin/resume", s.adminOnly(s.handleAdminResume))
+	mux.HandleFunc("POST /admin/rides/{id}/assign", s.adminOnly(s.handleAdminAssign))
+	mux.HandleFunc("POST /admin/dead-letters/{id}/requeue", s.adminOnly(s.handleAdminRequeue))
+	mux.HandleFunc("POST /admin/taxis", s.adminOnly(s.handleAdminRegisterTaxi))
+	mux.HandleFunc("DELETE /admin/taxis/{id}", s.adminOnly(s.handleAdminDeleteTaxi))
+	mux.HandleFunc("POST /admin/taxis/{id}/approve", s.adminOnly(s.handleAdminApproveTaxi))
+	mux.HandleFunc("POST /admin/taxis/{id}/reject", s.adminOnly(s.handleAdminRejectTaxi))
+	mux.HandleFunc("POST /admin/holds", s.adminOnly(s.handleAdminPlaceHold))
+	mux.HandleFunc("DELETE /admin/holds/{id}", s.adminOnly(s.handleAdminRemoveHold))
+	mux.HandleFunc("POST /admin/maintenance", s.adminOnly(s.handleAdminScheduleMaintenance))
+	mux.HandleFunc("DELETE /admin/maintenance/{id}", s.adminOnly(s.handleAdminCancelMaintenance))
+	mux.HandleFunc("POST /admin/broadcasts", s.adminOnly(s.handleAdminBroadcast))
+	mux.HandleFunc("PATCH /admin/roads", s.adminOnly(s.handleEditRoads))
+	return Chain(mux, append(append(s.middleware(), stripAPIVersion), extra...)...)
+}
+
+// adminOnly lets a request through to handler only with the Bearer token of an admin account.
+func (s *Server) adminOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requireRole(RoleAdmin)(handler).ServeHTTP
+}
+
+// writeAuthError answers 401 for a missing or unknown token and 403 for the wrong role.
+func writeAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// handleMetricsStream pushes a Metrics snapshot every second as a Server-Sent Event
+// until the client disconnects.
+func (s *Server) handleMetricsStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(metricsStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		data, err := json.Marshal(s.GetMetrics())
+		if err != nil {
+			log.Printf("[Server] ERROR: Failed to encode metrics: %v\n", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: metrics\ndata: %s\n\n", data); err != nil {
+			return // Client went away
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
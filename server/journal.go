@@ -0,0 +1,193 @@
+// journal.go - Event-sourced ride persistence
+// Appends every ride event, with the ride's details on creation, to a JSON Lines file
+// and rebuilds RideStore state on startup by replaying it
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// JournalRide holds the details of a ride that cannot be derived from later events.
+type JournalRide struct {
+	ClientID      int                 `json:"client_id"`
+	StartLocation taxi.Location       `json:"start"`
+	EndLocation   taxi.Location       `json:"end"`
+	Waypoints     []taxi.Location     `json:"waypoints,omitempty"` // Stops in between, in order
+	Requirements  taxi.TaxiAttributes `json:"requirements"`
+	LinkedRideID  int                 `json:"linked_ride_id,omitempty"` // Other leg of a round trip (0 for none)
+	ExpiresAt     time.Time           `json:"expires_at,omitzero"`      // Assignment deadline (zero for none)
+	Pool          string              `json:"pool,omitempty"`           // Dispatch pool ("" = general fleet)
+	QuotedFare    int                 `json:"quoted_fare,omitempty"`    // Fare locked by a quote (0 for none)
+	Tariff        *ride.Tariff        `json:"tariff,omitempty"`         // Tariff the ride is priced with (nil in journals written before tariffs)
+}
+
+// JournalEntry is one line of the ride journal: a ride event plus, for RIDE_CREATED,
+// the ride's details.
+type JournalEntry struct {
+	ride.RideEvent
+	Ride *JournalRide `json:"ride,omitempty"` // Only set on RIDE_CREATED
+}
+
+// RideJournal appends ride events to an append-only file.
+// Unlike EventLogger it records everything needed to rebuild rides with ReplayJournal.
+type RideJournal struct {
+	file  *os.File         // Destination file, opened in append mode
+	rides ride.RideStorage // For the details of newly created rides
+}
+
+// NewRideJournal opens (or creates) the journal file at path for appending.
+func NewRideJournal(path string, rides ride.RideStorage) (*RideJournal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening ride journal: %w", err)
+	}
+	return &RideJournal{file: file, rides: rides}, nil
+}
+
+// Run appends every event from the channel to the journal until the channel is closed.
+// This method blocks and should be run as a goroutine.
+func (rj *RideJournal) Run(events <-chan ride.RideEvent) {
+	defer rj.file.Close()
+
+	for event := range events {
+		if err := rj.write(event); err != nil {
+			log.Printf("[RideJournal] ERROR: Failed to write %s event for ride #%d: %v\n", event.Type, event.RideID, err)
+		}
+	}
+}
+
+// write appends a single entry, looking up the ride's details for RIDE_CREATED.
+func (rj *RideJournal) write(event ride.RideEvent) error {
+	entry := JournalEntry{RideEvent: event}
+	if event.Type == ride.RideCreated {
+		ride := rj.rides.Snapshot(event.RideID)
+		if ride == nil {
+			return fmt.Errorf("ride #%d not found", event.RideID)
+		}
+		entry.Ride = &JournalRide{
+			ClientID:      ride.ClientID,
+			StartLocation: ride.StartLocation,
+			EndLocation:   ride.EndLocation,
+			Waypoints:     ride.Waypoints,
+			Requirements:  ride.Requirements,
+			LinkedRideID:  ride.LinkedRideID,
+			ExpiresAt:     ride.ExpiresAt,
+			Pool:          ride.Pool,
+			QuotedFare:    ride.QuotedFare,
+			Tariff:        &ride.Tariff,
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = rj.file.Write(append(line, '\n'))
+	return err
+}
+
+// ReadJournal loads every entry of a journal file, in the order they were written.
+// A missing file is not an error and yields no entries.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening ride journal: %w", err)
+	}
+	defer file.Close()
+
+	entries := make([]JournalEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("ride journal line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ride journal: %w", err)
+	}
+	return entries, nil
+}
+
+// ReplayJournal rebuilds rides by applying journal entries in order.
+// Entries after until are ignored, so the rides can be inspected as they were at any moment;
+// pass the zero time to replay everything.
+// Events for rides whose RIDE_CREATED entry is missing are skipped.
+// Rides archived since (RIDE_ARCHIVED) are left out; see ReadArchive for those.
+func ReplayJournal(entries []JournalEntry, until time.Time) map[int]*ride.Ride {
+	rides := make(map[int]*ride.Ride)
+	for _, entry := range entries {
+		if !until.IsZero() && entry.Time.After(until) {
+			break
+		}
+
+		if entry.Type == ride.RideCreated {
+			if entry.Ride == nil {
+				continue
+			}
+			rides[entry.RideID] = &ride.Ride{
+				ID:            entry.RideID,
+				ClientID:      entry.Ride.ClientID,
+				StartLocation: entry.Ride.StartLocation,
+				EndLocation:   entry.Ride.EndLocation,
+				Waypoints:     entry.Ride.Waypoints,
+				Requirements:  entry.Ride.Requirements,
+				LinkedRideID:  entry.Ride.LinkedRideID,
+				CreatedAt:     entry.Time,
+				ExpiresAt:     entry.Ride.ExpiresAt,
+				TraceID:       entry.TraceID,
+				Pool:          entry.Ride.Pool,
+				Metadata:      entry.Metadata,
+				QuotedFare:    entry.Ride.QuotedFare,
+			}
+			if entry.Ride.Tariff != nil {
+				rides[entry.RideID].Tariff = *entry.Ride.Tariff
+			}
+			// The outbound leg was created before it was linked, so link it from here
+			if outbound, exists := rides[entry.Ride.LinkedRideID]; exists {
+				outbound.LinkedRideID = entry.RideID
+			}
+			continue
+		}
+
+		r, exists := rides[entry.RideID]
+		if !exists {
+			continue
+		}
+		switch entry.Type {
+		case ride.RideArchived:
+			delete(rides, entry.RideID) // Lives on in the archive only
+		case ride.TaxiAssigned:
+			r.AssignTaxi(entry.TaxiID, entry.Time)
+		case ride.RideAccepted:
+			r.SetStatus(ride.ACCEPTED, entry.Time)
+		case ride.RideStarted:
+			r.SetStatus(ride.IN_PROGRESS, entry.Time)
+		case ride.RideFinished:
+			r.SetStatus(ride.FINISHED, entry.Time)
+		case ride.RideExpired:
+			r.SetStatus(ride.EXPIRED, entry.Time)
+		case ride.RideFailed:
+			r.SetStatus(ride.FAILED, entry.Time)
+		case ride.RideNoShow:
+			r.SetStatus(ride.NO_SHOW, entry.Time)
+		case ride.RideRequeued, ride.RideReassigned, ride.RideDeclined:
+			r.SetStatus(ride.CREATED, entry.Time)
+		}
+	}
+	return rides
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// testRides adds n rides to a new RideStore and returns them, to publish events about.
+func testRides(clock taxi.Clock, n int) (*ride.RideStore, []*ride.Ride) {
+	store := ride.NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+	rides := make([]*ride.Ride, 0, n)
+	for i := 0; i < n; i++ {
+		rides = append(rides, store.Add(ride.RideRequest{StartLocation: taxi.Location{X: i % 100}, EndLocation: taxi.Location{X: i % 100, Y: 1}}))
+	}
+	return store, rides
+}
+
+func TestJournalKeepsEveryEventOfABurst(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	bus := ride.NewEventBus(clock)
+	store, rides := testRides(clock, 2000)
+	path := filepath.Join(t.TempDir(), "rides.journal")
+	journal, err := NewRideJournal(path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := bus.SubscribeWith(ride.SubscribeOptions{Name: "journal", Policy: ride.KeepAll})
+	done := make(chan struct{})
+	go func() {
+		journal.Run(events)
+		close(done)
+	}()
+
+	for _, r := range rides {
+		bus.Publish(ride.RideCreated, r, 0)
+	}
+	for _, r := range rides[:500] {
+		r.AssignTaxi(7, clock.Now())
+		bus.Publish(ride.TaxiAssigned, r, 7)
+	}
+	unsubscribe()
+	<-done
+
+	entries, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(rides) + 500; len(entries) != want {
+		t.Fatalf("journal has %d entries, want %d", len(entries), want)
+	}
+	restored := ReplayJournal(entries, time.Time{})
+	if len(restored) != len(rides) {
+		t.Fatalf("replay restored %d rides, want %d", len(restored), len(rides))
+	}
+	for _, r := range rides[:500] {
+		if got := restored[r.ID]; got.Status() != ride.ASSIGNED || got.TaxiID() != 7 {
+			t.Errorf("ride #%d restored %s with taxi #%d, want ASSIGNED to taxi #7", r.ID, got.Status(), got.TaxiID())
+		}
+	}
+}
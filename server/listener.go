@@ -0,0 +1,106 @@
+// listener.go - HTTP API listener
+// Binds the HTTP API (and the WebSockets it upgrades to) to any address, IPv4 or IPv6,
+// optionally over TLS, with client certificates required of drivers
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ListenConfig says where and how the HTTP API is served.
+type ListenConfig struct {
+	Addr         string // Host and port to bind, e.g. ":8080", "127.0.0.1:8080" or "[::1]:8080" (port 0 picks a free one)
+	CertFile     string // PEM certificate chain served over TLS ("" = plain HTTP); needs KeyFile
+	KeyFile      string // PEM private key of CertFile
+	ClientCAFile string // PEM CAs driver client certificates must chain to ("" = drivers need none); needs CertFile
+}
+
+// tlsConfig loads the certificates of a TLS listener.
+// Returns nil for plain HTTP, or an error if the files are missing or do not fit together.
+func (config ListenConfig) tlsConfig() (*tls.Config, error) {
+	if config.CertFile == "" && config.KeyFile == "" {
+		if config.ClientCAFile != "" {
+			return nil, fmt.Errorf("client certificates need TLS: set a certificate and key too")
+		}
+		return nil, nil
+	}
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, fmt.Errorf("TLS needs both a certificate and a key")
+	}
+	certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if config.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CAs: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates in client CAs %s", config.ClientCAFile)
+	}
+	// Riders, admins and dashboards connect without certificates; driverCertsRequired
+	// turns drivers without a verified one away.
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// driverCertsRequired is a Middleware answering 401 to requests for the driver endpoints
+// made without a client certificate the listener verified.
+func driverCertsRequired(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/driver/") && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Error(w, "driver endpoints need a client certificate", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// StartHTTP binds config.Addr and serves the HTTP API on it in the background, over
+// TLS if a certificate is set. With client CAs, the driver endpoints also need a
+// client certificate signed by one of them, on top of the driver's token.
+// Returns the address bound (with the port picked for port 0), or an error if the
+// certificates cannot be loaded or the address cannot be bound.
+func (s *Server) StartHTTP(config ListenConfig) (net.Addr, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("binding HTTP API: %w", err)
+	}
+
+	handler := s.Handler()
+	scheme := "http"
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+		scheme = "https"
+		if tlsConfig.ClientCAs != nil {
+			handler = s.handler(driverCertsRequired)
+			scheme = "https, driver certificates required"
+		}
+	}
+	go func() {
+		fmt.Printf("[Server] HTTP API listening on %s (%s)\n", listener.Addr(), scheme)
+		if err := http.Serve(listener, handler); err != nil {
+			log.Printf("[Server] ERROR: HTTP API stopped: %v\n", err)
+		}
+	}()
+	return listener.Addr(), nil
+}
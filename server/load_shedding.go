@@ -0,0 +1,200 @@
+// load_shedding.go - Load shedding of low-priority rides
+// When few taxis are free and the queue is long, low-priority ride requests are turned
+// away or held back, so the rides that matter are not stuck behind them
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// PriorityValidator rejects rides with an unknown priority.
+func PriorityValidator() ride.RideValidator {
+	return func(request ride.RideRequest) error {
+		switch request.Priority {
+		case "", ride.PriorityLow, ride.PriorityNormal, ride.PriorityHigh:
+			return nil
+		}
+		return fmt.Errorf("%w: unknown priority %q, want %q, %q or %q",
+			ride.ErrInvalidRequest, request.Priority, ride.PriorityLow, ride.PriorityNormal, ride.PriorityHigh)
+	}
+}
+
+// ErrOverloaded is wrapped by every *OverloadError, so callers can use errors.Is.
+var ErrOverloaded = errors.New("fleet saturated")
+
+// OverloadError is returned by RequestRide for a low-priority ride rejected while the
+// fleet was saturated. Asking again later, or with a higher priority, may succeed.
+type OverloadError struct {
+	AvailableTaxis int // Taxis free when the ride was shed
+	QueueDepth     int // Requests waiting to be dispatched then
+}
+
+// Error describes the load the ride was shed under.
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("%v: %d taxis available, %d requests queued; low-priority ride shed",
+		ErrOverloaded, e.AvailableTaxis, e.QueueDepth)
+}
+
+// Unwrap returns ErrOverloaded.
+func (e *OverloadError) Unwrap() error {
+	return ErrOverloaded
+}
+
+// LoadSheddingPolicy says when the fleet counts as saturated and what happens to
+// low-priority rides then. The zero policy never sheds.
+type LoadSheddingPolicy struct {
+	MinAvailableTaxis int           `json:"min_available_taxis"` // Saturated while fewer taxis than this are available...
+	MaxQueueDepth     int           `json:"max_queue_depth"`     // ...and more requests than this wait to be dispatched
+	Defer             taxi.Duration `json:"defer"`               // Hold shed rides and check again this often instead of rejecting them (zero = reject)
+}
+
+// LoadSheddingStats counts the low-priority rides shed since the server started.
+type LoadSheddingStats struct {
+	Policy   LoadSheddingPolicy `json:"policy"`   // Policy in force now
+	Rejected int                `json:"rejected"` // Requests turned away with an *OverloadError
+	Deferred int                `json:"deferred"` // Rides created but held back until the fleet had room
+}
+
+// LoadShedder decides whether a ride request may be queued under the load-shedding policy.
+// All methods are safe for concurrent access.
+type LoadShedder struct {
+	taxis     taxi.TaxiStorage         // For the number of available taxis
+	scheduler *scheduler.RideScheduler // For the queue depth
+	mu        sync.Mutex               // Protects stats
+	stats     LoadSheddingStats
+}
+
+// NewLoadShedder creates a shedder with the zero policy, so nothing is shed until SetPolicy.
+func NewLoadShedder(taxis taxi.TaxiStorage, scheduler *scheduler.RideScheduler) *LoadShedder {
+	return &LoadShedder{taxis: taxis, scheduler: scheduler}
+}
+
+// SetPolicy replaces the load-shedding policy. Rides deferred before keep being
+// checked as often as they were.
+func (ls *LoadShedder) SetPolicy(policy LoadSheddingPolicy) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.stats.Policy = policy
+}
+
+// Stats returns the policy and how many rides were shed.
+func (ls *LoadShedder) Stats() LoadSheddingStats {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.stats
+}
+
+// Admit decides whether a validated request may be queued now. Normal and high
+// priority requests always may. A low-priority one may not while the fleet is
+// saturated: Admit then returns an *OverloadError and how long to hold the ride before
+// checking again, or zero if the policy rejects it instead.
+func (ls *LoadShedder) Admit(request ride.RideRequest) (time.Duration, error) {
+	if request.Priority != ride.PriorityLow {
+		return 0, nil
+	}
+	ls.mu.Lock()
+	policy := ls.stats.Policy
+	ls.mu.Unlock()
+
+	err := ls.saturation(policy)
+	if err == nil {
+		return 0, nil
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if policy.Defer.Duration > 0 {
+		ls.stats.Deferred++
+	} else {
+		ls.stats.Rejected++
+	}
+	return policy.Defer.Duration, err
+}
+
+// Saturated reports whether low-priority rides are being shed right now.
+func (ls *LoadShedder) Saturated() bool {
+	ls.mu.Lock()
+	policy := ls.stats.Policy
+	ls.mu.Unlock()
+	return ls.saturation(policy) != nil
+}
+
+// saturation returns an *OverloadError if the fleet is saturated under policy.
+func (ls *LoadShedder) saturation(policy LoadSheddingPolicy) *OverloadError {
+	if policy.MinAvailableTaxis <= 0 {
+		return nil
+	}
+	available := len(ls.taxis.GetAllAvailable())
+	if available >= policy.MinAvailableTaxis {
+		return nil
+	}
+	queued := ls.scheduler.QueueDepth()
+	if queued <= policy.MaxQueueDepth {
+		return nil
+	}
+	return &OverloadError{AvailableTaxis: available, QueueDepth: queued}
+}
+
+// deferRide creates the ride of a shed low-priority request without queueing it, and
+// queues it once the fleet is no longer saturated (see requeueDeferred).
+// Returns the new ride's ID. Must be called with queueMu held and s.shutdown unset.
+func (s *Server) deferRide(request ride.RideRequest, every time.Duration, reason error) int {
+	r, request := s.createRide(request)
+	s.events.Publish(ride.RideDeferred, r, 0)
+	fmt.Printf("[Server] %sDeferring low-priority ride #%d from client #%d, checking again every %v: %v\n",
+		ride.TraceTag(r.TraceID), r.ID, request.ClientID, every, reason)
+	s.requeueDeferred(request, every)
+	return r.ID
+}
+
+// requeueDeferred queues a deferred ride after every (simulated time) if the fleet has
+// room by then, or checks again after another every if not. Gives up once the ride has
+// been assigned by hand, its deadline has passed (the scheduler expires it), or the
+// server shuts down.
+func (s *Server) requeueDeferred(request ride.RideRequest, every time.Duration) {
+	s.clock.AfterFunc(every, func() {
+		s.queueMu.RLock()
+		defer s.queueMu.RUnlock()
+		if s.shutdown {
+			log.Printf("[Server] %sDropping deferred ride #%d, server is shutting down\n", ride.TraceTag(request.TraceID), request.RideID)
+			return
+		}
+
+		r := s.rideStore.Get(request.RideID)
+		if r == nil {
+			return
+		}
+		if r.Status() != ride.CREATED || (!request.ExpiresAt.IsZero() && !s.clock.Now().Before(request.ExpiresAt)) {
+			return
+		}
+
+		if s.shedder.Saturated() {
+			s.requeueDeferred(request, every)
+			return
+		}
+		if s.queueRide(request) != nil {
+			log.Printf("[Server] %sDropping deferred ride #%d, server is shutting down\n", ride.TraceTag(request.TraceID), request.RideID)
+		}
+	})
+}
+
+// SetLoadShedding sets when low-priority rides are shed and whether they are rejected
+// with an *OverloadError or deferred (see LoadSheddingPolicy). Shed rides are counted
+// in Metrics.LoadShedding.
+func (s *Server) SetLoadShedding(policy LoadSheddingPolicy) {
+	s.shedder.SetPolicy(policy)
+	action := "reject"
+	if policy.Defer.Duration > 0 {
+		action = fmt.Sprintf("defer by %v", policy.Defer)
+	}
+	fmt.Printf("[Server] Load shedding: %s low-priority rides below %d available taxis with over %d queued\n",
+		action, policy.MinAvailableTaxis, policy.MaxQueueDepth)
+}
@@ -0,0 +1,55 @@
+// metrics.go - Live system metrics
+// A point-in-time view of the system for dashboards
+
+package server
+
+import (
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Metrics is a snapshot of the system's live state.
+type Metrics struct {
+	Time           time.Time                `json:"time"`                  // When the snapshot was taken (simulated time)
+	QueueDepth     int                      `json:"queue_depth"`           // Ride requests waiting to be dispatched
+	TotalTaxis     int                      `json:"total_taxis"`           // Registered taxis
+	AvailableTaxis int                      `json:"available_taxis"`       // Taxis free to take a ride
+	ActiveRides    int                      `json:"active_rides"`          // Rides with a taxi currently driving them
+	QueueWait      scheduler.QueueWaitStats `json:"queue_wait"`            // How long recent requests were queued before processing
+	SLA            SLAStats                 `json:"sla"`                   // SLA targets and how often rides missed them
+	LoadShedding   LoadSheddingStats        `json:"load_shedding"`         // Load-shedding policy and how many low-priority rides were shed
+	EventBus       ride.EventBusStats       `json:"event_bus"`             // Ride events fanned out and dropped per subscriber
+	RouteCache     *taxi.RouteCacheStats    `json:"route_cache,omitempty"` // Distance cache counters (nil without a cache)
+	Store          *taxi.StoreStats         `json:"store,omitempty"`       // Taxi store calls and lock contention (nil for other backends)
+}
+
+// GetMetrics returns a snapshot of the system's live state.
+func (s *Server) GetMetrics() Metrics {
+	return s.metrics(s.GetTaxiSnapshot())
+}
+
+// metrics returns the system's live state with the fleet as in taxis, so the taxi
+// counts agree with each other and with anything else the caller reads from taxis.
+func (s *Server) metrics(taxis taxi.TaxiSnapshot) Metrics {
+	metrics := Metrics{
+		Time:           s.clock.Now(),
+		QueueDepth:     s.scheduler.QueueDepth(),
+		TotalTaxis:     taxis.Count(),
+		AvailableTaxis: taxis.CountAvailable(),
+		ActiveRides:    s.scheduler.ActiveRideCount(),
+		QueueWait:      s.scheduler.QueueWaitStats(),
+		SLA:            s.sla.Stats(),
+		LoadShedding:   s.shedder.Stats(),
+		EventBus:       s.events.Stats(),
+	}
+	if stats, ok := s.GetRouteCacheStats(); ok {
+		metrics.RouteCache = &stats
+	}
+	if stats, ok := s.GetStoreStats(); ok {
+		metrics.Store = &stats
+	}
+	return metrics
+}
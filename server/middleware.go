@@ -0,0 +1,327 @@
+// middleware.go - HTTP middleware
+// Cross-cutting concerns every HTTP API request goes through, in one chain instead of
+// in each handler: request IDs, logging, panic recovery, authentication and rate limits
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// Middleware wraps an http.Handler with behavior shared by every endpoint.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler in middlewares, the first one outermost: it sees each request
+// first and its response last.
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// middleware returns the chain every HTTP API request goes through, outermost first.
+// Recovery sits inside logging, so a panicking request is still logged with its 500.
+func (s *Server) middleware() []Middleware {
+	return []Middleware{withRequestID, s.identifyCaller, logRequests, recoverPanics, s.rateLimited}
+}
+
+// contextKey is the type of the request context values set by middleware.
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota // string, set by withRequestID
+	accountKey                     // Client, set by identifyCaller for a known Bearer token
+)
+
+// requestIDHeader carries a request's ID, both ways.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest X-Request-ID a caller may choose.
+const maxRequestIDLength = 64
+
+// RequestID returns the ID withRequestID gave the request of ctx ("" outside the chain).
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestAccount returns the account whose Bearer token the request carries.
+// Returns false without a token, or with one no account has.
+func requestAccount(r *http.Request) (Client, bool) {
+	account, ok := r.Context().Value(accountKey).(Client)
+	return account, ok
+}
+
+// withRequestID gives every request an ID, the caller's own X-Request-ID if it is a
+// short token of letters, digits, '-', '_' and '.', or a new trace ID otherwise. The
+// ID is echoed in the response's X-Request-ID and becomes the trace ID of a ride the
+// request creates, so its log lines can be found from the caller's side.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = ride.NewTraceID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// validRequestID reports whether a caller's request ID is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status a handler answered with. It passes Flush and
+// Hijack through, so Server-Sent Events and WebSockets work behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int // 0 until the handler writes a header or body
+}
+
+// WriteHeader records the status and sends it.
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 and sends the body.
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered data to the client, if the underlying writer can.
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, e.g. for a WebSocket.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		sr.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// logRequests logs every request once it is answered, with its status, how long it
+// took (wall time) and the account that made it.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		caller := "anonymous"
+		if account, ok := requestAccount(r); ok {
+			caller = fmt.Sprintf("%s #%d", account.Role, account.ID)
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		fmt.Printf("[HTTP] %s%s %s -> %d in %v (%s)\n",
+			ride.TraceTag(RequestID(r.Context())), r.Method, r.URL.Path, status, time.Since(start).Round(time.Microsecond), caller)
+	})
+}
+
+// recoverPanics answers 500 to a request whose handler panicked, instead of dropping
+// the connection, and logs the panic.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // The handler meant to abort the response; net/http handles it
+			}
+			log.Printf("[HTTP] %sERROR: Panic serving %s %s: %v\n", ride.TraceTag(RequestID(r.Context())), r.Method, r.URL.Path, err)
+			if recorder, ok := w.(*statusRecorder); !ok || recorder.status == 0 {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// identifyCaller looks up the account of the request's Bearer token once, for the
+// middleware and handlers after it (see requestAccount and requireRole). Requests
+// without a known token go on anonymously; endpoints that need one turn them away.
+func (s *Server) identifyCaller(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, ok := s.clients.Authenticate(bearerToken(r))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accountKey, account)))
+	})
+}
+
+// requireRole lets a request through only with the Bearer token of an account with role.
+func (s *Server) requireRole(role Role) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			account, ok := requestAccount(r)
+			if !ok {
+				writeAuthError(w, ErrUnauthorized)
+				return
+			}
+			if err := account.hasRole(role); err != nil {
+				writeAuthError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimit caps how fast each caller may make HTTP API requests. Callers are told
+// apart by account, or by IP address without a known token. The zero limit is off.
+type RateLimit struct {
+	PerSecond float64 `json:"per_second"` // Requests each caller may make per second on average (wall time)
+	Burst     int     `json:"burst"`      // Requests a caller may make at once after being idle (default: PerSecond, at least 1)
+}
+
+// maxRateBuckets is how many callers the rate limiter keeps track of before it forgets
+// the ones that are back to a full burst.
+const maxRateBuckets = 10000
+
+// rateBucket is the token bucket of one caller.
+type rateBucket struct {
+	tokens  float64   // Requests the caller may still make now
+	updated time.Time // When tokens was last refilled
+}
+
+// RateLimiter keeps a token bucket per caller. It runs on the wall clock, like the
+// metrics stream: callers are people and programs, not the simulation.
+// All methods are safe for concurrent access.
+type RateLimiter struct {
+	mu      sync.Mutex             // Protects limit and buckets
+	limit   RateLimit              // Limit in force
+	buckets map[string]*rateBucket // Caller -> bucket
+}
+
+// NewRateLimiter creates a limiter that is off until SetLimit.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[string]*rateBucket)}
+}
+
+// SetLimit replaces the limit. Callers start again from a full burst.
+func (rl *RateLimiter) SetLimit(limit RateLimit) {
+	if limit.PerSecond > 0 && limit.Burst <= 0 {
+		limit.Burst = max(1, int(math.Ceil(limit.PerSecond)))
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limit = limit
+	clear(rl.buckets)
+}
+
+// Allow takes one request from a caller's bucket at now.
+// Returns false and how long until the caller may try again if the bucket is empty.
+func (rl *RateLimiter) Allow(caller string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.limit.PerSecond <= 0 {
+		return true, 0
+	}
+
+	burst := float64(rl.limit.Burst)
+	bucket, exists := rl.buckets[caller]
+	if !exists {
+		if len(rl.buckets) >= maxRateBuckets {
+			rl.forgetIdle(now)
+		}
+		bucket = &rateBucket{tokens: burst, updated: now}
+		rl.buckets[caller] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*rl.limit.PerSecond)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rl.limit.PerSecond * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// forgetIdle drops the buckets that have refilled: those callers would start from a
+// full burst anyway. Must be called with rl.mu held.
+func (rl *RateLimiter) forgetIdle(now time.Time) {
+	for caller, bucket := range rl.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*rl.limit.PerSecond >= float64(rl.limit.Burst) {
+			delete(rl.buckets, caller)
+		}
+	}
+}
+
+// rateLimited answers 429 with a Retry-After header to callers over the rate limit.
+func (s *Server) rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := "ip:" + r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			caller = "ip:" + host
+		}
+		if account, ok := requestAccount(r); ok {
+			caller = fmt.Sprintf("account:%d", account.ID)
+		}
+		allowed, retryAfter := s.rateLimiter.Allow(caller, time.Now())
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "rate limit exceeded, slow down", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetRateLimit caps how fast each caller may make HTTP API requests (see RateLimit).
+// Requests over it are answered 429 with a Retry-After header.
+func (s *Server) SetRateLimit(limit RateLimit) {
+	s.rateLimiter.SetLimit(limit)
+	if limit.PerSecond <= 0 {
+		fmt.Println("[Server] HTTP API rate limit off")
+		return
+	}
+	fmt.Printf("[Server] HTTP API rate limit: %.4g requests per second per caller\n", limit.PerSecond)
+}
@@ -0,0 +1,269 @@
+// notifications.go - Client notification preferences
+// Lets each client choose where it hears about its rides (webhooks, a WebSocket, or
+// just the server log) and whether it wants every transition or only how rides end
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// Notification channels a client can choose.
+const (
+	NotifyWebhook   = "webhook"   // The client's registered webhooks (see RegisterWebhook)
+	NotifyWebSocket = "websocket" // GET /notifications/ws connections of the client
+	NotifyLog       = "log"       // A line in the server log; choose only this to mute the push channels
+)
+
+// Notification severities: which ride events a client is told about.
+const (
+	NotifyAll      = "all"      // Every lifecycle event
+	NotifyTerminal = "terminal" // Only how the ride ended (see terminalEvents)
+)
+
+// notifyBufferSize is how many events may wait for one WebSocket before new ones are dropped.
+const notifyBufferSize = 100
+
+// defaultNotifyChannels are used for clients that never chose any.
+var defaultNotifyChannels = []string{NotifyWebhook, NotifyWebSocket}
+
+// terminalEvents are the events after which a ride never changes again.
+var terminalEvents = []ride.RideEventType{ride.RideFinished, ride.RideExpired, ride.RideFailed, ride.RideNoShow}
+
+// NotificationPrefs is how a client wants to be told about its rides.
+// The zero value sends every event to the client's webhooks and WebSockets.
+type NotificationPrefs struct {
+	Channels []string `json:"channels"` // Any of NotifyWebhook, NotifyWebSocket, NotifyLog (nil = defaultNotifyChannels)
+	Severity string   `json:"severity"` // NotifyAll or NotifyTerminal ("" = NotifyAll)
+}
+
+// withDefaults returns the preferences with unset fields filled in.
+func (p NotificationPrefs) withDefaults() NotificationPrefs {
+	if p.Channels == nil {
+		p.Channels = defaultNotifyChannels
+	}
+	if p.Severity == "" {
+		p.Severity = NotifyAll
+	}
+	return p
+}
+
+// Wants reports whether an event of the given type should be sent on channel.
+func (p NotificationPrefs) Wants(channel string, eventType ride.RideEventType) bool {
+	p = p.withDefaults()
+	if !slices.Contains(p.Channels, channel) {
+		return false
+	}
+	return p.Severity == NotifyAll || slices.Contains(terminalEvents, eventType)
+}
+
+// validate returns an error for unknown channels or severities, or no channel at all.
+func (p NotificationPrefs) validate() error {
+	if p.Channels != nil && len(p.Channels) == 0 {
+		return fmt.Errorf("at least one notification channel is needed; use %q to only log events", NotifyLog)
+	}
+	for _, channel := range p.Channels {
+		if channel != NotifyWebhook && channel != NotifyWebSocket && channel != NotifyLog {
+			return fmt.Errorf("unknown notification channel %q (want %s, %s or %s)", channel, NotifyWebhook, NotifyWebSocket, NotifyLog)
+		}
+	}
+	if p.Severity != "" && p.Severity != NotifyAll && p.Severity != NotifyTerminal {
+		return fmt.Errorf("unknown notification severity %q (want %s or %s)", p.Severity, NotifyAll, NotifyTerminal)
+	}
+	return nil
+}
+
+// RideNotifier delivers ride events to the clients who requested the rides, on the
+// log and WebSocket channels of their NotificationPrefs. Webhooks are delivered by
+// the WebhookDispatcher, which honors the same preferences.
+// All methods are safe for concurrent access.
+type RideNotifier struct {
+	rides       ride.RideStorage                         // For the client of each ride
+	clients     *ClientManager                           // For each client's preferences
+	mu          sync.Mutex                               // Protects subscribers
+	subscribers map[int]map[chan WebhookPayload]struct{} // Client ID -> its open WebSocket streams
+}
+
+// NewRideNotifier creates a notifier with no subscribers.
+func NewRideNotifier(rides ride.RideStorage, clients *ClientManager) *RideNotifier {
+	return &RideNotifier{
+		rides:       rides,
+		clients:     clients,
+		subscribers: make(map[int]map[chan WebhookPayload]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives the events of clientID's rides that its
+// preferences send to WebSockets, and a function that closes the channel again.
+// Events are dropped while the channel is full, as with EventBus.Subscribe.
+func (rn *RideNotifier) Subscribe(clientID int) (<-chan WebhookPayload, func()) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	ch := make(chan WebhookPayload, notifyBufferSize)
+	if rn.subscribers[clientID] == nil {
+		rn.subscribers[clientID] = make(map[chan WebhookPayload]struct{})
+	}
+	rn.subscribers[clientID][ch] = struct{}{}
+
+	unsubscribe := func() {
+		rn.mu.Lock()
+		defer rn.mu.Unlock()
+		if _, open := rn.subscribers[clientID][ch]; !open {
+			return // Already unsubscribed
+		}
+		delete(rn.subscribers[clientID], ch)
+		if len(rn.subscribers[clientID]) == 0 {
+			delete(rn.subscribers, clientID)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Run delivers every event from the channel until the channel is closed.
+// This method blocks and should be run as a goroutine.
+func (rn *RideNotifier) Run(events <-chan ride.RideEvent) {
+	for event := range events {
+		ride := rn.rides.Get(event.RideID)
+		if ride == nil {
+			continue
+		}
+		payload := WebhookPayload{RideEventV1: newRideEventV1(event), ClientID: ride.ClientID} // ClientID is fixed at creation
+		client, exists := rn.clients.Get(payload.ClientID)
+		if !exists {
+			continue
+		}
+
+		if client.Notify.Wants(NotifyLog, event.Type) {
+			fmt.Printf("[RideNotifier] Client #%d: ride #%d %s\n", client.ID, event.RideID, event.Type)
+		}
+		if !client.Notify.Wants(NotifyWebSocket, event.Type) {
+			continue
+		}
+		rn.mu.Lock()
+		for ch := range rn.subscribers[client.ID] {
+			select {
+			case ch <- payload:
+			default:
+				log.Printf("[RideNotifier] WARNING: A WebSocket of client #%d is too far behind, dropped %s event for ride #%d\n",
+					client.ID, event.Type, event.RideID)
+			}
+		}
+		rn.mu.Unlock()
+	}
+}
+
+// SetNotificationPrefs chooses how a client is told about its rides.
+// Returns an error for invalid preferences or an unknown client.
+func (s *Server) SetNotificationPrefs(clientID int, prefs NotificationPrefs) error {
+	if err := prefs.validate(); err != nil {
+		return err
+	}
+	if !s.clients.SetNotificationPrefs(clientID, prefs) {
+		return fmt.Errorf("client #%d not found", clientID)
+	}
+	fmt.Printf("[Server] Client #%d notifications: %v, %s events\n", clientID, prefs.withDefaults().Channels, prefs.withDefaults().Severity)
+	return nil
+}
+
+// GetNotificationPrefs returns how a client is told about its rides, with defaults filled in.
+// Returns an error for an unknown client.
+func (s *Server) GetNotificationPrefs(clientID int) (NotificationPrefs, error) {
+	client, exists := s.clients.Get(clientID)
+	if !exists {
+		return NotificationPrefs{}, fmt.Errorf("client #%d not found", clientID)
+	}
+	return client.Notify.withDefaults(), nil
+}
+
+// handleGetNotificationPrefs serves GET /notifications/preferences for the rider of the Bearer token.
+func (s *Server) handleGetNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	account, err := s.clients.Authorize(bearerToken(r), RoleRider)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	prefs, err := s.GetNotificationPrefs(account.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, prefs)
+}
+
+// handleSetNotificationPrefs serves PUT /notifications/preferences for the rider of the
+// Bearer token, and answers with the preferences now in effect.
+func (s *Server) handleSetNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	account, err := s.clients.Authorize(bearerToken(r), RoleRider)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	var prefs NotificationPrefs
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, fmt.Sprintf("parsing notification preferences: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.SetNotificationPrefs(account.ID, prefs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, prefs.withDefaults())
+}
+
+// handleNotificationSocket serves GET /notifications/ws: a WebSocket that receives the
+// rider's ride events as WebhookPayload JSON text messages while its preferences include
+// NotifyWebSocket. Browsers cannot set headers on WebSockets, so the token may also be
+// passed as ?token=.
+func (s *Server) handleNotificationSocket(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	account, err := s.clients.Authorize(token, RoleRider)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		if !errors.Is(err, errNotUpgradable) {
+			log.Printf("[Server] ERROR: WebSocket for client #%d: %v\n", account.ID, err)
+		}
+		return
+	}
+	defer ws.Close()
+
+	events, unsubscribe := s.notifier.Subscribe(account.ID)
+	defer unsubscribe()
+	closed := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case payload := <-events:
+			body, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("[Server] ERROR: Failed to encode %s event for ride #%d: %v\n", payload.Type, payload.RideID, err)
+				continue
+			}
+			if ws.WriteText(body) != nil {
+				return // Client went away; the read loop ends with the connection
+			}
+		}
+	}
+}
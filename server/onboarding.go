@@ -0,0 +1,112 @@
+// onboarding.go - Taxi onboarding approval API
+// Lets operators require approval for new taxis and approve or reject them
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+)
+
+// RequireTaxiApproval makes taxis registered from now on wait in PENDING_APPROVAL,
+// getting no rides, until ApproveTaxi; RejectTaxi removes them from the fleet instead.
+// Taxis registered before stay approved.
+func (s *Server) RequireTaxiApproval() {
+	s.onboarding.RequireApproval(s.taxiStore.GetAll())
+	fmt.Println("[Server] New taxis need an admin's approval before they get rides")
+}
+
+// ApproveTaxi lets a taxi pending approval serve rides.
+// Returns an error if the taxi is not pending approval.
+func (s *Server) ApproveTaxi(taxiID int) error {
+	if err := s.onboarding.Decide(taxiID, true, ""); err != nil {
+		return err
+	}
+	s.record(TraceEntry{Kind: TraceTaxiApproved, TaxiID: taxiID})
+	s.taxiStore.SetAvailability(taxiID, true)
+	fmt.Printf("[Server] Taxi #%d approved\n", taxiID)
+	return nil
+}
+
+// RejectTaxi turns down a taxi pending approval and removes it from the fleet.
+// Its application stays listed as REJECTED with the reason.
+// Returns an error if the taxi is not pending approval.
+func (s *Server) RejectTaxi(taxiID int, reason string) error {
+	if err := s.onboarding.Decide(taxiID, false, reason); err != nil {
+		return err
+	}
+	s.record(TraceEntry{Kind: TraceTaxiRejected, TaxiID: taxiID, Reason: reason})
+	if err := s.taxiManager.DeleteTaxi(taxiID); err != nil {
+		log.Printf("[Server] ERROR: Failed to remove rejected taxi #%d: %v\n", taxiID, err)
+	}
+	fmt.Printf("[Server] Taxi #%d rejected: %s\n", taxiID, reason)
+	return nil
+}
+
+// GetTaxiApplications returns the applications of taxis registered while approval was
+// required with a given status ("" for all), ordered by taxi ID.
+func (s *Server) GetTaxiApplications(status scheduler.OnboardingStatus) []scheduler.TaxiApplication {
+	return s.onboarding.Applications(status)
+}
+
+// SubscribeOnboardingEvents returns a channel that receives taxi onboarding events.
+func (s *Server) SubscribeOnboardingEvents() <-chan scheduler.OnboardingEvent {
+	return s.onboarding.Subscribe()
+}
+
+// TaxiRejection is the body of POST /admin/taxis/{id}/reject. It may be empty.
+type TaxiRejection struct {
+	Reason string `json:"reason"`
+}
+
+// handleAdminOnboarding serves GET /admin/onboarding, optionally ?status=PENDING_APPROVAL.
+func (s *Server) handleAdminOnboarding(w http.ResponseWriter, r *http.Request) {
+	status := scheduler.OnboardingStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", scheduler.PendingApproval, scheduler.Approved, scheduler.Rejected:
+	default:
+		http.Error(w, fmt.Sprintf("unknown status %q, want %s, %s or %s", status, scheduler.PendingApproval, scheduler.Approved, scheduler.Rejected), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, s.GetTaxiApplications(status))
+}
+
+// handleAdminApproveTaxi serves POST /admin/taxis/{id}/approve.
+func (s *Server) handleAdminApproveTaxi(w http.ResponseWriter, r *http.Request) {
+	taxiID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid taxi ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.ApproveTaxi(taxiID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, map[string]int{"approved": taxiID})
+}
+
+// handleAdminRejectTaxi serves POST /admin/taxis/{id}/reject.
+func (s *Server) handleAdminRejectTaxi(w http.ResponseWriter, r *http.Request) {
+	taxiID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid taxi ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	var rejection TaxiRejection
+	if err := json.NewDecoder(r.Body).Decode(&rejection); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("parsing rejection: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.RejectTaxi(taxiID, rejection.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, map[string]int{"rejected": taxiID})
+}
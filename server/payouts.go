@@ -0,0 +1,174 @@
+// payouts.go - Driver earnings payout reports
+// Sums the ledger's finished rides per driver and day or week, as JSON or CSV,
+// so drivers can be paid for what they drove
+
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Payout periods.
+const (
+	PayoutDaily  = "daily"  // Calendar days (UTC)
+	PayoutWeekly = "weekly" // Weeks from Monday 00:00 (UTC)
+)
+
+// payoutCSVHeader is the first line of a CSV payout report.
+var payoutCSVHeader = []string{"period_start", "driver_id", "driver_name", "rides", "distance", "fares_cents", "no_shows", "cancellation_fees_cents", "currency"}
+
+// DriverPayout is what one driver earned in one period and currency.
+type DriverPayout struct {
+	PeriodStart      time.Time `json:"period_start"` // Midnight (UTC) starting the day or week
+	DriverID         int       `json:"driver_id"`    // 0 for rides of taxis without a driver
+	DriverName       string    `json:"driver_name,omitempty"`
+	Rides            int       `json:"rides"`             // Finished rides, not counting no-shows
+	Distance         int       `json:"distance"`          // Pickup plus trip distance, no-show pickups included
+	Fares            int       `json:"fares"`             // Fares of the finished rides (minor units of Currency, e.g. cents)
+	NoShows          int       `json:"no_shows"`          // Rides whose passenger did not turn up
+	CancellationFees int       `json:"cancellation_fees"` // No-show fees charged for them (minor units of Currency)
+	Currency         string    `json:"currency"`          // ISO 4217 code of Fares and CancellationFees
+}
+
+// PayoutReport is the payout of every driver in every period between From and To.
+type PayoutReport struct {
+	Period  string         `json:"period"` // PayoutDaily or PayoutWeekly
+	From    time.Time      `json:"from,omitzero"`
+	To      time.Time      `json:"to,omitzero"`
+	Payouts []DriverPayout `json:"payouts"` // Ordered by period, then driver ID, then currency
+}
+
+// payoutPeriodStart returns the start of the day or week (UTC) that at falls in.
+func payoutPeriodStart(period string, at time.Time) time.Time {
+	at = at.UTC()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	if period == PayoutWeekly {
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	}
+	return day
+}
+
+// GetPayoutReport sums the rides that finished at or after from and before to (zero
+// for no bound) per driver, per day (PayoutDaily) or week (PayoutWeekly) and per
+// currency: a driver whose rides were charged in two currencies gets two payouts.
+// A ride counts for whoever drove the taxi when it finished. No-shows are counted
+// apart, with their fees, and not as rides and fares.
+// Returns an error for an unknown period.
+func (s *Server) GetPayoutReport(period string, from, to time.Time) (PayoutReport, error) {
+	if period != PayoutDaily && period != PayoutWeekly {
+		return PayoutReport{}, fmt.Errorf("unknown payout period %q, want %q or %q", period, PayoutDaily, PayoutWeekly)
+	}
+
+	type key struct {
+		start    time.Time
+		driverID int
+		currency string
+	}
+	totals := make(map[key]*DriverPayout)
+	for _, ride := range s.ledger.RidesBetween(from, to) {
+		k := key{start: payoutPeriodStart(period, ride.At), driverID: ride.DriverID, currency: ride.Currency}
+		payout, exists := totals[k]
+		if !exists {
+			payout = &DriverPayout{PeriodStart: k.start, DriverID: k.driverID, Currency: k.currency}
+			if driver, err := s.GetDriver(k.driverID); err == nil {
+				payout.DriverName = driver.Name
+			}
+			totals[k] = payout
+		}
+		payout.Distance += ride.Distance
+		if ride.NoShow {
+			payout.NoShows++
+			payout.CancellationFees += ride.Fare
+		} else {
+			payout.Rides++
+			payout.Fares += ride.Fare
+		}
+	}
+
+	report := PayoutReport{Period: period, From: from, To: to, Payouts: make([]DriverPayout, 0, len(totals))}
+	for _, payout := range totals {
+		report.Payouts = append(report.Payouts, *payout)
+	}
+	sort.Slice(report.Payouts, func(i, j int) bool {
+		a, b := report.Payouts[i], report.Payouts[j]
+		if !a.PeriodStart.Equal(b.PeriodStart) {
+			return a.PeriodStart.Before(b.PeriodStart)
+		}
+		if a.DriverID != b.DriverID {
+			return a.DriverID < b.DriverID
+		}
+		return a.Currency < b.Currency
+	})
+	return report, nil
+}
+
+// WriteCSV writes the report as CSV, one line per driver and period after a header.
+func (report PayoutReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(payoutCSVHeader); err != nil {
+		return err
+	}
+	for _, payout := range report.Payouts {
+		record := []string{
+			payout.PeriodStart.Format(time.DateOnly),
+			strconv.Itoa(payout.DriverID),
+			payout.DriverName,
+			strconv.Itoa(payout.Rides),
+			strconv.Itoa(payout.Distance),
+			strconv.Itoa(payout.Fares),
+			payout.Currency,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// handleAdminPayouts serves GET /admin/payouts?period=daily|weekly (default daily),
+// optionally limited with from and to (RFC 3339), as JSON or, with ?format=csv, CSV.
+func (s *Server) handleAdminPayouts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		period = PayoutDaily
+	}
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		text := query.Get(name)
+		if text == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s %q, want RFC 3339 like 2024-01-01T00:00:00Z", name, text), http.StatusBadRequest)
+			return
+		}
+		bounds[i] = value
+	}
+
+	report, err := s.GetPayoutReport(period, bounds[0], bounds[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch query.Get("format") {
+	case "", "json":
+		writeJSON(w, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := report.WriteCSV(w); err != nil {
+			return // Client went away
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %q, want json or csv", query.Get("format")), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,39 @@
+// pricing.go - Tariff administration
+// Lets operators read and replace the tariffs rides are priced by
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// SetTariffs replaces the tariffs rides are priced with (see PricingService.SetTariffs):
+// each ride gets the first that applies to its pool and pickup when it is requested,
+// and keeps it. nil prices every ride with DefaultTariff again.
+// Returns an error, leaving the tariffs as they were, if any tariff is invalid.
+func (s *Server) SetTariffs(tariffs []ride.Tariff) error {
+	if err := s.pricing.SetTariffs(tariffs); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(tariffs)+1)
+	for _, tariff := range tariffs {
+		names = append(names, fmt.Sprintf("%s (%s)", tariff.Name, tariff.Currency))
+	}
+	names = append(names, ride.DefaultTariff().Name+" for the rest")
+	fmt.Printf("[Server] Tariffs: %s\n", strings.Join(names, ", "))
+	return nil
+}
+
+// GetTariffs returns the tariffs set, in the order they are checked.
+func (s *Server) GetTariffs() []ride.Tariff {
+	return s.pricing.Tariffs()
+}
+
+// handleAdminTariffs serves GET /admin/tariffs.
+func (s *Server) handleAdminTariffs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{"tariffs": s.GetTariffs(), "default": ride.DefaultTariff()})
+}
@@ -0,0 +1,178 @@
+// quotes.go - Ride price quotes
+// Prices a ride before it is booked and holds that fare for a while: a ride booked with
+// the quote is charged the quoted fare, whatever the trip costs by the time it ends
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// quoteValidity is how long a quote can be booked after it was given (simulated time).
+const quoteValidity = 5 * time.Minute
+
+// RideQuote is a fare offered to a rider for one trip, until ExpiresAt.
+type RideQuote struct {
+	ID        int             `json:"quote_id"`
+	ClientID  int             `json:"client_id"` // Only this rider may book it
+	Start     taxi.Location   `json:"start"`
+	End       taxi.Location   `json:"end"`
+	Waypoints []taxi.Location `json:"waypoints,omitempty"` // Stops in between, in order
+	Distance  int             `json:"distance"`            // Through every waypoint, as priced
+	Fare      int             `json:"fare"`                // Charged for the ride booked with the quote (minor units of Currency)
+	Currency  string          `json:"currency"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Tariff    ride.Tariff     `json:"-"` // Priced with; the ride booked gets it too
+}
+
+// QuoteStore keeps the quotes given and not booked yet. Expired quotes are dropped as
+// new ones are added.
+// All methods are safe for concurrent access.
+type QuoteStore struct {
+	mu     sync.Mutex        // Protects quotes and nextID
+	quotes map[int]RideQuote // Quote ID -> quote
+	nextID int               // ID of the next quote given
+	clock  taxi.Clock        // For expiring quotes
+}
+
+// NewQuoteStore creates a store without quotes.
+func NewQuoteStore(clock taxi.Clock) *QuoteStore {
+	return &QuoteStore{quotes: make(map[int]RideQuote), nextID: 1, clock: clock}
+}
+
+// Add stores a quote and returns it with its ID set.
+func (qs *QuoteStore) Add(quote RideQuote) RideQuote {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	now := qs.clock.Now()
+	for id, stored := range qs.quotes {
+		if !now.Before(stored.ExpiresAt) {
+			delete(qs.quotes, id)
+		}
+	}
+	quote.ID = qs.nextID
+	qs.nextID++
+	qs.quotes[quote.ID] = quote
+	return quote
+}
+
+// Take removes and returns the quote a request books, so it is booked at most once.
+// Returns an error if the quote is unknown or already booked, has expired, or was
+// given to another rider or for another trip; the quote is left as it was then.
+func (qs *QuoteStore) Take(request ride.RideRequest) (RideQuote, error) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	quote, exists := qs.quotes[request.QuoteID]
+	switch {
+	case !exists || quote.ClientID != request.ClientID:
+		return RideQuote{}, fmt.Errorf("quote #%d not found or already booked", request.QuoteID)
+	case !qs.clock.Now().Before(quote.ExpiresAt):
+		delete(qs.quotes, quote.ID)
+		return RideQuote{}, fmt.Errorf("quote #%d expired at %s", quote.ID, quote.ExpiresAt.Format(time.RFC3339))
+	case quote.Start != request.StartLocation || quote.End != request.EndLocation || !slices.Equal(quote.Waypoints, request.Waypoints):
+		return RideQuote{}, fmt.Errorf("quote #%d is for another trip: (%d,%d) -> (%d,%d)%s", quote.ID,
+			quote.Start.X, quote.Start.Y, quote.End.X, quote.End.Y, ride.ViaTag(quote.Waypoints))
+	}
+	delete(qs.quotes, quote.ID)
+	return quote, nil
+}
+
+// Restore puts back a taken quote whose ride was not booked after all.
+func (qs *QuoteStore) Restore(quote RideQuote) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	qs.quotes[quote.ID] = quote
+}
+
+// QuoteRide prices the trip of a request, as the authenticated rider would be charged
+// for it now by the tariff of its pool and pickup, and holds that fare for 5 minutes
+// (simulated time): a RequestRide with the quote's ID as QuoteID, for the same trip,
+// is charged the quoted fare even if the trip costs more or less by the time it ends,
+// e.g. after road edits make it longer or the tariffs change. A quote books one ride.
+// Only the request's token, places, locations, waypoints and pool are used.
+// Returns ErrUnauthorized, an error wrapping ErrForbidden, or an error wrapping
+// ErrInvalidRequest if a place cannot be found.
+func (s *Server) QuoteRide(request ride.RideRequest) (RideQuote, error) {
+	if err := s.authenticate(&request); err != nil {
+		return RideQuote{}, err
+	}
+	if err := s.resolvePlaces(&request); err != nil {
+		return RideQuote{}, err
+	}
+
+	tariff := s.pricing.TariffFor(request.Pool, request.StartLocation)
+	estimate := s.estimateTrip(tariff, request.StartLocation, request.EndLocation, request.Waypoints)
+	quote := s.quotes.Add(RideQuote{
+		ClientID:  request.ClientID,
+		Start:     request.StartLocation,
+		End:       request.EndLocation,
+		Waypoints: slices.Clone(request.Waypoints),
+		Distance:  estimate.Distance,
+		Fare:      estimate.Fare,
+		Currency:  tariff.Currency,
+		ExpiresAt: s.clock.Now().Add(quoteValidity),
+		Tariff:    tariff,
+	})
+	fmt.Printf("[Server] Quoted %d %s to client #%d for (%d,%d) -> (%d,%d)%s (quote #%d)\n", quote.Fare, quote.Currency, quote.ClientID,
+		quote.Start.X, quote.Start.Y, quote.End.X, quote.End.Y, ride.ViaTag(quote.Waypoints), quote.ID)
+	return quote, nil
+}
+
+// priceRequest sets the tariff of an authenticated, geocoded request: the one of its
+// pool and pickup, or, for a request booking a quote, the quote's, whose fare it locks
+// by taking the quote and setting request.QuotedFare. Returns the quote taken (zero
+// without one), to be restored if the ride is not booked, or an error wrapping
+// ErrInvalidRequest if the request cannot book its quote.
+func (s *Server) priceRequest(request *ride.RideRequest) (RideQuote, error) {
+	request.Tariff = s.pricing.TariffFor(request.Pool, request.StartLocation)
+	request.QuotedFare = 0
+	if request.QuoteID == 0 {
+		return RideQuote{}, nil
+	}
+	quote, err := s.quotes.Take(*request)
+	if err != nil {
+		fmt.Printf("[Server] Rejecting ride request from client #%d: %v\n", request.ClientID, err)
+		return RideQuote{}, fmt.Errorf("%w: %v", ride.ErrInvalidRequest, err)
+	}
+	request.QuotedFare, request.Tariff = quote.Fare, quote.Tariff
+	return quote, nil
+}
+
+// restoreQuotes puts back the quotes taken by priceRequest for rides that were not booked.
+func (s *Server) restoreQuotes(quotes ...RideQuote) {
+	for _, quote := range quotes {
+		if quote.ID != 0 {
+			s.quotes.Restore(quote)
+		}
+	}
+}
+
+// handleQuoteRide serves POST /quotes: the body is a RideOrderV1, of which only the
+// trip is used, and the answer the RideQuote.
+func (s *Server) handleQuoteRide(w http.ResponseWriter, r *http.Request) {
+	var order RideOrderV1
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, fmt.Sprintf("parsing quote request: %v", err), http.StatusBadRequest)
+		return
+	}
+	quote, err := s.QuoteRide(s.orderRequest(order, bearerToken(r)))
+	switch {
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, ErrForbidden):
+		writeAuthError(w, err)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, quote)
+	}
+}
@@ -0,0 +1,379 @@
+// recorder.go - Simulation recording and replay
+// Records every input and state change of a run to a JSON Lines trace, and feeds the
+// recorded inputs back through a fresh Server so assignment strategies can be compared
+// on identical demand
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TraceKind describes what a trace entry records.
+// Inputs are replayed by TraceReplayer; state changes are kept for comparison only.
+type TraceKind string
+
+const (
+	TraceTaxiRegistered     TraceKind = "TAXI_REGISTERED"      // Input: RegisterTaxi
+	TraceTaxiMoved          TraceKind = "TAXI_MOVED"           // Input: UpdateTaxiLocation
+	TraceMaintenanceStarted TraceKind = "MAINTENANCE_STARTED"  // Input: SetTaxiMaintenance(on)
+	TraceMaintenanceEnded   TraceKind = "MAINTENANCE_ENDED"    // Input: SetTaxiMaintenance(off)
+	TraceTaxiPoolChanged    TraceKind = "TAXI_POOL_CHANGED"    // Input: SetTaxiPool
+	TraceTaxiApproved       TraceKind = "TAXI_APPROVED"        // Input: ApproveTaxi
+	TraceTaxiRejected       TraceKind = "TAXI_REJECTED"        // Input: RejectTaxi
+	TraceRideRequested      TraceKind = "RIDE_REQUESTED"       // Input: RequestRide
+	TraceRoundTripRequested TraceKind = "ROUND_TRIP_REQUESTED" // Input: RequestRoundTrip
+	TraceRideEvent          TraceKind = "RIDE_EVENT"           // State change: a RideEvent
+	TraceTaxiChanged        TraceKind = "TAXI_CHANGED"         // State change: a TaxiChangedEvent
+)
+
+// TraceRequest is a ride request as recorded in a trace.
+// Deadlines are stored relative to the request, so they still make sense on replay.
+type TraceRequest struct {
+	ClientID        int                 `json:"client_id"`
+	StartLocation   taxi.Location       `json:"start"`
+	EndLocation     taxi.Location       `json:"end"`
+	Waypoints       []taxi.Location     `json:"waypoints,omitempty"`
+	Requirements    taxi.TaxiAttributes `json:"requirements,omitempty"`
+	PreferredTaxiID int                 `json:"preferred_taxi_id,omitempty"`
+	Pool            string              `json:"pool,omitempty"`
+	Priority        ride.RidePriority   `json:"priority,omitempty"`
+	Metadata        map[string]string   `json:"metadata,omitempty"`
+	ExpiresIn       taxi.Duration       `json:"expires_in,omitzero"` // Time from the request to its deadline (zero for none)
+	ReturnIn        taxi.Duration       `json:"return_in,omitzero"`  // Round trips only: time until the return leg
+}
+
+// TraceEntry is one line of a simulation trace.
+// Only the fields relevant to Kind are set.
+type TraceEntry struct {
+	At         taxi.Duration          `json:"at"`   // Time since recording started (simulated time)
+	Kind       TraceKind              `json:"kind"` // What was recorded
+	TaxiID     int                    `json:"taxi_id,omitempty"`
+	Location   *taxi.Location         `json:"location,omitempty"`
+	Attributes taxi.TaxiAttributes    `json:"attributes,omitempty"`
+	Pool       string                 `json:"pool,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Request    *TraceRequest          `json:"request,omitempty"`
+	RideEvent  *ride.RideEvent        `json:"ride_event,omitempty"`
+	TaxiChange *taxi.TaxiChangedEvent `json:"taxi_change,omitempty"`
+}
+
+// SimulationRecorder appends trace entries to a file.
+// All public methods are safe for concurrent access from multiple goroutines.
+type SimulationRecorder struct {
+	mu    sync.Mutex // Protects file writes, so lines never interleave
+	file  *os.File   // Destination file
+	clock taxi.Clock // For entry offsets
+	start time.Time  // When recording started
+}
+
+// NewSimulationRecorder creates (or truncates) the trace file at path.
+func NewSimulationRecorder(path string, clock taxi.Clock) (*SimulationRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating simulation trace: %w", err)
+	}
+	return &SimulationRecorder{file: file, clock: clock, start: clock.Now()}, nil
+}
+
+// Record appends an entry stamped with the current time.
+func (sr *SimulationRecorder) Record(entry TraceEntry) {
+	sr.recordAt(sr.clock.Now(), entry)
+}
+
+// recordAt appends an entry stamped with the given time.
+func (sr *SimulationRecorder) recordAt(at time.Time, entry TraceEntry) {
+	entry.At = taxi.Duration{Duration: at.Sub(sr.start)}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[SimulationRecorder] ERROR: Failed to encode %s entry: %v\n", entry.Kind, err)
+		return
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if _, err := sr.file.Write(append(line, '\n')); err != nil {
+		log.Printf("[SimulationRecorder] ERROR: Failed to write %s entry: %v\n", entry.Kind, err)
+	}
+}
+
+// Run records every ride event and taxi change from the channels as state changes.
+// This method blocks and should be run as a goroutine.
+func (sr *SimulationRecorder) Run(rideEvents <-chan ride.RideEvent, taxiChanges <-chan taxi.TaxiChangedEvent) {
+	for {
+		select {
+		case event := <-rideEvents:
+			sr.recordAt(event.Time, TraceEntry{Kind: TraceRideEvent, RideEvent: &event})
+		case change := <-taxiChanges:
+			sr.Record(TraceEntry{Kind: TraceTaxiChanged, TaxiChange: &change})
+		}
+	}
+}
+
+// traceRequest converts a ride request for the trace, made at time now.
+func traceRequest(request ride.RideRequest, now time.Time) *TraceRequest {
+	recorded := &TraceRequest{
+		ClientID:        request.ClientID,
+		StartLocation:   request.StartLocation,
+		EndLocation:     request.EndLocation,
+		Waypoints:       request.Waypoints,
+		Requirements:    request.Requirements,
+		PreferredTaxiID: request.PreferredTaxiID,
+		Pool:            request.Pool,
+		Priority:        request.Priority,
+		Metadata:        request.Metadata,
+	}
+	if !request.ExpiresAt.IsZero() {
+		recorded.ExpiresIn = taxi.Duration{Duration: request.ExpiresAt.Sub(now)}
+	}
+	return recorded
+}
+
+// ReadTrace loads every entry of the trace at path, ordered by time.
+func ReadTrace(path string) ([]TraceEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading simulation trace: %w", err)
+	}
+	defer file.Close()
+
+	entries := make([]TraceEntry, 0)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry TraceEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("simulation trace line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading simulation trace: %w", err)
+	}
+
+	// Ride events are stamped with their own time and may be written slightly late
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Duration < entries[j].At.Duration })
+	return entries, nil
+}
+
+// TraceSummary sums up how the rides of one run went, for comparing runs.
+type TraceSummary struct {
+	Requested    int           // Rides created
+	Finished     int           // Rides that reached FINISHED
+	Expired      int           // Rides that reached EXPIRED
+	NoShows      int           // Rides whose passenger did not turn up (NO_SHOW)
+	Reassigned   int           // Times a ride lost its taxi or was declined
+	MeanWait     time.Duration // Average time from request to the ride starting
+	MeanDuration time.Duration // Average time from start to finish
+}
+
+// String formats the summary for log lines.
+func (ts TraceSummary) String() string {
+	return fmt.Sprintf("%d rides: %d finished, %d expired, %d no-shows, %d reassigned, mean wait %v, mean ride %v",
+		ts.Requested, ts.Finished, ts.Expired, ts.NoShows, ts.Reassigned,
+		ts.MeanWait.Round(time.Second), ts.MeanDuration.Round(time.Second))
+}
+
+// SummarizeEvents builds a TraceSummary from a run's ride events, in the order they happened.
+func SummarizeEvents(events []ride.RideEvent) TraceSummary {
+	var summary TraceSummary
+	created := make(map[int]time.Time)
+	started := make(map[int]time.Time)
+	var totalWait, totalDuration time.Duration
+	waits := 0
+
+	for _, event := range events {
+		switch event.Type {
+		case ride.RideCreated:
+			summary.Requested++
+			created[event.RideID] = event.Time
+		case ride.RideStarted:
+			started[event.RideID] = event.Time
+		case ride.RideFinished:
+			summary.Finished++
+			if start, ok := started[event.RideID]; ok {
+				totalDuration += event.Time.Sub(start)
+				if request, ok := created[event.RideID]; ok {
+					totalWait += start.Sub(request)
+					waits++
+				}
+			}
+		case ride.RideExpired:
+			summary.Expired++
+		case ride.RideNoShow:
+			summary.NoShows++
+		case ride.RideReassigned, ride.RideDeclined:
+			summary.Reassigned++
+		}
+	}
+
+	if waits > 0 {
+		summary.MeanWait = totalWait / time.Duration(waits)
+	}
+	if summary.Finished > 0 {
+		summary.MeanDuration = totalDuration / time.Duration(summary.Finished)
+	}
+	return summary
+}
+
+// SummarizeTrace builds a TraceSummary from the ride events recorded in a trace.
+func SummarizeTrace(entries []TraceEntry) TraceSummary {
+	events := make([]ride.RideEvent, 0)
+	for _, entry := range entries {
+		if entry.Kind == TraceRideEvent && entry.RideEvent != nil {
+			events = append(events, *entry.RideEvent)
+		}
+	}
+	return SummarizeEvents(events)
+}
+
+// TraceReplayer feeds the inputs of a recorded trace to a Server at their original
+// (simulated) times and collects the ride events of the replayed run.
+// Taxi IDs in the trace are mapped to the IDs the Server hands out on replay, and each
+// recorded client is registered again on replay to get a token.
+// Driver answers to ride offers are not recorded, so traces from runs with
+// simulated drivers replay without confirmation.
+type TraceReplayer struct {
+	server  *Server          // Server the inputs are replayed against
+	entries []TraceEntry     // Recorded trace, ordered by time
+	mu      sync.Mutex       // Protects events
+	events  []ride.RideEvent // Ride events of the replayed run
+}
+
+// NewTraceReplayer creates a TraceReplayer for the given trace.
+func NewTraceReplayer(server *Server, entries []TraceEntry) *TraceReplayer {
+	return &TraceReplayer{server: server, entries: entries}
+}
+
+// Start replays every recorded input and returns once the last one has been sent.
+func (tr *TraceReplayer) Start() {
+	go func(events <-chan ride.RideEvent) {
+		for event := range events {
+			tr.mu.Lock()
+			tr.events = append(tr.events, event)
+			tr.mu.Unlock()
+		}
+	}(tr.server.SubscribeRideEvents())
+
+	clock := tr.server.Clock()
+	start := clock.Now()
+	taxiIDs := make(map[int]int)   // Recorded taxi ID -> replayed taxi ID
+	tokens := make(map[int]string) // Recorded client ID -> token of the replayed client
+	replayed := 0
+
+	fmt.Printf("[TraceReplayer] Replaying %d trace entries...\n", len(tr.entries))
+	for _, entry := range tr.entries {
+		if !isTraceInput(entry.Kind) {
+			continue
+		}
+		if wait := entry.At.Duration - clock.Since(start); wait > 0 {
+			clock.Sleep(wait)
+		}
+		if tr.apply(entry, taxiIDs, tokens) {
+			replayed++
+		}
+	}
+	fmt.Printf("[TraceReplayer] Replayed %d inputs\n", replayed)
+}
+
+// Summary sums up the ride events of the replayed run so far.
+func (tr *TraceReplayer) Summary() TraceSummary {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return SummarizeEvents(tr.events)
+}
+
+// isTraceInput reports whether entries of a kind are replayed.
+func isTraceInput(kind TraceKind) bool {
+	switch kind {
+	case TraceTaxiRegistered, TraceTaxiMoved, TraceMaintenanceStarted, TraceMaintenanceEnded, TraceTaxiPoolChanged,
+		TraceTaxiApproved, TraceTaxiRejected, TraceRideRequested, TraceRoundTripRequested:
+		return true
+	}
+	return false
+}
+
+// apply replays a single input entry. Returns false if it could not be replayed.
+func (tr *TraceReplayer) apply(entry TraceEntry, taxiIDs map[int]int, tokens map[int]string) bool {
+	switch entry.Kind {
+	case TraceTaxiRegistered:
+		if entry.Location == nil {
+			return false
+		}
+		taxiIDs[entry.TaxiID] = tr.server.RegisterTaxi(*entry.Location, entry.Attributes)
+		return true
+
+	case TraceTaxiMoved:
+		id, known := taxiIDs[entry.TaxiID]
+		if !known || entry.Location == nil {
+			return false
+		}
+		return tr.server.UpdateTaxiLocation(id, *entry.Location) == nil
+
+	case TraceMaintenanceStarted, TraceMaintenanceEnded:
+		id, known := taxiIDs[entry.TaxiID]
+		if !known {
+			return false
+		}
+		return tr.server.SetTaxiMaintenance(id, entry.Kind == TraceMaintenanceStarted) == nil
+
+	case TraceTaxiPoolChanged:
+		id, known := taxiIDs[entry.TaxiID]
+		if !known {
+			return false
+		}
+		return tr.server.SetTaxiPool(id, entry.Pool) == nil
+
+	case TraceTaxiApproved, TraceTaxiRejected:
+		id, known := taxiIDs[entry.TaxiID]
+		if !known {
+			return false
+		}
+		if entry.Kind == TraceTaxiApproved {
+			return tr.server.ApproveTaxi(id) == nil
+		}
+		return tr.server.RejectTaxi(id, entry.Reason) == nil
+
+	case TraceRideRequested, TraceRoundTripRequested:
+		if entry.Request == nil {
+			return false
+		}
+		token, known := tokens[entry.Request.ClientID]
+		if !known {
+			_, token = tr.server.RegisterClient(fmt.Sprintf("replayed client %d", entry.Request.ClientID))
+			tokens[entry.Request.ClientID] = token
+		}
+		now := tr.server.Clock().Now()
+		request := ride.RideRequest{
+			Token:           token,
+			StartLocation:   entry.Request.StartLocation,
+			EndLocation:     entry.Request.EndLocation,
+			Waypoints:       entry.Request.Waypoints,
+			Requirements:    entry.Request.Requirements,
+			PreferredTaxiID: taxiIDs[entry.Request.PreferredTaxiID],
+			Pool:            entry.Request.Pool,
+			Priority:        entry.Request.Priority,
+			Metadata:        entry.Request.Metadata,
+		}
+		if entry.Request.ExpiresIn.Duration > 0 {
+			request.ExpiresAt = now.Add(entry.Request.ExpiresIn.Duration)
+		}
+		var err error
+		if entry.Kind == TraceRoundTripRequested {
+			_, _, err = tr.server.RequestRoundTrip(request, now.Add(entry.Request.ReturnIn.Duration))
+		} else {
+			_, err = tr.server.RequestRide(request)
+		}
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+// recovery.go - Restart recovery of in-flight rides
+// Hands the rides restored from the journal that had a taxi to the scheduler on startup
+
+package server
+
+import "github.com/Nart-Tehaucha/TaxiScheduler/ride"
+
+// recoverRides hands every restored ride that had a taxi to RecoverRide, oldest first.
+// Returns how many were resumed and how many failed.
+func (s *Server) recoverRides() (int, int) {
+	resumed, failed := 0, 0
+	for _, snapshot := range s.rideStore.List() {
+		if status := snapshot.Status(); status != ride.ASSIGNED && status != ride.ACCEPTED && status != ride.IN_PROGRESS {
+			continue
+		}
+		ride := s.rideStore.Get(snapshot.ID)
+		if s.scheduler.RecoverRide(ride) {
+			resumed++
+		} else {
+			failed++
+		}
+	}
+	return resumed, failed
+}
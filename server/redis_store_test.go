@@ -0,0 +1,111 @@
+//go:build redis
+
+// Integration tests against a real Redis server (6.2 or newer):
+//
+//	REDIS_ADDR=localhost:6379 go test -tags redis -run Redis .
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// redisAddr returns $REDIS_ADDR, or localhost:6379 if it is not set.
+func redisAddr() string {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return addr
+	}
+	return "localhost:6379"
+}
+
+func TestRedisEndToEnd(t *testing.T) {
+	result := RunEndToEnd(EndToEndConfig{Taxis: 5, Rides: 20, Seed: 1, RedisAddr: redisAddr()})
+	if !result.Passed() {
+		t.Fatal(result)
+	}
+}
+
+// TestRedisTwoServersNeverShareATaxi runs two Servers on one fleet in Redis, as two
+// instances behind a load balancer would, with more rides than taxis, and checks that
+// no taxi is ever on a ride of both at once.
+func TestRedisTwoServersNeverShareATaxi(t *testing.T) {
+	const taxis, ridesPerServer = 3, 8
+	clock := taxi.NewManualClock(testStart)
+	prefix := fmt.Sprintf("taxischeduler-test-%d", time.Now().UnixNano())
+	servers := make([]*Server, 2)
+	for i := range servers {
+		store, err := taxi.NewRedisTaxiStore(redisAddr(), prefix, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers[i] = NewServerWithConfig(ServerConfig{Clock: clock, Seed: int64(i + 1), Taxis: store})
+		defer servers[i].Shutdown()
+	}
+	defer func() {
+		for _, taxi := range servers[0].GetAllTaxis() {
+			servers[0].DeleteTaxi(taxi.ID)
+		}
+	}()
+
+	for i := 0; i < taxis; i++ {
+		servers[0].RegisterTaxi(taxi.Location{X: 10 * i, Y: 10}, 0)
+	}
+	for _, server := range servers {
+		_, token := server.RegisterClient("test rider")
+		go func() { // A full queue only drains as the clock moves
+			for n := 0; n < ridesPerServer; n++ {
+				server.RequestRide(testRide(token, n))
+			}
+		}()
+	}
+
+	// Only rides on the same taxi before and after reading every server count: a ride
+	// can be reassigned in between
+	type serverRide struct{ server, ride int }
+	onTaxi := func() map[serverRide]int {
+		rides := make(map[serverRide]int)
+		for i, server := range servers {
+			for _, r := range server.GetRides() {
+				if isOnTaxi(r) {
+					rides[serverRide{i, r.ID}] = r.TaxiID()
+				}
+			}
+		}
+		return rides
+	}
+
+	finished := func() bool {
+		for _, server := range servers {
+			if len(server.GetRidesByStatus(ride.FINISHED)) < ridesPerServer {
+				return false
+			}
+		}
+		return true
+	}
+	clock.Settle()
+	for step := 0; !finished(); step++ {
+		if step == 2*60*60 {
+			t.Fatal("not every ride finished within two simulated hours")
+		}
+		clock.Advance(time.Second)
+		clock.Settle()
+
+		before, after := onTaxi(), onTaxi()
+		riding := make(map[int]serverRide) // Taxi ID -> the ride on it
+		for key, taxiID := range after {
+			if before[key] != taxiID {
+				continue
+			}
+			if other, busy := riding[taxiID]; busy {
+				t.Fatalf("taxi #%d on ride #%d of server %d and ride #%d of server %d at once", taxiID, other.ride, other.server, key.ride, key.server)
+			}
+			riding[taxiID] = key
+		}
+	}
+}
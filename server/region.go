@@ -0,0 +1,172 @@
+// region.go - Multi-region dispatch
+// Splits the grid into regions, each owned by its own Server, and forwards rides to a
+// neighboring region when the local one has no taxi to send
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Region is an area of the grid served by its own Server.
+type Region struct {
+	Zone   taxi.Zone // Area owned by the region; Zone.Name is the region's name
+	Server *Server   // Dispatches the region's taxis
+}
+
+// RegionalRide identifies a ride across regions, since ride IDs are only unique within one Server.
+type RegionalRide struct {
+	Region string // Name of the region whose Server holds the ride
+	RideID int    // ID of the ride on that Server
+}
+
+// RegionCoordinator routes taxis and ride requests to the Server owning their region.
+// A ride whose region has no available taxi is forwarded to the nearest neighboring
+// region that has one, and that region's taxi drives across the border for the pickup.
+// Build the regions' Servers with one shared ServerConfig.Clients, so a client's token
+// is accepted by whichever region the ride lands in.
+// All public methods are safe for concurrent access from multiple goroutines.
+type RegionCoordinator struct {
+	mu      sync.RWMutex // Protects regions
+	regions []*Region    // Regions in the order they were added
+}
+
+// NewRegionCoordinator creates a RegionCoordinator with no regions.
+func NewRegionCoordinator() *RegionCoordinator {
+	return &RegionCoordinator{}
+}
+
+// AddRegion hands the area of zone to server.
+// Returns an error if a region with the same name exists or the zone overlaps another region.
+func (rc *RegionCoordinator) AddRegion(zone taxi.Zone, server *Server) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for _, region := range rc.regions {
+		if region.Zone.Name == zone.Name {
+			return fmt.Errorf("region %q already exists", zone.Name)
+		}
+		if region.Zone.Overlaps(zone) {
+			return fmt.Errorf("region %q overlaps region %q", zone.Name, region.Zone.Name)
+		}
+	}
+	rc.regions = append(rc.regions, &Region{Zone: zone, Server: server})
+	fmt.Printf("[RegionCoordinator] Region %q covers (%d,%d)-(%d,%d)\n",
+		zone.Name, zone.Min.X, zone.Min.Y, zone.Max.X, zone.Max.Y)
+	return nil
+}
+
+// GetRegion returns the region with the given name.
+// Returns an error if it was not found.
+func (rc *RegionCoordinator) GetRegion(name string) (*Region, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	for _, region := range rc.regions {
+		if region.Zone.Name == name {
+			return region, nil
+		}
+	}
+	return nil, fmt.Errorf("region %q not found", name)
+}
+
+// RegisterTaxi registers a taxi with the Server of the region containing location.
+// Returns the region's name and the taxi's ID there, or an error if no region covers location.
+func (rc *RegionCoordinator) RegisterTaxi(location taxi.Location, attributes taxi.TaxiAttributes) (string, int, error) {
+	region := rc.regionAt(location)
+	if region == nil {
+		return "", 0, fmt.Errorf("no region covers (%d,%d)", location.X, location.Y)
+	}
+	return region.Zone.Name, region.Server.RegisterTaxi(location, attributes), nil
+}
+
+// RequestRide submits a ride to the Server of the region containing its pickup.
+// If that region has no available taxi meeting the ride's requirements and pool, the ride goes to
+// the closest adjacent region that has one. When no neighbor can help either, the ride
+// stays local and waits for a taxi there.
+// The check is a snapshot: a taxi may be taken before the ride is dispatched, in which
+// case the ride waits in the region it was sent to.
+// Returns where the ride was created, or an error if no region covers the pickup or
+// the chosen Server rejected the request.
+func (rc *RegionCoordinator) RequestRide(request ride.RideRequest) (RegionalRide, error) {
+	home := rc.regionAt(request.StartLocation)
+	if home == nil {
+		return RegionalRide{}, fmt.Errorf("%w: no region covers pickup (%d,%d)",
+			ride.ErrInvalidRequest, request.StartLocation.X, request.StartLocation.Y)
+	}
+
+	target := home
+	if !home.Server.HasAvailableTaxi(request.Requirements, request.Pool) {
+		for _, neighbor := range rc.neighborsOf(home, request.StartLocation) {
+			if neighbor.Server.HasAvailableTaxi(request.Requirements, request.Pool) {
+				target = neighbor
+				break
+			}
+		}
+		if target != home {
+			fmt.Printf("[RegionCoordinator] No taxis in %q for client #%d, forwarding to %q\n",
+				home.Zone.Name, request.ClientID, target.Zone.Name)
+		}
+	}
+
+	rideID, err := target.Server.RequestRide(request)
+	if err != nil {
+		return RegionalRide{}, err
+	}
+	return RegionalRide{Region: target.Zone.Name, RideID: rideID}, nil
+}
+
+// GetRide returns a snapshot copy of a ride from the region holding it.
+// Returns an error if the region or ride was not found.
+func (rc *RegionCoordinator) GetRide(ride RegionalRide) (*ride.Ride, error) {
+	region, err := rc.GetRegion(ride.Region)
+	if err != nil {
+		return nil, err
+	}
+	return region.Server.GetRide(ride.RideID)
+}
+
+// Shutdown shuts down every region's Server.
+func (rc *RegionCoordinator) Shutdown() {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	for _, region := range rc.regions {
+		region.Server.Shutdown()
+	}
+}
+
+// regionAt returns the region containing location, or nil if none does.
+func (rc *RegionCoordinator) regionAt(location taxi.Location) *Region {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	for _, region := range rc.regions {
+		if region.Zone.Contains(location) {
+			return region
+		}
+	}
+	return nil
+}
+
+// neighborsOf returns the regions sharing a border with home, closest to pickup first.
+func (rc *RegionCoordinator) neighborsOf(home *Region, pickup taxi.Location) []*Region {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	neighbors := make([]*Region, 0)
+	for _, region := range rc.regions {
+		if region != home && region.Zone.Adjacent(home.Zone) {
+			neighbors = append(neighbors, region)
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].Zone.DistanceTo(pickup) < neighbors[j].Zone.DistanceTo(pickup)
+	})
+	return neighbors
+}
@@ -0,0 +1,134 @@
+// ride_search.go - Ride search
+// Finds rides by status, client, taxi and request time, one page at a time, so
+// admin endpoints and dashboards never have to pull every ride to show a few
+
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// RideFilter selects rides for SearchRides. Zero fields match every ride.
+type RideFilter struct {
+	Statuses      []ride.RideStatus // Rides in any of these states (nil = any state)
+	ClientID      int               // Rides requested by this client (0 = any)
+	TaxiID        int               // Rides assigned to this taxi (0 = any)
+	CreatedAfter  time.Time         // Rides requested at or after this time (zero = no lower bound)
+	CreatedBefore time.Time         // Rides requested before this time (zero = no upper bound)
+	Offset        int               // Matching rides to skip, oldest first
+	Limit         int               // Most rides to return (0 = all the rest)
+}
+
+// RidePage is one page of SearchRides results.
+type RidePage struct {
+	Rides []*ride.Ride // Snapshot copies, oldest first
+	Total int          // Rides matching the filter, across every page
+}
+
+// matches reports whether a ride snapshot passes every condition of the filter.
+func (filter RideFilter) matches(ride *ride.Ride) bool {
+	if filter.ClientID != 0 && ride.ClientID != filter.ClientID {
+		return false
+	}
+	if filter.TaxiID != 0 && ride.TaxiID() != filter.TaxiID {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && ride.CreatedAt.Before(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !ride.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+	if len(filter.Statuses) == 0 {
+		return true
+	}
+	for _, status := range filter.Statuses {
+		if ride.Status() == status {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchRides returns the page of rides matching filter, oldest first, and how many
+// rides match in total.
+// Returns an error for a negative offset or limit.
+func (s *Server) SearchRides(filter RideFilter) (RidePage, error) {
+	if filter.Offset < 0 || filter.Limit < 0 {
+		return RidePage{}, fmt.Errorf("offset and limit must not be negative")
+	}
+
+	page := RidePage{Rides: make([]*ride.Ride, 0)}
+	for _, ride := range s.rideStore.List() {
+		if !filter.matches(ride) {
+			continue
+		}
+		if page.Total >= filter.Offset && (filter.Limit == 0 || len(page.Rides) < filter.Limit) {
+			page.Rides = append(page.Rides, ride)
+		}
+		page.Total++
+	}
+	return page, nil
+}
+
+// parseRideFilter reads a RideFilter from the query of GET /admin/rides:
+// status (comma-separated names), client_id, taxi_id, from and to (RFC 3339), offset and limit.
+func parseRideFilter(query url.Values) (RideFilter, error) {
+	var filter RideFilter
+	if names := query.Get("status"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			status, ok := ride.ParseRideStatus(strings.TrimSpace(name))
+			if !ok {
+				return RideFilter{}, fmt.Errorf("unknown ride status %q", name)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	numbers := []struct {
+		name  string
+		value *int
+	}{
+		{"client_id", &filter.ClientID},
+		{"taxi_id", &filter.TaxiID},
+		{"offset", &filter.Offset},
+		{"limit", &filter.Limit},
+	}
+	for _, number := range numbers {
+		text := query.Get(number.name)
+		if text == "" {
+			continue
+		}
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return RideFilter{}, fmt.Errorf("invalid %s %q", number.name, text)
+		}
+		*number.value = value
+	}
+
+	times := []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &filter.CreatedAfter},
+		{"to", &filter.CreatedBefore},
+	}
+	for _, bound := range times {
+		text := query.Get(bound.name)
+		if text == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return RideFilter{}, fmt.Errorf("invalid %s %q, want RFC 3339 like 2024-01-01T08:00:00Z", bound.name, text)
+		}
+		*bound.value = value
+	}
+	return filter, nil
+}
@@ -0,0 +1,128 @@
+// ride_wait.go - Long polling of ride status
+// Lets clients that cannot keep a WebSocket open follow a ride with one request per
+// status change: the request is answered as soon as the ride's status moves on
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+// Long poll timeouts of GET /rides/{id}/wait. Real time, not simulated time: they bound
+// how long an HTTP request stays open.
+const (
+	defaultRideWaitTimeout = 30 * time.Second
+	maxRideWaitTimeout     = time.Minute
+)
+
+// WaitForRide blocks until a ride's status is no longer since, timeout (real time) has
+// passed or ctx is done, and returns a snapshot of the ride then and whether its status
+// changed. The status may also move back, e.g. to CREATED when the driver declines the
+// offer; to follow a ride, call again with the status returned.
+// It is driven by the ride's events, so waiting costs nothing until the ride changes.
+// Returns an error if the ride is not found (or is archived while waiting), or ctx's
+// error if it is done first.
+func (s *Server) WaitForRide(ctx context.Context, rideID int, since ride.RideStatus, timeout time.Duration) (*ride.Ride, bool, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		ride, changed, lost, err := s.waitForRideEvents(ctx, timer.C, rideID, since)
+		if !lost {
+			return ride, changed, err
+		}
+		// The subscription fell behind and may have missed the ride's event: look again
+	}
+}
+
+// waitForRideEvents is WaitForRide on one event subscription. Returns lost if the event
+// bus cut the subscription off for falling behind, before anything else happened.
+func (s *Server) waitForRideEvents(ctx context.Context, timeout <-chan time.Time, rideID int, since ride.RideStatus) (*ride.Ride, bool, bool, error) {
+	// Subscribe before looking, so a change right after the look is not missed
+	events, unsubscribe := s.events.SubscribeWith(ride.SubscribeOptions{Name: fmt.Sprintf("wait for ride #%d", rideID), Policy: ride.Disconnect})
+	defer unsubscribe()
+
+	ride, err := s.GetRide(rideID)
+	for err == nil && ride.Status() == since {
+		select {
+		case <-ctx.Done():
+			return ride, false, false, ctx.Err()
+		case <-timeout:
+			return ride, false, false, nil
+		case event, ok := <-events:
+			if !ok {
+				return ride, false, true, nil
+			}
+			if event.RideID == rideID {
+				ride, err = s.GetRide(rideID)
+			}
+		}
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	return ride, true, false, nil
+}
+
+// RideWait is the answer of GET /rides/{id}/wait.
+type RideWait struct {
+	Changed bool   `json:"changed"` // False if the wait timed out with the ride still in the status since (always true without since)
+	Ride    RideV1 `json:"ride"`
+}
+
+// handleRideWait serves GET /rides/{id}/wait?since=ASSIGNED&timeout=30s: it answers
+// once the ride's status is no longer since, or after timeout (30s by default, at most
+// a minute) with the ride unchanged. Without since it answers at once. Riders may only
+// wait for their own rides, admins for any.
+func (s *Server) handleRideWait(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.webhookOwner(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	rideID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid ride ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	timeout := defaultRideWaitTimeout
+	if value := query.Get("timeout"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 || parsed > maxRideWaitTimeout {
+			http.Error(w, fmt.Sprintf("timeout must be a duration from 0s to %s, got %q", maxRideWaitTimeout, value), http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	// Someone else's ride answers the same as a missing one, so IDs cannot be probed
+	current, err := s.GetRide(rideID)
+	if err != nil || (owner != 0 && current.ClientID != owner) {
+		http.Error(w, fmt.Sprintf("ride #%d not found", rideID), http.StatusNotFound)
+		return
+	}
+	changed := true
+	if value := query.Get("since"); value != "" {
+		since, ok := ride.ParseRideStatus(value)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown ride status %q", value), http.StatusBadRequest)
+			return
+		}
+		current, changed, err = s.WaitForRide(r.Context(), rideID, since, timeout)
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+			return // The client went away
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	writeJSON(w, RideWait{Changed: changed, Ride: newRideV1(current)})
+}
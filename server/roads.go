@@ -0,0 +1,95 @@
+// roads.go - Road network editor API
+// Lets operators read and edit the road network while the system runs, when the
+// Server routes on a GridRouter
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// ErrRoadsNotEditable is returned by the road network methods of a Server whose Router
+// is not a GridRouter (e.g. the default straight-line LocationService).
+var ErrRoadsNotEditable = errors.New("road network is not editable: the server does not route on a GridRouter")
+
+// roads returns the GridRouter the Server routes on, behind its route cache if it has one.
+func (s *Server) roads() (*taxi.GridRouter, error) {
+	router := s.locationService
+	if cache, ok := router.(*taxi.CachingRouter); ok {
+		router = cache.Unwrap()
+	}
+	grid, ok := router.(*taxi.GridRouter)
+	if !ok {
+		return nil, ErrRoadsNotEditable
+	}
+	return grid, nil
+}
+
+// GetRoadNetwork returns the blocked, one-way and slowed cells of the road network.
+// Returns ErrRoadsNotEditable unless the Server routes on a GridRouter.
+func (s *Server) GetRoadNetwork() (taxi.RoadNetwork, error) {
+	grid, err := s.roads()
+	if err != nil {
+		return taxi.RoadNetwork{}, err
+	}
+	return grid.Network(), nil
+}
+
+// EditRoads changes cells of the road network while the system runs. Every edit is
+// checked first and none is applied if one is invalid; they are then applied in order.
+// Distances and routes calculated from then on follow the new network; cached distances
+// the edits could have changed are dropped (see CachingRouter). Rides already under way
+// keep the route and duration they started with.
+// Returns the network after the edits, ErrRoadsNotEditable unless the Server routes on
+// a GridRouter, or an error describing the first invalid edit.
+func (s *Server) EditRoads(edits []taxi.RoadEdit) (taxi.RoadNetwork, error) {
+	grid, err := s.roads()
+	if err != nil {
+		return taxi.RoadNetwork{}, err
+	}
+	for i, edit := range edits {
+		if err := grid.Validate(edit); err != nil {
+			return taxi.RoadNetwork{}, fmt.Errorf("road edit #%d: %w", i, err)
+		}
+	}
+	for _, edit := range edits {
+		grid.Apply(edit)
+	}
+
+	network := grid.Network()
+	fmt.Printf("[Server] Applied %d road edits, now %s (network version %d)\n", len(edits), grid, network.Version)
+	return network, nil
+}
+
+// handleGetRoads serves GET /admin/roads.
+func (s *Server) handleGetRoads(w http.ResponseWriter, r *http.Request) {
+	network, err := s.GetRoadNetwork()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, network)
+}
+
+// handleEditRoads serves PATCH /admin/roads: a JSON array of RoadEdits, applied all or none.
+func (s *Server) handleEditRoads(w http.ResponseWriter, r *http.Request) {
+	var edits []taxi.RoadEdit
+	if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+		http.Error(w, fmt.Sprintf("parsing road edits: %v", err), http.StatusBadRequest)
+		return
+	}
+	network, err := s.EditRoads(edits)
+	switch {
+	case errors.Is(err, ErrRoadsNotEditable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		writeJSON(w, network)
+	}
+}
@@ -0,0 +1,152 @@
+// roundtrip.go - Round trip bookings
+// Books an outbound ride now and pre-registers the return leg for later
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// RequestRoundTrip books an outbound ride and a linked return ride.
+// The outbound ride is queued immediately like any other request.
+// The return ride (end -> start, same requirements) is created now but only queued once
+// its time window opens at returnAt (in simulated time, see Server.Clock). It is then processed with priority and
+// is offered to the outbound taxi first, falling back to the nearest taxi.
+// Returns both ride IDs, or the error from RequestRide for the outbound leg.
+func (s *Server) RequestRoundTrip(request ride.RideRequest, returnAt time.Time) (int, int, error) {
+	if err := s.authenticate(&request); err != nil {
+		return 0, 0, err
+	}
+	if err := s.resolvePlaces(&request); err != nil {
+		return 0, 0, err
+	}
+	quote, err := s.priceRequest(&request)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := s.clock.Now()
+	recorded := traceRequest(request, now)
+	recorded.ReturnIn = taxi.Duration{Duration: returnAt.Sub(now)}
+	s.record(TraceEntry{Kind: TraceRoundTripRequested, Request: recorded})
+
+	outboundID, err := s.submitRide(request)
+	if err != nil {
+		s.restoreQuotes(quote)
+		return 0, 0, err
+	}
+
+	// Pre-register the return leg so the client can already poll it
+	returnLeg := request
+	returnLeg.StartLocation, returnLeg.EndLocation = request.EndLocation, request.StartLocation
+	returnLeg.Waypoints = slices.Clone(request.Waypoints)
+	slices.Reverse(returnLeg.Waypoints) // Back the way it came
+	returnLeg.ExpiresAt = time.Time{}   // The outbound deadline does not apply to the return
+	returnLeg.TraceID = ride.NewTraceID()
+	returnLeg.QuoteID, returnLeg.QuotedFare = 0, 0 // The quote was for the outbound trip; the tariff is kept
+	inbound := s.rideStore.Add(returnLeg)
+	s.rideStore.Link(outboundID, inbound.ID)
+	s.events.Publish(ride.RideCreated, inbound, 0)
+
+	fmt.Printf("[Server] Round trip for client #%d: ride #%d now, return ride #%d at %s\n",
+		request.ClientID, outboundID, inbound.ID, returnAt.Format(time.TimeOnly))
+
+	// Queue the return leg when its window opens
+	s.clock.AfterFunc(returnAt.Sub(s.clock.Now()), func() {
+		s.openReturnWindow(inbound.ID, outboundID)
+	})
+
+	return outboundID, inbound.ID, nil
+}
+
+// GetRoundTrip returns snapshot copies of both legs of a round trip.
+// Either leg's ID may be given. Returns an error if the ride is not part of a round trip.
+func (s *Server) GetRoundTrip(rideID int) (*ride.Ride, *ride.Ride, error) {
+	ride, err := s.GetRide(rideID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ride.LinkedRideID == 0 {
+		return nil, nil, fmt.Errorf("ride #%d is not part of a round trip", rideID)
+	}
+
+	linked, err := s.GetRide(ride.LinkedRideID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The outbound leg always has the lower ID since it is created first
+	if ride.ID < linked.ID {
+		return ride, linked, nil
+	}
+	return linked, ride, nil
+}
+
+// RoundTripReceipt combines the receipts of both legs of a round trip.
+type RoundTripReceipt struct {
+	Outbound  *ride.Receipt // Receipt for the outbound leg
+	Return    *ride.Receipt // Receipt for the return leg
+	Distance  int           // Total distance of both legs
+	TotalFare int           // Total amount charged for both legs (minor units of Currency)
+	Currency  string        // Both legs are priced with the outbound leg's tariff
+}
+
+// GetRoundTripReceipt returns a combined receipt once both legs have finished.
+// Either leg's ID may be given.
+func (s *Server) GetRoundTripReceipt(rideID int) (*RoundTripReceipt, error) {
+	outbound, inbound, err := s.GetRoundTrip(rideID)
+	if err != nil {
+		return nil, err
+	}
+
+	outboundReceipt, err := s.GetReceipt(outbound.ID)
+	if err != nil {
+		return nil, err
+	}
+	returnReceipt, err := s.GetReceipt(inbound.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RoundTripReceipt{
+		Outbound:  outboundReceipt,
+		Return:    returnReceipt,
+		Distance:  outboundReceipt.Distance + returnReceipt.Distance,
+		TotalFare: outboundReceipt.Fare + returnReceipt.Fare,
+		Currency:  outboundReceipt.Currency,
+	}, nil
+}
+
+// openReturnWindow sends the return leg of a round trip to the scheduler with priority.
+// The taxi that drove the outbound leg is preferred.
+func (s *Server) openReturnWindow(returnID, outboundID int) {
+	s.queueMu.RLock()
+	defer s.queueMu.RUnlock()
+	if s.shutdown {
+		log.Printf("[Server] Dropping return ride #%d, server is shutting down\n", returnID)
+		return
+	}
+
+	inbound := s.rideStore.Snapshot(returnID)
+	outbound := s.rideStore.Snapshot(outboundID)
+	if inbound == nil || outbound == nil {
+		log.Printf("[Server] ERROR: Round trip rides #%d/#%d not found\n", outboundID, returnID)
+		return
+	}
+
+	request := ride.RequestFor(inbound)
+	request.PreferredTaxiID = outbound.TaxiID()
+	request.EnqueuedAt = s.clock.Now()
+	select {
+	case s.priorityRides <- request:
+	case <-s.stopping: // The queue is full and may never drain; Shutdown is waiting for queueMu
+		log.Printf("[Server] Dropping return ride #%d, server is shutting down\n", returnID)
+		return
+	}
+	fmt.Printf("[Server] %sReturn window open for ride #%d (preferred taxi #%d)\n", ride.TraceTag(inbound.TraceID), returnID, outbound.TaxiID())
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// newSlowTestServer is newTestServer with taxis so slow that no ride finishes during a test.
+func newSlowTestServer(t testing.TB) (*Server, *taxi.ManualClock, string) {
+	t.Helper()
+	clock := taxi.NewManualClock(testStart)
+	server := NewServerWithConfig(ServerConfig{Clock: clock, Seed: 1, TaxiSpeed: 0.001})
+	_, token := server.RegisterClient("test rider")
+	return server, clock, token
+}
+
+// advanceUntil steps the clock a second at a time until condition holds,
+// failing the test after an hour of simulated time.
+func advanceUntil(t testing.TB, clock *taxi.ManualClock, what string, condition func() bool) {
+	t.Helper()
+	clock.Settle()
+	for elapsed := time.Duration(0); !condition(); elapsed += time.Second {
+		if elapsed > time.Hour {
+			t.Fatalf("simulated time ran out waiting for %s", what)
+		}
+		clock.Advance(time.Second)
+		clock.Settle()
+	}
+}
+
+func TestDeletedTaxiRideIsReassignedAtOnce(t *testing.T) {
+	server, clock, token := newSlowTestServer(t)
+	first := server.RegisterTaxi(taxi.Location{X: 0, Y: 10}, 0)
+	rideID, err := server.RequestRide(testRide(token, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := server.rideStore.Get(rideID)
+	advanceUntil(t, clock, "the ride to start", func() bool { return r.Status() == ride.IN_PROGRESS })
+
+	second := server.RegisterTaxi(taxi.Location{X: 1, Y: 10}, 0)
+	if err := server.DeleteTaxi(first); err != nil {
+		t.Fatal(err)
+	}
+	// Not left to the taxi change feed: the ride is back in the queue when DeleteTaxi returns
+	if status := r.Status(); status != ride.CREATED {
+		t.Fatalf("ride is %s right after its taxi was deleted, want CREATED", status)
+	}
+	advanceUntil(t, clock, "the ride to be reassigned", func() bool { return r.TaxiID() == second })
+}
+
+// reserveHookStore is a TaxiStorage that calls reserved after each successful ReserveBest.
+type reserveHookStore struct {
+	taxi.TaxiStorage
+	reserved func(taxiID int)
+}
+
+func (store *reserveHookStore) ReserveBest(start taxi.Location, router taxi.Router, maxDistance int, eligible func(taxi.Taxi) bool, score func(taxi.Taxi, int) float64) (taxi.Taxi, int, bool) {
+	found, distance, ok := store.TaxiStorage.ReserveBest(start, router, maxDistance, eligible, score)
+	if ok {
+		store.reserved(found.ID)
+	}
+	return found, distance, ok
+}
+
+func TestTaxiDeletedWhileReservedRideIsReassigned(t *testing.T) {
+	clock := taxi.NewManualClock(testStart)
+	store := &reserveHookStore{TaxiStorage: taxi.NewTaxiStore(taxi.NewSequentialIDGenerator(1), clock)}
+	server := NewServerWithConfig(ServerConfig{Clock: clock, Seed: 1, Taxis: store, TaxiSpeed: 0.001})
+	_, token := server.RegisterClient("test rider")
+
+	// The first taxi is deleted right after it is reserved, before the ride is on it
+	first := server.RegisterTaxi(taxi.Location{X: 0, Y: 10}, 0)
+	store.reserved = func(taxiID int) {
+		if taxiID == first {
+			if err := server.DeleteTaxi(first); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(100 * time.Millisecond) // Let the taxi change feed forget it as well
+		}
+	}
+	rideID, err := server.RequestRide(testRide(token, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := server.rideStore.Get(rideID)
+	advanceUntil(t, clock, "the first taxi to be deleted", func() bool { return server.taxiStore.Count() == 0 })
+
+	second := server.RegisterTaxi(taxi.Location{X: 1, Y: 10}, 0)
+	advanceUntil(t, clock, "the ride to be reassigned", func() bool { return r.TaxiID() == second })
+}
+
+func TestDeletingTaxisAfterShutdownDoesNotHang(t *testing.T) {
+	server, clock, token := newSlowTestServer(t)
+
+	// More rides on taxis than the reassignment queue holds (50)
+	rides := 60
+	taxis := make([]int, 0, rides)
+	for i := 0; i < rides; i++ {
+		taxis = append(taxis, server.RegisterTaxi(taxi.Location{X: i % 50, Y: 10}, 0))
+		if _, err := server.RequestRide(testRide(token, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	advanceUntil(t, clock, "every ride to start", func() bool {
+		return len(server.GetRidesByStatus(ride.IN_PROGRESS)) == rides
+	})
+	server.Shutdown()
+
+	deleted := make(chan struct{})
+	go func() {
+		defer close(deleted)
+		for _, taxiID := range taxis {
+			if err := server.DeleteTaxi(taxiID); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	select {
+	case <-deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("DeleteTaxi hung after Shutdown: nothing drains the reassignment queue")
+	}
+	if waiting := len(server.GetRidesByStatus(ride.CREATED)); waiting != rides {
+		t.Errorf("%d rides back in the queue, want %d", waiting, rides)
+	}
+}
+
+func TestReassigningWhilePausedDoesNotBlock(t *testing.T) {
+	server, clock, token := newSlowTestServer(t)
+	defer server.Shutdown()
+
+	// More rides on the road than the reassignment queue holds
+	const rides = 60
+	taxiIDs := make([]int, 0, rides)
+	rideIDs := make([]int, 0, rides)
+	for n := 0; n < rides; n++ {
+		taxiIDs = append(taxiIDs, server.RegisterTaxi(taxi.Location{X: n % 50, Y: 20 + n/50}, 0))
+		rideID, err := server.RequestRide(testRide(token, n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rideIDs = append(rideIDs, rideID)
+	}
+	onTaxi := func() bool {
+		for _, rideID := range rideIDs {
+			if !isOnTaxi(server.rideStore.Get(rideID)) {
+				return false
+			}
+		}
+		return true
+	}
+	advanceUntil(t, clock, "every ride to get a taxi", onTaxi)
+
+	server.PauseDispatch()
+	deleted := make(chan struct{})
+	go func() {
+		defer close(deleted)
+		for _, taxiID := range taxiIDs {
+			server.DeleteTaxi(taxiID)
+		}
+	}()
+	select {
+	case <-deleted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deleting taxis blocked while dispatch is paused")
+	}
+
+	server.ResumeDispatch()
+	for n := 0; n < rides; n++ {
+		server.RegisterTaxi(taxi.Location{X: n % 50, Y: 30 + n/50}, 0)
+	}
+	advanceUntil(t, clock, "every ride to get a new taxi", onTaxi)
+}
+
+func TestSetZoneDispatchRateAgainMovesTheZone(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Shutdown()
+	server.SetZoneDispatchRate(taxi.Zone{Name: "airport", Min: taxi.Location{X: 0, Y: 0}, Max: taxi.Location{X: 9, Y: 9}}, time.Second)
+	moved := taxi.Zone{Name: "airport", Min: taxi.Location{X: 20, Y: 20}, Max: taxi.Location{X: 29, Y: 29}}
+	server.SetZoneDispatchRate(moved, 2*time.Second)
+
+	if zones := server.scheduler.Zones(); len(zones) != 1 || zones[0] != moved {
+		t.Errorf("zones = %+v, want only %+v", zones, moved)
+	}
+}
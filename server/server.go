@@ -0,0 +1,1141 @@
+// server.go - API gateway
+// Provides a centralized API for all client requests
+
+// Package server wires the fleet, rides and scheduler into a Server, the API other Go
+// programs call and that StartHTTP serves over HTTP:
+//
+//	s := server.NewServerWithConfig(server.ServerConfig{StoreShards: 16})
+//	defer s.Shutdown()
+//	s.RegisterTaxi(taxi.Location{X: 3, Y: 4}, 0)
+//	_, token := s.RegisterClient("Ana Lima")
+//	rideID, err := s.RequestRide(ride.RideRequest{Token: token, StartLocation: taxi.Location{X: 1, Y: 1}, EndLocation: taxi.Location{X: 9, Y: 9}})
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Server is the central API gateway for the taxi scheduling system.
+// All client requests (taxi registration, ride requests) go through the Server.
+type Server struct {
+	taxiManager     *scheduler.TaxiManager          // For taxi CRUD operations
+	rideRequests    chan ride.RideRequest           // Channel for ride requests to scheduler
+	priorityRides   chan ride.RideRequest           // Channel for priority requests (round trip return legs)
+	scheduler       *scheduler.RideScheduler        // For pausing and resuming dispatch
+	assigner        *scheduler.TaxiAssigner         // For assignment limits
+	locationService taxi.Router                     // For distance calculations
+	taxiStore       taxi.TaxiStorage                // For direct store access if needed
+	rideStore       ride.RideStorage                // For ride status queries
+	rideIDs         taxi.IDGenerator                // Ride IDs of the default RideStore, moved past journaled rides on restore
+	detector        *scheduler.AnomalyDetector      // For reviewing flagged rides
+	pricing         *ride.PricingService            // Tariffs, for pricing rides on receipts
+	ledger          *scheduler.Ledger               // For per-taxi utilization and earnings
+	faults          *scheduler.FaultInjector        // For chaos mode
+	events          *ride.EventBus                  // For ride event subscriptions
+	traffic         *scheduler.TrafficService       // For configuring congestion
+	travelTime      scheduler.TravelTimeModel       // How long drives take, for rides and ETAs
+	clients         *ClientManager                  // Registered clients and their API tokens
+	geocoder        Geocoder                        // Resolves named pickups and destinations
+	blacklist       *ride.ClientBlacklist           // Clients not allowed to request rides
+	heatmap         *scheduler.DemandHeatmap        // Recent ride demand by area
+	advisor         *scheduler.RepositioningAdvisor // Moves idle taxis toward demand
+	idlePolicy      *scheduler.IdleRepositioner     // Drives taxis idle too long home or toward demand
+	webhooks        *WebhookDispatcher              // POSTs ride events to registered URLs
+	notifier        *RideNotifier                   // Pushes ride events to clients over WebSockets and the log
+	sla             *SLAMonitor                     // Flags rides that miss the SLA targets
+	shedder         *LoadShedder                    // Turns away low-priority rides while the fleet is saturated
+	rateLimiter     *RateLimiter                    // Caps how fast each caller may use the HTTP API
+	audit           *scheduler.AssignmentAudit      // How each ride's taxi was chosen
+	holds           *scheduler.TaxiHolds            // Taxis kept for particular clients
+	maintenance     *scheduler.ZoneMaintenance      // Zones closed for road works or events
+	quotes          *QuoteStore                     // Fares quoted to riders and not booked yet
+	broadcasts      *DriverBroadcasts               // Operator messages to drivers and their delivery
+	breaks          *scheduler.TaxiBreaks           // Taxis whose drivers asked for or are on a break
+	onboarding      *scheduler.TaxiOnboarding       // Taxis waiting for an admin's approval
+	mu              sync.Mutex                      // Protects validators, config, recorder, exports, archiver and forecaster
+	queueMu         sync.RWMutex                    // Held for reading while queueing a request, for writing by Shutdown
+	shutdown        bool                            // Set by Shutdown under queueMu, so no send ever hits the closed channel
+	stopping        chan struct{}                   // Closed by Shutdown before it takes queueMu, so sends waiting for room in a full queue give up
+	stopOnce        sync.Once                       // Closes stopping once
+	validators      []ride.RideValidator            // Checks run on every ride request, in order
+	clock           taxi.Clock                      // Source of time for the whole system
+	config          RuntimeConfig                   // Last runtime configuration applied (see ApplyConfig)
+	recorder        *SimulationRecorder             // Records inputs and state changes (nil unless EnableRecording)
+	exports         []eventExport                   // Event files written since ExportEvents, flushed by Shutdown
+	archiver        *RideArchiver                   // Moves old rides out of memory (nil unless EnableArchival)
+	forecaster      *scheduler.DemandForecaster     // Predicts demand per zone (nil unless EnableDemandForecast)
+}
+
+// eventExport is an event file written since ExportEvents.
+type eventExport struct {
+	logger *EventLogger
+	events <-chan ride.RideEvent // Its subscription, to count the events handed to it
+}
+
+// ErrShuttingDown is returned by RequestRide once Shutdown has been called.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// ServerConfig holds the pluggable parts of a Server. Zero fields get defaults.
+type ServerConfig struct {
+	Router         taxi.Router               // Distance and route calculations (default: Manhattan LocationService)
+	RouteCacheSize int                       // Cache this many distances in front of Router (0 = no cache)
+	Clock          taxi.Clock                // Source of time for sleeps and timestamps (default: real time)
+	TaxiIDs        taxi.IDGenerator          // Generator for taxi IDs (default: sequential from 1)
+	RideIDs        taxi.IDGenerator          // Generator for ride IDs (default: sequential from 1)
+	Taxis          taxi.TaxiStorage          // Fleet state backend (default: in-memory TaxiStore using TaxiIDs)
+	StoreShards    int                       // Split the default in-memory store into this many ShardedTaxiStore shards (0 or 1 = one TaxiStore)
+	Rides          ride.RideStorage          // Ride state backend (default: in-memory RideStore using RideIDs)
+	Clients        *ClientManager            // Client accounts and tokens (default: a new registry; share one between regions)
+	Geocoder       Geocoder                  // Place names for ride requests (default: StaticGeocoder with the default landmarks)
+	Seed           int64                     // Seed for chaos mode and drive times, so the same run repeats (0 = random)
+	TravelTime     scheduler.TravelTimeModel // How long drives take (default: SpeedModel at TaxiSpeed, with SpeedVariance and the Server's traffic)
+	TaxiSpeed      float64                   // Distance units per simulated second for the default TravelTime (default 10)
+	SpeedVariance  float64                   // Drives take up to this fraction longer or shorter for the default TravelTime, e.g. 0.2 (default 0)
+	Executor       scheduler.RideExecutor    // Carries out started rides (default: ClockExecutor on Clock at RideTimeScale)
+	RideTimeScale  float64                   // Rides take this many times their drive time with the default Executor (default 1)
+}
+
+// NewServer creates and initializes a new Server with all dependencies,
+// using Manhattan distances and real time.
+func NewServer() *Server {
+	return NewServerWithConfig(ServerConfig{})
+}
+
+// subscription returns the channel of a subscription that is never cancelled, for
+// components that run as long as the server.
+func subscription(ch <-chan ride.RideEvent, _ func()) <-chan ride.RideEvent {
+	return ch
+}
+
+// NewServerWithConfig creates a Server using the given configuration.
+func NewServerWithConfig(config ServerConfig) *Server {
+	locationService := config.Router
+	if locationService == nil {
+		locationService = taxi.NewLocationService()
+	}
+	if config.RouteCacheSize > 0 {
+		locationService = taxi.NewCachingRouter(locationService, config.RouteCacheSize)
+	}
+	clock := config.Clock
+	if clock == nil {
+		clock = taxi.NewRealClock()
+	}
+	taxiIDs := config.TaxiIDs
+	if taxiIDs == nil {
+		taxiIDs = taxi.NewSequentialIDGenerator(1)
+	}
+	rideIDs := config.RideIDs
+	if rideIDs == nil {
+		rideIDs = taxi.NewSequentialIDGenerator(1)
+	}
+
+	// Initialize core services
+	taxiStore := config.Taxis
+	if taxiStore == nil && config.StoreShards > 1 {
+		taxiStore = taxi.NewShardedTaxiStore(config.StoreShards, taxiIDs, clock)
+	}
+	if taxiStore == nil {
+		taxiStore = taxi.NewTaxiStore(taxiIDs, clock)
+	}
+	rideStore := config.Rides
+	if rideStore == nil {
+		rideStore = ride.NewRideStore(rideIDs, clock)
+	}
+	detector := scheduler.NewAnomalyDetector(locationService, clock)
+	faults := scheduler.NewFaultInjector(config.Seed)
+	events := ride.NewEventBus(clock)
+	traffic := scheduler.NewTrafficService()
+	maintenance := scheduler.NewZoneMaintenance(clock)
+	traffic.SetZoneMaintenance(maintenance)
+	travelTime := config.TravelTime
+	if travelTime == nil {
+		travelTime = scheduler.NewSpeedModel(config.TaxiSpeed, config.SpeedVariance, traffic, config.Seed)
+	}
+	executor := config.Executor
+	if executor == nil {
+		executor = scheduler.NewClockExecutor(clock, config.RideTimeScale)
+	}
+	clients := config.Clients
+	if clients == nil {
+		clients = NewClientManager(taxi.NewSequentialIDGenerator(1), clock)
+	}
+	geocoder := config.Geocoder
+	if geocoder == nil {
+		geocoder = NewStaticGeocoder(DefaultPlaces)
+	}
+	blacklist := ride.NewClientBlacklist()
+	pricing := ride.NewPricingService()
+	heatmap := scheduler.NewDemandHeatmap(10, 15*time.Minute, clock)
+	advisor := scheduler.NewRepositioningAdvisor(taxiStore, heatmap, locationService, clock)
+	idlePolicy := scheduler.NewIdleRepositioner(taxiStore, heatmap, locationService, travelTime, clock)
+	drivers := taxi.NewDriverStore(taxi.NewSequentialIDGenerator(1))
+	ledger := scheduler.NewLedger(taxiStore, pricing, drivers, clock)
+	taxiManager := scheduler.NewTaxiManager(taxiStore, drivers, detector, faults)
+	audit := scheduler.NewAssignmentAudit()
+	holds := scheduler.NewTaxiHolds(clock)
+	breaks := scheduler.NewTaxiBreaks(taxiStore, clock)
+	onboarding := scheduler.NewTaxiOnboarding(clock)
+	taxiAssigner := scheduler.NewTaxiAssigner(taxiStore, locationService, audit, holds, breaks, onboarding, maintenance, clock)
+	webhooks := NewWebhookDispatcher(rideStore, clients, clock)
+	go webhooks.Run(subscription(events.SubscribeWith(ride.SubscribeOptions{Name: "webhooks", Policy: ride.KeepAll})))
+	notifier := NewRideNotifier(rideStore, clients)
+	go notifier.Run(subscription(events.SubscribeWith(ride.SubscribeOptions{Name: "notifications", Policy: ride.KeepAll})))
+	sla := NewSLAMonitor(rideStore, taxiStore, locationService, travelTime, events, clock)
+	// A lost RideCreated would let its ride wait past the SLA unnoticed
+	go sla.Run(subscription(events.SubscribeWith(ride.SubscribeOptions{Name: "sla", Policy: ride.KeepAll})))
+
+	// Create ride requests channel (buffered to prevent blocking)
+	rideRequests := make(chan ride.RideRequest, 150)
+	priorityRides := make(chan ride.RideRequest, 50)
+
+	// Create and start the ride scheduler
+	rideScheduler := scheduler.NewRideScheduler(rideRequests, priorityRides, taxiAssigner, taxiStore, rideStore, locationService, detector, faults, events, travelTime, ledger, breaks, executor, clock)
+	go rideScheduler.Start()
+	shedder := NewLoadShedder(taxiStore, rideScheduler)
+
+	return &Server{
+		taxiManager:     taxiManager,
+		rideRequests:    rideRequests,
+		priorityRides:   priorityRides,
+		scheduler:       rideScheduler,
+		assigner:        taxiAssigner,
+		locationService: locationService,
+		taxiStore:       taxiStore,
+		rideStore:       rideStore,
+		detector:        detector,
+		pricing:         pricing,
+		rideIDs:         rideIDs,
+		ledger:          ledger,
+		audit:           audit,
+		holds:           holds,
+		maintenance:     maintenance,
+		quotes:          NewQuoteStore(clock),
+		broadcasts:      NewDriverBroadcasts(clock),
+		breaks:          breaks,
+		onboarding:      onboarding,
+		faults:          faults,
+		events:          events,
+		traffic:         traffic,
+		travelTime:      travelTime,
+		clients:         clients,
+		geocoder:        geocoder,
+		blacklist:       blacklist,
+		heatmap:         heatmap,
+		advisor:         advisor,
+		idlePolicy:      idlePolicy,
+		webhooks:        webhooks,
+		notifier:        notifier,
+		sla:             sla,
+		shedder:         shedder,
+		rateLimiter:     NewRateLimiter(),
+		stopping:        make(chan struct{}),
+		clock:           clock,
+		validators: []ride.RideValidator{
+			ride.SameStartEndValidator(),
+			ride.WaypointsValidator(ride.MaxWaypoints),
+			ride.BoundsValidator(taxi.Location{X: 0, Y: 0}, taxi.Location{X: 99, Y: 99}),
+			ride.ExpiryValidator(clock),
+			PriorityValidator(),
+			blacklist.Validator(),
+		},
+	}
+}
+
+// RegisterTaxi registers a new taxi at the given location with the given attributes.
+// While approval is required (see RequireTaxiApproval) the taxi is unavailable and gets
+// no rides until ApproveTaxi.
+// Returns the new taxi's ID.
+func (s *Server) RegisterTaxi(location taxi.Location, attributes taxi.TaxiAttributes) int {
+	id := s.taxiManager.CreateTaxi(location, attributes)
+	s.record(TraceEntry{Kind: TraceTaxiRegistered, TaxiID: id, Location: &location, Attributes: attributes})
+	if s.onboarding.Apply(id) {
+		s.taxiStore.SetAvailability(id, false)
+		fmt.Printf("[Server] Taxi #%d is pending approval\n", id)
+	}
+	return id
+}
+
+// GetTaxi returns a copy of a registered taxi.
+// Returns an error if the taxi was not found.
+func (s *Server) GetTaxi(taxiID int) (taxi.Taxi, error) {
+	t, exists := s.taxiManager.GetTaxi(taxiID)
+	if !exists {
+		return taxi.Taxi{}, fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	return t, nil
+}
+
+// UpdateTaxiLocation records a location heartbeat from a taxi.
+// Returns an error if the taxi was not found.
+func (s *Server) UpdateTaxiLocation(taxiID int, location taxi.Location) error {
+	s.record(TraceEntry{Kind: TraceTaxiMoved, TaxiID: taxiID, Location: &location})
+	return s.taxiManager.UpdateTaxiLocation(taxiID, location)
+}
+
+// RegisterDriver adds a driver profile; assign it to a taxi with AssignDriver.
+// Returns the new driver's ID, or an error if the name or license is missing.
+func (s *Server) RegisterDriver(name, license, phone string) (int, error) {
+	return s.taxiManager.CreateDriver(name, license, phone)
+}
+
+// GetDriver returns a copy of a driver's profile.
+// Returns an error if the driver was not found.
+func (s *Server) GetDriver(driverID int) (taxi.Driver, error) {
+	driver, exists := s.taxiManager.GetDriver(driverID)
+	if !exists {
+		return taxi.Driver{}, fmt.Errorf("driver #%d not found", driverID)
+	}
+	return driver, nil
+}
+
+// GetDrivers returns copies of every driver profile, ordered by ID.
+func (s *Server) GetDrivers() []taxi.Driver {
+	return s.taxiManager.GetDrivers()
+}
+
+// UpdateDriver changes a driver's name, license and phone number.
+// Returns an error if the name or license is missing or the driver was not found.
+func (s *Server) UpdateDriver(driverID int, name, license, phone string) error {
+	return s.taxiManager.UpdateDriver(driverID, name, license, phone)
+}
+
+// AssignDriver puts a driver in a taxi (taxiID 0 takes them out of it).
+// Returns an error if either was not found or the taxi already has another driver.
+func (s *Server) AssignDriver(driverID, taxiID int) error {
+	return s.taxiManager.AssignDriver(driverID, taxiID)
+}
+
+// DeleteDriver removes a driver profile.
+// Returns an error if the driver was not found.
+func (s *Server) DeleteDriver(driverID int) error {
+	return s.taxiManager.DeleteDriver(driverID)
+}
+
+// RegisterClient creates a rider account and returns its ID and the API token
+// to put in RideRequest.Token.
+func (s *Server) RegisterClient(name string) (int, string) {
+	return s.clients.Register(name, RoleRider, 0)
+}
+
+// RegisterAdmin creates an admin account and returns its ID and API token,
+// which unlocks the operator endpoints of the HTTP API.
+func (s *Server) RegisterAdmin(name string) (int, string) {
+	return s.clients.Register(name, RoleAdmin, 0)
+}
+
+// RegisterDriverAccount creates an account for a driver profile and returns its ID
+// and API token, with which the driver answers ride offers for their taxi.
+// Returns an error if the driver was not found.
+func (s *Server) RegisterDriverAccount(driverID int) (int, string, error) {
+	driver, exists := s.taxiManager.GetDriver(driverID)
+	if !exists {
+		return 0, "", fmt.Errorf("driver #%d not found", driverID)
+	}
+	id, token := s.clients.Register(driver.Name, RoleDriver, driverID)
+	return id, token, nil
+}
+
+// RemoveClient deletes a client account; its token stops working.
+// Returns an error if the client was not found.
+func (s *Server) RemoveClient(clientID int) error {
+	if !s.clients.Remove(clientID) {
+		return fmt.Errorf("client #%d not found", clientID)
+	}
+	return nil
+}
+
+// RequestRide submits a ride request to the system.
+// request.Token must belong to a registered rider (see RegisterClient); the ride is
+// booked for that client, whatever request.ClientID says.
+// A request.StartPlace or EndPlace is looked up among the client's favorites (see
+// SetFavorite), then with the Server's Geocoder, and replaces the matching location.
+// The ride is created immediately, then queued and processed by the RideScheduler.
+// Only a taxi with all of request.Requirements, in request.Pool, will be assigned.
+// If request.ExpiresAt is set and no taxi is assigned by then, the ride becomes EXPIRED
+// and a RideExpired event is published.
+// The request must pass every validator first (see AddValidator).
+// A PriorityHigh request is queued ahead of normal ones. A PriorityLow one is rejected or
+// deferred while the fleet is saturated (see SetLoadShedding).
+// The request gets a new trace ID unless request.TraceID is already set (e.g. by an upstream service).
+// Returns the new ride's ID, ErrUnauthorized, an error wrapping ErrForbidden,
+// ErrShuttingDown, an error wrapping ErrInvalidRequest, or an *OverloadError.
+func (s *Server) RequestRide(request ride.RideRequest) (int, error) {
+	if err := s.authenticate(&request); err != nil {
+		return 0, err
+	}
+	if err := s.resolvePlaces(&request); err != nil {
+		return 0, err
+	}
+	quote, err := s.priceRequest(&request)
+	if err != nil {
+		return 0, err
+	}
+	s.record(TraceEntry{Kind: TraceRideRequested, Request: traceRequest(request, s.clock.Now())})
+	id, err := s.submitRide(request)
+	if err != nil {
+		s.restoreQuotes(quote)
+	}
+	return id, err
+}
+
+// authenticate sets request.ClientID to the rider its token belongs to.
+// Returns ErrUnauthorized if the token is missing or unknown, and an error wrapping
+// ErrForbidden if it belongs to a driver or admin account.
+func (s *Server) authenticate(request *ride.RideRequest) error {
+	client, err := s.clients.Authorize(request.Token, RoleRider)
+	if err != nil {
+		fmt.Printf("[Server] Rejecting ride request: %v\n", err)
+		return err
+	}
+	request.ClientID = client.ID
+	return nil
+}
+
+// resolvePlaces sets the locations of a request that names its pickup or destination,
+// trying the favorites of the request's client before the Server's Geocoder.
+// Returns an error wrapping ErrInvalidRequest if a place cannot be found.
+func (s *Server) resolvePlaces(request *ride.RideRequest) error {
+	if request.StartPlace == "" && request.EndPlace == "" {
+		return nil
+	}
+	geocoder := s.geocoder
+	if client, ok := s.clients.Get(request.ClientID); ok && len(client.Favorites) > 0 {
+		geocoder = GeocoderChain{client, s.geocoder}
+	}
+	var err error
+	if request.StartPlace != "" {
+		request.StartLocation, err = geocoder.Geocode(request.StartPlace)
+	}
+	if err == nil && request.EndPlace != "" {
+		request.EndLocation, err = geocoder.Geocode(request.EndPlace)
+	}
+	if err != nil {
+		fmt.Printf("[Server] Rejecting ride request from client #%d: %v\n", request.ClientID, err)
+		return fmt.Errorf("%w: %v", ride.ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// submitRide validates, creates and queues a ride request (see RequestRide).
+func (s *Server) submitRide(request ride.RideRequest) (int, error) {
+	if request.TraceID == "" {
+		request.TraceID = ride.NewTraceID()
+	}
+
+	// Checking the flag and sending must happen under the same lock that Shutdown
+	// takes to close the channel; otherwise Shutdown could slip in between and the send would panic
+	s.queueMu.RLock()
+	defer s.queueMu.RUnlock()
+	if s.shutdown {
+		log.Printf("[Server] %sRejecting ride request from client #%d, server is shutting down\n", ride.TraceTag(request.TraceID), request.ClientID)
+		return 0, ErrShuttingDown
+	}
+
+	if err := s.validate(request); err != nil {
+		return 0, err
+	}
+	every, err := s.shedder.Admit(request)
+	switch {
+	case err != nil && every > 0:
+		return s.deferRide(request, every, err), nil
+	case err != nil:
+		fmt.Printf("[Server] %sRejecting ride request from client #%d: %v\n", ride.TraceTag(request.TraceID), request.ClientID, err)
+		return 0, err
+	}
+	return s.enqueueRide(request)
+}
+
+// validate runs the validator chain on a request and returns the first error.
+func (s *Server) validate(request ride.RideRequest) error {
+	s.mu.Lock()
+	validators := s.validators
+	s.mu.Unlock()
+
+	for _, validate := range validators {
+		if err := validate(request); err != nil {
+			fmt.Printf("[Server] %sRejecting ride request from client #%d: %v\n", ride.TraceTag(request.TraceID), request.ClientID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueRide creates the ride of a validated request and queues it for the scheduler.
+// Returns the new ride's ID, or ErrShuttingDown if Shutdown started while the queue was
+// full; the ride is then FAILED. Must be called with queueMu held and s.shutdown unset.
+func (s *Server) enqueueRide(request ride.RideRequest) (int, error) {
+	_, request = s.createRide(request)
+	if err := s.queueRide(request); err != nil {
+		s.failUnqueued(request.RideID)
+		return 0, err
+	}
+	return request.RideID, nil
+}
+
+// failUnqueued marks rides that never reached the queue FAILED, so none of them waits
+// for a taxi for good. Rides the dispatcher has taken meanwhile are left alone.
+func (s *Server) failUnqueued(rideIDs ...int) {
+	for _, rideID := range rideIDs {
+		r := s.rideStore.Get(rideID)
+		if r == nil || !r.SetStatus(ride.FAILED, s.clock.Now(), ride.CREATED) {
+			continue
+		}
+		s.events.Publish(ride.RideFailed, r, 0)
+		log.Printf("[Server] %sRide #%d FAILED, server shut down before it was queued\n", ride.TraceTag(r.TraceID), rideID)
+	}
+}
+
+// createRide adds the ride of a validated request to the store and starts its deadline.
+// Returns the ride and the request with RideID set.
+func (s *Server) createRide(request ride.RideRequest) (*ride.Ride, ride.RideRequest) {
+	r := s.rideStore.Add(request)
+	s.events.Publish(ride.RideCreated, r, 0)
+	s.scheduler.WatchExpiry(r)
+	s.heatmap.Record(request.StartLocation)
+	s.recordDemand(request.StartLocation)
+	request.RideID = r.ID
+	return r, request
+}
+
+// queueRide sends the request of a created ride to the scheduler, high priority ones
+// ahead of the rest. While the queue is full it waits for room, unless Shutdown starts:
+// then it gives up and returns ErrShuttingDown, so Shutdown never waits on a queue that
+// may not drain (e.g. while dispatch is paused).
+// Must be called with queueMu held and s.shutdown unset.
+func (s *Server) queueRide(request ride.RideRequest) error {
+	request.EnqueuedAt = s.clock.Now()
+	queue := s.rideRequests
+	if request.Priority == ride.PriorityHigh {
+		queue = s.priorityRides
+	}
+	select {
+	case queue <- request:
+	case <-s.stopping:
+		log.Printf("[Server] %sGave up queueing ride #%d, server is shutting down\n", ride.TraceTag(request.TraceID), request.RideID)
+		return ErrShuttingDown
+	}
+	fmt.Printf("[Server] %sReceived ride request #%d from client #%d: (%d,%d) -> (%d,%d)%s\n",
+		ride.TraceTag(request.TraceID), request.RideID, request.ClientID,
+		request.StartLocation.X, request.StartLocation.Y,
+		request.EndLocation.X, request.EndLocation.Y, ride.ViaTag(request.Waypoints))
+	return nil
+}
+
+// AddValidator appends a check to the chain run on every ride request.
+// For example: s.AddValidator(MinDistanceValidator(router, 5)).
+func (s *Server) AddValidator(validator ride.RideValidator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.validators = append(s.validators, validator)
+}
+
+// BlacklistClient stops a client from requesting rides.
+func (s *Server) BlacklistClient(clientID int) {
+	s.blacklist.Add(clientID)
+	fmt.Printf("[Server] Client #%d blacklisted\n", clientID)
+}
+
+// UnblacklistClient allows a blacklisted client to request rides again.
+func (s *Server) UnblacklistClient(clientID int) {
+	s.blacklist.Remove(clientID)
+	fmt.Printf("[Server] Client #%d removed from blacklist\n", clientID)
+}
+
+// GetRideStatus returns the current lifecycle state of a ride.
+// Returns an error if the ride was not found.
+func (s *Server) GetRideStatus(rideID int) (ride.RideStatus, error) {
+	r := s.rideStore.Snapshot(rideID)
+	if r == nil {
+		return ride.UNKNOWN, fmt.Errorf("ride #%d not found", rideID)
+	}
+	return r.Status(), nil
+}
+
+// GetRide returns a snapshot copy of a ride.
+// The copy does not change as the ride progresses; call again to poll.
+// Returns an error if the ride was not found.
+func (s *Server) GetRide(rideID int) (*ride.Ride, error) {
+	ride := s.rideStore.Snapshot(rideID)
+	if ride == nil {
+		return nil, fmt.Errorf("ride #%d not found", rideID)
+	}
+	return ride, nil
+}
+
+// GetRideDriver returns the driver of the taxi serving a ride, so clients can see
+// who is picking them up. This is the taxi's current driver, looked up on every call.
+// Returns an error if the ride was not found, has no taxi yet, or its taxi has no driver.
+func (s *Server) GetRideDriver(rideID int) (taxi.Driver, error) {
+	ride, err := s.GetRide(rideID)
+	if err != nil {
+		return taxi.Driver{}, err
+	}
+	if ride.TaxiID() == 0 {
+		return taxi.Driver{}, fmt.Errorf("ride #%d is %s, no taxi assigned yet", rideID, ride.Status())
+	}
+	driver, exists := s.taxiManager.GetTaxiDriver(ride.TaxiID())
+	if !exists {
+		return taxi.Driver{}, fmt.Errorf("taxi #%d of ride #%d has no driver", ride.TaxiID(), rideID)
+	}
+	return driver, nil
+}
+
+// EstimateTrip quotes the time and fare of a general fleet ride from start to end,
+// through any waypoints in order, starting now, using the same travel time model as
+// the simulated rides and the same tariff as receipts.
+func (s *Server) EstimateTrip(start, end taxi.Location, waypoints ...taxi.Location) ride.TripEstimate {
+	return s.estimateTrip(s.pricing.TariffFor("", start), start, end, waypoints)
+}
+
+// estimateTrip quotes a trip as EstimateTrip does, priced with tariff.
+func (s *Server) estimateTrip(tariff ride.Tariff, start, end taxi.Location, waypoints []taxi.Location) ride.TripEstimate {
+	now := s.clock.Now()
+	distance := taxi.TripDistance(s.locationService, taxi.TripStops(start, waypoints, end))
+	duration := s.travelTime.Estimate(distance, start, now)
+	return ride.TripEstimate{
+		Distance: distance,
+		Duration: duration,
+		Fare:     tariff.Fare(distance, duration, now),
+		Currency: tariff.Currency,
+	}
+}
+
+// GetRideETA returns when a ride with a taxi is expected to reach its destination.
+// Returns an error if the ride was not found or no taxi is on the way.
+func (s *Server) GetRideETA(rideID int) (time.Time, error) {
+	r, err := s.GetRide(rideID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch r.Status() {
+	case ride.IN_PROGRESS:
+		if at, ok := s.scheduler.ExpectedDropOff(r.ID, r.TaxiID()); ok {
+			return at, nil
+		}
+	case ride.ASSIGNED, ride.ACCEPTED:
+		// Not started yet: the whole drive, from where the taxi waits, is still ahead
+		if t, exists := s.taxiStore.Get(r.TaxiID()); exists {
+			now := s.clock.Now()
+			distance := taxi.RoutedDistance(s.locationService, t.Location, r.StartLocation) +
+				taxi.TripDistance(s.locationService, r.Stops())
+			return now.Add(s.travelTime.Estimate(distance, r.StartLocation, now)), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ride #%d is %s, no taxi on the way", rideID, r.Status())
+}
+
+// GetReceipt returns the receipt for a finished ride, or the no-show fee of a NO_SHOW one.
+// Returns an error if the ride was not found or has not finished yet.
+func (s *Server) GetReceipt(rideID int) (*ride.Receipt, error) {
+	r, err := s.GetRide(rideID)
+	if err != nil {
+		return nil, err
+	}
+	if status := r.Status(); status != ride.FINISHED && status != ride.NO_SHOW {
+		return nil, fmt.Errorf("ride #%d is %s, receipt is available once FINISHED", rideID, status)
+	}
+	return ride.NewReceipt(r, s.locationService, s.pricing), nil
+}
+
+// EnableChaos turns on fault injection with the given probabilities.
+// Pass a zero FaultConfig to turn it off again.
+func (s *Server) EnableChaos(config scheduler.FaultConfig) {
+	s.faults.Configure(config)
+	fmt.Printf("[Server] Chaos mode: %+v\n", config)
+}
+
+// Clock returns the clock shared by every component, so clients can sleep in simulated time.
+func (s *Server) Clock() taxi.Clock {
+	return s.clock
+}
+
+// Traffic returns the traffic model so rush hours and congested zones can be configured.
+func (s *Server) Traffic() *scheduler.TrafficService {
+	return s.traffic
+}
+
+// SubscribeRideEvents returns a channel that receives ride lifecycle events.
+func (s *Server) SubscribeRideEvents() <-chan ride.RideEvent {
+	return s.events.Subscribe()
+}
+
+// SubscribeRideEventsWith returns a channel that receives ride lifecycle events with
+// its own buffer size and slow-subscriber policy, and a function that unsubscribes.
+// Its counters show up in Metrics.EventBus under options.Name.
+func (s *Server) SubscribeRideEventsWith(options ride.SubscribeOptions) (<-chan ride.RideEvent, func()) {
+	return s.events.SubscribeWith(options)
+}
+
+// PauseDispatch stops the scheduler from taking new ride requests off the queue.
+// In-flight rides still finish, and new requests are still accepted and queued.
+func (s *Server) PauseDispatch() {
+	s.scheduler.Pause()
+}
+
+// ResumeDispatch lets the scheduler process queued ride requests again.
+func (s *Server) ResumeDispatch() {
+	s.scheduler.Resume()
+}
+
+// GetPendingRides returns snapshot copies of every ride still waiting for a taxi, oldest first.
+// This includes rides in the queue, rides no taxi could take yet, and round trip
+// return legs whose window has not opened.
+func (s *Server) GetPendingRides() []*ride.Ride {
+	return s.rideStore.ListByStatus(ride.CREATED)
+}
+
+// AssignRideToTaxi lets an operator force a waiting ride onto a specific taxi,
+// bypassing the taxi scoring. The taxi must be available and meet the
+// ride's requirements. Returns an error if the assignment is not possible.
+func (s *Server) AssignRideToTaxi(rideID, taxiID int) error {
+	if err := s.scheduler.AssignManually(rideID, taxiID); err != nil {
+		fmt.Printf("[Server] Manual assignment of ride #%d to taxi #%d failed: %v\n", rideID, taxiID, err)
+		return err
+	}
+	fmt.Printf("[Server] Ride #%d manually assigned to taxi #%d\n", rideID, taxiID)
+	return nil
+}
+
+// GetDeadLetterRides returns the rides the dispatcher gave up on, because they expired
+// or ran out of attempts (see SetMaxDispatchAttempts), ordered by ride ID.
+// They stay there until requeued or assigned by hand.
+func (s *Server) GetDeadLetterRides() []scheduler.DeadLetter {
+	return s.scheduler.DeadLetters()
+}
+
+// RequeueDeadLetterRide sends a dead-lettered ride back to the dispatcher, without its
+// deadline and with a fresh count of attempts.
+// Returns an error if the ride is not in the dead-letter queue.
+func (s *Server) RequeueDeadLetterRide(rideID int) error {
+	if err := s.scheduler.Requeue(rideID); err != nil {
+		fmt.Printf("[Server] Requeue of ride #%d failed: %v\n", rideID, err)
+		return err
+	}
+	return nil
+}
+
+// SetMaxDispatchAttempts moves a ride to the dead-letter queue once the dispatcher has
+// found no eligible taxi for it this many times (0 = keep retrying forever).
+func (s *Server) SetMaxDispatchAttempts(attempts int) {
+	s.scheduler.SetMaxAttempts(attempts)
+	fmt.Printf("[Server] Max dispatch attempts: %d\n", attempts)
+}
+
+// SetTaxiMaintenance takes a taxi out of dispatch without deleting it (on),
+// or puts it back into service (off). A taxi that is mid-ride finishes the ride first.
+// Returns an error if the taxi was not found.
+func (s *Server) SetTaxiMaintenance(taxiID int, on bool) error {
+	if !s.taxiStore.SetMaintenance(taxiID, on) {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	if on {
+		s.record(TraceEntry{Kind: TraceMaintenanceStarted, TaxiID: taxiID})
+	} else {
+		s.record(TraceEntry{Kind: TraceMaintenanceEnded, TaxiID: taxiID})
+	}
+	if on {
+		fmt.Printf("[Server] Taxi #%d is in maintenance\n", taxiID)
+	} else {
+		fmt.Printf("[Server] Taxi #%d is back in service\n", taxiID)
+	}
+	return nil
+}
+
+// SetTaxiPool reserves a taxi for rides requested in a dispatch pool (e.g. "airport"),
+// or returns it to the general fleet with "". Pool taxis only serve rides of their pool.
+// Returns an error if the taxi was not found.
+func (s *Server) SetTaxiPool(taxiID int, pool string) error {
+	if !s.taxiStore.SetPool(taxiID, pool) {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	s.record(TraceEntry{Kind: TraceTaxiPoolChanged, TaxiID: taxiID, Pool: pool})
+	if pool == "" {
+		fmt.Printf("[Server] Taxi #%d is back in the general fleet\n", taxiID)
+	} else {
+		fmt.Printf("[Server] Taxi #%d is in the %q pool\n", taxiID, pool)
+	}
+	return nil
+}
+
+// SetTaxiRating records a taxi's driver rating, used when scoring taxis for rides.
+// Returns an error if the rating is outside 1 to 5 stars or the taxi was not found.
+func (s *Server) SetTaxiRating(taxiID int, rating float64) error {
+	if rating < taxi.MinTaxiRating || rating > taxi.MaxTaxiRating {
+		return fmt.Errorf("rating %.1f is outside %v to %v stars", rating, taxi.MinTaxiRating, taxi.MaxTaxiRating)
+	}
+	if !s.taxiStore.SetRating(taxiID, rating) {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	return nil
+}
+
+// SetTaxiEnergyLevel records how much fuel or charge a taxi has left (0 to 100 percent),
+// used when scoring taxis for rides.
+// Returns an error if the level is out of range or the taxi was not found.
+func (s *Server) SetTaxiEnergyLevel(taxiID, level int) error {
+	if level < 0 || level > 100 {
+		return fmt.Errorf("energy level %d%% is outside 0 to 100", level)
+	}
+	if !s.taxiStore.SetEnergyLevel(taxiID, level) {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	return nil
+}
+
+// SetScoringWeights changes how taxis are ranked when assigning rides (see ScoringWeights).
+func (s *Server) SetScoringWeights(weights taxi.ScoringWeights) {
+	s.assigner.SetScoringWeights(weights)
+	fmt.Printf("[Server] Scoring weights: distance %.2f, idle %.2f/min, rating %.2f/star, energy %.2f/%%\n",
+		weights.Distance, weights.IdleTime, weights.Rating, weights.Energy)
+}
+
+// SetZoneDispatchRate lets rides starting in zone be dispatched once every interval,
+// independently of the default 3 second pace used everywhere else.
+// Calling it again for a zone with the same name changes its pace and bounds.
+func (s *Server) SetZoneDispatchRate(zone taxi.Zone, interval time.Duration) {
+	s.scheduler.SetZoneRate(zone, interval)
+}
+
+// EnableAdaptiveDispatch lets a dispatch lane with more than rate.Threshold requests
+// waiting speed up, down to rate.MinInterval between rides, and slow back down to its
+// configured pace once the backlog clears. Pass a zero AdaptiveRate to turn it off.
+func (s *Server) EnableAdaptiveDispatch(rate scheduler.AdaptiveRate) {
+	s.scheduler.SetAdaptiveRate(rate)
+	if rate.Threshold <= 0 {
+		fmt.Println("[Server] Adaptive dispatch: off")
+		return
+	}
+	minInterval := rate.MinInterval.Duration
+	if minInterval <= 0 {
+		minInterval = scheduler.DefaultMinDispatchInterval
+	}
+	fmt.Printf("[Server] Adaptive dispatch: speed up above %d waiting, min interval %v\n", rate.Threshold, minInterval)
+}
+
+// SetMaxPickupDistance stops taxis farther than distance from being sent to a pickup
+// (0 = no limit). Rides with no taxi in range stay pending and are retried as taxis free up.
+func (s *Server) SetMaxPickupDistance(distance int) {
+	s.assigner.SetMaxPickupDistance(distance)
+	fmt.Printf("[Server] Max pickup distance: %d\n", distance)
+}
+
+// EnableLookAhead lets the dispatcher hold a new ride for a busy taxi that will finish
+// its current ride within window (simulated time) closer to the pickup than any available
+// taxi. The ride starts as soon as that taxi is done. Pass 0 to turn it off again.
+func (s *Server) EnableLookAhead(window time.Duration) {
+	s.scheduler.SetLookAhead(window)
+	fmt.Printf("[Server] Look-ahead pre-assignment window: %v\n", window)
+}
+
+// EnableBatching makes every dispatch lane collect ride requests for window (simulated
+// time, e.g. 500ms) and match each batch to the available taxis together, minimizing
+// the total pickup distance over the batch (Hungarian algorithm), rather than giving each
+// ride the best-scoring taxi as it arrives. This can make the whole fleet more efficient,
+// at the cost of up to window more wait per ride. Pass 0 to turn it off again.
+func (s *Server) EnableBatching(window time.Duration) {
+	s.scheduler.SetBatchWindow(window)
+	fmt.Printf("[Server] Assignment batching window: %v\n", window)
+}
+
+// SetBatchMatching chooses how EnableBatching pairs each batch with taxis: OptimalMatching
+// (the default, least total pickup distance) or GreedyMatching (each ride in turn takes
+// the closest taxi left), e.g. to measure what the optimal matching gains.
+// Returns an error for an unknown matching.
+func (s *Server) SetBatchMatching(matching scheduler.BatchMatching) error {
+	matching, err := scheduler.ParseBatchMatching(string(matching))
+	if err != nil {
+		return err
+	}
+	s.assigner.SetBatchMatching(matching)
+	fmt.Printf("[Server] Batch matching: %s\n", matching)
+	return nil
+}
+
+// RequireConfirmation makes drivers confirm every assignment within timeout
+// (simulated time) via AcceptRide/DeclineRide. Pass 0 to turn it off again.
+// Offers are announced as RideOffered events (see SubscribeRideEvents).
+func (s *Server) RequireConfirmation(timeout time.Duration) {
+	s.scheduler.SetConfirmationTimeout(timeout)
+	fmt.Printf("[Server] Driver confirmation timeout: %v\n", timeout)
+}
+
+// DeleteTaxi removes a taxi from the fleet. A ride it was driving goes back to the queue.
+// Returns an error if the taxi was not found.
+func (s *Server) DeleteTaxi(taxiID int) error {
+	if err := s.taxiManager.DeleteTaxi(taxiID); err != nil {
+		return err
+	}
+	s.scheduler.ForgetTaxi(taxiID)
+	s.onboarding.Forget(taxiID)
+	return nil
+}
+
+// AcceptRide is called by a driver to accept a ride offered to their taxi.
+func (s *Server) AcceptRide(taxiID, rideID int) error {
+	return s.scheduler.RespondToOffer(rideID, taxiID, true)
+}
+
+// DeclineRide is called by a driver to turn down a ride offered to their taxi.
+// The ride is reassigned to another taxi.
+func (s *Server) DeclineRide(taxiID, rideID int) error {
+	return s.scheduler.RespondToOffer(rideID, taxiID, false)
+}
+
+// ExportEvents appends every ride event from now on to the file at path.
+// Files ending in ".csv" get CSV, anything else gets JSON Lines.
+// Shutdown waits until every event published before it is on disk.
+func (s *Server) ExportEvents(path string) error {
+	logger, err := NewEventLogger(path)
+	if err != nil {
+		return err
+	}
+	// Offline analysis needs every event, however far behind the file is
+	events := subscription(s.events.SubscribeWith(ride.SubscribeOptions{Name: "event export", Policy: ride.KeepAll}))
+	go logger.Run(events)
+
+	s.mu.Lock()
+	s.exports = append(s.exports, eventExport{logger: logger, events: events})
+	s.mu.Unlock()
+	fmt.Printf("[Server] Exporting ride events to %s\n", path)
+	return nil
+}
+
+// EnableRecording writes a trace of every input (taxi registrations and moves, maintenance,
+// ride requests) and every ride and taxi state change from now on to the file at path,
+// replacing it. The trace can be replayed with TraceReplayer.
+func (s *Server) EnableRecording(path string) error {
+	recorder, err := NewSimulationRecorder(path, s.clock)
+	if err != nil {
+		return err
+	}
+	go recorder.Run(subscription(s.events.SubscribeWith(ride.SubscribeOptions{Name: "recorder"})), s.taxiStore.Subscribe())
+
+	s.mu.Lock()
+	s.recorder = recorder
+	s.mu.Unlock()
+	fmt.Printf("[Server] Recording simulation trace to %s\n", path)
+	return nil
+}
+
+// record adds an input entry to the simulation trace, if recording is enabled.
+func (s *Server) record(entry TraceEntry) {
+	s.mu.Lock()
+	recorder := s.recorder
+	s.mu.Unlock()
+
+	if recorder != nil {
+		recorder.Record(entry)
+	}
+}
+
+// EnableJournal replays the ride journal at path into the RideStore, then appends
+// every ride event from now on to it, so rides survive a restart.
+// Restored rides keep the status they had; CREATED ones are listed by GetPendingRides
+// but are not queued again. Rides that had a taxi are resumed if the taxi is still
+// reserved for them (as with a fleet in Redis), or else FAILED (see RideScheduler.RecoverRide).
+// Must be called before any ride is requested.
+func (s *Server) EnableJournal(path string) error {
+	if s.rideStore.Count() > 0 {
+		return errors.New("journal must be enabled before any rides are requested")
+	}
+
+	entries, err := ReadJournal(path)
+	if err != nil {
+		return err
+	}
+	restored := ReplayJournal(entries, time.Time{})
+	s.rideStore.Restore(restored)
+	// Archived rides are not restored, but their IDs must not be handed out again
+	if sequential, ok := s.rideIDs.(*taxi.SequentialIDGenerator); ok {
+		for _, entry := range entries {
+			sequential.Advance(entry.RideID)
+		}
+	}
+
+	journal, err := NewRideJournal(path, s.rideStore)
+	if err != nil {
+		return err
+	}
+	// A lost event would make the next replay restore the wrong rides
+	go journal.Run(subscription(s.events.SubscribeWith(ride.SubscribeOptions{Name: "journal", Policy: ride.KeepAll})))
+	fmt.Printf("[Server] Journaling rides to %s (%d rides restored from %d events)\n", path, len(restored), len(entries))
+
+	// After the journal subscribed, so the outcome of every recovery is journaled too
+	if resumed, failed := s.recoverRides(); resumed+failed > 0 {
+		fmt.Printf("[Server] Recovered rides in flight: %d resumed, %d failed\n", resumed, failed)
+	}
+	return nil
+}
+
+// GetTaxiLedger returns a taxi's utilization and earnings totals.
+// Returns an error if the taxi has never been registered.
+func (s *Server) GetTaxiLedger(taxiID int) (scheduler.TaxiLedger, error) {
+	entry, exists := s.ledger.Get(taxiID)
+	if !exists {
+		return scheduler.TaxiLedger{}, fmt.Errorf("taxi #%d not found in ledger", taxiID)
+	}
+	return entry, nil
+}
+
+// GetTopEarners returns up to n taxis with the highest earnings, highest first.
+func (s *Server) GetTopEarners(n int) []scheduler.TaxiLedger {
+	return s.ledger.TopEarners(n)
+}
+
+// GetDemandHotspots returns up to n areas with the most ride requests
+// in the last 15 minutes (simulated time), busiest first.
+func (s *Server) GetDemandHotspots(n int) []scheduler.Hotspot {
+	return s.heatmap.Hotspots(n)
+}
+
+// GetRepositioningSuggestions returns moves that would bring idle taxis closer to demand.
+// Nothing is moved; see EnableAutoRepositioning.
+func (s *Server) GetRepositioningSuggestions() []scheduler.Suggestion {
+	return s.advisor.Suggest()
+}
+
+// EnableAutoRepositioning moves idle taxis toward demand every interval (simulated time).
+// Intended for simulation only: taxis jump straight to their new location.
+func (s *Server) EnableAutoRepositioning(interval time.Duration) {
+	if s.advisor.StartAutoMove(interval) {
+		fmt.Printf("[Server] Auto repositioning every %v\n", interval)
+	}
+}
+
+// EnableIdleRepositioning drives every taxi that has been available longer than timeout
+// (simulated time) back into its home zone (see SetTaxiHome), or toward the nearest busy
+// area if it has none. Taxis move one cell at a time, at the pace of rides, and stop
+// as soon as they are given a ride.
+func (s *Server) EnableIdleRepositioning(timeout time.Duration) {
+	if s.idlePolicy.Start(timeout) {
+		fmt.Printf("[Server] Idle taxis reposition after %v\n", timeout)
+	}
+}
+
+// SetTaxiHome gives a taxi a zone to return to once it has been idle too long
+// (see EnableIdleRepositioning).
+// Returns an error if the taxi was not found.
+func (s *Server) SetTaxiHome(taxiID int, zone taxi.Zone) error {
+	if _, exists := s.taxiStore.Get(taxiID); !exists {
+		return fmt.Errorf("taxi #%d not found", taxiID)
+	}
+	s.idlePolicy.SetHome(taxiID, zone)
+	fmt.Printf("[Server] Taxi #%d is based in zone %q\n", taxiID, zone.Name)
+	return nil
+}
+
+// GetRouteCacheStats returns the distance cache counters.
+// Returns false if the server was created without a route cache.
+func (s *Server) GetRouteCacheStats() (taxi.RouteCacheStats, bool) {
+	cache, ok := s.locationService.(*taxi.CachingRouter)
+	if !ok {
+		return taxi.RouteCacheStats{}, false
+	}
+	return cache.Stats(), true
+}
+
+// GetStoreStats returns the calls and lock contention of every taxi store method.
+// Returns false if the fleet is not kept in memory (TaxiStore or ShardedTaxiStore).
+func (s *Server) GetStoreStats() (taxi.StoreStats, bool) {
+	store, ok := s.taxiStore.(interface{ Stats() taxi.StoreStats })
+	if !ok {
+		return taxi.StoreStats{}, false
+	}
+	return store.Stats(), true
+}
+
+// GetAllTaxis returns copies of every registered taxi, ordered by ID.
+func (s *Server) GetAllTaxis() []taxi.Taxi {
+	return s.taxiStore.GetAll()
+}
+
+// GetTaxiSnapshot returns every taxi as it is at this instant (see TaxiSnapshot). Use it
+// to read the fleet more than one way, e.g. count and list it, so the reads agree.
+func (s *Server) GetTaxiSnapshot() taxi.TaxiSnapshot {
+	return s.taxiStore.Snapshot()
+}
+
+// GetRides returns snapshot copies of every ride, oldest first.
+func (s *Server) GetRides() []*ride.Ride {
+	return s.rideStore.List()
+}
+
+// GetRidesByStatus returns snapshot copies of every ride in the given status, oldest first.
+func (s *Server) GetRidesByStatus(status ride.RideStatus) []*ride.Ride {
+	return s.rideStore.ListByStatus(status)
+}
+
+// GetQueue returns a snapshot of every queue feeding the dispatcher.
+func (s *Server) GetQueue() scheduler.QueueSnapshot {
+	return s.scheduler.Queue()
+}
+
+// HasAvailableTaxi reports whether any taxi in pool with all of requirements is free right now.
+func (s *Server) HasAvailableTaxi(requirements taxi.TaxiAttributes, pool string) bool {
+	for _, taxi := range s.taxiStore.GetAllAvailable() {
+		if taxi.Attributes.Has(requirements) && taxi.Pool == pool {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTaxiCount returns the number of registered taxis.
+func (s *Server) GetTaxiCount() int {
+	return s.taxiStore.Count()
+}
+
+// GetAvailableTaxiCount returns the number of available taxis.
+func (s *Server) GetAvailableTaxiCount() int {
+	return len(s.taxiStore.GetAllAvailable())
+}
+
+// GetFlaggedRides returns all anomalies waiting in the review queue.
+func (s *Server) GetFlaggedRides() []scheduler.Anomaly {
+	return s.detector.ReviewQueue()
+}
+
+// Shutdown closes the ride requests channel to signal shutdown.
+// Waits for requests that are already being queued, then makes every later
+// RequestRide return ErrShuttingDown. A request waiting for room in a full queue
+// gives up instead, so Shutdown never hangs. Event exports (see ExportEvents) are
+// flushed to disk before it returns; they keep recording the rides still finishing.
+// Calling it again does nothing.
+func (s *Server) Shutdown() {
+	// First, so senders blocked on a full queue let go of queueMu
+	s.stopOnce.Do(func() { close(s.stopping) })
+
+	s.queueMu.Lock()
+	if s.shutdown {
+		s.queueMu.Unlock()
+		return
+	}
+	s.shutdown = true
+	close(s.rideRequests)
+	s.queueMu.Unlock()
+	fmt.Println("[Server] Shutdown initiated")
+
+	s.flushExports()
+}
+
+// flushExports waits until every event file has written the events handed to it so far
+// and synced them to disk.
+func (s *Server) flushExports() {
+	s.mu.Lock()
+	exports := append([]eventExport(nil), s.exports...)
+	s.mu.Unlock()
+
+	for _, export := range exports {
+		if err := export.logger.Flush(s.events.Delivered(export.events)); err != nil {
+			log.Printf("[Server] ERROR: Failed to flush event export: %v\n", err)
+		}
+	}
+}
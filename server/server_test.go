@@ -0,0 +1,197 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// testStart is when the ManualClock of every test server starts.
+var testStart = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+// newTestServer returns a Server on a ManualClock, so nothing happens until the test
+// advances time, and a rider token to request rides with.
+func newTestServer(t testing.TB) (*Server, *taxi.ManualClock, string) {
+	t.Helper()
+	clock := taxi.NewManualClock(testStart)
+	server := NewServerWithConfig(ServerConfig{Clock: clock, Seed: 1})
+	_, token := server.RegisterClient("test rider")
+	return server, clock, token
+}
+
+// testRide returns a valid ride request for the rider of token; n varies the route.
+func testRide(token string, n int) ride.RideRequest {
+	return ride.RideRequest{
+		Token:         token,
+		StartLocation: taxi.Location{X: n % 50, Y: 10},
+		EndLocation:   taxi.Location{X: n % 50, Y: 60},
+	}
+}
+
+// waitFor polls condition until it holds, failing the test after a few seconds.
+func waitFor(t testing.TB, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShutdownWithFullQueueDoesNotHang(t *testing.T) {
+	server, _, token := newTestServer(t)
+	server.PauseDispatch()
+
+	// Nothing leaves the queue while dispatch is paused
+	for i := 0; i < cap(server.rideRequests); i++ {
+		if _, err := server.RequestRide(testRide(token, i)); err != nil {
+			t.Fatalf("ride %d: %v", i, err)
+		}
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := server.RequestRide(testRide(token, 0))
+		blocked <- err
+	}()
+	waitFor(t, "the last ride to be created", func() bool { return server.rideStore.Count() == cap(server.rideRequests)+1 })
+
+	shutdown := make(chan struct{})
+	go func() {
+		server.Shutdown()
+		close(shutdown)
+	}()
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown hung on the full queue")
+	}
+
+	if err := <-blocked; !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("request waiting for room: got %v, want ErrShuttingDown", err)
+	}
+	if _, err := server.RequestRide(testRide(token, 1)); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("request after Shutdown: got %v, want ErrShuttingDown", err)
+	}
+	if failed := len(server.GetRidesByStatus(ride.FAILED)); failed != 1 {
+		t.Errorf("%d rides FAILED, want only the one that never reached the queue", failed)
+	}
+}
+
+func TestShutdownConcurrentWithRequestRide(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		t.Run(fmt.Sprintf("round %d", round), func(t *testing.T) {
+			server, _, token := newTestServer(t)
+			if round%2 == 1 {
+				server.PauseDispatch() // Let the queue fill up, so some senders wait for room
+			}
+
+			const senders = 8
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for sender := 0; sender < senders; sender++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					for i := 0; ; i++ {
+						_, err := server.RequestRide(testRide(token, sender*1000+i))
+						if errors.Is(err, ErrShuttingDown) {
+							return
+						}
+						if err != nil {
+							t.Errorf("sender %d: %v", sender, err)
+							return
+						}
+					}
+				}()
+			}
+
+			close(start)
+			time.Sleep(time.Duration(round%5) * time.Millisecond)
+			server.Shutdown()
+			server.Shutdown() // A second call does nothing
+
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RequestRide kept going after Shutdown")
+			}
+			if _, err := server.RequestRide(testRide(token, 0)); !errors.Is(err, ErrShuttingDown) {
+				t.Errorf("request after Shutdown: got %v, want ErrShuttingDown", err)
+			}
+		})
+	}
+}
+
+func TestGetRideStatusOfMissingRide(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Shutdown()
+
+	status, err := server.GetRideStatus(42)
+	if err == nil || status != ride.UNKNOWN {
+		t.Errorf("GetRideStatus(42) = %s, %v; want UNKNOWN and an error", status, err)
+	}
+}
+
+func TestPayoutReportCountsNoShowsApart(t *testing.T) {
+	server, _, _ := newTestServer(t)
+	defer server.Shutdown()
+	tariff := ride.Tariff{Name: "test", Currency: "EUR", NoShowFee: 500}
+	server.ledger.RecordRide(&ride.Ride{ID: 1, Tariff: tariff, QuotedFare: 1200}, 1, 10, 40, 5*time.Minute)
+	server.ledger.RecordNoShow(&ride.Ride{ID: 2, Tariff: tariff}, 1, 15)
+	server.ledger.RecordRide(&ride.Ride{ID: 3, Tariff: tariff, QuotedFare: 800}, 1, 5, 20, 3*time.Minute)
+
+	report, err := server.GetPayoutReport(PayoutDaily, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Payouts) != 1 {
+		t.Fatalf("got %d payouts, want 1: %+v", len(report.Payouts), report.Payouts)
+	}
+	payout := report.Payouts[0]
+	if payout.Rides != 2 || payout.Fares != 2000 || payout.NoShows != 1 || payout.CancellationFees != 500 || payout.Distance != 90 {
+		t.Errorf("payout = %+v, want 2 rides for 2000, 1 no-show for 500 and distance 90", payout)
+	}
+}
+
+func TestAdminReadRoutesNeedAdminToken(t *testing.T) {
+	server, _, riderToken := newTestServer(t)
+	defer server.Shutdown()
+	_, adminToken := server.RegisterAdmin("test admin")
+	handler := server.Handler()
+
+	get := func(path, token string) int {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	for _, path := range []string{"/admin/taxis", "/admin/queue", "/admin/stats", "/v1/admin/payouts"} {
+		if code := get(path, ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without a token = %d, want %d", path, code, http.StatusUnauthorized)
+		}
+		if code := get(path, riderToken); code != http.StatusForbidden {
+			t.Errorf("GET %s with a rider token = %d, want %d", path, code, http.StatusForbidden)
+		}
+		if code := get(path, adminToken); code != http.StatusOK {
+			t.Errorf("GET %s with an admin token = %d, want %d", path, code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// sla.go - Per-ride service level targets
+// Watches every ride against the configured SLA (how long a ride may wait for a taxi,
+// how far away in time that taxi may be) and raises an event and a counter for each
+// breach, so operators see when the fleet is under-provisioned
+
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// SLATargets are the service levels every ride should meet. Zero fields are not checked.
+type SLATargets struct {
+	MaxAssignmentWait taxi.Duration `json:"max_assignment_wait"` // Longest a ride may wait for a taxi after it was requested
+	MaxPickupETA      taxi.Duration `json:"max_pickup_eta"`      // Longest the assigned taxi may need to reach the pickup
+}
+
+// SLAStats counts the breaches seen since the server started.
+type SLAStats struct {
+	Targets        SLATargets `json:"targets"`         // Targets in force now
+	WaitBreaches   int        `json:"wait_breaches"`   // Rides still without a taxi after MaxAssignmentWait
+	PickupBreaches int        `json:"pickup_breaches"` // Assignments whose pickup ETA was over MaxPickupETA
+}
+
+// SLAMonitor checks rides against the SLA targets as their events come in.
+// Each breach is logged, counted and published as RideWaitSLABreached or RidePickupSLABreached.
+// All methods are safe for concurrent access.
+type SLAMonitor struct {
+	rides      ride.RideStorage          // For the status of waiting rides
+	taxis      taxi.TaxiStorage          // For where assigned taxis are
+	router     taxi.Router               // For pickup distances
+	travelTime scheduler.TravelTimeModel // For pickup ETAs, as the rides themselves are timed
+	events     *ride.EventBus            // Where breaches are published
+	clock      taxi.Clock                // For wait deadlines
+	mu         sync.Mutex                // Protects stats
+	stats      SLAStats                  // Targets and breach counts
+}
+
+// NewSLAMonitor creates a monitor with no targets.
+func NewSLAMonitor(rides ride.RideStorage, taxis taxi.TaxiStorage, router taxi.Router, travelTime scheduler.TravelTimeModel, events *ride.EventBus, clock taxi.Clock) *SLAMonitor {
+	return &SLAMonitor{rides: rides, taxis: taxis, router: router, travelTime: travelTime, events: events, clock: clock}
+}
+
+// SetTargets replaces the SLA targets. Rides requested before keep the wait target they started with.
+func (sm *SLAMonitor) SetTargets(targets SLATargets) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.stats.Targets = targets
+}
+
+// Stats returns the targets and breach counts.
+func (sm *SLAMonitor) Stats() SLAStats {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.stats
+}
+
+// Run checks every event from the channel until the channel is closed.
+// This method blocks and should be run as a goroutine.
+func (sm *SLAMonitor) Run(events <-chan ride.RideEvent) {
+	for event := range events {
+		sm.mu.Lock()
+		targets := sm.stats.Targets
+		sm.mu.Unlock()
+
+		switch {
+		case event.Type == ride.RideCreated && targets.MaxAssignmentWait.Duration > 0:
+			sm.watchWait(event, targets.MaxAssignmentWait.Duration)
+		case event.Type == ride.TaxiAssigned && targets.MaxPickupETA.Duration > 0:
+			sm.checkPickup(event, targets.MaxPickupETA.Duration)
+		}
+	}
+}
+
+// watchWait flags the ride of a RideCreated event if it still has no taxi once maxWait is up.
+func (sm *SLAMonitor) watchWait(event ride.RideEvent, maxWait time.Duration) {
+	deadline := event.Time.Add(maxWait)
+	sm.clock.AfterFunc(deadline.Sub(sm.clock.Now()), func() {
+		r := sm.rides.Get(event.RideID)
+		if r == nil {
+			return
+		}
+		if r.Status() != ride.CREATED {
+			return
+		}
+
+		sm.mu.Lock()
+		sm.stats.WaitBreaches++
+		sm.mu.Unlock()
+		sm.events.Publish(ride.RideWaitSLABreached, r, 0)
+		log.Printf("[SLAMonitor] %sWARNING: Ride #%d has waited over %v for a taxi\n", ride.TraceTag(r.TraceID), r.ID, maxWait)
+	})
+}
+
+// checkPickup flags a TaxiAssigned event whose taxi needs longer than maxETA to reach the pickup.
+func (sm *SLAMonitor) checkPickup(event ride.RideEvent, maxETA time.Duration) {
+	r := sm.rides.Get(event.RideID)
+	t, exists := sm.taxis.Get(event.TaxiID)
+	if r == nil || !exists {
+		return
+	}
+	distance := taxi.RoutedDistance(sm.router, t.Location, r.StartLocation)
+	eta := sm.travelTime.Estimate(distance, t.Location, event.Time)
+	if eta <= maxETA {
+		return
+	}
+
+	sm.mu.Lock()
+	sm.stats.PickupBreaches++
+	sm.mu.Unlock()
+	sm.events.Publish(ride.RidePickupSLABreached, r, t.ID)
+	log.Printf("[SLAMonitor] %sWARNING: Taxi #%d needs %v to reach the pickup of ride #%d (SLA %v)\n",
+		ride.TraceTag(r.TraceID), t.ID, eta.Round(100*time.Millisecond), r.ID, maxETA)
+}
+
+// SetSLATargets sets the service levels rides are checked against (see SLATargets).
+// Breaches are published as RideWaitSLABreached and RidePickupSLABreached events and
+// counted in Metrics.SLA.
+func (s *Server) SetSLATargets(targets SLATargets) {
+	s.sla.SetTargets(targets)
+	fmt.Printf("[Server] SLA targets: assignment wait %v, pickup ETA %v\n", targets.MaxAssignmentWait, targets.MaxPickupETA)
+}
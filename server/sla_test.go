@@ -0,0 +1,40 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// subscriberStats returns the EventBus stats of the subscriber called name.
+func subscriberStats(t testing.TB, server *Server, name string) ride.SubscriberStats {
+	t.Helper()
+	for _, subscriber := range server.events.Stats().Subscribers {
+		if subscriber.Name == name {
+			return subscriber
+		}
+	}
+	t.Fatalf("no %q subscriber", name)
+	return ride.SubscriberStats{}
+}
+
+func TestSLAWatchesEveryRideOfABurst(t *testing.T) {
+	server, clock, _ := newTestServer(t)
+	server.SetSLATargets(SLATargets{MaxAssignmentWait: taxi.Duration{Duration: time.Minute}})
+
+	// Far more RideCreated events at once than the subscriber buffer holds
+	const rides = 2000
+	for i := 0; i < rides; i++ {
+		r := server.rideStore.Add(testRide("", i))
+		server.events.Publish(ride.RideCreated, r, 0)
+	}
+	waitFor(t, "the SLA monitor to read every event", func() bool { return subscriberStats(t, server, "sla").Queued == 0 })
+	if dropped := subscriberStats(t, server, "sla").Dropped; dropped != 0 {
+		t.Fatalf("SLA monitor lost %d RideCreated events", dropped)
+	}
+
+	clock.Advance(2 * time.Minute)
+	waitFor(t, "every wait breach", func() bool { return server.sla.Stats().WaitBreaches == rides })
+}
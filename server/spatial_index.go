@@ -0,0 +1,98 @@
+// spatial_index.go - Nearby taxi search
+// Finds the taxis around a point through the fleet's spatial index, closest first
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// sortByDistance orders taxis nearest to center first, then by ID.
+func sortByDistance(taxis []taxi.Taxi, center taxi.Location) {
+	distance := taxi.NewLocationService().CalculateDistance
+	sort.Slice(taxis, func(i, j int) bool {
+		di, dj := distance(taxis[i].Location, center), distance(taxis[j].Location, center)
+		if di != dj {
+			return di < dj
+		}
+		return taxis[i].ID < taxis[j].ID
+	})
+}
+
+// FindTaxisNear returns the available taxis within radius (Manhattan distance, as the
+// crow flies on the grid rather than by road) of location, nearest first, e.g. for a
+// rider app showing the cars around the rider. Taxis pending approval or in a zone
+// under maintenance are left out.
+// The in-memory stores answer from their spatial index; other stores are scanned.
+func (s *Server) FindTaxisNear(location taxi.Location, radius int) []taxi.Taxi {
+	if radius < 0 {
+		return []taxi.Taxi{}
+	}
+	var nearby []taxi.Taxi
+	if store, ok := s.taxiStore.(interface {
+		Near(taxi.Location, int) []taxi.Taxi
+	}); ok {
+		nearby = store.Near(location, radius)
+	} else {
+		distance := taxi.NewLocationService().CalculateDistance
+		for _, taxi := range s.taxiStore.GetAllAvailable() {
+			if distance(taxi.Location, location) <= radius {
+				nearby = append(nearby, taxi)
+			}
+		}
+	}
+
+	now := s.clock.Now()
+	found := make([]taxi.Taxi, 0, len(nearby))
+	for _, taxi := range nearby {
+		if _, closed := s.maintenance.Closed(taxi.Location, now); !closed && s.onboarding.Approved(taxi.ID) {
+			found = append(found, taxi)
+		}
+	}
+	sortByDistance(found, location)
+	return found
+}
+
+// maxSearchRadius is the largest radius GET /taxis/near searches.
+const maxSearchRadius = 50
+
+// NearbyTaxi is a taxi found by GET /taxis/near, with what a rider app shows of it.
+type NearbyTaxi struct {
+	TaxiID     int                 `json:"taxi_id"`
+	Location   taxi.Location       `json:"location"`
+	Distance   int                 `json:"distance"` // From the searched location, as the crow flies
+	Attributes taxi.TaxiAttributes `json:"attributes"`
+}
+
+// handleTaxisNear serves GET /taxis/near?x=3&y=4&radius=10 (radius defaults to 10).
+func (s *Server) handleTaxisNear(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	x, errX := strconv.Atoi(query.Get("x"))
+	y, errY := strconv.Atoi(query.Get("y"))
+	if errX != nil || errY != nil {
+		http.Error(w, fmt.Sprintf("x and y must be numbers, got %q and %q", query.Get("x"), query.Get("y")), http.StatusBadRequest)
+		return
+	}
+	radius := 10
+	if value := query.Get("radius"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > maxSearchRadius {
+			http.Error(w, fmt.Sprintf("radius must be a number from 0 to %d, got %q", maxSearchRadius, value), http.StatusBadRequest)
+			return
+		}
+		radius = parsed
+	}
+
+	location := taxi.Location{X: x, Y: y}
+	distance := taxi.NewLocationService().CalculateDistance
+	found := make([]NearbyTaxi, 0)
+	for _, taxi := range s.FindTaxisNear(location, radius) {
+		found = append(found, NearbyTaxi{TaxiID: taxi.ID, Location: taxi.Location, Distance: distance(taxi.Location, location), Attributes: taxi.Attributes})
+	}
+	writeJSON(w, found)
+}
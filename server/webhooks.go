@@ -0,0 +1,362 @@
+// webhooks.go - Ride lifecycle webhooks
+// POSTs every ride event as signed JSON to the URLs registered by clients (for their
+// own rides) or operators (for every ride), so other systems need not poll
+
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/internal/relay"
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// Webhook delivery settings. Backoff is real time: receivers recover on the wall clock.
+const (
+	webhookTimeout     = 5 * time.Second // Per delivery attempt
+	webhookAttempts    = 5               // Tries per event before it is given up
+	webhookBackoff     = time.Second     // Wait before the first retry, doubled after every failure
+	webhookBufferSize  = 100             // Events waiting per webhook before the rest queue up beyond the channel
+	webhookSignatureV1 = "sha256="       // Prefix of the X-TaxiScheduler-Signature header
+)
+
+// Webhook is a URL that receives ride events.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`        // Where events are POSTed
+	ClientID  int       `json:"client_id"`  // Only this client's rides (0 = every ride)
+	CreatedAt time.Time `json:"created_at"` // When the webhook was registered
+}
+
+// WebhookPayload is the JSON body POSTed for every ride event.
+type WebhookPayload struct {
+	RideEventV1
+	ClientID int `json:"client_id"` // Client who requested the ride
+}
+
+// webhookTarget is a registered webhook with its secret and delivery queue.
+type webhookTarget struct {
+	hook    Webhook
+	secret  string                       // Key of the HMAC signature
+	queue   *relay.Relay[WebhookPayload] // Events waiting for delivery, in order, however many
+	removed chan struct{}                // Closed by Remove, so queued events are skipped
+}
+
+// WebhookDispatcher delivers ride events to the registered webhooks.
+// Each webhook has its own queue and worker, so a slow or failing receiver only
+// delays its own events, which still arrive in order. No event is dropped for being late.
+// All methods are safe for concurrent access.
+type WebhookDispatcher struct {
+	rides   ride.RideStorage       // For the client of each ride
+	clients *ClientManager         // For the notification preferences of each client
+	clock   taxi.Clock             // For registration timestamps
+	client  *http.Client           // Shared client with webhookTimeout
+	mu      sync.Mutex             // Protects hooks and nextID
+	hooks   map[int]*webhookTarget // Webhook ID -> webhook
+	nextID  int                    // ID of the next registered webhook
+}
+
+// NewWebhookDispatcher creates a dispatcher with no webhooks.
+func NewWebhookDispatcher(rides ride.RideStorage, clients *ClientManager, clock taxi.Clock) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		rides:   rides,
+		clients: clients,
+		clock:   clock,
+		client:  &http.Client{Timeout: webhookTimeout},
+		hooks:   make(map[int]*webhookTarget),
+		nextID:  1,
+	}
+}
+
+// Register adds a webhook for the rides of clientID (0 for every ride) and returns it
+// with the secret its requests are signed with. The secret is only handed out here.
+func (wd *WebhookDispatcher) Register(url string, clientID int) (Webhook, string) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	target := &webhookTarget{
+		hook:    Webhook{ID: wd.nextID, URL: url, ClientID: clientID, CreatedAt: wd.clock.Now()},
+		secret:  newClientToken(),
+		queue:   relay.New[WebhookPayload](webhookBufferSize),
+		removed: make(chan struct{}),
+	}
+	wd.nextID++
+	wd.hooks[target.hook.ID] = target
+	go wd.deliverAll(target)
+	fmt.Printf("[WebhookDispatcher] Registered webhook #%d -> %s\n", target.hook.ID, url)
+	return target.hook, target.secret
+}
+
+// Get returns a registered webhook.
+// Returns false if the webhook was not found.
+func (wd *WebhookDispatcher) Get(id int) (Webhook, bool) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	target, exists := wd.hooks[id]
+	if !exists {
+		return Webhook{}, false
+	}
+	return target.hook, true
+}
+
+// GetAll returns every registered webhook, ordered by ID.
+func (wd *WebhookDispatcher) GetAll() []Webhook {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	hooks := make([]Webhook, 0, len(wd.hooks))
+	for _, target := range wd.hooks {
+		hooks = append(hooks, target.hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks
+}
+
+// Remove unregisters a webhook. Events already queued for it are dropped.
+// Returns false if the webhook was not found.
+func (wd *WebhookDispatcher) Remove(id int) bool {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	target, exists := wd.hooks[id]
+	if !exists {
+		return false
+	}
+	delete(wd.hooks, id)
+	close(target.removed)
+	target.queue.Close()
+	fmt.Printf("[WebhookDispatcher] Removed webhook #%d\n", id)
+	return true
+}
+
+// Run queues every event from the channel for the matching webhooks until the channel is closed.
+// A client's own webhooks only get the events its notification preferences ask for
+// (see NotificationPrefs); operator webhooks for every ride get them all.
+// This method blocks and should be run as a goroutine.
+func (wd *WebhookDispatcher) Run(events <-chan ride.RideEvent) {
+	for event := range events {
+		wd.mu.Lock()
+		idle := len(wd.hooks) == 0
+		wd.mu.Unlock()
+		if idle {
+			continue // Nobody listening; skip the ride lookup
+		}
+
+		payload := WebhookPayload{RideEventV1: newRideEventV1(event)}
+		if ride := wd.rides.Get(event.RideID); ride != nil {
+			payload.ClientID = ride.ClientID // Fixed at creation, no lock needed
+		}
+		client, _ := wd.clients.Get(payload.ClientID) // A removed client gets the defaults
+		wanted := client.Notify.Wants(NotifyWebhook, event.Type)
+
+		wd.mu.Lock()
+		for _, target := range wd.hooks {
+			if target.hook.ClientID != 0 && (target.hook.ClientID != payload.ClientID || !wanted) {
+				continue
+			}
+			target.queue.Push(payload)
+		}
+		wd.mu.Unlock()
+	}
+}
+
+// deliverAll sends a webhook's events one at a time until the webhook is removed.
+func (wd *WebhookDispatcher) deliverAll(target *webhookTarget) {
+	for payload := range target.queue.C {
+		select {
+		case <-target.removed:
+			continue // Read on, so the queue can close
+		default:
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("[WebhookDispatcher] ERROR: Failed to encode %s event for ride #%d: %v\n", payload.Type, payload.RideID, err)
+			continue
+		}
+		wd.deliver(target, payload, body)
+	}
+}
+
+// deliver POSTs one event, retrying with exponential backoff on network errors,
+// 429 and 5xx answers. Other answers are final.
+func (wd *WebhookDispatcher) deliver(target *webhookTarget, payload WebhookPayload, body []byte) {
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := wd.post(target, payload, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == webhookAttempts {
+			log.Printf("[WebhookDispatcher] ERROR: Gave up delivering %s event for ride #%d to webhook #%d after %d attempts: %v\n",
+				payload.Type, payload.RideID, target.hook.ID, attempt, err)
+			return
+		}
+		log.Printf("[WebhookDispatcher] WARNING: Delivery to webhook #%d failed (attempt %d/%d), retrying in %v: %v\n",
+			target.hook.ID, attempt, webhookAttempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt. Returns whether a failure is worth retrying.
+func (wd *WebhookDispatcher) post(target *webhookTarget, payload WebhookPayload, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, target.hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-TaxiScheduler-Event", payload.Type)
+	request.Header.Set("X-TaxiScheduler-Signature", webhookSignatureV1+signWebhook(target.secret, body))
+
+	response, err := wd.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("webhook answered %s", response.Status)
+}
+
+// signWebhook returns the hex HMAC-SHA256 of body with secret. Receivers compute the
+// same over the raw request body and compare it with the X-TaxiScheduler-Signature header.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterWebhook makes the server POST every event of clientID's rides (0 for every
+// ride) to rawURL, and returns the webhook with the secret its requests are signed with.
+// Returns an error for a URL that is not http(s) or an unknown client.
+func (s *Server) RegisterWebhook(rawURL string, clientID int) (Webhook, string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Webhook{}, "", fmt.Errorf("webhook URL %q must be an absolute http or https URL", rawURL)
+	}
+	if clientID != 0 {
+		if _, exists := s.clients.Get(clientID); !exists {
+			return Webhook{}, "", fmt.Errorf("client #%d not found", clientID)
+		}
+	}
+	hook, secret := s.webhooks.Register(rawURL, clientID)
+	return hook, secret, nil
+}
+
+// GetWebhooks returns every registered webhook, ordered by ID.
+func (s *Server) GetWebhooks() []Webhook {
+	return s.webhooks.GetAll()
+}
+
+// RemoveWebhook stops delivering events to a webhook.
+// Returns an error if the webhook was not found.
+func (s *Server) RemoveWebhook(id int) error {
+	if !s.webhooks.Remove(id) {
+		return fmt.Errorf("webhook #%d not found", id)
+	}
+	return nil
+}
+
+// WebhookSubscription is the body of POST /webhooks.
+type WebhookSubscription struct {
+	URL string `json:"url"`
+}
+
+// WebhookCredentials is the answer to POST /webhooks.
+type WebhookCredentials struct {
+	Webhook
+	Secret string `json:"secret"` // Key of the X-TaxiScheduler-Signature HMAC; only shown once
+}
+
+// webhookOwner returns the client whose webhooks the token may manage: the rider
+// itself, or 0 (every webhook, and new ones for every ride) for an admin.
+func (s *Server) webhookOwner(r *http.Request) (int, error) {
+	account, ok := s.clients.Authenticate(bearerToken(r))
+	if !ok {
+		return 0, ErrUnauthorized
+	}
+	switch account.Role {
+	case RoleRider:
+		return account.ID, nil
+	case RoleAdmin:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%w: %s account, needs %s or %s", ErrForbidden, account.Role, RoleRider, RoleAdmin)
+}
+
+// handleRegisterWebhook serves POST /webhooks: for the rider's own rides with a rider
+// token, for every ride with an admin token.
+func (s *Server) handleRegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.webhookOwner(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	var subscription WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		http.Error(w, fmt.Sprintf("parsing webhook: %v", err), http.StatusBadRequest)
+		return
+	}
+	hook, secret, err := s.RegisterWebhook(subscription.URL, owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, WebhookCredentials{Webhook: hook, Secret: secret})
+}
+
+// handleListWebhooks serves GET /webhooks: the rider's own webhooks, or every webhook for an admin.
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.webhookOwner(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	hooks := make([]Webhook, 0)
+	for _, hook := range s.GetWebhooks() {
+		if owner == 0 || hook.ClientID == owner {
+			hooks = append(hooks, hook)
+		}
+	}
+	writeJSON(w, hooks)
+}
+
+// handleRemoveWebhook serves DELETE /webhooks/{id}. Riders may only remove their own webhooks.
+func (s *Server) handleRemoveWebhook(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.webhookOwner(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid webhook ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	// Someone else's webhook answers the same as a missing one, so IDs cannot be probed
+	if hook, exists := s.webhooks.Get(id); !exists || (owner != 0 && hook.ClientID != owner) {
+		http.Error(w, fmt.Sprintf("webhook #%d not found", id), http.StatusNotFound)
+		return
+	}
+	if err := s.RemoveWebhook(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"webhook_id": id})
+}
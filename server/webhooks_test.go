@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+)
+
+func TestWebhookGetsEveryEventOfABurstInOrder(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	var mu sync.Mutex
+	var received []int // Ride IDs, in delivery order
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("bad payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, payload.RideID)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+	if _, _, err := server.RegisterWebhook(receiver.URL, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Far more events at once than the subscriber and the webhook queue hold
+	const rides = 500
+	for i := 0; i < rides; i++ {
+		r := server.rideStore.Add(testRide("", i))
+		server.events.Publish(ride.RideCreated, r, 0)
+	}
+	waitFor(t, "every delivery", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == rides
+	})
+	for i, rideID := range received {
+		if rideID != i+1 {
+			t.Fatalf("delivery %d is for ride #%d, want #%d", i, rideID, i+1)
+		}
+	}
+	if dropped := subscriberStats(t, server, "webhooks").Dropped; dropped != 0 {
+		t.Errorf("webhooks subscriber lost %d events", dropped)
+	}
+}
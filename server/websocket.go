@@ -0,0 +1,175 @@
+// websocket.go - Minimal WebSocket server connections (RFC 6455)
+// Just enough of the protocol to push text messages to browsers and other clients:
+// the upgrade handshake, unfragmented server frames, and answering pings and closes
+
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// WebSocket protocol constants.
+const (
+	wsGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // Mixed into Sec-WebSocket-Accept
+	wsOpText        = 0x1
+	wsOpClose       = 0x8
+	wsOpPing        = 0x9
+	wsOpPong        = 0xA
+	wsMaxClientData = 64 << 10 // Largest client frame accepted; clients only send control frames here
+)
+
+// errNotUpgradable is returned by upgradeWebSocket for requests that are not a valid handshake.
+var errNotUpgradable = errors.New("not a WebSocket handshake")
+
+// wsConn is a server side WebSocket connection. Writes are safe for concurrent use.
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex // Serializes frames from WriteText and the read loop's replies
+}
+
+// upgradeWebSocket answers a WebSocket handshake request and takes over its connection.
+// On error an HTTP error response has already been sent, unless the connection was taken over.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade request", http.StatusBadRequest)
+		return nil, errNotUpgradable
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: unsupported version %q", errNotUpgradable, r.Header.Get("Sec-WebSocket-Version"))
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported here", http.StatusInternalServerError)
+		return nil, errors.New("response cannot be hijacked")
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("taking over connection: %w", err)
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := buffered.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: buffered.Reader}, nil
+}
+
+// WriteText sends one text message.
+func (ws *wsConn) WriteText(message []byte) error {
+	return ws.writeFrame(wsOpText, message)
+}
+
+// writeFrame sends one unfragmented, unmasked frame.
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode} // FIN set
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readLoop reads client frames until the client closes the connection or it fails,
+// answering pings and ignoring data messages. Returns once the connection is done.
+func (ws *wsConn) readLoop() {
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsOpClose:
+			ws.writeFrame(wsOpClose, payload) // Echo the status code, as the protocol asks
+			return
+		case wsOpPing:
+			if ws.writeFrame(wsOpPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads one client frame and unmasks its payload.
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(ws.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if !masked {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+	if length > wsMaxClientData {
+		return 0, nil, fmt.Errorf("client frame of %d bytes is too large", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Close closes the underlying connection.
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
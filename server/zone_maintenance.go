@@ -0,0 +1,110 @@
+// zone_maintenance.go - Zone maintenance API
+// Lets operators schedule, list and cancel maintenance windows per zone
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// defaultMaintenanceSlowdown is the duration multiplier of rides starting in a zone
+// under maintenance when the window does not set one.
+const defaultMaintenanceSlowdown = 1.5
+
+// ScheduleZoneMaintenance closes a zone from from until until: meanwhile taxis inside
+// it get no rides (they keep the rides they have), so rides starting there are served
+// by taxis from outside, and those rides take slowdown times as long (0 for the
+// default 1.5) in ETAs and simulated drives alike.
+// Returns an error if the zone is empty, the window is empty or already over, or the
+// slowdown is below 1.
+func (s *Server) ScheduleZoneMaintenance(zone taxi.Zone, from, until time.Time, slowdown float64, reason string) (scheduler.MaintenanceWindow, error) {
+	if zone.Min.X > zone.Max.X || zone.Min.Y > zone.Max.Y {
+		return scheduler.MaintenanceWindow{}, fmt.Errorf("zone %q is empty: min %v is past max %v", zone.Name, zone.Min, zone.Max)
+	}
+	if !from.Before(until) {
+		return scheduler.MaintenanceWindow{}, fmt.Errorf("maintenance must end after it starts")
+	}
+	if !s.clock.Now().Before(until) {
+		return scheduler.MaintenanceWindow{}, fmt.Errorf("maintenance ending at %s is already over", until.Format(time.RFC3339))
+	}
+	if slowdown == 0 {
+		slowdown = defaultMaintenanceSlowdown
+	}
+	if slowdown < 1 {
+		return scheduler.MaintenanceWindow{}, fmt.Errorf("slowdown must be at least 1, got %v", slowdown)
+	}
+
+	window := s.maintenance.Add(scheduler.MaintenanceWindow{Zone: zone, From: from, Until: until, Slowdown: slowdown, Reason: reason})
+	fmt.Printf("[Server] Zone %q under maintenance from %s until %s, rides x%.2f (window #%d): %s\n", zone.Name,
+		from.Format(time.RFC3339), until.Format(time.RFC3339), slowdown, window.ID, reason)
+	return window, nil
+}
+
+// GetZoneMaintenance returns the maintenance windows that have not ended yet, ordered by ID.
+func (s *Server) GetZoneMaintenance() []scheduler.MaintenanceWindow {
+	return s.maintenance.GetAll()
+}
+
+// CancelZoneMaintenance cancels a maintenance window, reopening its zone at once if
+// the window is open. Returns an error if the window was not found.
+func (s *Server) CancelZoneMaintenance(id int) error {
+	if !s.maintenance.Remove(id) {
+		return fmt.Errorf("maintenance window #%d not found", id)
+	}
+	fmt.Printf("[Server] Maintenance window #%d cancelled\n", id)
+	return nil
+}
+
+// MaintenanceWindowRequest is the body of POST /admin/maintenance. From defaults to
+// now and Slowdown to 1.5.
+type MaintenanceWindowRequest struct {
+	Zone     taxi.Zone `json:"zone"`
+	From     time.Time `json:"from"`
+	Until    time.Time `json:"until"`
+	Slowdown float64   `json:"slowdown"`
+	Reason   string    `json:"reason"`
+}
+
+// handleAdminScheduleMaintenance serves POST /admin/maintenance.
+func (s *Server) handleAdminScheduleMaintenance(w http.ResponseWriter, r *http.Request) {
+	var request MaintenanceWindowRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("parsing maintenance window: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.From.IsZero() {
+		request.From = s.clock.Now()
+	}
+	window, err := s.ScheduleZoneMaintenance(request.Zone, request.From, request.Until, request.Slowdown, request.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, window)
+}
+
+// handleAdminMaintenance serves GET /admin/maintenance.
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetZoneMaintenance())
+}
+
+// handleAdminCancelMaintenance serves DELETE /admin/maintenance/{id}.
+func (s *Server) handleAdminCancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid maintenance window ID %q", r.PathValue("id")), http.StatusBadRequest)
+		return
+	}
+	if err := s.CancelZoneMaintenance(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"cancelled": id})
+}
@@ -0,0 +1,125 @@
+// driver_client.go - Simulates a driver's mobile app
+// Registers one taxi, answers ride offers and sends location heartbeats through the Server API
+
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/server"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// DriverBehavior describes how a simulated driver reacts.
+type DriverBehavior struct {
+	AcceptProbability float64       // Chance an offer is accepted (0-1)
+	MaxResponseDelay  time.Duration // Offers are answered after a random delay up to this (simulated time)
+	HeartbeatInterval time.Duration // How often the driver reports its location (0 = never)
+	Seed              int64         // Seed for the driver's answers and delays (0 = random)
+}
+
+// DefaultDriverBehavior accepts 80% of offers within 5 seconds and reports its location every 10 seconds.
+func DefaultDriverBehavior() DriverBehavior {
+	return DriverBehavior{
+		AcceptProbability: 0.8,
+		MaxResponseDelay:  5 * time.Second,
+		HeartbeatInterval: 10 * time.Second,
+	}
+}
+
+// DriverClient simulates the app of one driver, to exercise the confirmation
+// handshake (see Server.RequireConfirmation) end to end.
+type DriverClient struct {
+	server     *server.Server      // Server API gateway
+	location   taxi.Location       // Where the taxi registers
+	attributes taxi.TaxiAttributes // Features of the taxi
+	behavior   DriverBehavior      // How the driver answers offers
+	rng        *rand.Rand          // Private random source, only used by the Start goroutine
+}
+
+// NewDriverClient creates a driver that will register a taxi at location.
+func NewDriverClient(server *server.Server, location taxi.Location, attributes taxi.TaxiAttributes, behavior DriverBehavior) *DriverClient {
+	seed := behavior.Seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return &DriverClient{
+		server:     server,
+		location:   location,
+		attributes: attributes,
+		behavior:   behavior,
+		rng:        rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Start registers the taxi, then answers offers and sends heartbeats forever.
+// This method blocks and should be run as a goroutine.
+func (dc *DriverClient) Start() {
+	// Subscribe before registering so the very first offer is not missed
+	events := dc.server.SubscribeRideEvents()
+	taxiID := dc.server.RegisterTaxi(dc.location, dc.attributes)
+	fmt.Printf("[DriverClient] Driver of taxi #%d online at (%d, %d)\n", taxiID, dc.location.X, dc.location.Y)
+
+	clock := dc.server.Clock()
+	var heartbeat <-chan time.Time
+	if dc.behavior.HeartbeatInterval > 0 {
+		ticker := clock.NewTicker(dc.behavior.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if event.Type != ride.RideOffered || event.TaxiID != taxiID {
+				continue
+			}
+			// Decide now, on this goroutine, so the random source is never shared
+			accept := dc.rng.Float64() < dc.behavior.AcceptProbability
+			var delay time.Duration
+			if dc.behavior.MaxResponseDelay > 0 {
+				delay = time.Duration(dc.rng.Int63n(int64(dc.behavior.MaxResponseDelay)))
+			}
+			go dc.respond(taxiID, event.RideID, accept, delay)
+
+		case <-heartbeat:
+			dc.sendHeartbeat(taxiID)
+		}
+	}
+}
+
+// respond answers one offer after the driver's thinking time.
+func (dc *DriverClient) respond(taxiID, rideID int, accept bool, delay time.Duration) {
+	dc.server.Clock().Sleep(delay)
+
+	var err error
+	if accept {
+		err = dc.server.AcceptRide(taxiID, rideID)
+	} else {
+		err = dc.server.DeclineRide(taxiID, rideID)
+	}
+	if err != nil {
+		fmt.Printf("[DriverClient] Taxi #%d could not answer ride #%d: %v\n", taxiID, rideID, err)
+		return
+	}
+	if accept {
+		fmt.Printf("[DriverClient] Taxi #%d accepted ride #%d after %v\n", taxiID, rideID, delay.Round(time.Millisecond))
+	} else {
+		fmt.Printf("[DriverClient] Taxi #%d declined ride #%d after %v\n", taxiID, rideID, delay.Round(time.Millisecond))
+	}
+}
+
+// sendHeartbeat reports the taxi's current position, as a phone's GPS would.
+func (dc *DriverClient) sendHeartbeat(taxiID int) {
+	taxi, err := dc.server.GetTaxi(taxiID)
+	if err != nil {
+		fmt.Printf("[DriverClient] Taxi #%d heartbeat failed: %v\n", taxiID, err)
+		return
+	}
+	if err := dc.server.UpdateTaxiLocation(taxiID, taxi.Location); err != nil {
+		fmt.Printf("[DriverClient] Taxi #%d heartbeat failed: %v\n", taxiID, err)
+	}
+}
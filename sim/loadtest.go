@@ -0,0 +1,155 @@
+// loadtest.go - Load generation harness
+// Hammers TaxiStore and TaxiAssigner with many taxis and rides, without any sleeps,
+// and reports throughput, allocations and lock contention
+
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// LoadTestConfig sizes a load test run.
+type LoadTestConfig struct {
+	Taxis   int // Taxis in the fleet
+	Rides   int // Ride requests to assign
+	Workers int // Goroutines assigning rides concurrently
+	Shards  int // Split the store into this many ShardedTaxiStore shards (0 or 1 = one TaxiStore)
+}
+
+// LoadTestResult summarizes a load test run.
+type LoadTestResult struct {
+	Config              LoadTestConfig
+	Elapsed             time.Duration // Wall time spent assigning
+	Assigned            int           // Rides that got a taxi
+	Unassigned          int           // Rides for which no taxi was free
+	AssignmentsPerSec   float64       // Assigned rides per second of wall time
+	AllocsPerAssignment float64       // Heap allocations per ride request
+	BytesPerAssignment  float64       // Heap bytes allocated per ride request
+	MutexWait           time.Duration // Total time goroutines spent blocked on mutexes
+}
+
+// mutexWaitMetric is the runtime metric counting time spent waiting for sync.Mutex/RWMutex.
+const mutexWaitMetric = "/sync/mutex/wait/total:seconds"
+
+// RunLoadTest registers the taxis, then assigns every ride as fast as the workers can.
+// Each assigned taxi is freed again straight away at the ride's destination, so the fleet
+// keeps cycling like it would over a long simulation. Progress logging from the
+// assigner is discarded while the test runs so printing does not dominate the numbers.
+func RunLoadTest(config LoadTestConfig) LoadTestResult {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+
+	clock := taxi.NewRealClock()
+	router := taxi.NewLocationService()
+	var store taxi.TaxiStorage = taxi.NewTaxiStore(taxi.NewSequentialIDGenerator(1), clock)
+	if config.Shards > 1 {
+		store = taxi.NewShardedTaxiStore(config.Shards, taxi.NewSequentialIDGenerator(1), clock)
+	}
+	rides := ride.NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+	assigner := scheduler.NewTaxiAssigner(store, router, nil, nil, nil, nil, nil, clock)
+
+	rng := rand.New(rand.NewSource(1)) // Fixed seed so runs are comparable
+
+	// Silence per-assignment log lines while measuring
+	stdout := os.Stdout
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+		defer func() {
+			os.Stdout = stdout
+			devNull.Close()
+		}()
+	}
+
+	for i := 0; i < config.Taxis; i++ {
+		store.Add(taxi.RandomLocation(rng, nil), 0)
+	}
+	requests := make(chan *ride.Ride, config.Rides)
+	for i := 0; i < config.Rides; i++ {
+		requests <- rides.Add(ride.RideRequest{StartLocation: taxi.RandomLocation(rng, nil), EndLocation: taxi.RandomLocation(rng, nil)})
+	}
+	close(requests)
+
+	var assigned, unassigned int
+	var mu sync.Mutex // Protects assigned and unassigned
+
+	var memBefore, memAfter runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memBefore)
+	waitBefore := readMutexWait()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ride := range requests {
+				taxi := assigner.AssignBestTaxi(ride, nil)
+				if taxi == nil {
+					mu.Lock()
+					unassigned++
+					mu.Unlock()
+					continue
+				}
+				// The ride is over instantly; the taxi waits at the destination
+				store.UpdateLocation(taxi.ID, ride.EndLocation)
+				store.SetAvailability(taxi.ID, true)
+				mu.Lock()
+				assigned++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	waitAfter := readMutexWait()
+	runtime.ReadMemStats(&memAfter)
+
+	result := LoadTestResult{
+		Config:     config,
+		Elapsed:    elapsed,
+		Assigned:   assigned,
+		Unassigned: unassigned,
+		MutexWait:  waitAfter - waitBefore,
+	}
+	if elapsed > 0 {
+		result.AssignmentsPerSec = float64(assigned) / elapsed.Seconds()
+	}
+	if config.Rides > 0 {
+		result.AllocsPerAssignment = float64(memAfter.Mallocs-memBefore.Mallocs) / float64(config.Rides)
+		result.BytesPerAssignment = float64(memAfter.TotalAlloc-memBefore.TotalAlloc) / float64(config.Rides)
+	}
+	return result
+}
+
+// readMutexWait returns the total time the program has spent blocked on mutexes so far.
+// Returns 0 if the runtime does not report it.
+func readMutexWait() time.Duration {
+	sample := []metrics.Sample{{Name: mutexWaitMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return time.Duration(sample[0].Value.Float64() * float64(time.Second))
+}
+
+// String formats the result as a short report.
+func (r LoadTestResult) String() string {
+	return fmt.Sprintf("%d taxis, %d rides, %d workers, %d store shards: %d assigned, %d unassigned in %v\n"+
+		"  %.0f assignments/sec, %.1f allocs and %.0f bytes per request, %v waiting on locks",
+		r.Config.Taxis, r.Config.Rides, r.Config.Workers, max(r.Config.Shards, 1), r.Assigned, r.Unassigned,
+		r.Elapsed.Round(time.Millisecond), r.AssignmentsPerSec,
+		r.AllocsPerAssignment, r.BytesPerAssignment, r.MutexWait.Round(time.Microsecond))
+}
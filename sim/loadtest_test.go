@@ -0,0 +1,126 @@
+package sim
+
+import (
+	"math/rand"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// benchmarkFleet is how many taxis the store benchmarks run against.
+const benchmarkFleet = 10000
+
+// benchmarkStores returns a store of every in-memory TaxiStorage kind, by name,
+// each holding benchmarkFleet taxis spread over the grid.
+func benchmarkStores() map[string]taxi.TaxiStorage {
+	stores := map[string]taxi.TaxiStorage{
+		"TaxiStore":        taxi.NewTaxiStore(taxi.NewSequentialIDGenerator(1), taxi.NewRealClock()),
+		"ShardedTaxiStore": taxi.NewShardedTaxiStore(16, taxi.NewSequentialIDGenerator(1), taxi.NewRealClock()),
+	}
+	for _, store := range stores {
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < benchmarkFleet; i++ {
+			store.Add(taxi.RandomLocation(rng, nil), 0)
+		}
+	}
+	return stores
+}
+
+// discardStdout sends the assigner's per-assignment log lines to /dev/null until the
+// benchmark ends, so printing does not dominate the numbers.
+func discardStdout(b *testing.B) {
+	b.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	b.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+// Assigns rides from every CPU at once; each taxi is freed at the destination straight away.
+func BenchmarkAssignBestTaxi(b *testing.B) {
+	for name, store := range benchmarkStores() {
+		b.Run(name, func(b *testing.B) {
+			discardStdout(b)
+			clock := taxi.NewRealClock()
+			rides := ride.NewRideStore(taxi.NewSequentialIDGenerator(1), clock)
+			assigner := scheduler.NewTaxiAssigner(store, taxi.NewLocationService(), nil, nil, nil, nil, nil, clock)
+			var seed atomic.Int64
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(seed.Add(1)))
+				for pb.Next() {
+					ride := rides.Add(ride.RideRequest{StartLocation: taxi.RandomLocation(rng, nil), EndLocation: taxi.RandomLocation(rng, nil)})
+					if taxi := assigner.AssignBestTaxi(ride, nil); taxi != nil {
+						store.UpdateLocation(taxi.ID, ride.EndLocation)
+						store.SetAvailability(taxi.ID, true)
+					}
+				}
+			})
+		})
+	}
+}
+
+// Moves taxis from every CPU at once, the write every taxi sends most often.
+func BenchmarkStoreUpdateLocation(b *testing.B) {
+	for name, store := range benchmarkStores() {
+		b.Run(name, func(b *testing.B) {
+			var seed atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(seed.Add(1)))
+				for pb.Next() {
+					store.UpdateLocation(1+rng.Intn(benchmarkFleet), taxi.RandomLocation(rng, nil))
+				}
+			})
+		})
+	}
+}
+
+// Reads the whole available fleet, the scan the spatial index is meant to replace.
+func BenchmarkStoreGetAllAvailable(b *testing.B) {
+	for name, store := range benchmarkStores() {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if taxis := store.GetAllAvailable(); len(taxis) != benchmarkFleet {
+					b.Fatalf("%d taxis available, want %d", len(taxis), benchmarkFleet)
+				}
+			}
+		})
+	}
+}
+
+// Runs the -loadtest harness with b.N rides, reporting its own measurements as metrics.
+func BenchmarkLoadTest(b *testing.B) {
+	for _, shards := range []int{1, 16} {
+		name := "TaxiStore"
+		if shards > 1 {
+			name = "ShardedTaxiStore"
+		}
+		b.Run(name, func(b *testing.B) {
+			result := RunLoadTest(LoadTestConfig{Taxis: benchmarkFleet, Rides: b.N, Workers: runtime.GOMAXPROCS(0), Shards: shards})
+			if result.Assigned+result.Unassigned != b.N {
+				b.Fatalf("%d rides assigned and %d unassigned, want %d in all", result.Assigned, result.Unassigned, b.N)
+			}
+			b.ReportMetric(result.AssignmentsPerSec, "assignments/s")
+			b.ReportMetric(result.AllocsPerAssignment, "allocs/ride")
+			b.ReportMetric(result.BytesPerAssignment, "B/ride")
+			b.ReportMetric(float64(result.MutexWait.Nanoseconds())/float64(b.N), "mutex-wait-ns/ride")
+		})
+	}
+}
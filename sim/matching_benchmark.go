@@ -0,0 +1,111 @@
+// matching_benchmark.go - Batch matching benchmark
+// Solves the same random batches with the optimal and the greedy batch matching and
+// reports how far taxis drive to pickups with each, and how long matching takes
+
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// MatchingBenchmarkConfig sizes a batch matching benchmark run.
+type MatchingBenchmarkConfig struct {
+	Rides   int // Rides per batch
+	Taxis   int // Available taxis per batch
+	Batches int // Random batches matched
+}
+
+// MatchingResult is how one batch matching did over every batch of a benchmark.
+type MatchingResult struct {
+	Matching       scheduler.BatchMatching
+	Matched        int           // Rides that got a taxi
+	PickupDistance int           // Distance from every matched taxi to its pickup
+	Longest        int           // Longest single pickup
+	Elapsed        time.Duration // Wall time spent matching
+}
+
+// MatchingBenchmarkResult compares the batch matchings on the same batches.
+type MatchingBenchmarkResult struct {
+	Config  MatchingBenchmarkConfig
+	Optimal MatchingResult
+	Greedy  MatchingResult
+}
+
+// RunMatchingBenchmark places the rides and taxis of each batch at random on the grid
+// and matches them with both OptimalMatching and GreedyMatching, by Manhattan pickup
+// distance. The seed is fixed so runs are comparable. Only the matching is measured:
+// -compare with the batched and batched-greedy strategies shows what it does to a
+// whole simulated run.
+func RunMatchingBenchmark(config MatchingBenchmarkConfig) MatchingBenchmarkResult {
+	result := MatchingBenchmarkResult{
+		Config:  config,
+		Optimal: MatchingResult{Matching: scheduler.OptimalMatching},
+		Greedy:  MatchingResult{Matching: scheduler.GreedyMatching},
+	}
+	router := taxi.NewLocationService()
+	rng := rand.New(rand.NewSource(1))
+	for batch := 0; batch < config.Batches; batch++ {
+		costs := randomBatch(rng, router, config.Rides, config.Taxis)
+		result.Optimal.add(costs, config.Taxis)
+		result.Greedy.add(costs, config.Taxis)
+	}
+	return result
+}
+
+// randomBatch places ride pickups and taxis at random on the grid and returns the
+// pickup distance from every taxi to every ride, indexed [ride][taxi].
+func randomBatch(rng *rand.Rand, router *taxi.LocationService, rides, taxis int) [][]int {
+	locations := make([]taxi.Location, taxis)
+	for j := range locations {
+		locations[j] = taxi.RandomLocation(rng, nil)
+	}
+	costs := make([][]int, rides)
+	for i := range costs {
+		pickup := taxi.RandomLocation(rng, nil)
+		costs[i] = make([]int, taxis)
+		for j, taxi := range locations {
+			costs[i][j] = router.CalculateDistance(taxi, pickup)
+		}
+	}
+	return costs
+}
+
+// add matches one batch and counts how it went.
+func (r *MatchingResult) add(costs [][]int, taxis int) {
+	start := time.Now()
+	matched := r.Matching.Match(costs, taxis)
+	r.Elapsed += time.Since(start)
+	for i, j := range matched {
+		if j < 0 {
+			continue
+		}
+		r.Matched++
+		r.PickupDistance += costs[i][j]
+		r.Longest = max(r.Longest, costs[i][j])
+	}
+}
+
+// String formats the result as a short report, one line per matching, and what the
+// optimal matching saves over the greedy one.
+func (r MatchingBenchmarkResult) String() string {
+	batches := max(r.Config.Batches, 1)
+	line := func(m MatchingResult) string {
+		mean := 0.0
+		if m.Matched > 0 {
+			mean = float64(m.PickupDistance) / float64(m.Matched)
+		}
+		return fmt.Sprintf("  %-8s %d matched, pickup distance %d (%.1f per ride, longest %d), %v per batch",
+			m.Matching, m.Matched, m.PickupDistance, mean, m.Longest, (m.Elapsed / time.Duration(batches)).Round(time.Microsecond))
+	}
+	saved := 0.0
+	if r.Greedy.PickupDistance > 0 {
+		saved = 100 * float64(r.Greedy.PickupDistance-r.Optimal.PickupDistance) / float64(r.Greedy.PickupDistance)
+	}
+	return fmt.Sprintf("%d batches of %d rides and %d taxis:\n%s\n%s\n  optimal matching drives %.1f%% less to pickups than greedy",
+		r.Config.Batches, r.Config.Rides, r.Config.Taxis, line(r.Optimal), line(r.Greedy), saved)
+}
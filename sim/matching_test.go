@@ -0,0 +1,48 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/scheduler"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// benchmarkMatching matches random batches of a few sizes with matching, one batch per
+// iteration. The batches are built before the timer starts and are the same for every
+// matching, so the optimal and greedy numbers compare directly.
+func benchmarkMatching(b *testing.B, matching scheduler.BatchMatching) {
+	sizes := []struct{ rides, taxis int }{{10, 15}, {50, 75}, {200, 300}}
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("%dx%d", size.rides, size.taxis), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			router := taxi.NewLocationService()
+			batches := make([][][]int, 16)
+			for i := range batches {
+				batches[i] = randomBatch(rng, router, size.rides, size.taxis)
+			}
+
+			pickups := 0
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				costs := batches[i%len(batches)]
+				for ride, taxi := range matching.Match(costs, size.taxis) {
+					if taxi >= 0 {
+						pickups += costs[ride][taxi]
+					}
+				}
+			}
+			b.ReportMetric(float64(pickups)/float64(b.N), "pickup-distance/batch")
+		})
+	}
+}
+
+func BenchmarkMatchOptimal(b *testing.B) {
+	benchmarkMatching(b, scheduler.OptimalMatching)
+}
+
+func BenchmarkMatchGreedy(b *testing.B) {
+	benchmarkMatching(b, scheduler.GreedyMatching)
+}
@@ -0,0 +1,134 @@
+// scenario.go - Declarative simulation scenarios
+// Describes when taxis register and when rides are requested, loaded from a JSON file
+
+// Package sim drives a Server the way its clients would: simulated taxis, drivers and
+// riders following a Scenario, the load test and the batch matching benchmark.
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiWave registers Count taxis, one every Interval, starting At after the scenario begins.
+type TaxiWave struct {
+	At               taxi.Duration       `json:"at"`                // Offset from the scenario start (simulated time)
+	Count            int                 `json:"count"`             // Number of taxis to register
+	Interval         taxi.Duration       `json:"interval"`          // Delay between registrations
+	Area             *taxi.Zone          `json:"area"`              // Where taxis appear (nil = whole grid)
+	Attributes       taxi.TaxiAttributes `json:"attributes"`        // Features every taxi in the wave has
+	RandomAttributes bool                `json:"random_attributes"` // Give each taxi a random mix of features instead
+	Pool             string              `json:"pool"`              // Dispatch pool the taxis are reserved for ("" = general fleet)
+}
+
+// RideWave requests Count rides, one every Interval, starting At after the scenario begins.
+// Overlapping waves run side by side, so a short dense wave on top of a long sparse one
+// scripts a rush-hour spike.
+type RideWave struct {
+	At              taxi.Duration       `json:"at"`               // Offset from the scenario start (simulated time)
+	Count           int                 `json:"count"`            // Number of rides to request
+	Interval        taxi.Duration       `json:"interval"`         // Delay between requests
+	From            *taxi.Zone          `json:"from"`             // Where rides start (nil = whole grid)
+	To              *taxi.Zone          `json:"to"`               // Where rides end (nil = whole grid)
+	Requirements    taxi.TaxiAttributes `json:"requirements"`     // Features the taxi must have
+	RequirementRate float64             `json:"requirement_rate"` // Fraction of rides with Requirements (0 = all of them)
+	Pool            string              `json:"pool"`             // Dispatch pool the rides are served from ("" = general fleet)
+}
+
+// Scenario is a scripted demand pattern for TaxiClient and UserClient.
+type Scenario struct {
+	Seed  int64      `json:"seed"`  // Random seed for locations; the same seed gives the same rides (0 = random)
+	Taxis []TaxiWave `json:"taxis"` // Taxi registrations
+	Rides []RideWave `json:"rides"` // Ride requests
+}
+
+// DefaultScenario returns the original simulation: 15 taxis anywhere on the grid, one every
+// 5 seconds, then after 10 seconds 100 rides, one every 5 seconds, 1 in 10 needing a wheelchair.
+func DefaultScenario() *Scenario {
+	return &Scenario{
+		Taxis: []TaxiWave{
+			{Count: 15, Interval: taxi.Duration{Duration: 5 * time.Second}, RandomAttributes: true},
+		},
+		Rides: []RideWave{
+			{
+				At:              taxi.Duration{Duration: 10 * time.Second},
+				Count:           100,
+				Interval:        taxi.Duration{Duration: 5 * time.Second},
+				Requirements:    taxi.WheelchairAccessible,
+				RequirementRate: 0.1,
+			},
+		},
+	}
+}
+
+// LoadScenario reads a scenario from a JSON file and checks it for mistakes.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// validate rejects negative counts and timings and areas outside the grid.
+func (s *Scenario) validate() error {
+	for i, wave := range s.Taxis {
+		if wave.Count < 0 || wave.At.Duration < 0 || wave.Interval.Duration < 0 {
+			return fmt.Errorf("taxi wave %d: count, at and interval must not be negative", i)
+		}
+		if err := checkArea(wave.Area); err != nil {
+			return fmt.Errorf("taxi wave %d: %w", i, err)
+		}
+	}
+	for i, wave := range s.Rides {
+		if wave.Count < 0 || wave.At.Duration < 0 || wave.Interval.Duration < 0 {
+			return fmt.Errorf("ride wave %d: count, at and interval must not be negative", i)
+		}
+		if wave.RequirementRate < 0 || wave.RequirementRate > 1 {
+			return fmt.Errorf("ride wave %d: requirement_rate must be between 0 and 1", i)
+		}
+		if err := checkArea(wave.From); err != nil {
+			return fmt.Errorf("ride wave %d from: %w", i, err)
+		}
+		if err := checkArea(wave.To); err != nil {
+			return fmt.Errorf("ride wave %d to: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkArea makes sure an area is a proper rectangle inside the grid. nil is allowed.
+func checkArea(area *taxi.Zone) error {
+	if area == nil {
+		return nil
+	}
+	if area.Min.X > area.Max.X || area.Min.Y > area.Max.Y {
+		return fmt.Errorf("area %q has min above max", area.Name)
+	}
+	if !taxi.GridArea.Contains(area.Min) || !taxi.GridArea.Contains(area.Max) {
+		return fmt.Errorf("area %q is outside the grid", area.Name)
+	}
+	return nil
+}
+
+// rng returns the random source for one wave. Each wave gets its own source so waves
+// can run concurrently and still produce the same locations for the same seed.
+func (s *Scenario) rng(stream int) *rand.Rand {
+	if s.Seed == 0 {
+		return rand.New(rand.NewSource(rand.Int63()))
+	}
+	return rand.New(rand.NewSource(s.Seed + int64(stream)))
+}
@@ -0,0 +1,77 @@
+// taxi_client.go - Simulates taxi registration
+// Registers taxis through the Server API following the taxi waves of a Scenario
+
+package sim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/server"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// TaxiClient simulates taxis registering with the system.
+// Calls the Server API to register taxis at the pace set by the scenario.
+type TaxiClient struct {
+	server   *server.Server // Server API gateway
+	scenario *Scenario      // When and where taxis register
+}
+
+// NewTaxiClient creates a TaxiClient that plays the taxi waves of scenario.
+func NewTaxiClient(server *server.Server, scenario *Scenario) *TaxiClient {
+	return &TaxiClient{
+		server:   server,
+		scenario: scenario,
+	}
+}
+
+// Start plays every taxi wave of the scenario; waves run side by side.
+// This method blocks until all registrations are sent.
+func (tc *TaxiClient) Start() {
+	fmt.Println("[TaxiClient] Starting taxi registration...")
+
+	var wg sync.WaitGroup
+	for i, wave := range tc.scenario.Taxis {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tc.runWave(i, wave)
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println("[TaxiClient] All taxi registrations sent")
+}
+
+// runWave registers the taxis of one wave.
+func (tc *TaxiClient) runWave(index int, wave TaxiWave) {
+	clock := tc.server.Clock()
+	rng := tc.scenario.rng(index)
+	clock.Sleep(wave.At.Duration)
+
+	for i := 0; i < wave.Count; i++ {
+		location := taxi.RandomLocation(rng, wave.Area)
+
+		attributes := wave.Attributes
+		if wave.RandomAttributes {
+			// Random mix of features (each of the 4 attribute bits on or off)
+			attributes = taxi.TaxiAttributes(rng.Intn(16))
+		}
+
+		// Call Server API to register taxi
+		taxiID := tc.server.RegisterTaxi(location, attributes)
+		fmt.Printf("[TaxiClient] Registered taxi #%d at (%d, %d)\n",
+			taxiID, location.X, location.Y)
+		if wave.Pool != "" {
+			if err := tc.server.SetTaxiPool(taxiID, wave.Pool); err != nil {
+				fmt.Printf("[TaxiClient] Failed to add taxi #%d to pool %q: %v\n", taxiID, wave.Pool, err)
+			}
+		}
+
+		// Rate limit: wait before the next request (except after last)
+		if i < wave.Count-1 {
+			clock.Sleep(wave.Interval.Duration)
+		}
+	}
+}
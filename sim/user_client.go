@@ -0,0 +1,86 @@
+// user_client.go - Simulates users requesting rides
+// Requests rides through the Server API following the ride waves of a Scenario
+
+package sim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/ride"
+	"github.com/Nart-Tehaucha/TaxiScheduler/server"
+	"github.com/Nart-Tehaucha/TaxiScheduler/taxi"
+)
+
+// UserClient simulates users requesting rides.
+// Calls the Server API to request rides at the pace set by the scenario.
+// Every ride comes from a new client, registered with the Server just before it.
+type UserClient struct {
+	server   *server.Server // Server API gateway
+	scenario *Scenario      // When and where rides are requested
+}
+
+// NewUserClient creates a UserClient that plays the ride waves of scenario.
+func NewUserClient(server *server.Server, scenario *Scenario) *UserClient {
+	return &UserClient{server: server, scenario: scenario}
+}
+
+// Start plays every ride wave of the scenario; waves run side by side.
+// This method blocks until all requests are sent.
+func (uc *UserClient) Start() {
+	fmt.Println("[UserClient] Starting ride requests...")
+
+	var wg sync.WaitGroup
+	for i, wave := range uc.scenario.Rides {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Ride waves use the random streams after the taxi waves
+			uc.runWave(len(uc.scenario.Taxis)+i, wave)
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println("[UserClient] All ride requests sent")
+}
+
+// runWave requests the rides of one wave.
+func (uc *UserClient) runWave(stream int, wave RideWave) {
+	clock := uc.server.Clock()
+	rng := uc.scenario.rng(stream)
+	clock.Sleep(wave.At.Duration)
+
+	for i := 0; i < wave.Count; i++ {
+		clientID, token := uc.server.RegisterClient(fmt.Sprintf("rider %d-%d", stream, i+1))
+		startLocation := taxi.RandomLocation(rng, wave.From)
+		endLocation := taxi.RandomLocation(rng, wave.To)
+
+		// Only a share of riders may need special features
+		requirements := wave.Requirements
+		if wave.RequirementRate > 0 && rng.Float64() >= wave.RequirementRate {
+			requirements = 0
+		}
+
+		// Call Server API to request ride
+		rideID, err := uc.server.RequestRide(ride.RideRequest{
+			Token:         token,
+			StartLocation: startLocation,
+			EndLocation:   endLocation,
+			Requirements:  requirements,
+			Pool:          wave.Pool,
+		})
+		if err != nil {
+			fmt.Printf("[UserClient] Client #%d request rejected: %v\n", clientID, err)
+		} else {
+			fmt.Printf("[UserClient] Client #%d requested ride #%d: (%d,%d) -> (%d,%d)\n",
+				clientID, rideID,
+				startLocation.X, startLocation.Y,
+				endLocation.X, endLocation.Y)
+		}
+
+		// Rate limit: wait before the next request (except after last)
+		if i < wave.Count-1 {
+			clock.Sleep(wave.Interval.Duration)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// clock.go - Simulation clock
+// All sleeps, tickers and timestamps go through a Clock so a run can be sped up
+
+package taxi
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for every component.
+// Durations passed in are in simulated time; a faster clock waits less real time.
+type Clock interface {
+	Now() time.Time                                  // Current simulated time
+	Since(t time.Time) time.Duration                 // Simulated time elapsed since t
+	Sleep(d time.Duration)                           // Pause for d of simulated time
+	NewTicker(d time.Duration) *time.Ticker          // Tick every d of simulated time
+	AfterFunc(d time.Duration, f func()) *time.Timer // Run f after d of simulated time
+	After(d time.Duration) <-chan time.Time          // Fires once after d of simulated time
+}
+
+// ScaledClock runs simulated time at a fixed multiple of real time.
+// A speed of 1 is real time (demo mode); 100 runs the simulation 100x faster.
+type ScaledClock struct {
+	speed float64   // Simulated seconds per real second
+	start time.Time // Real time when the clock was created; simulated time starts here too
+}
+
+// NewRealClock creates a clock that runs at real-time speed.
+func NewRealClock() *ScaledClock {
+	return NewScaledClock(1)
+}
+
+// NewScaledClock creates a clock running speed times faster than real time.
+// Speeds of 0 or below are treated as 1.
+func NewScaledClock(speed float64) *ScaledClock {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &ScaledClock{speed: speed, start: time.Now()}
+}
+
+// Now returns the simulated time: the start time plus scaled elapsed real time.
+func (c *ScaledClock) Now() time.Time {
+	elapsed := time.Since(c.start)
+	return c.start.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// Since returns the simulated time elapsed since t.
+func (c *ScaledClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep pauses for d of simulated time.
+func (c *ScaledClock) Sleep(d time.Duration) {
+	time.Sleep(c.real(d))
+}
+
+// NewTicker returns a ticker that fires every d of simulated time.
+func (c *ScaledClock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(c.real(d))
+}
+
+// AfterFunc runs f after d of simulated time.
+func (c *ScaledClock) AfterFunc(d time.Duration, f func()) *time.Timer {
+	return time.AfterFunc(c.real(d), f)
+}
+
+// After returns a channel that fires once after d of simulated time.
+func (c *ScaledClock) After(d time.Duration) <-chan time.Time {
+	return time.After(c.real(d))
+}
+
+// real converts a simulated duration into the real duration to wait.
+// Never returns less than 1ns, since tickers reject non-positive intervals.
+func (c *ScaledClock) real(d time.Duration) time.Duration {
+	scaled := time.Duration(float64(d) / c.speed)
+	if scaled <= 0 {
+		return 1
+	}
+	return scaled
+}
+
+// ManualClock only moves when Advance is called, so a whole run can be stepped
+// through deterministically (see RunEndToEnd). Sleeps, timers and tickers fire
+// when Advance moves the clock past their deadline.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*clockWaiter // Sleeps, timers and tickers not yet due
+}
+
+// clockWaiter is one pending deadline on a ManualClock.
+type clockWaiter struct {
+	at    time.Time       // When it fires
+	every time.Duration   // Tickers fire again after this long; 0 fires once
+	fire  func(time.Time) // Called without the clock lock held
+}
+
+// NewManualClock creates a clock standing still at start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the current simulated time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the simulated time elapsed since t.
+func (c *ManualClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep blocks until the clock has been advanced by d.
+func (c *ManualClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	c.schedule(d, 0, func(time.Time) { close(done) })
+	<-done
+}
+
+// NewTicker returns a ticker that fires every d of advanced time.
+// The ticker only satisfies the Clock interface: Stop stops nothing, so the
+// clock keeps offering ticks (dropped while the last one is unread) until it is discarded.
+func (c *ManualClock) NewTicker(d time.Duration) *time.Ticker {
+	if d <= 0 {
+		d = 1
+	}
+	ticks := make(chan time.Time, 1)
+	ticker := time.NewTicker(time.Hour)
+	ticker.Stop()
+	ticker.C = ticks
+	c.schedule(d, d, func(t time.Time) {
+		select {
+		case ticks <- t:
+		default:
+		}
+	})
+	return ticker
+}
+
+// AfterFunc runs f in its own goroutine once the clock has been advanced by d.
+// The returned timer only satisfies the Clock interface; stopping it does not cancel f.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) *time.Timer {
+	c.schedule(d, 0, func(time.Time) { go f() })
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return timer
+}
+
+// After returns a channel that fires once the clock has been advanced by d.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.schedule(d, 0, func(t time.Time) { ch <- t })
+	return ch
+}
+
+// Advance moves the clock forward by d and fires everything that became due, earliest first.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due []*clockWaiter
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		due = append(due, w)
+		if w.every > 0 {
+			// A ticker fires once per Advance however far it moved, like a slow reader would see
+			next := *w
+			for !next.at.After(now) {
+				next.at = next.at.Add(w.every)
+			}
+			waiting = append(waiting, &next)
+		}
+	}
+	c.waiters = waiting
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, w := range due {
+		w.fire(now)
+	}
+}
+
+// Waiters returns how many sleeps, timers and tickers are waiting for the clock to move.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Settle gives the goroutines woken by the last Advance real time to run until they
+// are all blocked on the clock again: the number of waiters and goroutines stays the
+// same for a few checks in a row. Gives up after a second so a busy loop cannot hang the run.
+func (c *ManualClock) Settle() {
+	const stableChecks = 3
+	deadline := time.Now().Add(time.Second)
+	lastWaiters, lastGoroutines, stable := -1, -1, 0
+	for stable < stableChecks && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Microsecond)
+		waiters, goroutines := c.Waiters(), runtime.NumGoroutine()
+		if waiters == lastWaiters && goroutines == lastGoroutines {
+			stable++
+		} else {
+			stable = 0
+		}
+		lastWaiters, lastGoroutines = waiters, goroutines
+	}
+}
+
+// schedule registers fire to run once the clock reaches d from now.
+// A deadline that is already due fires straight away.
+func (c *ManualClock) schedule(d, every time.Duration, fire func(time.Time)) {
+	c.mu.Lock()
+	if d > 0 {
+		c.waiters = append(c.waiters, &clockWaiter{at: c.now.Add(d), every: every, fire: fire})
+		c.mu.Unlock()
+		return
+	}
+	now := c.now
+	if every > 0 {
+		c.waiters = append(c.waiters, &clockWaiter{at: now.Add(every), every: every, fire: fire})
+	}
+	c.mu.Unlock()
+	fire(now)
+}
+
+// Duration is a time.Duration that reads and writes as a string like "5s" or "1m30s" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string such as "500ms" or "2m".
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string such as "5s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
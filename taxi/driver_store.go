@@ -0,0 +1,156 @@
+// driver_store.go - Thread-safe driver storage
+// Keeps driver profiles and which taxi each one drives
+
+package taxi
+
+import (
+	"sort"
+	"sync"
+)
+
+// DriverStore holds all drivers with concurrent access protection.
+// Each taxi has at most one driver; byTaxi keeps that link in both directions.
+// All public methods are safe for concurrent access from multiple goroutines.
+// Read methods return copies, so the *Driver pointers never leave the store.
+type DriverStore struct {
+	mu      sync.RWMutex    // Read-write mutex for concurrent access
+	drivers map[int]*Driver // Map from driver ID to Driver pointer
+	byTaxi  map[int]int     // Taxi ID -> ID of the driver assigned to it
+	ids     IDGenerator     // Hands out new driver IDs
+}
+
+// NewDriverStore creates and returns an initialized DriverStore that takes IDs from ids.
+func NewDriverStore(ids IDGenerator) *DriverStore {
+	return &DriverStore{
+		drivers: make(map[int]*Driver),
+		byTaxi:  make(map[int]int),
+		ids:     ids,
+	}
+}
+
+// Add inserts a new driver, not yet assigned to a taxi, and returns its assigned ID.
+func (ds *DriverStore) Add(name, license, phone string) int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	id := ds.ids.NextID()
+	ds.drivers[id] = &Driver{ID: id, Name: name, License: license, Phone: phone}
+	return id
+}
+
+// Get returns a copy of the driver with the given ID.
+// Returns false if the driver was not found.
+func (ds *DriverStore) Get(id int) (Driver, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	driver, exists := ds.drivers[id]
+	if !exists {
+		return Driver{}, false
+	}
+	return *driver, true
+}
+
+// ForTaxi returns a copy of the driver assigned to a taxi.
+// Returns false if the taxi has no driver.
+func (ds *DriverStore) ForTaxi(taxiID int) (Driver, bool) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	id, assigned := ds.byTaxi[taxiID]
+	if !assigned {
+		return Driver{}, false
+	}
+	return *ds.drivers[id], true
+}
+
+// GetAll returns copies of every driver, ordered by ID.
+func (ds *DriverStore) GetAll() []Driver {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	drivers := make([]Driver, 0, len(ds.drivers))
+	for _, driver := range ds.drivers {
+		drivers = append(drivers, *driver)
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].ID < drivers[j].ID })
+	return drivers
+}
+
+// Update replaces a driver's profile details; the taxi assignment is kept.
+// Returns false if the driver was not found.
+func (ds *DriverStore) Update(id int, name, license, phone string) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	driver, exists := ds.drivers[id]
+	if !exists {
+		return false
+	}
+	driver.Name, driver.License, driver.Phone = name, license, phone
+	return true
+}
+
+// Assign puts a driver in a taxi (taxiID 0 takes them out of their taxi).
+// The driver leaves any taxi they drove before.
+// Returns the ID of the driver currently in that taxi if it is someone else (the
+// assignment is then refused), and false if the driver was not found.
+func (ds *DriverStore) Assign(id, taxiID int) (int, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	driver, exists := ds.drivers[id]
+	if !exists {
+		return 0, false
+	}
+	if other, taken := ds.byTaxi[taxiID]; taxiID != 0 && taken && other != id {
+		return other, true
+	}
+	if driver.TaxiID != 0 {
+		delete(ds.byTaxi, driver.TaxiID)
+	}
+	driver.TaxiID = taxiID
+	if taxiID != 0 {
+		ds.byTaxi[taxiID] = id
+	}
+	return 0, true
+}
+
+// ReleaseTaxi takes the driver out of a taxi, e.g. because the taxi was deleted.
+// Returns the ID of the released driver, or false if the taxi had none.
+func (ds *DriverStore) ReleaseTaxi(taxiID int) (int, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	id, assigned := ds.byTaxi[taxiID]
+	if !assigned {
+		return 0, false
+	}
+	delete(ds.byTaxi, taxiID)
+	ds.drivers[id].TaxiID = 0
+	return id, true
+}
+
+// Remove deletes a driver from the store, taking them out of their taxi.
+// Returns false if the driver was not found.
+func (ds *DriverStore) Remove(id int) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	driver, exists := ds.drivers[id]
+	if !exists {
+		return false
+	}
+	if driver.TaxiID != 0 {
+		delete(ds.byTaxi, driver.TaxiID)
+	}
+	delete(ds.drivers, id)
+	return true
+}
+
+// Count returns the total number of drivers in the store.
+func (ds *DriverStore) Count() int {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return len(ds.drivers)
+}
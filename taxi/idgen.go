@@ -0,0 +1,100 @@
+// idgen.go - ID generation
+// Pluggable generators for taxi and ride IDs
+
+package taxi
+
+import (
+	"sync"
+	"time"
+)
+
+// IDGenerator hands out unique IDs. Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NextID() int
+}
+
+// SequentialIDGenerator returns 1, 2, 3, ... (or start, start+1, ... when restoring state).
+type SequentialIDGenerator struct {
+	mu   sync.Mutex // Protects next
+	next int        // The ID returned by the next call
+}
+
+// NewSequentialIDGenerator creates a generator whose first ID is start.
+// Use 1 for a fresh system, or one past the highest restored ID.
+func NewSequentialIDGenerator(start int) *SequentialIDGenerator {
+	return &SequentialIDGenerator{next: start}
+}
+
+// NextID returns the next ID in sequence.
+func (g *SequentialIDGenerator) NextID() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.next
+	g.next++
+	return id
+}
+
+// Advance makes sure every future ID is greater than past.
+// Used after restoring rides so new IDs do not collide with the restored ones.
+func (g *SequentialIDGenerator) Advance(past int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next <= past {
+		g.next = past + 1
+	}
+}
+
+// Snowflake layout: 41 bits of milliseconds since snowflakeEpoch, 10 bits of node ID,
+// 12 bits of per-millisecond sequence. IDs from different nodes never collide.
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxNode      = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+)
+
+// snowflakeEpoch is the zero point for snowflake timestamps (2024-01-01 UTC).
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeIDGenerator creates time-ordered IDs that are unique across nodes,
+// for deployments where several servers create rides at once.
+// Uses real wall-clock time, not the simulation Clock, so IDs stay unique across restarts.
+type SnowflakeIDGenerator struct {
+	mu       sync.Mutex // Protects lastMs and sequence
+	node     int        // This node's ID (0-1023)
+	lastMs   int64      // Millisecond of the last generated ID
+	sequence int        // Counter within lastMs
+}
+
+// NewSnowflakeIDGenerator creates a generator for the given node (0-1023).
+// Node IDs outside that range are wrapped into it.
+func NewSnowflakeIDGenerator(node int) *SnowflakeIDGenerator {
+	return &SnowflakeIDGenerator{node: node & snowflakeMaxNode}
+}
+
+// NextID returns a new snowflake ID.
+// If more than 4096 IDs are requested within one millisecond, it waits for the next one.
+func (g *SnowflakeIDGenerator) NextID() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Since(snowflakeEpoch).Milliseconds()
+	if now == g.lastMs {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSequence
+		if g.sequence == 0 {
+			// Sequence exhausted for this millisecond, spin until the next one
+			for now <= g.lastMs {
+				now = time.Since(snowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = now
+
+	return int(now<<(snowflakeNodeBits+snowflakeSequenceBits) |
+		int64(g.node)<<snowflakeSequenceBits |
+		int64(g.sequence))
+}
@@ -1,10 +1,11 @@
 // location.go - Distance calculation service
 // Provides utilities for calculating distances between locations
 
-package main
+package taxi
 
 // LocationService handles distance calculations between locations.
 // Uses Manhattan distance for simplicity (grid-based movement).
+// It is the default Router: every location is reachable and there are no obstacles.
 type LocationService struct{}
 
 // NewLocationService creates a new LocationService instance.
@@ -29,3 +30,31 @@ func (ls *LocationService) CalculateDistance(from, to Location) int {
 
 	return xDist + yDist
 }
+
+// Route returns an L-shaped path: first along X, then along Y.
+func (ls *LocationService) Route(from, to Location) []Location {
+	path := []Location{from}
+	current := from
+
+	for current.X != to.X {
+		current.X += sign(to.X - current.X)
+		path = append(path, current)
+	}
+	for current.Y != to.Y {
+		current.Y += sign(to.Y - current.Y)
+		path = append(path, current)
+	}
+	return path
+}
+
+// sign returns -1, 0 or 1 depending on the sign of n.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
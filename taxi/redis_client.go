@@ -0,0 +1,220 @@
+// redis_client.go - Minimal Redis client
+// Speaks just enough of the RESP protocol for RedisTaxiStore, over one connection,
+// so the shared fleet store needs nothing beyond the standard library
+
+package taxi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisDialTimeout bounds how long connecting to Redis may take (real time).
+const redisDialTimeout = 5 * time.Second
+
+// redisIOTimeout bounds how long sending a command (or pipeline) and reading its reply
+// may take (real time), so a stalled server cannot hold the client forever.
+const redisIOTimeout = 5 * time.Second
+
+// redisError is an error reply sent by the Redis server, such as "WRONGTYPE ...".
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient sends commands to one Redis server over a single connection.
+// Commands from different goroutines take turns; a broken or timed out connection
+// is dropped and dialed again on the next command.
+type redisClient struct {
+	addr    string        // host:port of the server
+	timeout time.Duration // Deadline of each command or pipeline (0 = none)
+	mu      sync.Mutex    // Protects conn and rd, and serializes commands
+	conn    net.Conn      // Current connection (nil until the first command or after an error)
+	rd      *bufio.Reader // Buffered reader over conn
+}
+
+// dialRedis connects to the Redis server at addr and checks that it answers.
+func dialRedis(addr string) (*redisClient, error) {
+	client := &redisClient{addr: addr, timeout: redisIOTimeout}
+	if _, err := client.do("PING"); err != nil {
+		return nil, fmt.Errorf("connecting to redis at %s: %w", addr, err)
+	}
+	return client, nil
+}
+
+// do sends one command and returns its reply: a string, an int64, nil, or a []any of those.
+// An error reply from the server is returned as a redisError.
+func (c *redisClient) do(args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roundTrip(args...)
+}
+
+// tx runs fn with the connection to itself, so a WATCH/MULTI/EXEC sequence is never
+// interleaved with commands from other goroutines. Any WATCH still active when fn
+// returns (because fn gave up before EXEC) is cleared.
+func (c *redisClient) tx(fn func(do func(args ...string) (any, error)) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := fn(c.roundTrip)
+	if c.conn != nil {
+		c.roundTrip("UNWATCH")
+	}
+	return err
+}
+
+// pipeline sends every command at once, then reads their replies in order, so the batch
+// costs a single round trip. Error replies come back as redisError values among the
+// replies; the error result is for a broken connection.
+func (c *redisClient) pipeline(commands [][]string) ([]any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(c.conn)
+	for _, args := range commands {
+		writeRESP(w, args) // Errors surface on Flush
+	}
+	if err := w.Flush(); err != nil {
+		c.drop()
+		return nil, err
+	}
+	replies := make([]any, len(commands))
+	for i := range replies {
+		reply, err := readRESP(c.rd)
+		if err != nil {
+			c.drop()
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// connect dials the server if there is no connection yet, and gives the connection a
+// fresh deadline for the next command. Must be called with c.mu held.
+func (c *redisClient) connect() error {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.addr, redisDialTimeout)
+		if err != nil {
+			return err
+		}
+		c.conn, c.rd = conn, bufio.NewReader(conn)
+	}
+
+	deadline := time.Time{}
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.drop()
+		return err
+	}
+	return nil
+}
+
+// roundTrip writes one command and reads its reply. Must be called with c.mu held.
+func (c *redisClient) roundTrip(args ...string) (any, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	if err := writeRESP(c.conn, args); err != nil {
+		c.drop()
+		return nil, err
+	}
+	reply, err := readRESP(c.rd)
+	if err != nil {
+		c.drop()
+		return nil, err
+	}
+	if replyErr, failed := reply.(redisError); failed {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// drop closes a connection that failed, so the next command dials a fresh one.
+func (c *redisClient) drop() {
+	c.conn.Close()
+	c.conn, c.rd = nil, nil
+}
+
+// writeRESP sends a command as a RESP array of bulk strings.
+func writeRESP(w io.Writer, args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readRESP reads one reply. Error replies are returned as redisError values rather
+// than errors, since they can also appear inside arrays (e.g. the results of EXEC);
+// the error result is only for broken connections and malformed replies.
+func readRESP(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err // $-1 is a nil reply
+		}
+		data := make([]byte, size+2) // Payload plus the trailing \r\n
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err // *-1 is a nil reply (e.g. EXEC after a WATCH conflict)
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = readRESP(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// redisStrings converts an array reply of bulk strings into a []string.
+func redisStrings(reply any) []string {
+	items, _ := reply.([]any)
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
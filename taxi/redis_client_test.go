@@ -0,0 +1,62 @@
+package taxi
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenStalled accepts connections and reads from them, but never answers.
+func listenStalled(t testing.TB) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				for {
+					if _, err := rd.ReadByte(); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestRedisClientGivesUpOnAStalledServer(t *testing.T) {
+	client := &redisClient{addr: listenStalled(t), timeout: 100 * time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.do("PING")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("PING to a server that never answers succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PING hung on a stalled server")
+	}
+
+	// The client lock is free again, and the broken connection was dropped
+	if _, err := client.pipeline([][]string{{"PING"}, {"PING"}}); err == nil {
+		t.Fatal("pipeline to a server that never answers succeeded")
+	}
+	if client.conn != nil {
+		t.Error("timed out connection kept for the next command")
+	}
+}
@@ -0,0 +1,706 @@
+// redis_store.go - Redis-backed taxi storage
+// Keeps the fleet in Redis so several server instances can share it:
+// one hash per taxi, a GEO set of available taxis for nearby lookups,
+// and a pub/sub channel that carries every change to all instances
+
+package taxi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// redisTxRetries is how often a transaction is retried when another instance
+	// changed the same taxi between WATCH and EXEC.
+	redisTxRetries = 10
+
+	// GeoDegreesPerUnit maps one grid unit onto the GEO set's longitude/latitude.
+	// The grid sits next to (0, 0), where a degree is about the same length both ways.
+	GeoDegreesPerUnit = 0.001
+
+	// geoMetersPerUnit is one grid unit in GEO distance, rounded up so search boxes
+	// never cut off a taxi right at the edge.
+	geoMetersPerUnit = 112
+
+	// redisResubscribeDelay is how long to wait before reconnecting the change feed (real time).
+	redisResubscribeDelay = time.Second
+)
+
+// redisTaxi is a taxi as stored in its hash, plus the store's bookkeeping for it.
+type redisTaxi struct {
+	Taxi
+	idleInMaintenance bool // In maintenance without a ride, so it becomes available when maintenance ends
+}
+
+// RedisTaxiStore is a TaxiStorage kept in Redis (6.2 or newer, for GEOSEARCH).
+// Servers started with the same address and prefix share one fleet: taxi IDs come from
+// a shared counter, reservations are atomic across instances (WATCH/MULTI/EXEC), and
+// subscribers see changes made by every instance.
+// Keys, all starting with prefix:
+//
+//	<prefix>:next-taxi-id  Counter handing out taxi IDs
+//	<prefix>:taxis         Set of all taxi IDs
+//	<prefix>:taxi:<id>     Hash with one taxi's state
+//	<prefix>:available     GEO set of available taxis at their locations
+//	<prefix>:changes       Pub/sub channel of TaxiChangedEvent JSON
+//
+// Like TaxiStore, the methods report problems as false or empty results; Redis
+// errors are logged.
+type RedisTaxiStore struct {
+	client      *redisClient    // Connection for commands
+	prefix      string          // Namespace of every key
+	clock       Clock           // For recording when taxis become idle
+	mu          sync.Mutex      // Protects listening
+	subscribers taxiSubscribers // Notified of every change on the feed
+	listening   bool            // The change feed goroutine is running
+}
+
+// NewRedisTaxiStore connects to the Redis server at addr and uses the keys under prefix.
+// Returns an error if Redis cannot be reached.
+func NewRedisTaxiStore(addr, prefix string, clock Clock) (*RedisTaxiStore, error) {
+	client, err := dialRedis(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisTaxiStore{client: client, prefix: prefix, clock: clock}, nil
+}
+
+// Add inserts a new taxi at the given location and returns its assigned ID.
+// Like TaxiStore.Add, the taxi starts available, fully charged and top rated.
+// Returns 0 if Redis failed.
+func (rt *RedisTaxiStore) Add(location Location, attributes TaxiAttributes) int {
+	reply, err := rt.client.do("INCR", rt.key("next-taxi-id"))
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to allocate a taxi ID: %v\n", err)
+		return 0
+	}
+	id := int(reply.(int64))
+
+	taxi := redisTaxi{Taxi: Taxi{
+		ID:          id,
+		Location:    location,
+		IsAvailable: true,
+		Attributes:  attributes,
+		IdleSince:   rt.clock.Now(),
+		Rating:      MaxTaxiRating,
+		EnergyLevel: 100,
+	}}
+	err = rt.client.tx(func(do func(args ...string) (any, error)) error {
+		return rt.write(do, taxi, "SADD", rt.key("taxis"), strconv.Itoa(id))
+	})
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to add taxi #%d: %v\n", id, err)
+		return 0
+	}
+	rt.publish(TaxiAdded, taxi.Taxi)
+	return id
+}
+
+// Get returns a copy of the taxi with the given ID.
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) Get(id int) (Taxi, bool) {
+	taxi, exists := rt.load(rt.client.do, id)
+	return taxi.Taxi, exists
+}
+
+// GetAllAvailable returns copies of all taxis that can accept rides, ordered by ID.
+func (rt *RedisTaxiStore) GetAllAvailable() []Taxi {
+	reply, err := rt.client.do("ZRANGE", rt.key("available"), "0", "-1")
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to list available taxis: %v\n", err)
+		return []Taxi{}
+	}
+	available := rt.loadAll(redisStrings(reply), true)
+	sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
+	return available
+}
+
+// GetAll returns copies of every taxi, ordered by ID.
+func (rt *RedisTaxiStore) GetAll() []Taxi {
+	reply, err := rt.client.do("SMEMBERS", rt.key("taxis"))
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to list taxis: %v\n", err)
+		return []Taxi{}
+	}
+	taxis := rt.loadAll(redisStrings(reply), false)
+	sort.Slice(taxis, func(i, j int) bool { return taxis[i].ID < taxis[j].ID })
+	return taxis
+}
+
+// Snapshot returns every taxi as it is now: the taxis' hashes are read in one
+// MULTI/EXEC transaction, which starts over if a taxi was added or removed since the
+// list of taxis was read (WATCH). Returns an empty snapshot if Redis failed.
+func (rt *RedisTaxiStore) Snapshot() TaxiSnapshot {
+	var taxis []Taxi
+	err := rt.client.tx(func(do func(args ...string) (any, error)) error {
+		for attempt := 0; attempt < redisTxRetries; attempt++ {
+			if _, err := do("WATCH", rt.key("taxis")); err != nil {
+				return err
+			}
+			members, err := do("SMEMBERS", rt.key("taxis"))
+			if err != nil {
+				return err
+			}
+			if _, err := do("MULTI"); err != nil {
+				return err
+			}
+			ids := redisStrings(members)
+			for _, member := range ids {
+				if _, err := do("HGETALL", rt.key("taxi:"+member)); err != nil {
+					return err
+				}
+			}
+			reply, err := do("EXEC")
+			if err != nil {
+				return err
+			}
+			hashes, ok := reply.([]any)
+			if !ok {
+				continue // The fleet changed in between
+			}
+			taxis = make([]Taxi, 0, len(hashes))
+			for _, hash := range hashes {
+				if taxi, exists := decodeRedisTaxi(redisStrings(hash)); exists {
+					taxis = append(taxis, taxi.Taxi)
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("the fleet kept changing")
+	})
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to snapshot taxis: %v\n", err)
+		return newTaxiSnapshot(nil)
+	}
+	return newTaxiSnapshot(taxis)
+}
+
+// ReserveBest finds the available taxi with the highest score for a pickup at start
+// and marks it unavailable (see TaxiStore.ReserveBest).
+// With a maxDistance, only taxis in the GEO box around start are fetched, which assumes
+// no route is shorter than the straight Manhattan distance.
+// Candidates are reserved best first (ties to the lowest ID); one taken by another
+// instance in the meantime is skipped for the next best. One that moved since it was
+// scored is not taken: the search starts over, so the distance returned still holds.
+func (rt *RedisTaxiStore) ReserveBest(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (Taxi, int, bool) {
+	for {
+		candidates, ok := rt.rankCandidates(start, router, maxDistance, eligible, score)
+		if !ok {
+			return Taxi{}, 0, false
+		}
+
+		moved := false
+		for _, c := range candidates {
+			// Checked in the same transaction as the reservation (see update)
+			taxi, ok := rt.Reserve(c.taxi.ID, func(taxi Taxi) bool {
+				if taxi.Location != c.taxi.Location {
+					moved = true
+					return false
+				}
+				return eligible(taxi)
+			})
+			if ok {
+				return taxi, c.distance, true
+			}
+			if moved {
+				break
+			}
+		}
+		if !moved {
+			return Taxi{}, 0, false
+		}
+	}
+}
+
+// redisCandidate is an available taxi ReserveBest may take, with its distance to the
+// pickup and its score.
+type redisCandidate struct {
+	taxi     Taxi
+	distance int
+	score    float64
+}
+
+// rankCandidates returns the available taxis eligible for a pickup at start, best
+// first (see ReserveBest), or false if Redis could not be searched.
+func (rt *RedisTaxiStore) rankCandidates(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) ([]redisCandidate, bool) {
+	var reply any
+	var err error
+	if maxDistance > 0 {
+		lon, lat := geoPosition(start)
+		side := strconv.Itoa((2*maxDistance + 1) * geoMetersPerUnit)
+		reply, err = rt.client.do("GEOSEARCH", rt.key("available"), "FROMLONLAT", lon, lat, "BYBOX", side, side, "m")
+	} else {
+		reply, err = rt.client.do("ZRANGE", rt.key("available"), "0", "-1")
+	}
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to search available taxis: %v\n", err)
+		return nil, false
+	}
+
+	var candidates []redisCandidate
+	for _, taxi := range rt.loadAll(redisStrings(reply), true) {
+		if !eligible(taxi) {
+			continue
+		}
+		distance := router.CalculateDistance(taxi.Location, start)
+		if distance == Unreachable || (maxDistance > 0 && distance > maxDistance) {
+			continue
+		}
+		candidates = append(candidates, redisCandidate{taxi: taxi, distance: distance, score: score(taxi, distance)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return betterCandidate(candidates[i].score, candidates[i].taxi.ID, candidates[j].score, candidates[j].taxi.ID)
+	})
+	return candidates, true
+}
+
+// Reserve marks a specific taxi unavailable, but only if it is currently available
+// and accepted by eligible. Checking and reserving happen in one transaction.
+// Returns a copy of the reserved taxi, or false if it is busy, unsuitable or not found.
+func (rt *RedisTaxiStore) Reserve(id int, eligible func(Taxi) bool) (Taxi, bool) {
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		if !taxi.IsAvailable || !eligible(taxi.Taxi) {
+			return false
+		}
+		taxi.setAvailable(false, rt.clock.Now())
+		return true
+	})
+	if ok {
+		rt.publish(AvailabilityChanged, taxi)
+	}
+	return taxi, ok
+}
+
+// SetAvailability updates a taxi's availability status, with the same maintenance
+// handling as TaxiStore.SetAvailability.
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) SetAvailability(id int, available bool) bool {
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		available := available
+		if taxi.InMaintenance {
+			taxi.idleInMaintenance = available
+			available = false
+		}
+		taxi.setAvailable(available, rt.clock.Now())
+		return true
+	})
+	if ok {
+		rt.publish(AvailabilityChanged, taxi)
+	}
+	return ok
+}
+
+// SetMaintenance puts a taxi into maintenance (on) or takes it out again
+// (see TaxiStore.SetMaintenance).
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) SetMaintenance(id int, on bool) bool {
+	changed := false
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		wasAvailable := taxi.IsAvailable
+		switch {
+		case on && !taxi.InMaintenance:
+			taxi.InMaintenance = true
+			taxi.idleInMaintenance = taxi.IsAvailable
+			taxi.setAvailable(false, rt.clock.Now())
+		case !on && taxi.InMaintenance:
+			taxi.InMaintenance = false
+			if taxi.idleInMaintenance {
+				taxi.setAvailable(true, rt.clock.Now())
+			}
+			taxi.idleInMaintenance = false
+		}
+		changed = taxi.IsAvailable != wasAvailable
+		return true
+	})
+	if ok && changed {
+		rt.publish(AvailabilityChanged, taxi)
+	}
+	return ok
+}
+
+// SetRating updates a taxi's driver rating (1 to 5 stars).
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) SetRating(id int, rating float64) bool {
+	_, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		taxi.Rating = rating
+		return true
+	})
+	return ok
+}
+
+// SetEnergyLevel updates how much fuel or charge a taxi has left (0 to 100 percent).
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) SetEnergyLevel(id int, level int) bool {
+	_, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		taxi.EnergyLevel = level
+		return true
+	})
+	return ok
+}
+
+// SetPool moves a taxi into a dispatch pool ("" = general fleet).
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) SetPool(id int, pool string) bool {
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		taxi.Pool = pool
+		return true
+	})
+	if ok {
+		rt.publish(PoolChanged, taxi)
+	}
+	return ok
+}
+
+// UpdateLocation updates a taxi's location.
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) UpdateLocation(id int, location Location) bool {
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		taxi.Location = location
+		return true
+	})
+	if ok {
+		rt.publish(LocationChanged, taxi)
+	}
+	return ok
+}
+
+// MoveIfAvailable updates a taxi's location, but only while it is available.
+// Returns false if the taxi is busy or was not found.
+func (rt *RedisTaxiStore) MoveIfAvailable(id int, location Location) bool {
+	taxi, ok := rt.update(id, func(taxi *redisTaxi) bool {
+		if !taxi.IsAvailable {
+			return false
+		}
+		taxi.Location = location
+		return true
+	})
+	if ok {
+		rt.publish(LocationChanged, taxi)
+	}
+	return ok
+}
+
+// Remove deletes a taxi from the store.
+// Returns false if the taxi was not found.
+func (rt *RedisTaxiStore) Remove(id int) bool {
+	var removed redisTaxi
+	found := false
+	err := rt.client.tx(func(do func(args ...string) (any, error)) error {
+		for attempt := 0; attempt < redisTxRetries; attempt++ {
+			if _, err := do("WATCH", rt.taxiKey(id)); err != nil {
+				return err
+			}
+			if removed, found = rt.load(do, id); !found {
+				return nil
+			}
+			member := strconv.Itoa(id)
+			for _, command := range [][]string{
+				{"MULTI"},
+				{"DEL", rt.taxiKey(id)},
+				{"SREM", rt.key("taxis"), member},
+				{"ZREM", rt.key("available"), member},
+			} {
+				if _, err := do(command...); err != nil {
+					return err
+				}
+			}
+			if reply, err := do("EXEC"); err != nil || reply != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("taxi #%d kept changing", id)
+	})
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to remove taxi #%d: %v\n", id, err)
+		return false
+	}
+	if found {
+		rt.publish(TaxiRemoved, removed.Taxi)
+	}
+	return found
+}
+
+// Count returns the total number of taxis in the store.
+func (rt *RedisTaxiStore) Count() int {
+	reply, err := rt.client.do("SCARD", rt.key("taxis"))
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to count taxis: %v\n", err)
+		return 0
+	}
+	return int(reply.(int64))
+}
+
+// Subscribe returns a channel that receives an event for every taxi change made by
+// any instance sharing the store. The first call subscribes to the change channel and
+// waits until Redis has confirmed it, so no later change is missed.
+// As with TaxiStore, a subscriber that falls behind gets every event later, in order.
+// Changes made while the feed is reconnecting after a lost connection are not delivered.
+func (rt *RedisTaxiStore) Subscribe() <-chan TaxiChangedEvent {
+	ch := rt.subscribers.subscribe()
+	rt.mu.Lock()
+	start := !rt.listening
+	rt.listening = true
+	rt.mu.Unlock()
+
+	if start {
+		ready := make(chan struct{})
+		go rt.listen(ready)
+		select {
+		case <-ready:
+		case <-time.After(redisDialTimeout):
+			log.Printf("[RedisTaxiStore] WARNING: Change feed not confirmed yet, early changes may be missed\n")
+		}
+	}
+	return ch
+}
+
+// Unsubscribe stops sending changes to a channel returned by Subscribe and closes it
+// (see TaxiStore.Unsubscribe). The change feed keeps running for the other subscribers.
+func (rt *RedisTaxiStore) Unsubscribe(changes <-chan TaxiChangedEvent) {
+	rt.subscribers.unsubscribe(changes)
+}
+
+// listen relays the change channel to the subscribers, reconnecting whenever the
+// connection drops. ready is closed once the first subscription is confirmed.
+// Runs as a goroutine for the lifetime of the store.
+func (rt *RedisTaxiStore) listen(ready chan struct{}) {
+	for {
+		err := rt.listenOnce(func() {
+			if ready != nil {
+				close(ready)
+				ready = nil
+			}
+		})
+		log.Printf("[RedisTaxiStore] ERROR: Change feed lost: %v, reconnecting\n", err)
+		time.Sleep(redisResubscribeDelay)
+	}
+}
+
+// listenOnce subscribes on a connection of its own and relays messages until it fails.
+// confirmed is called once Redis has acknowledged the subscription.
+func (rt *RedisTaxiStore) listenOnce(confirmed func()) error {
+	feed := &redisClient{addr: rt.client.addr, timeout: redisIOTimeout}
+	if _, err := feed.do("SUBSCRIBE", rt.key("changes")); err != nil {
+		return err
+	}
+	// Changes can be far apart, so only the subscription itself had a deadline
+	if err := feed.conn.SetDeadline(time.Time{}); err != nil {
+		feed.drop()
+		return err
+	}
+	confirmed()
+
+	for {
+		reply, err := readRESP(feed.rd)
+		if err != nil {
+			feed.drop()
+			return err
+		}
+		message := redisStrings(reply)
+		if len(message) != 3 || message[0] != "message" {
+			continue
+		}
+		var event TaxiChangedEvent
+		if err := json.Unmarshal([]byte(message[2]), &event); err != nil {
+			log.Printf("[RedisTaxiStore] WARNING: Ignoring malformed change event: %v\n", err)
+			continue
+		}
+
+		rt.subscribers.publish(event)
+	}
+}
+
+// publish announces a change on the shared change channel.
+func (rt *RedisTaxiStore) publish(kind TaxiChangeKind, taxi Taxi) {
+	data, err := json.Marshal(TaxiChangedEvent{
+		Kind:        kind,
+		TaxiID:      taxi.ID,
+		Location:    taxi.Location,
+		IsAvailable: taxi.IsAvailable,
+	})
+	if err == nil {
+		_, err = rt.client.do("PUBLISH", rt.key("changes"), string(data))
+	}
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to publish change of taxi #%d: %v\n", taxi.ID, err)
+	}
+}
+
+// update applies change to a taxi in a WATCH/MULTI/EXEC transaction, starting over
+// when another instance modified the taxi in between. change returns false to leave
+// the taxi untouched.
+// Returns a copy of the updated taxi, or false if it was not found, change declined,
+// or Redis failed.
+func (rt *RedisTaxiStore) update(id int, change func(taxi *redisTaxi) bool) (Taxi, bool) {
+	var updated redisTaxi
+	applied := false
+	err := rt.client.tx(func(do func(args ...string) (any, error)) error {
+		for attempt := 0; attempt < redisTxRetries; attempt++ {
+			if _, err := do("WATCH", rt.taxiKey(id)); err != nil {
+				return err
+			}
+			taxi, exists := rt.load(do, id)
+			if !exists || !change(&taxi) {
+				return nil
+			}
+			if _, err := do("MULTI"); err != nil {
+				return err
+			}
+			if err := rt.write(do, taxi); err != nil {
+				return err
+			}
+			reply, err := do("EXEC")
+			if err != nil {
+				return err
+			}
+			if reply != nil {
+				updated, applied = taxi, true
+				return nil
+			}
+		}
+		return fmt.Errorf("taxi #%d kept changing", id)
+	})
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to update taxi #%d: %v\n", id, err)
+		return Taxi{}, false
+	}
+	return updated.Taxi, applied
+}
+
+// write queues the commands storing a taxi: its hash, and its place in the GEO set of
+// available taxis. extra is one more command to queue with them (may be empty).
+// Inside MULTI the commands are only queued; Add runs them as its own transaction.
+func (rt *RedisTaxiStore) write(do func(args ...string) (any, error), taxi redisTaxi, extra ...string) error {
+	member := strconv.Itoa(taxi.ID)
+	commands := [][]string{append([]string{"HSET", rt.taxiKey(taxi.ID)}, encodeRedisTaxi(taxi)...)}
+	if taxi.IsAvailable {
+		lon, lat := geoPosition(taxi.Location)
+		commands = append(commands, []string{"GEOADD", rt.key("available"), lon, lat, member})
+	} else {
+		commands = append(commands, []string{"ZREM", rt.key("available"), member})
+	}
+	if len(extra) > 0 {
+		commands = append([][]string{{"MULTI"}}, append(commands, extra, []string{"EXEC"})...)
+	}
+	for _, command := range commands {
+		if _, err := do(command...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads one taxi's hash. Returns false if the taxi does not exist or Redis failed.
+func (rt *RedisTaxiStore) load(do func(args ...string) (any, error), id int) (redisTaxi, bool) {
+	reply, err := do("HGETALL", rt.taxiKey(id))
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to load taxi #%d: %v\n", id, err)
+		return redisTaxi{}, false
+	}
+	return decodeRedisTaxi(redisStrings(reply))
+}
+
+// loadAll reads the taxis with the given IDs in one pipeline, skipping any removed in the meantime.
+// With onlyAvailable, taxis reserved in the meantime are skipped too.
+func (rt *RedisTaxiStore) loadAll(ids []string, onlyAvailable bool) []Taxi {
+	commands := make([][]string, 0, len(ids))
+	for _, member := range ids {
+		if id, err := strconv.Atoi(member); err == nil {
+			commands = append(commands, []string{"HGETALL", rt.taxiKey(id)})
+		}
+	}
+	taxis := make([]Taxi, 0, len(commands))
+	if len(commands) == 0 {
+		return taxis
+	}
+
+	replies, err := rt.client.pipeline(commands)
+	if err != nil {
+		log.Printf("[RedisTaxiStore] ERROR: Failed to load taxis: %v\n", err)
+		return taxis
+	}
+	for _, reply := range replies {
+		if taxi, exists := decodeRedisTaxi(redisStrings(reply)); exists && (taxi.IsAvailable || !onlyAvailable) {
+			taxis = append(taxis, taxi.Taxi)
+		}
+	}
+	return taxis
+}
+
+// key returns the name of one of the store's keys.
+func (rt *RedisTaxiStore) key(name string) string {
+	return rt.prefix + ":" + name
+}
+
+// taxiKey returns the name of a taxi's hash.
+func (rt *RedisTaxiStore) taxiKey(id int) string {
+	return rt.key("taxi:" + strconv.Itoa(id))
+}
+
+// geoPosition converts a grid location into GEO longitude and latitude.
+func geoPosition(location Location) (string, string) {
+	lon := strconv.FormatFloat(float64(location.X)*GeoDegreesPerUnit, 'f', -1, 64)
+	lat := strconv.FormatFloat(float64(location.Y)*GeoDegreesPerUnit, 'f', -1, 64)
+	return lon, lat
+}
+
+// encodeRedisTaxi turns a taxi into HSET field/value pairs.
+func encodeRedisTaxi(taxi redisTaxi) []string {
+	flag := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return []string{
+		"id", strconv.Itoa(taxi.ID),
+		"x", strconv.Itoa(taxi.Location.X),
+		"y", strconv.Itoa(taxi.Location.Y),
+		"available", flag(taxi.IsAvailable),
+		"attributes", strconv.FormatUint(uint64(taxi.Attributes), 10),
+		"in_maintenance", flag(taxi.InMaintenance),
+		"idle_in_maintenance", flag(taxi.idleInMaintenance),
+		"idle_since", taxi.IdleSince.Format(time.RFC3339Nano),
+		"idle_time", strconv.FormatInt(int64(taxi.IdleTime), 10),
+		"rating", strconv.FormatFloat(taxi.Rating, 'f', -1, 64),
+		"energy_level", strconv.Itoa(taxi.EnergyLevel),
+		"pool", taxi.Pool,
+	}
+}
+
+// decodeRedisTaxi rebuilds a taxi from HGETALL's alternating fields and values.
+// Returns false for an empty reply (no such taxi).
+func decodeRedisTaxi(pairs []string) (redisTaxi, bool) {
+	if len(pairs) == 0 {
+		return redisTaxi{}, false
+	}
+	fields := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fields[pairs[i]] = pairs[i+1]
+	}
+
+	number := func(name string) int {
+		value, _ := strconv.Atoi(fields[name])
+		return value
+	}
+	var taxi redisTaxi
+	taxi.ID = number("id")
+	taxi.Location = Location{X: number("x"), Y: number("y")}
+	taxi.IsAvailable = fields["available"] == "1"
+	taxi.Attributes = TaxiAttributes(number("attributes"))
+	taxi.InMaintenance = fields["in_maintenance"] == "1"
+	taxi.idleInMaintenance = fields["idle_in_maintenance"] == "1"
+	taxi.IdleSince, _ = time.Parse(time.RFC3339Nano, fields["idle_since"])
+	idleTime, _ := strconv.ParseInt(fields["idle_time"], 10, 64)
+	taxi.IdleTime = time.Duration(idleTime)
+	taxi.Rating, _ = strconv.ParseFloat(fields["rating"], 64)
+	taxi.EnergyLevel = number("energy_level")
+	taxi.Pool = fields["pool"]
+	return taxi, true
+}
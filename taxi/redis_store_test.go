@@ -0,0 +1,145 @@
+//go:build redis
+
+// Integration tests against a real Redis server (6.2 or newer):
+//
+//	REDIS_ADDR=localhost:6379 go test -tags redis -run Redis .
+
+package taxi
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRedisStores returns n stores sharing one fresh namespace of the Redis server
+// at $REDIS_ADDR (default localhost:6379), like n server instances would.
+func newTestRedisStores(t testing.TB, n int) []*RedisTaxiStore {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	prefix := fmt.Sprintf("taxischeduler-test-%d", time.Now().UnixNano())
+	stores := make([]*RedisTaxiStore, 0, n)
+	for i := 0; i < n; i++ {
+		store, err := NewRedisTaxiStore(addr, prefix, NewManualClock(testStart))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, store)
+	}
+	t.Cleanup(func() {
+		for _, taxi := range stores[0].GetAll() {
+			stores[0].Remove(taxi.ID)
+		}
+		stores[0].client.do("DEL", stores[0].key("next-taxi-id"))
+	})
+	return stores
+}
+
+func TestRedisStoreKeepsTaxis(t *testing.T) {
+	store := newTestRedisStores(t, 1)[0]
+	ids := addTestTaxis(store, 20)
+
+	if count := store.Count(); count != len(ids) {
+		t.Fatalf("Count() = %d, want %d", count, len(ids))
+	}
+	if !store.UpdateLocation(ids[0], Location{X: 7, Y: 8}) || !store.SetAvailability(ids[1], false) {
+		t.Fatal("update failed")
+	}
+	if taxi, ok := store.Get(ids[0]); !ok || taxi.Location != (Location{X: 7, Y: 8}) {
+		t.Errorf("Get(%d) = %+v, %v after the move", ids[0], taxi, ok)
+	}
+	if available := len(store.GetAllAvailable()); available != len(ids)-1 {
+		t.Errorf("%d taxis available, want %d", available, len(ids)-1)
+	}
+	if snapshot := store.Snapshot(); snapshot.Count() != len(ids) || snapshot.CountAvailable() != len(ids)-1 {
+		t.Errorf("snapshot has %d taxis, %d available", snapshot.Count(), snapshot.CountAvailable())
+	}
+	if !store.Remove(ids[2]) || store.Count() != len(ids)-1 {
+		t.Error("Remove did not remove the taxi")
+	}
+}
+
+func TestRedisStoreNeverDoubleBooksAcrossInstances(t *testing.T) {
+	stores := newTestRedisStores(t, 3)
+	ids := addTestTaxis(stores[0], 10)
+	router := NewLocationService()
+
+	var reserved atomic.Int32
+	held := make(map[int]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		for worker := 0; worker < 4; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					taxi, _, ok := store.ReserveBest(Location{X: worker, Y: 0}, router, 0,
+						func(Taxi) bool { return true }, func(_ Taxi, distance int) float64 { return -float64(distance) })
+					if !ok {
+						return
+					}
+					reserved.Add(1)
+					mu.Lock()
+					if held[taxi.ID] {
+						t.Errorf("taxi #%d reserved twice", taxi.ID)
+					}
+					held[taxi.ID] = true
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if int(reserved.Load()) != len(ids) {
+		t.Errorf("%d reservations, want one per taxi (%d)", reserved.Load(), len(ids))
+	}
+}
+
+func TestRedisReserveBestRanksAgainATaxiThatMoved(t *testing.T) {
+	stores := newTestRedisStores(t, 2)
+	id := stores[0].Add(Location{X: 1, Y: 0}, 0)
+	router := NewLocationService()
+
+	// Another instance moves the taxi away after it was scored, before it is reserved
+	moved := false
+	score := func(taxi Taxi, distance int) float64 {
+		if !moved {
+			moved = stores[1].UpdateLocation(id, Location{X: 9, Y: 0})
+		}
+		return -float64(distance)
+	}
+	taxi, distance, ok := stores[0].ReserveBest(Location{}, router, 0, func(Taxi) bool { return true }, score)
+	if !ok || taxi.ID != id {
+		t.Fatalf("ReserveBest = %+v, %v; want taxi #%d", taxi, ok, id)
+	}
+	if taxi.Location != (Location{X: 9, Y: 0}) || distance != 9 {
+		t.Errorf("reserved at %v, %d away; want where it moved to, 9 away", taxi.Location, distance)
+	}
+}
+
+func TestRedisStoreFeedReachesOtherInstances(t *testing.T) {
+	stores := newTestRedisStores(t, 2)
+	changes := stores[1].Subscribe()
+
+	id := stores[0].Add(Location{X: 1, Y: 1}, 0)
+	stores[0].Remove(id)
+
+	for _, want := range []TaxiChangeKind{TaxiAdded, TaxiRemoved} {
+		select {
+		case change := <-changes:
+			if change.Kind != want || change.TaxiID != id {
+				t.Fatalf("got %+v, want %v of taxi #%d", change, want, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v event from the other instance", want)
+		}
+	}
+}
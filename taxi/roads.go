@@ -0,0 +1,119 @@
+// roads.go - Road network editor
+// Lets operators close and reopen streets, make them one-way and set how fast traffic
+// crosses each cell while the system runs, when the Server routes on a GridRouter
+
+package taxi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// directionNames maps the direction names of the road API to grid steps.
+var directionNames = map[string]Direction{"north": North, "east": East, "south": South, "west": West}
+
+// RoadEdit changes one cell of the road network. Fields left out are not changed.
+type RoadEdit struct {
+	Cell    Location `json:"cell"`
+	Blocked *bool    `json:"blocked,omitempty"` // Close (true) or reopen (false) the cell
+	OneWay  *string  `json:"one_way,omitempty"` // Traffic may only leave the cell "north", "east", "south" or "west" ("" = two-way)
+	Speed   *float64 `json:"speed,omitempty"`   // Speed through the cell relative to normal, e.g. 0.5 for half speed (1 = normal)
+}
+
+// OneWayCell is a one-way cell of a RoadNetwork.
+type OneWayCell struct {
+	Cell      Location `json:"cell"`
+	Direction string   `json:"direction"` // The only way out: "north", "east", "south" or "west"
+}
+
+// CellSpeed is a cell of a RoadNetwork that is not at normal speed.
+type CellSpeed struct {
+	Cell  Location `json:"cell"`
+	Speed float64  `json:"speed"` // Relative to normal, e.g. 0.5 for half speed
+}
+
+// RoadNetwork describes every change a GridRouter has from an open grid.
+type RoadNetwork struct {
+	Width   int          `json:"width"`
+	Height  int          `json:"height"`
+	Version uint64       `json:"version"` // Changes with every edit
+	Blocked []Location   `json:"blocked"` // Impassable cells
+	OneWay  []OneWayCell `json:"one_way"` // Cells that can only be left in one direction
+	Speeds  []CellSpeed  `json:"speeds"`  // Cells not at normal speed
+}
+
+// Network returns the grid's road network, every list ordered by cell.
+func (gr *GridRouter) Network() RoadNetwork {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	network := RoadNetwork{Width: gr.width, Height: gr.height, Version: gr.version,
+		Blocked: make([]Location, 0, len(gr.blocked)),
+		OneWay:  make([]OneWayCell, 0, len(gr.oneWay)),
+		Speeds:  make([]CellSpeed, 0, len(gr.speeds)),
+	}
+	for cell := range gr.blocked {
+		network.Blocked = append(network.Blocked, cell)
+	}
+	for cell, dir := range gr.oneWay {
+		for name, named := range directionNames {
+			if named == dir {
+				network.OneWay = append(network.OneWay, OneWayCell{Cell: cell, Direction: name})
+			}
+		}
+	}
+	for cell, speed := range gr.speeds {
+		network.Speeds = append(network.Speeds, CellSpeed{Cell: cell, Speed: speed})
+	}
+
+	sort.Slice(network.Blocked, func(i, j int) bool { return CellBefore(network.Blocked[i], network.Blocked[j]) })
+	sort.Slice(network.OneWay, func(i, j int) bool { return CellBefore(network.OneWay[i].Cell, network.OneWay[j].Cell) })
+	sort.Slice(network.Speeds, func(i, j int) bool { return CellBefore(network.Speeds[i].Cell, network.Speeds[j].Cell) })
+	return network
+}
+
+// CellBefore orders cells by X, then Y.
+func CellBefore(a, b Location) bool {
+	if a.X != b.X {
+		return a.X < b.X
+	}
+	return a.Y < b.Y
+}
+
+// Validate checks that an edit can be applied to the grid.
+func (gr *GridRouter) Validate(edit RoadEdit) error {
+	if edit.Cell.X < 0 || edit.Cell.Y < 0 || edit.Cell.X >= gr.width || edit.Cell.Y >= gr.height {
+		return fmt.Errorf("cell (%d, %d) is outside the %dx%d grid", edit.Cell.X, edit.Cell.Y, gr.width, gr.height)
+	}
+	if edit.OneWay != nil && *edit.OneWay != "" {
+		if _, known := directionNames[*edit.OneWay]; !known {
+			return fmt.Errorf("cell (%d, %d): unknown direction %q, want north, east, south, west or \"\"", edit.Cell.X, edit.Cell.Y, *edit.OneWay)
+		}
+	}
+	if edit.Speed != nil && (*edit.Speed <= 0 || math.IsInf(*edit.Speed, 0) || math.IsNaN(*edit.Speed)) {
+		return fmt.Errorf("cell (%d, %d): speed must be positive, got %v", edit.Cell.X, edit.Cell.Y, *edit.Speed)
+	}
+	return nil
+}
+
+// Apply makes the changes of a validated edit.
+func (gr *GridRouter) Apply(edit RoadEdit) {
+	if edit.Blocked != nil {
+		if *edit.Blocked {
+			gr.Block(edit.Cell)
+		} else {
+			gr.Unblock(edit.Cell)
+		}
+	}
+	if edit.OneWay != nil {
+		if *edit.OneWay == "" {
+			gr.SetTwoWay(edit.Cell)
+		} else {
+			gr.SetOneWay(edit.Cell, directionNames[*edit.OneWay])
+		}
+	}
+	if edit.Speed != nil {
+		gr.SetCellSpeed(edit.Cell, *edit.Speed)
+	}
+}
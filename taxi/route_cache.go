@@ -0,0 +1,202 @@
+// route_cache.go - Distance caching
+// An LRU cache in front of any Router, so repeated distance lookups skip the path search
+
+package taxi
+
+import (
+	"container/list"
+	"sync"
+)
+
+// versionedRouter is implemented by routers whose road network can change.
+// The version must change on every modification, so caches know to start over.
+type versionedRouter interface {
+	NetworkVersion() uint64
+}
+
+// editLogRouter is implemented by versioned routers that can tell which cells changed,
+// so caches only drop the distances those changes could affect (see GridRouter.EditsSince).
+type editLogRouter interface {
+	versionedRouter
+	EditsSince(version uint64) ([]NetworkEdit, uint64, bool)
+}
+
+// routeKey identifies one distance lookup.
+type routeKey struct {
+	from Location
+	to   Location
+}
+
+// cachedDistance is one LRU entry.
+type cachedDistance struct {
+	key      routeKey // Lookup this entry answers
+	distance int      // Distance returned by the wrapped router (may be Unreachable)
+}
+
+// RouteCacheStats describes how well a CachingRouter is doing.
+type RouteCacheStats struct {
+	Size          int     `json:"size"`          // Entries currently cached
+	Capacity      int     `json:"capacity"`      // Maximum number of entries
+	Hits          int     `json:"hits"`          // Lookups answered from the cache
+	Misses        int     `json:"misses"`        // Lookups passed to the wrapped router
+	Evictions     int     `json:"evictions"`     // Entries dropped to make room
+	Invalidations int     `json:"invalidations"` // Times the whole cache was cleared
+	Stale         int     `json:"stale"`         // Entries dropped because a road edit could have changed them
+	HitRate       float64 `json:"hit_rate"`      // Hits / (Hits + Misses), 0 before any lookup
+}
+
+// CachingRouter wraps a Router and remembers the most recently used distances.
+// Routes are not cached; they are only needed occasionally and can be long.
+// If the wrapped router's network changes (see versionedRouter) the cache drops the
+// distances the change could affect, or everything if the router cannot say which
+// cells changed; call Invalidate after changing any other kind of router.
+// All methods are safe for concurrent access.
+type CachingRouter struct {
+	router   Router                     // Does the actual calculations
+	capacity int                        // Maximum number of cached distances
+	mu       sync.Mutex                 // Protects everything below
+	entries  map[routeKey]*list.Element // Lookup -> element in order
+	order    *list.List                 // Most recently used at the front
+	version  uint64                     // Network version the entries were computed for
+	stats    RouteCacheStats            // Running counters
+}
+
+// NewCachingRouter creates a cache holding up to capacity distances in front of router.
+func NewCachingRouter(router Router, capacity int) *CachingRouter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	cr := &CachingRouter{
+		router:   router,
+		capacity: capacity,
+		entries:  make(map[routeKey]*list.Element),
+		order:    list.New(),
+	}
+	if versioned, ok := router.(versionedRouter); ok {
+		cr.version = versioned.NetworkVersion()
+	}
+	return cr
+}
+
+// CalculateDistance returns the cached distance, asking the wrapped router on a miss.
+func (cr *CachingRouter) CalculateDistance(from, to Location) int {
+	key := routeKey{from: from, to: to}
+
+	cr.mu.Lock()
+	cr.checkVersion()
+	version := cr.version
+	if element, cached := cr.entries[key]; cached {
+		cr.order.MoveToFront(element)
+		cr.stats.Hits++
+		distance := element.Value.(*cachedDistance).distance
+		cr.mu.Unlock()
+		return distance
+	}
+	cr.stats.Misses++
+	cr.mu.Unlock()
+
+	// Calculate without holding the lock; concurrent misses for one key just both compute it
+	distance := cr.router.CalculateDistance(from, to)
+
+	// A distance computed while the network changed may already be stale, so it is not kept
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.checkVersion()
+	if _, cached := cr.entries[key]; !cached && cr.version == version {
+		cr.entries[key] = cr.order.PushFront(&cachedDistance{key: key, distance: distance})
+		if cr.order.Len() > cr.capacity {
+			oldest := cr.order.Back()
+			cr.order.Remove(oldest)
+			delete(cr.entries, oldest.Value.(*cachedDistance).key)
+			cr.stats.Evictions++
+		}
+	}
+	return distance
+}
+
+// Route passes straight through to the wrapped router.
+func (cr *CachingRouter) Route(from, to Location) []Location {
+	return cr.router.Route(from, to)
+}
+
+// Unwrap returns the router the cache sits in front of.
+func (cr *CachingRouter) Unwrap() Router {
+	return cr.router
+}
+
+// Invalidate forgets every cached distance, e.g. after the road network changed.
+func (cr *CachingRouter) Invalidate() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.clear()
+}
+
+// Stats returns a snapshot of the cache counters.
+func (cr *CachingRouter) Stats() RouteCacheStats {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	stats := cr.stats
+	stats.Size = cr.order.Len()
+	stats.Capacity = cr.capacity
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// checkVersion brings the cache up to date if the wrapped router's network has changed.
+// Must be called with cr.mu held.
+func (cr *CachingRouter) checkVersion() {
+	versioned, ok := cr.router.(versionedRouter)
+	if !ok {
+		return
+	}
+	version := versioned.NetworkVersion()
+	if version == cr.version {
+		return
+	}
+	if logged, ok := cr.router.(editLogRouter); ok {
+		if edits, current, known := logged.EditsSince(cr.version); known {
+			cr.version = current
+			cr.dropAffected(edits)
+			return
+		}
+	}
+	cr.version = version
+	cr.clear()
+}
+
+// dropAffected removes every entry one of the edits could have changed.
+// A route can only get longer through a cell it crosses, and shorter through a cell
+// it could cross at a cost of at most its distance; both mean the cheapest conceivable
+// detour through the cell (Manhattan steps at the lowest cell cost) is no longer than
+// the cached distance. Unreachable entries are dropped by any edit that opens a cell.
+// Must be called with cr.mu held.
+func (cr *CachingRouter) dropAffected(edits []NetworkEdit) {
+	straight := NewLocationService()
+	for key, element := range cr.entries {
+		distance := element.Value.(*cachedDistance).distance
+		for _, edit := range edits {
+			affected := edit.Opens
+			if distance != Unreachable {
+				detour := straight.CalculateDistance(key.from, edit.Cell) + straight.CalculateDistance(edit.Cell, key.to)
+				affected = float64(detour)*edit.MinCost <= float64(distance)+0.5 // Distances are rounded
+			}
+			if affected {
+				cr.order.Remove(element)
+				delete(cr.entries, key)
+				cr.stats.Stale++
+				break
+			}
+		}
+	}
+}
+
+// clear drops every entry and counts an invalidation.
+// Must be called with cr.mu held.
+func (cr *CachingRouter) clear() {
+	cr.entries = make(map[routeKey]*list.Element)
+	cr.order.Init()
+	cr.stats.Invalidations++
+}
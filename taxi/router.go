@@ -0,0 +1,365 @@
+// router.go - Pluggable routing engines
+// Defines the Router interface and a street-grid implementation with obstacles
+
+package taxi
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Unreachable is returned by Router.CalculateDistance when no path exists.
+const Unreachable = -1
+
+// Router calculates distances and routes between locations.
+// LocationService is the default (pure Manhattan distance);
+// GridRouter models a street network with blocked cells and one-way streets.
+type Router interface {
+	// CalculateDistance returns the travel distance, or Unreachable if there is no path.
+	CalculateDistance(from, to Location) int
+	// Route returns the cells visited from "from" to "to" (both included), or nil if unreachable.
+	Route(from, to Location) []Location
+}
+
+// RoutedDistance returns the router's distance between two points,
+// falling back to Manhattan distance when the router finds no path.
+// Used where a number is always needed, such as ride durations and fares.
+func RoutedDistance(router Router, from, to Location) int {
+	distance := router.CalculateDistance(from, to)
+	if distance == Unreachable {
+		fmt.Printf("[Router] No route (%d,%d) -> (%d,%d), using straight distance\n",
+			from.X, from.Y, to.X, to.Y)
+		return NewLocationService().CalculateDistance(from, to)
+	}
+	return distance
+}
+
+// TripStops lists the stops of a trip in driving order: pickup, waypoints, destination.
+func TripStops(start Location, waypoints []Location, end Location) []Location {
+	stops := make([]Location, 0, len(waypoints)+2)
+	stops = append(stops, start)
+	stops = append(stops, waypoints...)
+	return append(stops, end)
+}
+
+// LegDistances returns the routed distance of each leg between consecutive stops
+// (see RoutedDistance).
+func LegDistances(router Router, stops []Location) []int {
+	legs := make([]int, 0, len(stops))
+	for i := 1; i < len(stops); i++ {
+		legs = append(legs, RoutedDistance(router, stops[i-1], stops[i]))
+	}
+	return legs
+}
+
+// TripDistance returns the routed distance through every stop, in order.
+func TripDistance(router Router, stops []Location) int {
+	total := 0
+	for _, leg := range LegDistances(router, stops) {
+		total += leg
+	}
+	return total
+}
+
+// Direction is a single step on the grid.
+type Direction Location
+
+var (
+	North = Direction{X: 0, Y: 1}
+	South = Direction{X: 0, Y: -1}
+	East  = Direction{X: 1, Y: 0}
+	West  = Direction{X: -1, Y: 0}
+)
+
+// directions lists every possible step, in the order the path search tries them.
+var directions = []Direction{North, East, South, West}
+
+// maxNetworkEdits is how many recent changes a GridRouter remembers for EditsSince.
+const maxNetworkEdits = 1024
+
+// NetworkEdit is one change to a GridRouter's road network, as seen by EditsSince.
+type NetworkEdit struct {
+	Version uint64   // Network version the change produced
+	Cell    Location // Cell that changed
+	Opens   bool     // True if the change may make routes shorter (reopened, sped up, two-way again)
+	MinCost float64  // Lowest cost of crossing any cell, before or after the change
+}
+
+// GridRouter finds the fastest paths on a bounded grid using Dijkstra's algorithm.
+// Cells can be blocked (impassable), one-way (can only be left in one direction) or
+// have their own speed. Crossing a cell at normal speed costs one distance unit; one
+// at speed 0.5 costs two, so distances are free-flow equivalents and ride times, ETAs
+// and fares all account for slow streets.
+// The network may be changed while rides are running; all methods are safe for concurrent access.
+type GridRouter struct {
+	width   int                    // Grid spans X in [0, width)
+	height  int                    // Grid spans Y in [0, height)
+	mu      sync.RWMutex           // Protects everything below
+	blocked map[Location]bool      // Impassable cells
+	oneWay  map[Location]Direction // Cells that can only be left in the given direction
+	speeds  map[Location]float64   // Relative speed of cells that are not at 1 (normal)
+	version uint64                 // Bumped on every change, so caches can tell (see CachingRouter)
+	edits   []NetworkEdit          // The last maxNetworkEdits changes, oldest first
+}
+
+// NewGridRouter creates a width x height grid with no obstacles.
+func NewGridRouter(width, height int) *GridRouter {
+	return &GridRouter{
+		width:   width,
+		height:  height,
+		blocked: make(map[Location]bool),
+		oneWay:  make(map[Location]Direction),
+		speeds:  make(map[Location]float64),
+	}
+}
+
+// Block marks a cell as impassable.
+func (gr *GridRouter) Block(cell Location) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	gr.blocked[cell] = true
+	gr.record(cell, false, gr.minCost())
+}
+
+// Unblock makes a blocked cell passable again.
+func (gr *GridRouter) Unblock(cell Location) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	delete(gr.blocked, cell)
+	gr.record(cell, true, gr.minCost())
+}
+
+// SetOneWay makes a cell one-way: traffic may only leave it in the given direction.
+func (gr *GridRouter) SetOneWay(cell Location, dir Direction) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	_, wasOneWay := gr.oneWay[cell] // Turning a one-way street around opens the other direction
+	gr.oneWay[cell] = dir
+	gr.record(cell, wasOneWay, gr.minCost())
+}
+
+// SetTwoWay lets traffic leave a one-way cell in every direction again.
+func (gr *GridRouter) SetTwoWay(cell Location) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	delete(gr.oneWay, cell)
+	gr.record(cell, true, gr.minCost())
+}
+
+// SetCellSpeed sets how fast traffic crosses a cell relative to normal, e.g. 0.5 for
+// half speed or 2 for twice as fast; 1 restores normal speed. speed must be positive.
+func (gr *GridRouter) SetCellSpeed(cell Location, speed float64) {
+	gr.mu.Lock()
+	defer gr.mu.Unlock()
+	previous, before := gr.speedAt(cell), gr.minCost()
+	if speed == 1 {
+		delete(gr.speeds, cell)
+	} else {
+		gr.speeds[cell] = speed
+	}
+	gr.record(cell, speed > previous, min(before, gr.minCost()))
+}
+
+// NetworkVersion returns a number that changes whenever the network is modified.
+func (gr *GridRouter) NetworkVersion() uint64 {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+	return gr.version
+}
+
+// EditsSince returns the changes made after version and the version they bring the
+// network to. Returns false if some of them are no longer remembered.
+func (gr *GridRouter) EditsSince(version uint64) ([]NetworkEdit, uint64, bool) {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+	if len(gr.edits) == 0 || version+1 < gr.edits[0].Version {
+		return nil, gr.version, version == gr.version
+	}
+	first := int(version + 1 - gr.edits[0].Version)
+	return append([]NetworkEdit(nil), gr.edits[min(first, len(gr.edits)):]...), gr.version, true
+}
+
+// CalculateDistance returns the cost of the fastest path, or Unreachable.
+// With every cell at normal speed that is the number of steps.
+func (gr *GridRouter) CalculateDistance(from, to Location) int {
+	route, cost := gr.search(from, to)
+	if route == nil {
+		return Unreachable
+	}
+	return int(math.Round(cost))
+}
+
+// Route returns the fastest path from "from" to "to".
+// Returns nil if either end is off the grid or blocked, or no path exists.
+func (gr *GridRouter) Route(from, to Location) []Location {
+	route, _ := gr.search(from, to)
+	return route
+}
+
+// search runs Dijkstra's algorithm from "from" until it reaches "to".
+// Entering a cell costs 1 / its speed. Returns the path and its cost, or nil if unreachable.
+func (gr *GridRouter) search(from, to Location) ([]Location, float64) {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+
+	if !gr.passable(from) || !gr.passable(to) {
+		return nil, 0
+	}
+
+	// Indexed by cell (y*width + x): the cheapest known cost of reaching each cell (+Inf
+	// until reached) and the cell it was reached from, to rebuild the path at the end
+	costs := make([]float64, gr.width*gr.height)
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	cameFrom := make([]Location, gr.width*gr.height)
+	costs[gr.index(from)] = 0
+	queue := routeQueue{{cell: from}}
+
+	for len(queue) > 0 {
+		current := queue.pop()
+		if current.cost > costs[gr.index(current.cell)] {
+			continue // Reached more cheaply since this step was queued
+		}
+		if current.cell == to {
+			return gr.buildPath(cameFrom, from, to), current.cost
+		}
+
+		for _, dir := range directions {
+			// One-way cells can only be left in their allowed direction
+			if allowed, oneWay := gr.oneWay[current.cell]; oneWay && allowed != dir {
+				continue
+			}
+
+			next := Location{X: current.cell.X + dir.X, Y: current.cell.Y + dir.Y}
+			if !gr.passable(next) {
+				continue
+			}
+			cost := current.cost + 1/gr.speedAt(next)
+			if costs[gr.index(next)] <= cost {
+				continue
+			}
+			costs[gr.index(next)] = cost
+			cameFrom[gr.index(next)] = current.cell
+			queue.push(routeStep{cell: next, cost: cost})
+		}
+	}
+
+	return nil, 0
+}
+
+// String describes the grid, mainly for log lines.
+func (gr *GridRouter) String() string {
+	gr.mu.RLock()
+	defer gr.mu.RUnlock()
+	return fmt.Sprintf("GridRouter(%dx%d, %d blocked, %d one-way, %d with own speed)",
+		gr.width, gr.height, len(gr.blocked), len(gr.oneWay), len(gr.speeds))
+}
+
+// passable reports whether a cell is on the grid and not blocked.
+// Must be called with gr.mu held.
+func (gr *GridRouter) passable(cell Location) bool {
+	if cell.X < 0 || cell.Y < 0 || cell.X >= gr.width || cell.Y >= gr.height {
+		return false
+	}
+	return !gr.blocked[cell]
+}
+
+// index returns the position of a cell on the grid in the path search's slices.
+func (gr *GridRouter) index(cell Location) int {
+	return cell.Y*gr.width + cell.X
+}
+
+// speedAt returns a cell's relative speed (1 unless set).
+// Must be called with gr.mu held.
+func (gr *GridRouter) speedAt(cell Location) float64 {
+	if speed, set := gr.speeds[cell]; set {
+		return speed
+	}
+	return 1
+}
+
+// minCost returns the lowest cost of crossing any cell.
+// Must be called with gr.mu held.
+func (gr *GridRouter) minCost() float64 {
+	fastest := 1.0
+	for _, speed := range gr.speeds {
+		fastest = max(fastest, speed)
+	}
+	return 1 / fastest
+}
+
+// record bumps the version for a change to cell and remembers it for EditsSince.
+// Must be called with gr.mu held for writing.
+func (gr *GridRouter) record(cell Location, opens bool, minCost float64) {
+	gr.version++
+	gr.edits = append(gr.edits, NetworkEdit{Version: gr.version, Cell: cell, Opens: opens, MinCost: minCost})
+	if len(gr.edits) > maxNetworkEdits {
+		gr.edits = append([]NetworkEdit(nil), gr.edits[len(gr.edits)-maxNetworkEdits:]...)
+	}
+}
+
+// routeStep is a cell waiting in the path search, with the cost of reaching it.
+type routeStep struct {
+	cell Location
+	cost float64
+}
+
+// routeQueue is a binary min-heap of the path search's cells, cheapest first.
+// Typed rather than container/heap, which would allocate for every cell.
+type routeQueue []routeStep
+
+// push adds a step.
+func (q *routeQueue) push(step routeStep) {
+	*q = append(*q, step)
+	heap := *q
+	for i := len(heap) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if heap[parent].cost <= heap[i].cost {
+			break
+		}
+		heap[parent], heap[i] = heap[i], heap[parent]
+		i = parent
+	}
+}
+
+// pop removes and returns the cheapest step. The queue must not be empty.
+func (q *routeQueue) pop() routeStep {
+	heap := *q
+	cheapest := heap[0]
+	last := len(heap) - 1
+	heap[0] = heap[last]
+	heap = heap[:last]
+	for i := 0; ; {
+		smallest, left, right := i, 2*i+1, 2*i+2
+		if left < len(heap) && heap[left].cost < heap[smallest].cost {
+			smallest = left
+		}
+		if right < len(heap) && heap[right].cost < heap[smallest].cost {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		heap[i], heap[smallest] = heap[smallest], heap[i]
+		i = smallest
+	}
+	*q = heap
+	return cheapest
+}
+
+// buildPath walks cameFrom backwards from "to" and returns the path in travel order.
+func (gr *GridRouter) buildPath(cameFrom []Location, from, to Location) []Location {
+	path := []Location{to}
+	for current := to; current != from; {
+		current = cameFrom[gr.index(current)]
+		path = append(path, current)
+	}
+
+	// Reverse so the path starts at "from"
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,74 @@
+// scoring.go - Taxi assignment scoring
+// Ranks candidate taxis for a ride by more than pickup distance alone
+
+package taxi
+
+import (
+	"fmt"
+	"time"
+)
+
+// Taxi ratings are between MinTaxiRating and MaxTaxiRating stars; new taxis start at the top.
+const (
+	MinTaxiRating = 1.0
+	MaxTaxiRating = 5.0
+)
+
+// ScoringWeights decide how much each factor counts when picking a taxi for a ride.
+// A taxi's score is
+//
+//	-Distance*pickup distance + IdleTime*minutes idle + Rating*rating stars + Energy*energy percent
+//
+// and the eligible taxi with the highest score is assigned.
+// With only Distance set, the closest taxi wins as before.
+type ScoringWeights struct {
+	Distance float64 `json:"distance"`  // Penalty per unit of pickup distance
+	IdleTime float64 `json:"idle_time"` // Bonus per minute the taxi has been waiting for a ride
+	Rating   float64 `json:"rating"`    // Bonus per rating star
+	Energy   float64 `json:"energy"`    // Bonus per percent of fuel or charge left
+}
+
+// DefaultScoringWeights returns the weights used until SetScoringWeights is called:
+// 10 minutes of waiting, 2.5 rating stars or 50% energy are each worth 5 units of pickup distance.
+func DefaultScoringWeights() ScoringWeights {
+	return ScoringWeights{
+		Distance: 1,
+		IdleTime: 0.5,
+		Rating:   2,
+		Energy:   0.1,
+	}
+}
+
+// ScoreBreakdown is a taxi's score split into the contribution of each factor.
+type ScoreBreakdown struct {
+	Distance float64 // -weight * pickup distance
+	IdleTime float64 // weight * minutes idle
+	Rating   float64 // weight * rating stars
+	Energy   float64 // weight * energy percent
+}
+
+// Total returns the overall score; higher is better.
+func (b ScoreBreakdown) Total() float64 {
+	return b.Distance + b.IdleTime + b.Rating + b.Energy
+}
+
+// String formats the breakdown for log lines.
+func (b ScoreBreakdown) String() string {
+	return fmt.Sprintf("%.2f = distance %.2f + idle %.2f + rating %.2f + energy %.2f",
+		b.Total(), b.Distance, b.IdleTime, b.Rating, b.Energy)
+}
+
+// Score rates a taxi that is distance units away from a pickup at time now.
+// Only available taxis earn idle time.
+func (w ScoringWeights) Score(taxi Taxi, distance int, now time.Time) ScoreBreakdown {
+	idleMinutes := 0.0
+	if taxi.IsAvailable && !taxi.IdleSince.IsZero() {
+		idleMinutes = now.Sub(taxi.IdleSince).Minutes()
+	}
+	return ScoreBreakdown{
+		Distance: -w.Distance * float64(distance),
+		IdleTime: w.IdleTime * idleMinutes,
+		Rating:   w.Rating * taxi.Rating,
+		Energy:   w.Energy * float64(taxi.EnergyLevel),
+	}
+}
@@ -0,0 +1,211 @@
+// sharded_store.go - Sharded in-memory taxi storage
+// Spreads the fleet across several TaxiStores, each with its own lock, so thousands
+// of goroutines updating different taxis do not all queue on one RWMutex
+
+package taxi
+
+import "sort"
+
+// ShardedTaxiStore is a TaxiStorage that keeps each taxi in one of N TaxiStore shards,
+// chosen by its ID. Calls about one taxi only lock its shard; GetAll, GetAllAvailable,
+// Count and ReserveBest visit every shard in turn, one lock at a time. Only Snapshot
+// holds every shard's lock at once.
+// The shards share one list of subscribers and one set of contention stats, so
+// Subscribe and Stats cover the whole fleet, and each taxi's events stay in order.
+// All public methods are safe for concurrent access from multiple goroutines.
+type ShardedTaxiStore struct {
+	shards      []*TaxiStore     // Taxi ID % len(shards) -> shard holding the taxi
+	ids         IDGenerator      // Hands out new taxi IDs
+	subscribers *taxiSubscribers // Shared by every shard
+	stats       *storeStats      // Shared by every shard
+}
+
+// NewShardedTaxiStore creates a store with the given number of shards (at least 1)
+// that takes IDs from ids.
+func NewShardedTaxiStore(shards int, ids IDGenerator, clock Clock) *ShardedTaxiStore {
+	store := &ShardedTaxiStore{
+		shards:      make([]*TaxiStore, max(shards, 1)),
+		ids:         ids,
+		subscribers: &taxiSubscribers{},
+		stats:       newStoreStats(),
+	}
+	for i := range store.shards {
+		store.shards[i] = newTaxiStoreShard(store.subscribers, store.stats, clock)
+	}
+	return store
+}
+
+// shard returns the shard holding the taxi with the given ID.
+func (ss *ShardedTaxiStore) shard(id int) *TaxiStore {
+	return ss.shards[uint(id)%uint(len(ss.shards))]
+}
+
+// Add inserts a new taxi at the given location into the shard of its ID and returns the ID.
+func (ss *ShardedTaxiStore) Add(location Location, attributes TaxiAttributes) int {
+	id := ss.ids.NextID()
+	ss.shard(id).insert(id, location, attributes)
+	return id
+}
+
+// Get returns a copy of the taxi with the given ID.
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) Get(id int) (Taxi, bool) {
+	return ss.shard(id).Get(id)
+}
+
+// GetAll returns copies of every taxi, ordered by ID.
+func (ss *ShardedTaxiStore) GetAll() []Taxi {
+	taxis := make([]Taxi, 0)
+	for _, shard := range ss.shards {
+		taxis = append(taxis, shard.GetAll()...)
+	}
+	sort.Slice(taxis, func(i, j int) bool { return taxis[i].ID < taxis[j].ID })
+	return taxis
+}
+
+// Snapshot returns every taxi as it is now. It read-locks every shard, always in the
+// same order, and releases them only once all are copied, so no change is half seen;
+// writers to any shard wait for it meanwhile.
+func (ss *ShardedTaxiStore) Snapshot() TaxiSnapshot {
+	taxis := make([]Taxi, 0)
+	for _, shard := range ss.shards {
+		defer shard.rlock("Snapshot")() // Held until every shard is copied
+		taxis = append(taxis, shard.copyAll()...)
+	}
+	return newTaxiSnapshot(taxis)
+}
+
+// GetAllAvailable returns copies of all taxis that can accept rides, merged from every
+// shard and ordered by ID.
+// Each shard is read at a slightly different moment, so the result is not one snapshot.
+func (ss *ShardedTaxiStore) GetAllAvailable() []Taxi {
+	available := make([]Taxi, 0)
+	for _, shard := range ss.shards {
+		available = append(available, shard.GetAllAvailable()...)
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
+	return available
+}
+
+// Near returns copies of the available taxis within radius of center, merged from the
+// spatial index of every shard, in no particular order.
+func (ss *ShardedTaxiStore) Near(center Location, radius int) []Taxi {
+	nearby := make([]Taxi, 0)
+	for _, shard := range ss.shards {
+		nearby = append(nearby, shard.Near(center, radius)...)
+	}
+	return nearby
+}
+
+// ReserveBest finds the available taxi with the highest score across all shards (ties
+// to the lowest ID, as in one TaxiStore) and reserves it in its own shard. If another
+// caller reserved or moved that taxi in the meantime, the search starts over, so the
+// same taxi is still never reserved twice.
+// eligible and score are called under a shard lock and must not call back into the store.
+// Returns a copy of the reserved taxi and its distance to start, or false if none are available.
+func (ss *ShardedTaxiStore) ReserveBest(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (Taxi, int, bool) {
+	for {
+		var best Taxi
+		bestDistance := 0
+		bestScore := 0.0
+		found := false
+		for _, shard := range ss.shards {
+			taxi, distance, taxiScore, ok := shard.bestCandidate(start, router, maxDistance, eligible, score)
+			if ok && (!found || betterCandidate(taxiScore, taxi.ID, bestScore, best.ID)) {
+				best, bestDistance, bestScore, found = taxi, distance, taxiScore, true
+			}
+		}
+		if !found {
+			return Taxi{}, 0, false
+		}
+
+		// Only take the taxi if it is still where it was scored, so the distance holds
+		reserved, ok := ss.shard(best.ID).Reserve(best.ID, func(taxi Taxi) bool {
+			return taxi.Location == best.Location && eligible(taxi)
+		})
+		if ok {
+			return reserved, bestDistance, true
+		}
+	}
+}
+
+// Reserve marks a specific taxi unavailable, but only if it is currently available
+// and accepted by eligible.
+// Returns a copy of the reserved taxi, or false if it is busy, unsuitable or not found.
+func (ss *ShardedTaxiStore) Reserve(id int, eligible func(Taxi) bool) (Taxi, bool) {
+	return ss.shard(id).Reserve(id, eligible)
+}
+
+// SetAvailability updates a taxi's availability status (see TaxiStore.SetAvailability).
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) SetAvailability(id int, available bool) bool {
+	return ss.shard(id).SetAvailability(id, available)
+}
+
+// SetMaintenance puts a taxi into maintenance (on) or takes it out again (see TaxiStore.SetMaintenance).
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) SetMaintenance(id int, on bool) bool {
+	return ss.shard(id).SetMaintenance(id, on)
+}
+
+// SetRating updates a taxi's driver rating (1 to 5 stars).
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) SetRating(id int, rating float64) bool {
+	return ss.shard(id).SetRating(id, rating)
+}
+
+// SetEnergyLevel updates how much fuel or charge a taxi has left (0 to 100 percent).
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) SetEnergyLevel(id int, level int) bool {
+	return ss.shard(id).SetEnergyLevel(id, level)
+}
+
+// SetPool moves a taxi into a dispatch pool ("" = general fleet).
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) SetPool(id int, pool string) bool {
+	return ss.shard(id).SetPool(id, pool)
+}
+
+// UpdateLocation updates a taxi's location.
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) UpdateLocation(id int, location Location) bool {
+	return ss.shard(id).UpdateLocation(id, location)
+}
+
+// MoveIfAvailable updates a taxi's location, but only while it is available.
+// Returns false if the taxi is busy or was not found.
+func (ss *ShardedTaxiStore) MoveIfAvailable(id int, location Location) bool {
+	return ss.shard(id).MoveIfAvailable(id, location)
+}
+
+// Remove deletes a taxi from the store.
+// Returns false if the taxi was not found.
+func (ss *ShardedTaxiStore) Remove(id int) bool {
+	return ss.shard(id).Remove(id)
+}
+
+// Count returns the total number of taxis in all shards.
+func (ss *ShardedTaxiStore) Count() int {
+	count := 0
+	for _, shard := range ss.shards {
+		count += shard.Count()
+	}
+	return count
+}
+
+// Subscribe returns a channel that receives an event for every taxi change in any shard.
+// As with TaxiStore, a subscriber that falls behind gets every event later, in order.
+func (ss *ShardedTaxiStore) Subscribe() <-chan TaxiChangedEvent {
+	return ss.subscribers.subscribe()
+}
+
+// Unsubscribe stops sending changes to a channel returned by Subscribe and closes it
+// (see TaxiStore.Unsubscribe).
+func (ss *ShardedTaxiStore) Unsubscribe(changes <-chan TaxiChangedEvent) {
+	ss.subscribers.unsubscribe(changes)
+}
+
+// Stats returns the calls and lock contention of every store method, summed over all shards.
+func (ss *ShardedTaxiStore) Stats() StoreStats {
+	return ss.stats.snapshot()
+}
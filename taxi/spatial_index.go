@@ -0,0 +1,64 @@
+// spatial_index.go - Spatial index of taxis
+// Buckets taxis by grid cell, so finding the taxis around a point only looks at the
+// cells near it instead of the whole fleet
+
+package taxi
+
+// spatialCellSize is the width and height of a spatial index cell in grid units.
+const spatialCellSize = 10
+
+// spatialIndex maps grid cells to the taxis in them. It is not safe for concurrent
+// access on its own: the TaxiStore owning it guards it with its lock.
+type spatialIndex struct {
+	cells map[Location]map[int]bool // Cell (location / spatialCellSize) -> IDs of the taxis in it
+}
+
+// newSpatialIndex creates an empty index.
+func newSpatialIndex() *spatialIndex {
+	return &spatialIndex{cells: make(map[Location]map[int]bool)}
+}
+
+// insert adds a taxi at location.
+func (si *spatialIndex) insert(id int, location Location) {
+	cell := GridCell(location, spatialCellSize)
+	if si.cells[cell] == nil {
+		si.cells[cell] = make(map[int]bool)
+	}
+	si.cells[cell][id] = true
+}
+
+// remove drops a taxi last indexed at location.
+func (si *spatialIndex) remove(id int, location Location) {
+	cell := GridCell(location, spatialCellSize)
+	delete(si.cells[cell], id)
+	if len(si.cells[cell]) == 0 {
+		delete(si.cells, cell)
+	}
+}
+
+// move re-indexes a taxi that drove from one location to another.
+func (si *spatialIndex) move(id int, from, to Location) {
+	if GridCell(from, spatialCellSize) == GridCell(to, spatialCellSize) {
+		return
+	}
+	si.remove(id, from)
+	si.insert(id, to)
+}
+
+// candidates returns the IDs of the taxis in every cell the square of radius around
+// center touches: all taxis within radius of it, and some farther ones the caller
+// must filter out.
+func (si *spatialIndex) candidates(center Location, radius int) []int {
+	low := GridCell(Location{X: center.X - radius, Y: center.Y - radius}, spatialCellSize)
+	high := GridCell(Location{X: center.X + radius, Y: center.Y + radius}, spatialCellSize)
+
+	ids := make([]int, 0)
+	for x := low.X; x <= high.X; x++ {
+		for y := low.Y; y <= high.Y; y++ {
+			for id := range si.cells[Location{X: x, Y: y}] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
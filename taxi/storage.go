@@ -0,0 +1,32 @@
+// storage.go - Fleet storage interface
+// The fleet state every component works against, so the in-memory stores can be
+// swapped for other backends (shared, sharded) without touching them
+
+package taxi
+
+// TaxiStorage holds the fleet: every taxi, its location and whether it can take a ride.
+// TaxiStore (in memory) is the default; pass another one as ServerConfig.Taxis.
+// Implementations must be safe for concurrent use, return copies from every read,
+// and make ReserveBest and Reserve atomic, so two callers can never reserve the same taxi.
+// Snapshot must read the whole fleet at one instant, unlike separate calls to GetAll,
+// GetAllAvailable and Count. Unsubscribe closes a channel returned by Subscribe.
+type TaxiStorage interface {
+	Add(location Location, attributes TaxiAttributes) int
+	Get(id int) (Taxi, bool)
+	GetAll() []Taxi
+	GetAllAvailable() []Taxi
+	Snapshot() TaxiSnapshot
+	ReserveBest(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (Taxi, int, bool)
+	Reserve(id int, eligible func(Taxi) bool) (Taxi, bool)
+	SetAvailability(id int, available bool) bool
+	SetMaintenance(id int, on bool) bool
+	SetRating(id int, rating float64) bool
+	SetEnergyLevel(id int, level int) bool
+	SetPool(id int, pool string) bool
+	UpdateLocation(id int, location Location) bool
+	MoveIfAvailable(id int, location Location) bool
+	Remove(id int) bool
+	Count() int
+	Subscribe() <-chan TaxiChangedEvent
+	Unsubscribe(changes <-chan TaxiChangedEvent)
+}
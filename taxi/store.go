@@ -0,0 +1,442 @@
+// store.go - Thread-safe taxi storage
+// Provides concurrent access protection using sync.RWMutex
+
+package taxi
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/Nart-Tehaucha/TaxiScheduler/internal/relay"
+)
+
+// SubscriberBufferSize is how many events a subscriber channel holds before the
+// subscriber counts as falling behind (see Subscribe and SubscribeOptions.Buffer).
+const SubscriberBufferSize = 100
+
+// TaxiStore holds all taxi data with concurrent access protection.
+// Uses a map for O(1) lookup by TaxiID.
+// All public methods are safe for concurrent access from multiple goroutines.
+// Read methods return copies, so the *Taxi pointers never leave the store.
+// This is the default TaxiStorage backend.
+type TaxiStore struct {
+	clock             Clock            // For recording when taxis become idle
+	mu                sync.RWMutex     // Read-write mutex for concurrent access
+	taxis             map[int]*Taxi    // Map from taxi ID to Taxi pointer
+	ids               IDGenerator      // Hands out new taxi IDs
+	subscribers       *taxiSubscribers // Channels notified on every change
+	idleInMaintenance map[int]bool     // Taxis in maintenance without a ride, which become available when it ends
+	stats             *storeStats      // Calls and lock contention per method
+	index             *spatialIndex    // Taxis by grid cell, for Near
+}
+
+// NewTaxiStore creates and returns an initialized TaxiStore that takes IDs from ids.
+func NewTaxiStore(ids IDGenerator, clock Clock) *TaxiStore {
+	store := newTaxiStoreShard(&taxiSubscribers{}, newStoreStats(), clock)
+	store.ids = ids
+	return store
+}
+
+// newTaxiStoreShard creates a TaxiStore without an ID generator that notifies subscribers
+// and counts into stats, both of which may be shared with other stores (see ShardedTaxiStore).
+// Taxis must be added with insert.
+func newTaxiStoreShard(subscribers *taxiSubscribers, stats *storeStats, clock Clock) *TaxiStore {
+	return &TaxiStore{
+		clock:             clock,
+		taxis:             make(map[int]*Taxi),
+		subscribers:       subscribers,
+		idleInMaintenance: make(map[int]bool),
+		stats:             stats,
+		index:             newSpatialIndex(),
+	}
+}
+
+// Add inserts a new taxi at the given location and returns its assigned ID.
+// The taxi is marked as available by default, starts with a full energy level
+// and the top rating.
+func (ts *TaxiStore) Add(location Location, attributes TaxiAttributes) int {
+	id := ts.ids.NextID()
+	ts.insert(id, location, attributes)
+	return id
+}
+
+// insert adds a new taxi under an ID handed out by the caller (see Add).
+func (ts *TaxiStore) insert(id int, location Location, attributes TaxiAttributes) {
+	defer ts.lock("Add")()
+
+	ts.taxis[id] = &Taxi{
+		ID:          id,
+		Location:    location,
+		IsAvailable: true,
+		Attributes:  attributes,
+		IdleSince:   ts.clock.Now(),
+		Rating:      MaxTaxiRating,
+		EnergyLevel: 100,
+	}
+	ts.index.insert(id, location)
+	ts.publish(TaxiAdded, ts.taxis[id])
+}
+
+// Get returns a copy of the taxi with the given ID.
+// The copy is safe to read while other goroutines update the store.
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) Get(id int) (Taxi, bool) {
+	defer ts.rlock("Get")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return Taxi{}, false
+	}
+	return *taxi, true
+}
+
+// GetAllAvailable returns copies of all taxis that can accept rides, ordered by ID so
+// callers picking among them (e.g. repositioning) choose the same way every run.
+// The copies are safe to read while other goroutines update the store.
+func (ts *TaxiStore) GetAllAvailable() []Taxi {
+	defer ts.rlock("GetAllAvailable")()
+
+	available := make([]Taxi, 0)
+	for _, taxi := range ts.taxis {
+		if taxi.IsAvailable {
+			available = append(available, *taxi)
+		}
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
+	return available
+}
+
+// GetAll returns copies of every taxi, ordered by ID.
+func (ts *TaxiStore) GetAll() []Taxi {
+	defer ts.rlock("GetAll")()
+
+	taxis := ts.copyAll()
+	sort.Slice(taxis, func(i, j int) bool { return taxis[i].ID < taxis[j].ID })
+	return taxis
+}
+
+// Snapshot returns every taxi as it is now, read under one lock.
+func (ts *TaxiStore) Snapshot() TaxiSnapshot {
+	defer ts.rlock("Snapshot")()
+	return newTaxiSnapshot(ts.copyAll())
+}
+
+// copyAll returns copies of every taxi, in no particular order.
+// Must be called with ts.mu held.
+func (ts *TaxiStore) copyAll() []Taxi {
+	taxis := make([]Taxi, 0, len(ts.taxis))
+	for _, taxi := range ts.taxis {
+		taxis = append(taxis, *taxi)
+	}
+	return taxis
+}
+
+// Near returns copies of the available taxis within radius (Manhattan distance) of
+// center, in no particular order. Only the spatial index cells around center are read.
+func (ts *TaxiStore) Near(center Location, radius int) []Taxi {
+	defer ts.rlock("Near")()
+
+	distance := NewLocationService().CalculateDistance
+	nearby := make([]Taxi, 0)
+	for _, id := range ts.index.candidates(center, radius) {
+		taxi := ts.taxis[id]
+		if taxi.IsAvailable && distance(taxi.Location, center) <= radius {
+			nearby = append(nearby, *taxi)
+		}
+	}
+	return nearby
+}
+
+// ReserveBest finds the available taxi with the highest score for a pickup at start
+// and marks it unavailable.
+// Finding and reserving happen under one lock, so two callers can never reserve the same taxi.
+// Only available taxis accepted by eligible are considered; taxis with no route to start,
+// or farther than maxDistance (0 = no limit), are skipped.
+// score rates a taxi given its distance to start; higher is better.
+// eligible and score are called under the store lock and must not call back into the store.
+// Returns a copy of the reserved taxi and its distance to start, or false if none are available.
+func (ts *TaxiStore) ReserveBest(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (Taxi, int, bool) {
+	defer ts.lock("ReserveBest")()
+
+	best, bestDistance, _ := ts.findBest(start, router, maxDistance, eligible, score)
+	if best == nil {
+		return Taxi{}, 0, false
+	}
+	best.setAvailable(false, ts.clock.Now())
+	ts.publish(AvailabilityChanged, best)
+	return *best, bestDistance, true
+}
+
+// bestCandidate is the search of ReserveBest without the reservation: it returns a copy
+// of the best taxi with its distance and score, or false if no taxi qualifies.
+func (ts *TaxiStore) bestCandidate(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (Taxi, int, float64, bool) {
+	defer ts.rlock("BestCandidate")()
+
+	best, bestDistance, bestScore := ts.findBest(start, router, maxDistance, eligible, score)
+	if best == nil {
+		return Taxi{}, 0, 0, false
+	}
+	return *best, bestDistance, bestScore, true
+}
+
+// betterCandidate reports whether a taxi with score and id beats the best one so far.
+// Equal scores go to the lowest ID, so the winner does not depend on map iteration
+// order and a seeded run assigns the same taxis every time.
+func betterCandidate(score float64, id int, bestScore float64, bestID int) bool {
+	if score != bestScore {
+		return score > bestScore
+	}
+	return id < bestID
+}
+
+// findBest returns the available, eligible taxi with the highest score within
+// maxDistance of start, its distance and its score, or nil if there is none.
+// Ties go to the lowest ID (see betterCandidate). Must be called with ts.mu held.
+func (ts *TaxiStore) findBest(start Location, router Router, maxDistance int, eligible func(Taxi) bool, score func(Taxi, int) float64) (*Taxi, int, float64) {
+	var best *Taxi
+	bestDistance := 0
+	bestScore := 0.0
+
+	for _, taxi := range ts.taxis {
+		if !taxi.IsAvailable || !eligible(*taxi) {
+			continue
+		}
+		distance := router.CalculateDistance(taxi.Location, start)
+		if distance == Unreachable || (maxDistance > 0 && distance > maxDistance) {
+			continue
+		}
+		if taxiScore := score(*taxi, distance); best == nil || betterCandidate(taxiScore, taxi.ID, bestScore, best.ID) {
+			best = taxi
+			bestDistance = distance
+			bestScore = taxiScore
+		}
+	}
+	return best, bestDistance, bestScore
+}
+
+// Reserve marks a specific taxi unavailable, but only if it is currently available
+// and accepted by eligible. Checking and reserving happen under one lock.
+// Returns a copy of the reserved taxi, or false if it is busy, unsuitable or not found.
+func (ts *TaxiStore) Reserve(id int, eligible func(Taxi) bool) (Taxi, bool) {
+	defer ts.lock("Reserve")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists || !taxi.IsAvailable || !eligible(*taxi) {
+		return Taxi{}, false
+	}
+	taxi.setAvailable(false, ts.clock.Now())
+	ts.publish(AvailabilityChanged, taxi)
+	return *taxi, true
+}
+
+// SetAvailability updates a taxi's availability status.
+// A taxi in maintenance stays unavailable; the change is remembered and applied
+// when maintenance ends.
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) SetAvailability(id int, available bool) bool {
+	defer ts.lock("SetAvailability")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	if taxi.InMaintenance {
+		ts.idleInMaintenance[id] = available
+		available = false
+	}
+	taxi.setAvailable(available, ts.clock.Now())
+	ts.publish(AvailabilityChanged, taxi)
+	return true
+}
+
+// SetMaintenance puts a taxi into maintenance (on) or takes it out again.
+// Entering maintenance makes the taxi unavailable. Leaving it makes the taxi available
+// only if it has no ride: a taxi that was reserved or driving when maintenance started
+// stays unavailable until its ride ends (SetAvailability).
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) SetMaintenance(id int, on bool) bool {
+	defer ts.lock("SetMaintenance")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+
+	switch {
+	case on && !taxi.InMaintenance:
+		taxi.InMaintenance = true
+		ts.idleInMaintenance[id] = taxi.IsAvailable
+		if taxi.IsAvailable {
+			taxi.setAvailable(false, ts.clock.Now())
+			ts.publish(AvailabilityChanged, taxi)
+		}
+	case !on && taxi.InMaintenance:
+		taxi.InMaintenance = false
+		if ts.idleInMaintenance[id] {
+			taxi.setAvailable(true, ts.clock.Now())
+			ts.publish(AvailabilityChanged, taxi)
+		}
+		delete(ts.idleInMaintenance, id)
+	}
+	return true
+}
+
+// SetRating updates a taxi's driver rating (1 to 5 stars).
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) SetRating(id int, rating float64) bool {
+	defer ts.lock("SetRating")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	taxi.Rating = rating
+	return true
+}
+
+// SetEnergyLevel updates how much fuel or charge a taxi has left (0 to 100 percent).
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) SetEnergyLevel(id int, level int) bool {
+	defer ts.lock("SetEnergyLevel")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	taxi.EnergyLevel = level
+	return true
+}
+
+// SetPool moves a taxi into a dispatch pool ("" = general fleet).
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) SetPool(id int, pool string) bool {
+	defer ts.lock("SetPool")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	taxi.Pool = pool
+	ts.publish(PoolChanged, taxi)
+	return true
+}
+
+// UpdateLocation updates a taxi's location.
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) UpdateLocation(id int, location Location) bool {
+	defer ts.lock("UpdateLocation")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	ts.index.move(id, taxi.Location, location)
+	taxi.Location = location
+	ts.publish(LocationChanged, taxi)
+	return true
+}
+
+// MoveIfAvailable updates a taxi's location, but only while it is available,
+// so a taxi that was just reserved for a ride is never moved away from it.
+// Returns false if the taxi is busy or was not found.
+func (ts *TaxiStore) MoveIfAvailable(id int, location Location) bool {
+	defer ts.lock("MoveIfAvailable")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists || !taxi.IsAvailable {
+		return false
+	}
+	ts.index.move(id, taxi.Location, location)
+	taxi.Location = location
+	ts.publish(LocationChanged, taxi)
+	return true
+}
+
+// Remove deletes a taxi from the store.
+// Returns false if the taxi was not found.
+func (ts *TaxiStore) Remove(id int) bool {
+	defer ts.lock("Remove")()
+
+	taxi, exists := ts.taxis[id]
+	if !exists {
+		return false
+	}
+	delete(ts.taxis, id)
+	delete(ts.idleInMaintenance, id)
+	ts.index.remove(id, taxi.Location)
+	ts.publish(TaxiRemoved, taxi)
+	return true
+}
+
+// Count returns the total number of taxis in the store.
+func (ts *TaxiStore) Count() int {
+	defer ts.rlock("Count")()
+	return len(ts.taxis)
+}
+
+// Subscribe returns a channel that receives an event for every taxi change, in order.
+// The channel is buffered; if a subscriber falls behind, its events queue up beyond
+// the buffer, so a slow subscriber can never block store updates nor miss a change.
+func (ts *TaxiStore) Subscribe() <-chan TaxiChangedEvent {
+	return ts.subscribers.subscribe()
+}
+
+// Unsubscribe stops sending changes to a channel returned by Subscribe and closes it.
+// Changes the subscriber has not read yet are dropped. Does nothing for any other channel.
+func (ts *TaxiStore) Unsubscribe(changes <-chan TaxiChangedEvent) {
+	ts.subscribers.unsubscribe(changes)
+}
+
+// publish sends a change event to every subscriber without blocking.
+// Must be called with ts.mu held for writing, so each taxi's events go out in order.
+func (ts *TaxiStore) publish(kind TaxiChangeKind, taxi *Taxi) {
+	ts.subscribers.publish(TaxiChangedEvent{
+		Kind:        kind,
+		TaxiID:      taxi.ID,
+		Location:    taxi.Location,
+		IsAvailable: taxi.IsAvailable,
+	})
+}
+
+// taxiSubscribers are the subscribers notified of taxi changes, with a lock of their own
+// so stores sharing them (see ShardedTaxiStore) publish to one list.
+type taxiSubscribers struct {
+	mu     sync.RWMutex                     // Protects relays
+	relays []*relay.Relay[TaxiChangedEvent] // One per Subscribe call
+}
+
+// subscribe adds a subscriber and returns its buffered channel.
+func (sub *taxiSubscribers) subscribe() <-chan TaxiChangedEvent {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	subscriber := relay.New[TaxiChangedEvent](SubscriberBufferSize)
+	sub.relays = append(sub.relays, subscriber)
+	return subscriber.C
+}
+
+// unsubscribe removes the subscriber whose channel is changes, dropping its backlog.
+func (sub *taxiSubscribers) unsubscribe(changes <-chan TaxiChangedEvent) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	for i, subscriber := range sub.relays {
+		if subscriber.C == changes {
+			sub.relays = slices.Delete(sub.relays, i, i+1)
+			subscriber.Unsubscribe()
+			return
+		}
+	}
+}
+
+// publish hands event to every subscriber without blocking; a subscriber that is
+// behind gets it once it has read the events before it.
+func (sub *taxiSubscribers) publish(event TaxiChangedEvent) {
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+
+	for _, subscriber := range sub.relays {
+		subscriber.Push(event)
+	}
+}
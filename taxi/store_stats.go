@@ -0,0 +1,165 @@
+// store_stats.go - TaxiStore contention instrumentation
+// Counts calls per TaxiStore method and how long each waited for and held the store
+// lock, so contention can be measured before and after changes to the store
+
+package taxi
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// storeHoldBuckets are the upper bounds of the lock hold histogram; longer holds
+// land in a final "+Inf" bucket. Wall time, not simulated time: contention is real.
+var storeHoldBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+}
+
+// storeMethods are the TaxiStore methods that take the store lock.
+var storeMethods = []string{
+	"Add", "Get", "GetAllAvailable", "GetAll", "Snapshot", "Near", "ReserveBest", "BestCandidate", "Reserve",
+	"SetAvailability", "SetMaintenance", "SetRating", "SetEnergyLevel", "SetPool",
+	"UpdateLocation", "MoveIfAvailable", "Remove", "Count",
+}
+
+// HistogramBucket counts the observations up to LE ("+Inf" for the rest).
+// Buckets are not cumulative: each observation is counted once.
+type HistogramBucket struct {
+	LE    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// StoreMethodStats is the contention of one TaxiStore method.
+type StoreMethodStats struct {
+	Calls    int64             `json:"calls"`
+	WaitAvg  Duration          `json:"wait_avg"`  // Average time spent waiting for the lock
+	WaitMax  Duration          `json:"wait_max"`  // Longest wait for the lock
+	HoldAvg  Duration          `json:"hold_avg"`  // Average time the lock was held
+	HoldMax  Duration          `json:"hold_max"`  // Longest the lock was held
+	HoldHist []HistogramBucket `json:"hold_hist"` // Lock hold times by storeHoldBuckets
+}
+
+// StoreStats is the contention of every TaxiStore method that has been called.
+type StoreStats struct {
+	Calls   int64                       `json:"calls"`    // Calls of all methods
+	WaitSum Duration                    `json:"wait_sum"` // Time all callers spent waiting for the lock
+	Methods map[string]StoreMethodStats `json:"methods"`
+}
+
+// methodCounters accumulates one method's stats. Atomic, so recording a call never
+// adds a lock of its own next to the one being measured.
+type methodCounters struct {
+	calls   atomic.Int64
+	waitSum atomic.Int64 // Nanoseconds
+	waitMax atomic.Int64 // Nanoseconds
+	holdSum atomic.Int64 // Nanoseconds
+	holdMax atomic.Int64 // Nanoseconds
+	hold    []atomic.Int64
+}
+
+// storeStats holds the counters of every store method.
+// The map is filled once by newStoreStats and only read afterwards, so it needs no lock.
+type storeStats struct {
+	methods map[string]*methodCounters
+}
+
+// newStoreStats creates zeroed counters for every method in storeMethods.
+func newStoreStats() *storeStats {
+	stats := &storeStats{methods: make(map[string]*methodCounters, len(storeMethods))}
+	for _, method := range storeMethods {
+		stats.methods[method] = &methodCounters{hold: make([]atomic.Int64, len(storeHoldBuckets)+1)}
+	}
+	return stats
+}
+
+// record counts one call of method that waited wait for the lock and held it for hold.
+func (ss *storeStats) record(method string, wait, hold time.Duration) {
+	counters := ss.methods[method]
+	counters.calls.Add(1)
+	counters.waitSum.Add(int64(wait))
+	counters.holdSum.Add(int64(hold))
+	atomicMax(&counters.waitMax, int64(wait))
+	atomicMax(&counters.holdMax, int64(hold))
+
+	bucket := sort.Search(len(storeHoldBuckets), func(i int) bool { return hold <= storeHoldBuckets[i] })
+	counters.hold[bucket].Add(1)
+}
+
+// snapshot returns the stats of every method called at least once.
+// Counters keep changing while it reads them, so totals can be off by the calls in flight.
+func (ss *storeStats) snapshot() StoreStats {
+	stats := StoreStats{Methods: make(map[string]StoreMethodStats)}
+	for method, counters := range ss.methods {
+		calls := counters.calls.Load()
+		if calls == 0 {
+			continue
+		}
+		waitSum := time.Duration(counters.waitSum.Load())
+		methodStats := StoreMethodStats{
+			Calls:    calls,
+			WaitAvg:  Duration{waitSum / time.Duration(calls)},
+			WaitMax:  Duration{time.Duration(counters.waitMax.Load())},
+			HoldAvg:  Duration{time.Duration(counters.holdSum.Load()) / time.Duration(calls)},
+			HoldMax:  Duration{time.Duration(counters.holdMax.Load())},
+			HoldHist: make([]HistogramBucket, 0, len(counters.hold)),
+		}
+		for i := range counters.hold {
+			label := "+Inf"
+			if i < len(storeHoldBuckets) {
+				label = storeHoldBuckets[i].String()
+			}
+			methodStats.HoldHist = append(methodStats.HoldHist, HistogramBucket{LE: label, Count: counters.hold[i].Load()})
+		}
+		stats.Methods[method] = methodStats
+		stats.Calls += calls
+		stats.WaitSum.Duration += waitSum
+	}
+	return stats
+}
+
+// atomicMax raises value to candidate if candidate is larger.
+func atomicMax(value *atomic.Int64, candidate int64) {
+	for {
+		current := value.Load()
+		if candidate <= current || value.CompareAndSwap(current, candidate) {
+			return
+		}
+	}
+}
+
+// lock takes the store lock for writing on behalf of method and returns the function
+// that releases it, recording how long the call waited and held it:
+//
+//	defer ts.lock("Add")()
+func (ts *TaxiStore) lock(method string) func() {
+	requested := time.Now()
+	ts.mu.Lock()
+	acquired := time.Now()
+	return func() {
+		held := time.Since(acquired)
+		ts.mu.Unlock()
+		ts.stats.record(method, acquired.Sub(requested), held)
+	}
+}
+
+// rlock is lock for readers.
+func (ts *TaxiStore) rlock(method string) func() {
+	requested := time.Now()
+	ts.mu.RLock()
+	acquired := time.Now()
+	return func() {
+		held := time.Since(acquired)
+		ts.mu.RUnlock()
+		ts.stats.record(method, acquired.Sub(requested), held)
+	}
+}
+
+// Stats returns the calls and lock contention of every store method since the store was created.
+func (ts *TaxiStore) Stats() StoreStats {
+	return ts.stats.snapshot()
+}
@@ -0,0 +1,257 @@
+package taxi
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testStart is when the ManualClock of every test starts.
+var testStart = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+// testStores returns an empty store of every in-memory TaxiStorage kind, by name.
+func testStores() map[string]TaxiStorage {
+	return map[string]TaxiStorage{
+		"TaxiStore":        NewTaxiStore(NewSequentialIDGenerator(1), NewManualClock(testStart)),
+		"ShardedTaxiStore": NewShardedTaxiStore(4, NewSequentialIDGenerator(1), NewManualClock(testStart)),
+	}
+}
+
+// addTestTaxis adds n available taxis spread over the grid and returns their IDs.
+func addTestTaxis(store TaxiStorage, n int) []int {
+	ids := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		ids = append(ids, store.Add(Location{X: i % 100, Y: (i / 100) % 100}, 0))
+	}
+	return ids
+}
+
+func TestStoreReadsReturnCopies(t *testing.T) {
+	for name, store := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			id := addTestTaxis(store, 1)[0]
+
+			taxi, _ := store.Get(id)
+			taxi.Location = Location{X: 99, Y: 99}
+			taxi.IsAvailable = false
+			for _, taxi := range store.GetAll() {
+				taxi.Rating = 1
+			}
+			for _, taxi := range store.GetAllAvailable() {
+				taxi.Pool = "changed"
+			}
+			for _, taxi := range store.Snapshot().All() {
+				taxi.EnergyLevel = 0
+			}
+
+			stored, _ := store.Get(id)
+			if stored.Location != (Location{X: 0, Y: 0}) || !stored.IsAvailable || stored.Rating != MaxTaxiRating || stored.Pool != "" || stored.EnergyLevel != 100 {
+				t.Errorf("changing returned taxis changed the store: %+v", stored)
+			}
+		})
+	}
+}
+
+// Run with -race: readers use the copies they get while writers change the same taxis.
+func TestStoreReadsDoNotRaceWithWrites(t *testing.T) {
+	for name, store := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			ids := addTestTaxis(store, 50)
+			const rounds = 200
+
+			var wg sync.WaitGroup
+			for writer := 0; writer < 4; writer++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for round := 0; round < rounds; round++ {
+						id := ids[(writer*rounds+round)%len(ids)]
+						store.UpdateLocation(id, Location{X: round % 100, Y: writer})
+						store.SetAvailability(id, round%2 == 0)
+						store.SetRating(id, float64(1+round%5))
+						store.SetEnergyLevel(id, round%101)
+						store.SetPool(id, fmt.Sprintf("pool %d", round%3))
+					}
+				}()
+			}
+			for reader := 0; reader < 4; reader++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					seen := 0
+					for round := 0; round < rounds; round++ {
+						if taxi, ok := store.Get(ids[round%len(ids)]); ok {
+							seen += taxi.Location.X + taxi.EnergyLevel + len(taxi.Pool)
+						}
+						for _, taxi := range store.GetAllAvailable() {
+							seen += taxi.Location.Y
+						}
+						for _, taxi := range store.Snapshot().All() {
+							seen += int(taxi.Rating)
+						}
+					}
+					_ = seen
+				}()
+			}
+			wg.Wait()
+
+			if count := store.Count(); count != len(ids) {
+				t.Errorf("store holds %d taxis, want %d", count, len(ids))
+			}
+		})
+	}
+}
+
+// Run with -race: reservations, releases, moves, maintenance and new taxis all at once,
+// checking that no taxi is ever reserved by two callers.
+func TestStoreStressNeverDoubleBooks(t *testing.T) {
+	for name, store := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			const (
+				initial   = 40
+				added     = 40
+				reservers = 8
+				rounds    = 300
+			)
+			addTestTaxis(store, initial)
+			router := NewLocationService()
+			anyTaxi := func(Taxi) bool { return true }
+			byDistance := func(_ Taxi, distance int) float64 { return -float64(distance) }
+
+			// holders[id] is 1 while a reserver holds taxi id
+			holders := make([]atomic.Int32, initial+added+1)
+			hold := func(taxi Taxi) {
+				if !holders[taxi.ID].CompareAndSwap(0, 1) {
+					t.Errorf("taxi #%d reserved twice", taxi.ID)
+					return
+				}
+				for i := 0; i < 3; i++ {
+					runtime.Gosched() // Let others try for it while it is held
+				}
+				holders[taxi.ID].Store(0)
+				store.SetAvailability(taxi.ID, true)
+			}
+
+			var wg sync.WaitGroup
+			for reserver := 0; reserver < reservers; reserver++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rng := rand.New(rand.NewSource(int64(reserver)))
+					for round := 0; round < rounds; round++ {
+						if round%2 == 0 {
+							start := Location{X: rng.Intn(100), Y: rng.Intn(100)}
+							if taxi, _, ok := store.ReserveBest(start, router, 0, anyTaxi, byDistance); ok {
+								hold(taxi)
+							}
+						} else if taxi, ok := store.Reserve(1+rng.Intn(initial), anyTaxi); ok {
+							hold(taxi)
+						}
+					}
+				}()
+			}
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(100))
+				for round := 0; round < rounds; round++ {
+					id := 1 + rng.Intn(initial)
+					store.UpdateLocation(id, Location{X: rng.Intn(100), Y: rng.Intn(100)})
+					store.MoveIfAvailable(id, Location{X: rng.Intn(100), Y: rng.Intn(100)})
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(101))
+				for round := 0; round < rounds; round++ {
+					// Prefer a held taxi: ending its maintenance must not free it
+					id := 1 + rng.Intn(initial)
+					for candidate := 1; candidate <= initial; candidate++ {
+						if holders[candidate].Load() == 1 {
+							id = candidate
+							break
+						}
+					}
+					store.SetMaintenance(id, true)
+					runtime.Gosched()
+					store.SetMaintenance(id, false)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				addTestTaxis(store, added)
+			}()
+			wg.Wait()
+
+			// Every reservation was released, so every taxi is available again
+			if count := store.Count(); count != initial+added {
+				t.Errorf("store holds %d taxis, want %d", count, initial+added)
+			}
+			for _, taxi := range store.GetAll() {
+				if !taxi.IsAvailable || taxi.InMaintenance {
+					t.Errorf("taxi #%d left unavailable: %+v", taxi.ID, taxi)
+				}
+			}
+		})
+	}
+}
+
+func TestShardedReserveBestCountsTheSearchSeparately(t *testing.T) {
+	store := NewShardedTaxiStore(4, NewSequentialIDGenerator(1), NewManualClock(testStart))
+	addTestTaxis(store, 8)
+	byDistance := func(_ Taxi, distance int) float64 { return -float64(distance) }
+	if _, _, ok := store.ReserveBest(Location{}, NewLocationService(), 0, func(Taxi) bool { return true }, byDistance); !ok {
+		t.Fatal("no taxi reserved")
+	}
+
+	// One search per shard, then one Reserve: none of them is a ReserveBest of a shard
+	methods := store.Stats().Methods
+	if calls := methods["BestCandidate"].Calls; calls != 4 {
+		t.Errorf("BestCandidate called %d times, want once per shard", calls)
+	}
+	if calls := methods["Reserve"].Calls; calls != 1 {
+		t.Errorf("Reserve called %d times, want 1", calls)
+	}
+	if stats, counted := methods["ReserveBest"]; counted {
+		t.Errorf("shard searches counted as ReserveBest: %+v", stats)
+	}
+}
+
+func TestStoreUnsubscribeClosesAFallenBehindSubscriber(t *testing.T) {
+	for name, store := range testStores() {
+		t.Run(name, func(t *testing.T) {
+			dropped, kept := store.Subscribe(), store.Subscribe()
+			ids := addTestTaxis(store, 2*SubscriberBufferSize) // Well past the buffer, so some wait in the backlog
+			store.Unsubscribe(dropped)
+			addTestTaxis(store, 1)
+
+			read := 0
+			timeout := time.After(5 * time.Second)
+			for open := true; open; {
+				select {
+				case _, open = <-dropped:
+					if open {
+						read++
+					}
+				case <-timeout:
+					t.Fatal("channel not closed after Unsubscribe")
+				}
+			}
+			// The buffer, plus at most the change the relay was handing over
+			if read > SubscriberBufferSize+1 {
+				t.Errorf("read %d changes after Unsubscribe, want at most %d", read, SubscriberBufferSize+1)
+			}
+			for i := 0; i <= len(ids); i++ {
+				select {
+				case <-kept:
+				case <-timeout:
+					t.Fatalf("other subscriber got %d of %d changes", i, len(ids)+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// taxi_snapshot.go - Point-in-time copies of the fleet
+// Lets readers that look at the fleet several ways (counts, maintenance, the taxi list)
+// see every taxi as it was at one instant instead of what separate store calls return
+
+package taxi
+
+import (
+	"slices"
+	"sort"
+)
+
+// TaxiSnapshot is every taxi of a TaxiStorage as it was at one instant (see
+// TaxiStorage.Snapshot): no taxi is missing or counted twice, and no change made
+// while it was taken is half seen. It never changes, and its reads return copies,
+// so it can be shared and read without locks.
+type TaxiSnapshot struct {
+	taxis []Taxi // Ordered by ID, never modified
+}
+
+// newTaxiSnapshot makes a snapshot of taxis, which the caller must not keep.
+func newTaxiSnapshot(taxis []Taxi) TaxiSnapshot {
+	sort.Slice(taxis, func(i, j int) bool { return taxis[i].ID < taxis[j].ID })
+	return TaxiSnapshot{taxis: taxis}
+}
+
+// All returns copies of every taxi, ordered by ID.
+func (ts TaxiSnapshot) All() []Taxi {
+	return slices.Clone(ts.taxis)
+}
+
+// Available returns copies of the taxis that could accept rides, ordered by ID.
+func (ts TaxiSnapshot) Available() []Taxi {
+	available := make([]Taxi, 0)
+	for _, taxi := range ts.taxis {
+		if taxi.IsAvailable {
+			available = append(available, taxi)
+		}
+	}
+	return available
+}
+
+// Get returns a copy of the taxi with the given ID.
+// Returns false if the taxi was not in the store.
+func (ts TaxiSnapshot) Get(id int) (Taxi, bool) {
+	i, found := slices.BinarySearchFunc(ts.taxis, id, func(taxi Taxi, id int) int { return taxi.ID - id })
+	if !found {
+		return Taxi{}, false
+	}
+	return ts.taxis[i], true
+}
+
+// Count returns the number of taxis.
+func (ts TaxiSnapshot) Count() int {
+	return len(ts.taxis)
+}
+
+// CountAvailable returns the number of taxis that could accept rides.
+func (ts TaxiSnapshot) CountAvailable() int {
+	count := 0
+	for _, taxi := range ts.taxis {
+		if taxi.IsAvailable {
+			count++
+		}
+	}
+	return count
+}
+
+// CountInMaintenance returns the number of taxis out of dispatch for maintenance.
+func (ts TaxiSnapshot) CountInMaintenance() int {
+	count := 0
+	for _, taxi := range ts.taxis {
+		if taxi.InMaintenance {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,95 @@
+// types.go - Core data structures for the TaxiScheduler system
+// Taxis, locations and the fleet change events every other package builds on
+
+// Package taxi holds the fleet: taxis and their locations, the stores that keep them
+// (in memory, sharded or in Redis), routing on the grid, and the Clock every other
+// package reads time from.
+package taxi
+
+import "time"
+
+// Location represents a simple 2D coordinate in the system.
+// Used for taxi positions and ride start/end points.
+type Location struct {
+	X int // X coordinate
+	Y int // Y coordinate
+}
+
+// TaxiAttributes is a set of taxi features, stored as bit flags.
+// Combine them with |, e.g. WheelchairAccessible | ChildSeat.
+type TaxiAttributes uint
+
+const (
+	WheelchairAccessible TaxiAttributes = 1 << iota // Has a ramp or lift for wheelchairs
+	ChildSeat                                       // Carries a child seat
+	Electric                                        // Electric vehicle
+	Luxury                                          // Luxury class vehicle
+)
+
+// Has reports whether every attribute in required is present.
+func (a TaxiAttributes) Has(required TaxiAttributes) bool {
+	return a&required == required
+}
+
+// Taxi represents a taxi vehicle in the system.
+type Taxi struct {
+	ID            int            // Unique identifier for the taxi
+	Location      Location       // Current (X,Y) position of the taxi
+	IsAvailable   bool           // Whether the taxi can accept new rides
+	Attributes    TaxiAttributes // Features the taxi offers
+	InMaintenance bool           // Out of dispatch until maintenance is cleared (never available meanwhile)
+	IdleSince     time.Time      // When the taxi last became available (meaningless while unavailable)
+	IdleTime      time.Duration  // Time spent available before IdleSince, over all earlier idle stretches
+	Rating        float64        // Driver rating, 1 to 5 stars
+	EnergyLevel   int            // Fuel or charge left, 0 to 100 percent
+	Pool          string         // Dispatch pool the taxi is reserved for, e.g. "airport" ("" = general fleet)
+}
+
+// setAvailable changes whether the taxi can accept rides, starting its idle clock when
+// it becomes available and adding the idle stretch to IdleTime when it stops being so.
+func (taxi *Taxi) setAvailable(available bool, now time.Time) {
+	switch {
+	case available && !taxi.IsAvailable:
+		taxi.IdleSince = now
+	case !available && taxi.IsAvailable:
+		taxi.IdleTime += now.Sub(taxi.IdleSince)
+	}
+	taxi.IsAvailable = available
+}
+
+// TotalIdle returns all the time the taxi has spent available, up to now.
+func (taxi Taxi) TotalIdle(now time.Time) time.Duration {
+	if taxi.IsAvailable {
+		return taxi.IdleTime + now.Sub(taxi.IdleSince)
+	}
+	return taxi.IdleTime
+}
+
+// Driver is a person who drives one of the taxis, shown to clients on their rides.
+type Driver struct {
+	ID      int    // Unique identifier for the driver
+	Name    string // Full name shown to clients
+	License string // Driving license number
+	Phone   string // Contact number for clients of the driver's rides
+	TaxiID  int    // Taxi the driver is currently assigned to (0 for none)
+}
+
+// TaxiChangeKind describes what changed about a taxi.
+type TaxiChangeKind int
+
+const (
+	TaxiAdded           TaxiChangeKind = iota // A new taxi was added to the store
+	AvailabilityChanged                       // A taxi became available or unavailable
+	LocationChanged                           // A taxi moved to a new location
+	TaxiRemoved                               // A taxi was removed from the store
+	PoolChanged                               // A taxi moved to another dispatch pool
+)
+
+// TaxiChangedEvent is sent to TaxiStore subscribers whenever a taxi changes.
+// It carries a copy of the taxi's state right after the change.
+type TaxiChangedEvent struct {
+	Kind        TaxiChangeKind `json:"kind"`      // What changed
+	TaxiID      int            `json:"taxi_id"`   // ID of the changed taxi
+	Location    Location       `json:"location"`  // Taxi location after the change
+	IsAvailable bool           `json:"available"` // Taxi availability after the change
+}
@@ -0,0 +1,63 @@
+// zone.go - Named areas of the grid
+// Zones are rectangles used to apply area-specific rules (traffic, limits, ...)
+
+package taxi
+
+import "math/rand"
+
+// Zone is a named rectangular area of the grid.
+// Both corners are included in the zone.
+type Zone struct {
+	Name string   // Human readable name, e.g. "Downtown"
+	Min  Location // Bottom-left corner
+	Max  Location // Top-right corner
+}
+
+// Contains reports whether a location lies inside the zone.
+func (z Zone) Contains(location Location) bool {
+	return location.X >= z.Min.X && location.X <= z.Max.X &&
+		location.Y >= z.Min.Y && location.Y <= z.Max.Y
+}
+
+// Overlaps reports whether the two zones share at least one location.
+func (z Zone) Overlaps(other Zone) bool {
+	return z.Min.X <= other.Max.X && other.Min.X <= z.Max.X &&
+		z.Min.Y <= other.Max.Y && other.Min.Y <= z.Max.Y
+}
+
+// Adjacent reports whether the two zones do not overlap but share a border,
+// i.e. some location in one zone is directly next to a location in the other.
+func (z Zone) Adjacent(other Zone) bool {
+	overlapX := z.Min.X <= other.Max.X && other.Min.X <= z.Max.X
+	overlapY := z.Min.Y <= other.Max.Y && other.Min.Y <= z.Max.Y
+	touchX := z.Max.X+1 == other.Min.X || other.Max.X+1 == z.Min.X
+	touchY := z.Max.Y+1 == other.Min.Y || other.Max.Y+1 == z.Min.Y
+	return (overlapX && touchY) || (overlapY && touchX)
+}
+
+// DistanceTo returns the Manhattan distance from location to the nearest location
+// in the zone (0 if the zone contains it).
+func (z Zone) DistanceTo(location Location) int {
+	dx := max(z.Min.X-location.X, 0, location.X-z.Max.X)
+	dy := max(z.Min.Y-location.Y, 0, location.Y-z.Max.Y)
+	return dx + dy
+}
+
+// GridArea is the whole service area, used when a wave does not name one.
+var GridArea = Zone{Name: "grid", Min: Location{X: 0, Y: 0}, Max: Location{X: 99, Y: 99}}
+
+// RandomLocation picks a location inside area (the whole grid when area is nil).
+func RandomLocation(rng *rand.Rand, area *Zone) Location {
+	if area == nil {
+		area = &GridArea
+	}
+	return Location{
+		X: area.Min.X + rng.Intn(area.Max.X-area.Min.X+1),
+		Y: area.Min.Y + rng.Intn(area.Max.Y-area.Min.Y+1),
+	}
+}
+
+// GridCell returns the coordinates of the cellSize x cellSize cell containing a location.
+func GridCell(location Location, cellSize int) Location {
+	return Location{X: location.X / cellSize, Y: location.Y / cellSize}
+}